@@ -0,0 +1,72 @@
+// Command cec-agent runs next to a TV with cec-client and reports the TV's
+// power state and active HDMI input to the hub.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"screentime-agent/internal/cec"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var listen, client string
+	var interval time.Duration
+
+	flag.StringVar(&listen, "listen", ":8070", "listen address")
+	flag.StringVar(&client, "cec-client", "cec-client", "path to cec-client")
+	flag.DurationVar(&interval, "interval", 30*time.Second, "how often to ask the TV for its state")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	tracker := cec.NewTracker()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(tracker.Status()); err != nil {
+			log.Printf("error encoding response: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	server := &http.Server{Addr: listen, Handler: mux}
+
+	errCh := make(chan error, 2)
+	go func() {
+		errCh <- tracker.Run(ctx, client, interval)
+	}()
+	go func() {
+		log.Printf("Starting CEC agent on %s", listen)
+		errCh <- server.ListenAndServe()
+	}()
+
+	err := <-errCh
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	_ = server.Shutdown(shutdownCtx)
+	if errors.Is(err, context.Canceled) || errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -21,36 +21,37 @@ func main() {
 }
 
 func run() error {
+	if len(os.Args) > 1 && os.Args[1] == "enroll" {
+		return runEnroll(os.Args[2:])
+	}
+
 	var configPath string
 	var listen string
 	var printConfig bool
+	var writeConfig bool
 
 	defaultConfigPath, _ := linux.DefaultConfigPath()
 
 	flag.StringVar(&configPath, "config", defaultConfigPath, "path to config file")
 	flag.StringVar(&listen, "listen", "", "override listen address (e.g., :8060)")
 	flag.BoolVar(&printConfig, "print-config", false, "print default config and exit")
+	flag.BoolVar(&writeConfig, "write-config", false, "write default config to the -config path and exit")
 	flag.Parse()
 
 	if printConfig {
-		cfg := linux.DefaultConfig()
-		fmt.Println(`{
-  "listen": ":8060",
-  "hostname": "my-linux-machine",
-  "categories": {
-    "homework": {
-      "domains": ["docs.google.com", "classroom.google.com", "khanacademy.org"],
-      "domain_suffixes": [".edu"]
-    },
-    "entertainment": {
-      "domains": ["youtube.com", "netflix.com", "twitch.tv", "reddit.com"]
-    }
-  },
-  "idle_window_patterns": ["screensaver", "lock screen", "xscreensaver"],
-  "ignored_windows": [],
-  "firefox_profile": ""
-}`)
-		_ = cfg // silence unused warning
+		data, err := linux.MarshalConfig(linux.DefaultConfig())
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if writeConfig {
+		if err := linux.WriteConfig(configPath, linux.DefaultConfig()); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "wrote default config to %s\n", configPath)
 		return nil
 	}
 
@@ -99,3 +100,29 @@ func run() error {
 }
 
 
+
+// runEnroll implements `linux-agent enroll`, which fetches the certificate
+// the agent serves with "mtls" set.
+func runEnroll(args []string) error {
+	var opts linux.EnrollOptions
+	fs := flag.NewFlagSet("enroll", flag.ExitOnError)
+	fs.StringVar(&opts.HubURL, "hub", "", "hub URL, e.g. https://hub.lan:8080")
+	fs.StringVar(&opts.DeviceID, "device", "", "this agent's device ID on the hub")
+	fs.StringVar(&opts.Token, "token", "", "token from the hub's POST /v1/devices/{id}/enrollment")
+	fs.StringVar(&opts.CAFingerprint, "ca-fingerprint", "", "expected fingerprint of the hub's CA, from the same response")
+	fs.StringVar(&opts.HubFingerprint, "hub-fingerprint", "", "fingerprint of the hub's self-signed HTTPS certificate")
+	fs.Parse(args)
+	if opts.HubURL == "" || opts.DeviceID == "" || opts.Token == "" || opts.CAFingerprint == "" {
+		return fmt.Errorf("usage: linux-agent enroll -hub URL -device ID -token TOKEN -ca-fingerprint FP [-hub-fingerprint FP]")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	caFP, err := linux.Enroll(ctx, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "enrolled as %s with the hub CA %s\n", opts.DeviceID, caFP)
+	fmt.Fprintf(os.Stderr, "set \"mtls\": true in this agent's config and on the hub's device, with an https base_url, and restart both\n")
+	return nil
+}
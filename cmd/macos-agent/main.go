@@ -0,0 +1,10 @@
+package main
+
+import (
+	"screentime-agent/internal/agent"
+	"screentime-agent/internal/macos"
+)
+
+func main() {
+	agent.Main(macos.Platform)
+}
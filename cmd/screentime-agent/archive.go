@@ -0,0 +1,181 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/storage"
+)
+
+// runExport implements `screentime-agent export`, writing a portable archive
+// of the hub database. Output ending in .gz is gzip-compressed.
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.json", "Path to JSON config file")
+	sinceStr := fs.String("since", "", "Only export sessions since this date (YYYY-MM-DD, RFC3339, or a duration like 720h)")
+	out := fs.String("o", "-", "Output file, - for stdout")
+	fs.Parse(args)
+
+	var since *time.Time
+	if *sinceStr != "" {
+		t, err := parseSince(*sinceStr, time.Now())
+		if err != nil {
+			return err
+		}
+		since = &t
+	}
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	db, err := storage.NewDB(ctx, cfg.DatabasePath)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	archive, err := storage.NewSessionStore(db).Export(ctx, since, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if raw, err := os.ReadFile(*cfgPath); err == nil {
+		archive.Config = raw
+	}
+
+	w, closeOut, err := createOutput(*out)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(archive); err != nil {
+		closeOut()
+		return fmt.Errorf("write archive: %w", err)
+	}
+	if err := closeOut(); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d devices, %d sessions, %d secondary sessions, %d apps\n",
+		len(archive.Devices), len(archive.Sessions), len(archive.SecondarySessions), len(archive.Apps))
+	return nil
+}
+
+// runImport implements `screentime-agent import`, merging an archive into
+// the database named by the config.
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.json", "Path to JSON config file")
+	configOut := fs.String("config-out", "", "Also write the archived config file here")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: screentime-agent import [-config path] [-config-out path] <archive>")
+	}
+
+	in, err := openInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var archive storage.Archive
+	if err := json.NewDecoder(in).Decode(&archive); err != nil {
+		return fmt.Errorf("read archive: %w", err)
+	}
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	db, err := storage.NewDB(ctx, cfg.DatabasePath)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	stats, err := storage.NewSessionStore(db).Import(ctx, &archive)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	if *configOut != "" && len(archive.Config) > 0 {
+		if err := os.WriteFile(*configOut, archive.Config, 0o600); err != nil {
+			return fmt.Errorf("write config: %w", err)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d devices, %d sessions, %d secondary sessions, %d apps, %d app categories\n",
+		stats.Devices, stats.Sessions, stats.SecondarySessions, stats.Apps, stats.AppCategories)
+	return nil
+}
+
+func parseSince(v string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -since %q", v)
+}
+
+// createOutput opens path for writing (stdout for "-"), gzipping when the
+// name ends in .gz. The returned func flushes and closes it.
+func createOutput(path string) (io.Writer, func() error, error) {
+	if path == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create %s: %w", path, err)
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, f.Close, nil
+	}
+	gz := gzip.NewWriter(f)
+	return gz, func() error {
+		if err := gz.Close(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}, nil
+}
+
+// openInput opens path for reading (stdin for "-"), transparently
+// decompressing gzip.
+func openInput(path string) (io.ReadCloser, error) {
+	var f *os.File
+	if path == "-" {
+		f = os.Stdin
+	} else {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			return nil, fmt.Errorf("open %s: %w", path, err)
+		}
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, f}, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/demo"
+	"screentime-agent/internal/storage"
+)
+
+// demoHistoryDays is how much made-up history -demo starts with.
+const demoHistoryDays = 28
+
+// openDemo starts the demo devices and returns the hub config polling them
+// and an empty in-memory database.
+func openDemo(ctx context.Context) (*config.Config, *storage.DB, error) {
+	baseURLs, err := demo.Serve(ctx, time.Local)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := demo.Config(baseURLs)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := storage.NewMemoryDB(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open database: %w", err)
+	}
+	return cfg, db, nil
+}
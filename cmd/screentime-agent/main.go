@@ -6,33 +6,74 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"screentime-agent/internal/alert"
+	"screentime-agent/internal/category"
 	"screentime-agent/internal/config"
+	"screentime-agent/internal/demo"
+	"screentime-agent/internal/enforce"
+	"screentime-agent/internal/hooks"
 	"screentime-agent/internal/http"
 	"screentime-agent/internal/poller"
+	"screentime-agent/internal/report"
+	"screentime-agent/internal/sheets"
 	"screentime-agent/internal/storage"
+	"screentime-agent/internal/tlsutil"
 )
 
 func main() {
-	cfgPath := flag.String("config", "config.json", "Path to JSON config file")
-	flag.Parse()
-
 	ctx, cancel := signal.NotifyContext(context.Background(),
 		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	// Load config
-	cfg, err := config.LoadConfig(*cfgPath)
-	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+	// Subcommands; with none, run the hub.
+	if len(os.Args) > 1 {
+		var run func(context.Context, []string) error
+		switch os.Args[1] {
+		case "export":
+			run = runExport
+		case "import":
+			run = runImport
+		case "replay":
+			run = runReplay
+		case "import-settings":
+			run = runImportSettings
+		}
+		if run != nil {
+			if err := run(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("%s: %v", os.Args[1], err)
+			}
+			return
+		}
 	}
 
-	// Initialize SQLite
-	db, err := storage.NewDB(ctx, cfg.DatabasePath)
-	if err != nil {
-		log.Fatalf("failed to open database: %v", err)
+	cfgPath := flag.String("config", "config.json", "Path to JSON config file")
+	demoMode := flag.Bool("demo", false, "Run against made-up devices with generated history in an in-memory database, ignoring -config")
+	flag.Parse()
+
+	var cfg *config.Config
+	var db *storage.DB
+	var err error
+	if *demoMode {
+		cfg, db, err = openDemo(ctx)
+		if err != nil {
+			log.Fatalf("failed to start demo: %v", err)
+		}
+	} else {
+		// Load config
+		cfg, err = config.LoadConfig(*cfgPath)
+		if err != nil {
+			log.Fatalf("failed to load config: %v", err)
+		}
+
+		// Initialize SQLite
+		db, err = storage.NewDB(ctx, cfg.DatabasePath)
+		if err != nil {
+			log.Fatalf("failed to open database: %v", err)
+		}
 	}
 	defer func() {
 		if err := db.Close(); err != nil {
@@ -40,7 +81,32 @@ func main() {
 		}
 	}()
 
+	loc, err := cfg.ResolveLocation()
+	if err != nil {
+		log.Fatalf("failed to resolve timezone: %v", err)
+	}
+
 	store := storage.NewSessionStore(db)
+	if err := store.LoadAppCategories(ctx); err != nil {
+		log.Fatalf("failed to load app categories: %v", err)
+	}
+	store.SplitOn(cfg.SplitOnFor)
+	store.CategorizeWith(category.New(cfg.Categories, store.AppCategory).Categorize)
+	store.RollUpDaily(func(t time.Time) time.Time {
+		return cfg.DayStart(t.In(loc))
+	})
+	if cfg.SplitSessionsAtDayStart {
+		store.SplitAtDayBoundaries(func(t time.Time) time.Time {
+			return cfg.NextDayStart(t.In(loc))
+		})
+	}
+
+	// Run hooks on session and limit events, including the
+	// sessions left open by the last run and closed just below
+	hookRunner := hooks.NewRunner(cfg)
+	if len(cfg.Hooks) > 0 {
+		store.OnSessionEvent(hookRunner.SessionEvent)
+	}
 
 	// Close any stale current_sessions on startup
 	now := time.Now().UTC()
@@ -48,12 +114,72 @@ func main() {
 		log.Fatalf("failed to close stale current sessions: %v", err)
 	}
 
+	// Register configured devices so they survive removal from config
+	for _, d := range cfg.Devices {
+		metadata := map[string]string{"base_url": d.BaseURL}
+		if len(d.Tags) > 0 {
+			metadata["tags"] = strings.Join(d.Tags, ",")
+		}
+		if err := store.RegisterDevice(ctx, d.ID, "config", metadata, now); err != nil {
+			log.Fatalf("failed to register device %s: %v", d.ID, err)
+		}
+	}
+
+	if *demoMode {
+		log.Printf("demo: generating %d days of history", demoHistoryDays)
+		if err := demo.GenerateHistory(ctx, store, loc, now, demoHistoryDays); err != nil {
+			log.Fatalf("failed to generate demo history: %v", err)
+		}
+	}
+
+	// The device CA enrolls agents for mutual TLS; the first enrollment
+	// creates it
+	if cfg.DeviceCA, err = tlsutil.OpenCA(cfg.DataDir()); err != nil {
+		log.Fatalf("failed to load device CA: %v", err)
+	}
+
 	// Start pollers
-	runner := poller.NewRunner(cfg.Devices, store)
+	notifier := alert.NewNotifier(cfg.Alerts.WebhookURL)
+	runner := poller.NewRunner(cfg, store, notifier)
 	runner.Start(ctx)
 
+	// Warn about and enforce limits as budgets run out
+	watcher := enforce.NewWatcher(cfg, store, notifier, loc)
+	if len(cfg.Hooks) > 0 {
+		watcher.AddEnforcer(hookRunner)
+	}
+	go watcher.Run(ctx)
+
+	// Launch scheduled channels on devices that have any
+	go enforce.NewLauncher(cfg, store, loc).Run(ctx)
+
+	// Start the weekly report schedule, if configured
+	reports := report.NewBuilder(cfg, store)
+	reports.DisplayWith(runner.CategoryDisplays)
+	if cfg.Reports.Schedule != "" {
+		scheduler, err := report.NewScheduler(cfg, reports, loc)
+		if err != nil {
+			log.Fatalf("failed to create report scheduler: %v", err)
+		}
+		go scheduler.Run(ctx)
+	}
+
+	// Alert daily about heavily used apps no category matches
+	if cfg.Alerts.UncategorizedMinutes > 0 {
+		go report.NewUncategorizedAlerter(cfg, reports, notifier, loc).Run(ctx)
+	}
+
+	// Start the Google Sheets export, if configured
+	if cfg.Sheets != nil {
+		exporter, err := sheets.NewExporter(cfg, store, loc)
+		if err != nil {
+			log.Fatalf("failed to create sheets exporter: %v", err)
+		}
+		go exporter.Run(ctx)
+	}
+
 	// Start HTTP server (blocks until ctx is canceled or server fails)
-	server, err := http.NewServer(cfg, store)
+	server, err := http.NewServer(cfg, store, runner)
 	if err != nil {
 		log.Fatalf("failed to create HTTP server: %v", err)
 	}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/storage"
+)
+
+// runReplay implements `screentime-agent replay`, feeding recorded raw polls
+// through sessionization into a scratch database and printing the sessions
+// that result. The hub database is only read.
+func runReplay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.json", "Path to JSON config file")
+	sinceStr := fs.String("since", "24h", "Replay polls since this date (YYYY-MM-DD, RFC3339, or a duration like 720h)")
+	deviceStr := fs.String("device", "", "Only replay this device")
+	out := fs.String("o", "", "Keep the scratch database at this path instead of a temporary file")
+	fs.Parse(args)
+
+	now := time.Now()
+	since, err := parseSince(*sinceStr, now)
+	if err != nil {
+		return err
+	}
+	var deviceID *string
+	if *deviceStr != "" {
+		deviceID = deviceStr
+	}
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	db, err := storage.NewDB(ctx, cfg.DatabasePath)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	polls, err := storage.NewSessionStore(db).GetRawPolls(ctx, deviceID, since, now, 0)
+	if err != nil {
+		return err
+	}
+	if len(polls) == 0 {
+		return fmt.Errorf("no raw polls since %s (is raw_poll_days set?)", since.Format(time.RFC3339))
+	}
+
+	path := *out
+	if path == "" {
+		dir, err := os.MkdirTemp("", "screentime-replay")
+		if err != nil {
+			return fmt.Errorf("create scratch dir: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		path = filepath.Join(dir, "replay.sqlite")
+	} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove %s: %w", path, err)
+	}
+
+	scratch, err := storage.NewDB(ctx, path)
+	if err != nil {
+		return fmt.Errorf("open scratch database: %w", err)
+	}
+	defer scratch.Close()
+	replay := storage.NewSessionStore(scratch)
+	replay.SplitOn(cfg.SplitOnFor)
+	if cfg.SplitSessionsAtDayStart {
+		loc, err := cfg.ResolveLocation()
+		if err != nil {
+			return err
+		}
+		replay.SplitAtDayBoundaries(func(t time.Time) time.Time {
+			return cfg.NextDayStart(t.In(loc))
+		})
+	}
+
+	stale := 0
+	for _, p := range polls {
+		err := replay.ApplyPoll(ctx, p)
+		if errors.Is(err, storage.ErrStalePoll) {
+			stale++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("apply poll for %s at %s: %w", p.DeviceID, p.Timestamp.Format(time.RFC3339), err)
+		}
+	}
+
+	sessions, err := replay.GetSessions(ctx, storage.SessionFilter{})
+	if err != nil {
+		return err
+	}
+	current, err := replay.GetCurrentSessions(ctx)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(struct {
+		Sessions []storage.Session
+		Current  []storage.CurrentSession
+	}{sessions, current}); err != nil {
+		return fmt.Errorf("write sessions: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "replayed %d polls into %d sessions, %d still open\n",
+		len(polls), len(sessions), len(current))
+	if stale > 0 {
+		fmt.Fprintf(os.Stderr, "left out %d polls older than the sessions they would change\n", stale)
+	}
+	return nil
+}
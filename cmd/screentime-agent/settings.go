@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/config"
+)
+
+// runImportSettings implements `screentime-agent import-settings`, merging
+// another hub's devices, users, categories and limits into the config file.
+// The source is a hub URL (its /v1/settings is fetched), a config file, an
+// export archive or the output of /v1/settings. User tokens are never
+// imported.
+func runImportSettings(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import-settings", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.json", "Path to JSON config file to update")
+	dryRun := fs.Bool("dry-run", false, "Print the merged config instead of writing it")
+	apiKey := fs.String("api-key", os.Getenv("SCREENTIME_API_KEY"), "Admin API key for a hub that requires one")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: screentime-agent import-settings [-config path] [-dry-run] [-api-key key] <hub URL|file|->")
+	}
+
+	data, err := readSettingsSource(ctx, fs.Arg(0), *apiKey)
+	if err != nil {
+		return err
+	}
+
+	// An export archive carries the config file under Config.
+	var archive struct {
+		Config json.RawMessage
+	}
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return fmt.Errorf("parse settings: %w", err)
+	}
+	if len(archive.Config) > 0 {
+		data = archive.Config
+	}
+
+	var settings config.Settings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("parse settings: %w", err)
+	}
+	for i := range settings.Users {
+		settings.Users[i].Token = ""
+	}
+
+	current, err := os.ReadFile(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	merged, stats, err := config.MergeSettings(current, settings)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		_, err := os.Stdout.Write(merged)
+		return err
+	}
+	if err := writeConfig(*cfgPath, merged); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d devices, %d users, %d categories, %d goals (%d added, %d replaced)\n",
+		len(settings.Devices), len(settings.Users), len(settings.Categories), len(settings.Goals),
+		stats.Added, stats.Replaced)
+	if len(stats.NeedToken) > 0 {
+		fmt.Fprintf(os.Stderr, "skipped push-only devices without a token: %s; add them with a token of their own\n",
+			strings.Join(stats.NeedToken, ", "))
+	}
+	return nil
+}
+
+// readSettingsSource reads a file (stdin for "-") or fetches a URL. A bare
+// hub URL gets /v1/settings appended, and apiKey, if set, is sent with it.
+func readSettingsSource(ctx context.Context, src, apiKey string) ([]byte, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		in, err := openInput(src)
+		if err != nil {
+			return nil, err
+		}
+		defer in.Close()
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", src, err)
+		}
+		return data, nil
+	}
+
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/settings"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch settings: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch settings: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read settings: %w", err)
+	}
+	return data, nil
+}
+
+// writeConfig replaces the config file at path with data, but only once
+// data loads as a valid config; a bad merge leaves the old file in place.
+func writeConfig(path string, data []byte) error {
+	mode := os.FileMode(0o600)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write temp config: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("chmod temp config: %w", err)
+	}
+
+	if _, err := config.LoadConfig(tmp.Name()); err != nil {
+		return fmt.Errorf("merged config is invalid: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace config: %w", err)
+	}
+	return nil
+}
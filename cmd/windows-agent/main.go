@@ -0,0 +1,10 @@
+package main
+
+import (
+	"screentime-agent/internal/agent"
+	"screentime-agent/internal/windows"
+)
+
+func main() {
+	agent.Main(windows.Platform)
+}
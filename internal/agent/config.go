@@ -0,0 +1,80 @@
+// Package agent runs the desktop agents for Windows and macOS: it serves
+// the platform's detector over the same Roku-compatible API as the Linux
+// agent. The OS packages only provide the detector.
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"screentime-agent/internal/linux"
+)
+
+// Config holds the settings shared by the desktop agents. Platform configs
+// embed it and add their own.
+type Config struct {
+	Listen string `json:"listen"`
+	// Categories are matched against browser window titles, the URL not
+	// being readable from outside the browser.
+	Categories         map[string]linux.Category `json:"categories"`
+	IdleWindowPatterns []string                  `json:"idle_window_patterns"`
+
+	// IdleSeconds is how long without keyboard or mouse input counts as
+	// idle. 0 disables the check.
+	IdleSeconds int `json:"idle_seconds"`
+
+	// TrackTitles sends the window title with the active app.
+	TrackTitles bool `json:"track_titles"`
+}
+
+// Settings is a platform config embedding Config.
+type Settings interface {
+	Agent() *Config
+}
+
+// Agent returns the shared settings of a platform config embedding c.
+func (c *Config) Agent() *Config {
+	return c
+}
+
+// DefaultConfig returns the shared settings with sensible defaults
+func DefaultConfig() Config {
+	return Config{
+		Listen:             ":8060",
+		Categories:         linux.DefaultConfig().Categories,
+		IdleWindowPatterns: []string{},
+		IdleSeconds:        300,
+	}
+}
+
+// DefaultConfigPath returns the default path for the config file, under
+// %AppData% on Windows and ~/Library/Application Support on macOS.
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "screentime-agent", "config.json"), nil
+}
+
+// LoadConfig loads the config from the given path over cfg, which should
+// hold the defaults. A missing file leaves them as they are.
+func LoadConfig(path string, cfg Settings) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+	if cfg.Agent().IdleSeconds < 0 {
+		return fmt.Errorf("idle_seconds must be >= 0")
+	}
+	return nil
+}
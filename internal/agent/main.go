@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Platform is what an OS package plugs into the agent.
+type Platform struct {
+	// Name is the platform as shown in logs, e.g. "Windows".
+	Name string
+	// DefaultConfig returns the platform config with its defaults.
+	DefaultConfig func() Settings
+	// NewDetector creates the detector for a loaded platform config.
+	NewDetector func(Settings) Detector
+}
+
+// Main runs the agent for p from the command line and exits on error.
+func Main(p Platform) {
+	if err := run(p); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(p Platform) error {
+	var configPath string
+	var listen string
+	var printConfig bool
+
+	defaultConfigPath, _ := DefaultConfigPath()
+
+	flag.StringVar(&configPath, "config", defaultConfigPath, "path to config file")
+	flag.StringVar(&listen, "listen", "", "override listen address (e.g., :8060)")
+	flag.BoolVar(&printConfig, "print-config", false, "print default config and exit")
+	flag.Parse()
+
+	if printConfig {
+		data, err := json.MarshalIndent(p.DefaultConfig(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal config: %w", err)
+		}
+		fmt.Println(string(data))
+		fmt.Fprintf(os.Stderr, "\nSave to: %s\n", defaultConfigPath)
+		return nil
+	}
+
+	cfg := p.DefaultConfig()
+	if err := LoadConfig(configPath, cfg); err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if listen != "" {
+		cfg.Agent().Listen = listen
+	}
+
+	server := NewServer(p.Name, cfg.Agent(), p.NewDetector(cfg))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Start()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case sig := <-sigCh:
+		log.Printf("Received signal %v, shutting down...", sig)
+		shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 5*time.Second)
+		defer shutdownCancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
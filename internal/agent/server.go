@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+
+	"screentime-agent/internal/linux"
+)
+
+// Detector reports the current activity on a platform.
+type Detector interface {
+	Detect() linux.Activity
+}
+
+// Server provides the Roku-compatible HTTP API
+type Server struct {
+	name     string
+	detector Detector
+	config   *Config
+	server   *http.Server
+}
+
+// activeAppResponse matches the Roku XML format, with the Linux agent's
+// title extension.
+type activeAppResponse struct {
+	XMLName xml.Name `xml:"active-app"`
+	App     xmlApp   `xml:"app"`
+}
+
+type xmlApp struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr,omitempty"`
+	Name  string `xml:",chardata"`
+}
+
+// NewServer creates a new HTTP server. name is the platform, for logs.
+func NewServer(name string, cfg *Config, detector Detector) *Server {
+	return &Server{name: name, detector: detector, config: cfg}
+}
+
+// Start starts the HTTP server
+func (s *Server) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/query/active-app", s.handleActiveApp)
+	mux.HandleFunc("/health", s.handleHealth)
+
+	s.server = &http.Server{
+		Addr:    s.config.Listen,
+		Handler: mux,
+	}
+
+	log.Printf("Starting %s agent on %s", s.name, s.config.Listen)
+	return s.server.ListenAndServe()
+}
+
+// Shutdown gracefully shuts down the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.server != nil {
+		return s.server.Shutdown(ctx)
+	}
+	return nil
+}
+
+func (s *Server) handleActiveApp(w http.ResponseWriter, r *http.Request) {
+	activity := s.detector.Detect()
+
+	resp := activeAppResponse{}
+	resp.App.ID = activity.ID
+	resp.App.Name = activity.Name
+	if s.config.TrackTitles {
+		resp.App.Title = activity.Title
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("error encoding response: %v", err)
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,91 @@
+// Package alert delivers operational and parental notifications from the hub.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Alert is a single notification.
+type Alert struct {
+	Kind     string    `json:"kind"`
+	DeviceID string    `json:"device_id,omitempty"`
+	AppID    string    `json:"app_id,omitempty"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// Notifier delivers alerts.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// NewNotifier returns a notifier that always logs and, when webhookURL is
+// set, also POSTs each alert as JSON to it.
+func NewNotifier(webhookURL string) Notifier {
+	n := multiNotifier{logNotifier{}}
+	if webhookURL != "" {
+		n = append(n, &webhookNotifier{
+			url:    webhookURL,
+			client: &http.Client{Timeout: 5 * time.Second},
+		})
+	}
+	return n
+}
+
+type logNotifier struct{}
+
+func (logNotifier) Notify(ctx context.Context, a Alert) error {
+	if a.DeviceID != "" {
+		log.Printf("alert [%s] device %s: %s", a.Kind, a.DeviceID, a.Message)
+	} else {
+		log.Printf("alert [%s]: %s", a.Kind, a.Message)
+	}
+	return nil
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("marshal alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type multiNotifier []Notifier
+
+func (m multiNotifier) Notify(ctx context.Context, a Alert) error {
+	var firstErr error
+	for _, n := range m {
+		if err := n.Notify(ctx, a); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,100 @@
+// Package category maps hub apps onto the parent-defined categories used by
+// goals and limits.
+package category
+
+import (
+	"sort"
+	"strings"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/storage"
+)
+
+// Uncategorized is returned when no rule matches.
+const Uncategorized = "uncategorized"
+
+// Calls is the built-in category for video calls reported by the linux
+// agent, kept apart so school lessons don't eat entertainment budgets.
+const Calls = "calls"
+
+// Categorizer assigns categories to apps using the config rules.
+type Categorizer struct {
+	names    []string // sorted so the first matching category is deterministic
+	rules    map[string]config.CategoryConfig
+	assigned func(appID string) (string, bool)
+}
+
+// New creates a categorizer from the config categories. assigned, if not
+// nil, returns categories assigned to apps through the API, which win over
+// the rules.
+func New(rules map[string]config.CategoryConfig, assigned func(appID string) (string, bool)) *Categorizer {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &Categorizer{names: names, rules: rules, assigned: assigned}
+}
+
+// Categorize returns the category for an app. An assigned category wins,
+// then explicit rules; otherwise linux agent IDs of the form
+// "browser:<category>" or "electron:<category>" keep the category the
+// agent assigned, and "call:" IDs are Calls.
+func (c *Categorizer) Categorize(appID, appName string) string {
+	if c.assigned != nil {
+		if cat, ok := c.assigned(appID); ok {
+			return cat
+		}
+	}
+
+	nameLower := strings.ToLower(appName)
+	for _, name := range c.names {
+		rule := c.rules[name]
+		for _, id := range rule.AppIDs {
+			if id == appID {
+				return name
+			}
+		}
+		for _, prefix := range rule.AppIDPrefixes {
+			if strings.HasPrefix(appID, prefix) {
+				return name
+			}
+		}
+		for _, n := range rule.AppNames {
+			if strings.ToLower(n) == nameLower {
+				return name
+			}
+		}
+	}
+
+	for _, prefix := range []string{"browser:", "electron:"} {
+		if rest, ok := strings.CutPrefix(appID, prefix); ok {
+			if cat, _, _ := strings.Cut(rest, ":"); cat != "" && cat != Uncategorized {
+				return cat
+			}
+		}
+	}
+	if strings.HasPrefix(appID, "call:") {
+		return Calls
+	}
+	return Uncategorized
+}
+
+// Totals sums usage entries per category.
+func (c *Categorizer) Totals(entries []storage.UsageEntry) map[string]int64 {
+	out := make(map[string]int64)
+	for _, e := range entries {
+		out[c.Categorize(e.AppID, e.AppName)] += e.TotalSeconds
+	}
+	return out
+}
+
+// LimitTotals sums usage entries per category, leaving out time in approved
+// exception sessions, which doesn't count toward limits.
+func (c *Categorizer) LimitTotals(entries []storage.UsageEntry) map[string]int64 {
+	out := make(map[string]int64)
+	for _, e := range entries {
+		out[c.Categorize(e.AppID, e.AppName)] += e.TotalSeconds - e.ExceptionSeconds
+	}
+	return out
+}
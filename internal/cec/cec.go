@@ -0,0 +1,211 @@
+// Package cec follows a TV's power state and active input by watching
+// HDMI-CEC traffic through cec-client (libCEC), as on a Raspberry Pi or
+// with a Pulse-Eight USB adapter.
+package cec
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Power states, as reported by the TV.
+const (
+	PowerOn      = "on"
+	PowerStandby = "standby"
+	PowerUnknown = "unknown"
+)
+
+// CEC opcodes used to track the TV.
+const (
+	opImageViewOn       = 0x04
+	opTextViewOn        = 0x0d
+	opStandby           = 0x36
+	opRoutingChange     = 0x80
+	opRoutingInfo       = 0x81
+	opActiveSource      = 0x82
+	opSetStreamPath     = 0x86
+	opReportPowerStatus = 0x90
+)
+
+// tvAddress is the TV's logical address.
+const tvAddress = 0
+
+// Status is what the TV is doing, as far as CEC traffic has shown.
+type Status struct {
+	Power string `json:"power"`
+	// PhysicalAddress is the active source's address, e.g. "2.0.0.0";
+	// empty until one is seen.
+	PhysicalAddress string `json:"physical_address,omitempty"`
+	// Input is the TV input the active source hangs off, e.g. "hdmi2".
+	Input   string    `json:"input,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// frame is one CEC message: initiator and destination logical addresses,
+// then the opcode and its operands.
+type frame struct {
+	initiator, destination byte
+	opcode                 byte
+	operands               []byte
+	hasOpcode              bool
+}
+
+// parseTraffic parses a cec-client traffic line such as
+// "TRAFFIC: [   413207]	>> 4f:82:10:00", for frames either received
+// (>>) or sent (<<).
+func parseTraffic(line string) (frame, bool) {
+	i := strings.Index(line, ">> ")
+	if i < 0 {
+		i = strings.Index(line, "<< ")
+	}
+	if i < 0 || !strings.Contains(line[:i], "TRAFFIC") {
+		return frame{}, false
+	}
+	raw := strings.ReplaceAll(strings.TrimSpace(line[i+3:]), ":", "")
+	b, err := hex.DecodeString(raw)
+	if err != nil || len(b) == 0 {
+		return frame{}, false
+	}
+	f := frame{initiator: b[0] >> 4, destination: b[0] & 0x0f}
+	if len(b) > 1 {
+		f.opcode, f.operands, f.hasOpcode = b[1], b[2:], true
+	}
+	return f, true
+}
+
+// physicalAddress formats two operand bytes as "a.b.c.d".
+func physicalAddress(b []byte) string {
+	return fmt.Sprintf("%x.%x.%x.%x", b[0]>>4, b[0]&0x0f, b[1]>>4, b[1]&0x0f)
+}
+
+// InputName returns the TV input a physical address hangs off: its first
+// digit is the TV's HDMI port. "0.0.0.0" is the TV itself.
+func InputName(addr string) string {
+	port, _, _ := strings.Cut(addr, ".")
+	if port == "" || port == "0" {
+		return "tv"
+	}
+	return "hdmi" + port
+}
+
+// Tracker folds CEC frames into the TV's status.
+type Tracker struct {
+	mu     sync.Mutex
+	status Status
+}
+
+// NewTracker returns a tracker that knows nothing yet.
+func NewTracker() *Tracker {
+	return &Tracker{status: Status{Power: PowerUnknown}}
+}
+
+// Status returns the current status.
+func (t *Tracker) Status() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+func (t *Tracker) apply(f frame, now time.Time) {
+	if !f.hasOpcode {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch f.opcode {
+	case opReportPowerStatus:
+		if f.initiator != tvAddress || len(f.operands) < 1 {
+			return
+		}
+		switch f.operands[0] {
+		case 0x00, 0x02: // on, or turning on
+			t.status.Power = PowerOn
+		case 0x01, 0x03: // standby, or turning off
+			t.status.Power = PowerStandby
+		}
+	case opStandby:
+		if f.initiator != tvAddress && f.destination != 0x0f {
+			return
+		}
+		t.status.Power = PowerStandby
+	case opImageViewOn, opTextViewOn:
+		t.status.Power = PowerOn
+	case opActiveSource, opRoutingInfo, opSetStreamPath:
+		if len(f.operands) < 2 {
+			return
+		}
+		t.setSource(physicalAddress(f.operands))
+	case opRoutingChange:
+		if len(f.operands) < 4 {
+			return
+		}
+		t.setSource(physicalAddress(f.operands[2:]))
+	default:
+		return
+	}
+	t.status.Updated = now
+}
+
+func (t *Tracker) setSource(addr string) {
+	t.status.PhysicalAddress = addr
+	t.status.Input = InputName(addr)
+}
+
+// Run starts cec-client at path and follows its traffic until ctx is done
+// or the client exits. Every interval it asks the TV for its power status
+// and the active source, so a state missed while starting is picked up.
+func (t *Tracker) Run(ctx context.Context, path string, interval time.Duration) error {
+	// -t r registers as a recording device (logical address 1); -d 8
+	// logs traffic.
+	cmd := exec.CommandContext(ctx, path, "-t", "r", "-d", "8")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("cec-client stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("cec-client stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start cec-client: %w", err)
+	}
+
+	go query(ctx, stdin, interval)
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if f, ok := parseTraffic(scanner.Text()); ok {
+			t.apply(f, time.Now())
+		}
+	}
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("cec-client: %w", err)
+	}
+	return ctx.Err()
+}
+
+// query sends the status requests to cec-client every interval.
+func query(ctx context.Context, w io.Writer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// "pow 0" requests the TV's power status; 1f:85 is Request
+		// Active Source from our address to everyone.
+		if _, err := io.WriteString(w, "pow 0\ntx 1f:85\n"); err != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
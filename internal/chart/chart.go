@@ -0,0 +1,133 @@
+// Package chart renders small stacked bar charts as PNG or SVG without any
+// JavaScript or third-party dependencies.
+package chart
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"sort"
+	"strconv"
+)
+
+// Segment is one stacked portion of a bar.
+type Segment struct {
+	Label string
+	Value float64
+}
+
+// Bar is a single labeled, stacked bar.
+type Bar struct {
+	Label    string
+	Segments []Segment
+}
+
+// Chart is a horizontal stacked bar chart.
+type Chart struct {
+	Title string
+	Bars  []Bar
+	// Colors optionally fixes the color for a segment label, e.g. the
+	// configured category colors. Other labels get a palette color.
+	Colors map[string]color.RGBA
+	// Format renders a value for the legend and bar totals.
+	Format func(float64) string
+}
+
+const (
+	width      = 640
+	margin     = 16
+	titleH     = 28
+	barH       = 28
+	barGap     = 10
+	labelW     = 120
+	legendRowH = 20
+)
+
+var palette = []color.RGBA{
+	{0x4e, 0x79, 0xa7, 0xff},
+	{0xf2, 0x8e, 0x2b, 0xff},
+	{0xe1, 0x57, 0x59, 0xff},
+	{0x76, 0xb7, 0xb2, 0xff},
+	{0x59, 0xa1, 0x4f, 0xff},
+	{0xed, 0xc9, 0x48, 0xff},
+	{0xb0, 0x7a, 0xa1, 0xff},
+	{0xff, 0x9d, 0xa7, 0xff},
+	{0x9c, 0x75, 0x5f, 0xff},
+	{0xba, 0xb0, 0xac, 0xff},
+}
+
+func (c *Chart) colorFor(label string) color.RGBA {
+	if col, ok := c.Colors[label]; ok {
+		return col
+	}
+	return PaletteColor(label)
+}
+
+// PaletteColor returns the built-in palette color for a label without a
+// fixed one. The same label always gets the same color.
+func PaletteColor(label string) color.RGBA {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(label))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+// ParseColor parses a "#rgb" or "#rrggbb" color.
+func ParseColor(s string) (color.RGBA, bool) {
+	if len(s) == 4 && s[0] == '#' {
+		s = "#" + string([]byte{s[1], s[1], s[2], s[2], s[3], s[3]})
+	}
+	if len(s) != 7 || s[0] != '#' {
+		return color.RGBA{}, false
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, true
+}
+
+func (c *Chart) format(v float64) string {
+	if c.Format != nil {
+		return c.Format(v)
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+// legend returns the distinct segment labels with their totals, largest first.
+func (c *Chart) legend() []Segment {
+	totals := make(map[string]float64)
+	for _, b := range c.Bars {
+		for _, s := range b.Segments {
+			totals[s.Label] += s.Value
+		}
+	}
+	out := make([]Segment, 0, len(totals))
+	for label, v := range totals {
+		out = append(out, Segment{Label: label, Value: v})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Value != out[j].Value {
+			return out[i].Value > out[j].Value
+		}
+		return out[i].Label < out[j].Label
+	})
+	return out
+}
+
+func (c *Chart) maxTotal() float64 {
+	var max float64
+	for _, b := range c.Bars {
+		var t float64
+		for _, s := range b.Segments {
+			t += s.Value
+		}
+		if t > max {
+			max = t
+		}
+	}
+	return max
+}
+
+func (c *Chart) height() int {
+	return margin*2 + titleH + len(c.Bars)*(barH+barGap) + margin + len(c.legend())*legendRowH
+}
@@ -0,0 +1,57 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"image/color"
+	"io"
+)
+
+// RenderSVG writes the chart as an SVG document.
+func (c *Chart) RenderSVG(w io.Writer) error {
+	h := c.height()
+	max := c.maxTotal()
+	plotW := float64(width - margin*2 - labelW)
+
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="13">`+"\n", width, h, width, h)
+	printf(`<rect width="100%%" height="100%%" fill="#fff"/>` + "\n")
+	printf(`<text x="%d" y="%d" font-size="16" font-weight="bold">%s</text>`+"\n", margin, margin+16, html.EscapeString(c.Title))
+
+	y := margin + titleH
+	for _, b := range c.Bars {
+		printf(`<text x="%d" y="%d">%s</text>`+"\n", margin, y+barH/2+5, html.EscapeString(b.Label))
+		x := float64(margin + labelW)
+		for _, s := range b.Segments {
+			sw := 0.0
+			if max > 0 {
+				sw = s.Value / max * plotW
+			}
+			printf(`<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"><title>%s: %s</title></rect>`+"\n",
+				x, y, sw, barH, Hex(c.colorFor(s.Label)), html.EscapeString(s.Label), html.EscapeString(c.format(s.Value)))
+			x += sw
+		}
+		y += barH + barGap
+	}
+
+	y += margin
+	for _, s := range c.legend() {
+		printf(`<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`+"\n", margin, y, Hex(c.colorFor(s.Label)))
+		printf(`<text x="%d" y="%d">%s (%s)</text>`+"\n", margin+18, y+11, html.EscapeString(s.Label), html.EscapeString(c.format(s.Value)))
+		y += legendRowH
+	}
+
+	printf("</svg>\n")
+	return err
+}
+
+// Hex formats a color as "#rrggbb".
+func Hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -2,17 +2,407 @@ package config
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
+
+	"screentime-agent/internal/cron"
+	"screentime-agent/internal/locale"
+	"screentime-agent/internal/tlsutil"
 )
 
+// MinPollInterval is the shortest poll interval a device may use.
+const MinPollInterval = 100 * time.Millisecond
+
 type DeviceConfig struct {
 	ID                  string   `json:"id"`
 	BaseURL             string   `json:"base_url"`
-	PollIntervalSeconds int      `json:"poll_interval_seconds"`
+	PollIntervalSeconds float64  `json:"poll_interval_seconds"`
 	Tags                []string `json:"tags,omitempty"`
+
+	// DisplayName is what dashboards call the device, e.g. "Living Room
+	// TV". PATCH /devices/{id} can override it.
+	DisplayName string `json:"display_name,omitempty"`
+
+	// TLSFingerprint pins the SHA-256 fingerprint of the certificate an
+	// https base_url serves, for agents with a self-signed certificate.
+	// The agent logs it at startup.
+	TLSFingerprint string `json:"tls_fingerprint,omitempty"`
+
+	// MTLS polls an agent enrolled with the hub's CA over mutual TLS: the
+	// hub shows its own certificate and only accepts the one it issued to
+	// this device. It needs an https base_url.
+	MTLS bool `json:"mtls,omitempty"`
+
+	// HeartbeatPath, when set, is polled independently of activity (e.g.
+	// "/health" on the linux agent) to tell "idle" apart from "agent down".
+	HeartbeatPath string `json:"heartbeat_path,omitempty"`
+
+	// NotifyPath, when set, receives warnings as they happen (e.g.
+	// "/notify" on the linux agent, which shows a desktop notification).
+	NotifyPath string `json:"notify_path,omitempty"`
+
+	// BlockPath, when set, is told when a budget covering the device is
+	// enforced (e.g. "/block" on the linux agent, which runs its block
+	// command).
+	BlockPath string `json:"block_path,omitempty"`
+
+	// MaxMinutes is a daily budget for all usage on the device, whoever's
+	// it is. Goals add per-category budgets for the device the same way.
+	MaxMinutes int          `json:"max_minutes,omitempty"`
+	Goals      []GoalConfig `json:"goals,omitempty"`
+
+	// ActiveHours are the hours the device is normally in use; being
+	// unreachable during them raises an alert. Empty means always.
+	ActiveHours []TimeRange `json:"active_hours,omitempty"`
+
+	// SplitOn overrides the global split_on for this device.
+	SplitOn string `json:"split_on,omitempty"`
+
+	// CEC, when set, checks a cec-agent watching the TV the device is
+	// plugged into, so time with the TV off or on another input isn't
+	// counted.
+	CEC *CECConfig `json:"cec,omitempty"`
+
+	// Token is the device's API token. Its agent signs what it pushes to
+	// POST /ingest with it, for devices the hub can't reach (behind NAT,
+	// or a laptop roaming networks). A device with a token and no
+	// base_url is push-only: it isn't polled, and poll_interval_seconds
+	// is how often it pushes. The hub also signs what it POSTs to
+	// notify_path and block_path with it; set the same hub_token on the
+	// linux agent, which refuses them unsigned unless it runs with mtls.
+	Token string `json:"token,omitempty"`
+
+	// Launches start an allowed channel on the device at scheduled times,
+	// such as a meditation app at bedtime.
+	Launches []LaunchConfig `json:"launches,omitempty"`
+}
+
+// LaunchConfig launches a Roku channel over ECP on a cron schedule,
+// optionally deep-linking into specific content.
+type LaunchConfig struct {
+	// Schedule is a cron expression ("minute hour dom month dow") in the
+	// config timezone, e.g. "30 20 * * *" for 20:30 daily.
+	Schedule string `json:"schedule"`
+	AppID    string `json:"app_id"` // Roku channel ID
+	// ContentID and MediaType deep-link into the channel, as the channel
+	// documents them (e.g. media_type "movie", "episode" or "series").
+	ContentID string `json:"content_id,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+}
+
+// PushOnly reports whether the device only pushes its state to the hub.
+func (d DeviceConfig) PushOnly() bool {
+	return d.BaseURL == "" && d.Token != ""
+}
+
+// CECConfig points a device at the cec-agent for its TV.
+type CECConfig struct {
+	BaseURL string `json:"base_url"`
+	// Input is the TV input the device is plugged into, e.g. "hdmi2".
+	Input string `json:"input"`
+}
+
+// PollInterval returns how often the device is polled. Fractional seconds
+// are allowed for agents that answer quickly.
+func (d DeviceConfig) PollInterval() time.Duration {
+	return time.Duration(d.PollIntervalSeconds * float64(time.Second))
+}
+
+// IsActiveHour reports whether t falls inside the device's active hours.
+func (d DeviceConfig) IsActiveHour(t time.Time) bool {
+	if len(d.ActiveHours) == 0 {
+		return true
+	}
+	for _, r := range d.ActiveHours {
+		if r.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// CategoryConfig lists the apps belonging to a category. Linux agent apps
+// reported as "browser:<category>" fall into <category> without a rule.
+type CategoryConfig struct {
+	AppIDs        []string `json:"app_ids,omitempty"`
+	AppIDPrefixes []string `json:"app_id_prefixes,omitempty"`
+	AppNames      []string `json:"app_names,omitempty"`
+	// Productivity is the RescueTime-style score for the category, from -2
+	// (very distracting) to 2 (very productive), used by the RescueTime CSV
+	// export. Defaults to 0 (neutral).
+	Productivity int `json:"productivity,omitempty"`
+	CategoryDisplay
+}
+
+// CategoryDisplay is how dashboards and reports draw a category, so every
+// client renders it the same way.
+type CategoryDisplay struct {
+	Color string `json:"color,omitempty"` // "#rrggbb"
+	Icon  string `json:"icon,omitempty"`  // an emoji or icon name
+}
+
+// ValidColor reports whether c is empty or a "#rgb" or "#rrggbb" color.
+func ValidColor(c string) bool {
+	if c == "" {
+		return true
+	}
+	if len(c) != 4 && len(c) != 7 || c[0] != '#' {
+		return false
+	}
+	for _, r := range c[1:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// GoalConfig is a daily target for a category: a ceiling (max_minutes), a
+// floor (min_minutes), or both. MaxWeeklyMinutes adds a ceiling on the
+// week's total, spent however the user likes within any daily ceiling.
+type GoalConfig struct {
+	Category         string `json:"category"`
+	MaxMinutes       int    `json:"max_minutes,omitempty"`
+	MinMinutes       int    `json:"min_minutes,omitempty"`
+	MaxWeeklyMinutes int    `json:"max_weekly_minutes,omitempty"`
+}
+
+// UserConfig describes a person whose screen time is tracked across one or
+// more devices.
+type UserConfig struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name,omitempty"`
+	Token   string   `json:"token,omitempty"` // bearer token for /me
+	Devices []string `json:"devices"`
+	// Downtime windows (e.g. "21:00-07:00") when no screen time is allowed.
+	Downtime []TimeRange `json:"downtime,omitempty"`
+	// ScreenFree are recurring weekly periods with no screen time at all,
+	// such as Sunday mornings.
+	ScreenFree []ScreenFreePeriod `json:"screen_free,omitempty"`
+	// Goals override the global goals for this user when set.
+	Goals []GoalConfig `json:"goals,omitempty"`
+	// Accounts are platform accounts reported by agents (e.g.
+	// "steam:76561198000000000") that are this user's on any device, so
+	// time on a shared PC goes to whoever is logged in.
+	Accounts []string `json:"accounts,omitempty"`
+}
+
+// ScreenFreePeriod is a weekly screen-free stretch on each of Days ("sun"
+// or "sunday", ...). Time limits it to part of those days; without it the
+// whole tracking day is screen-free.
+type ScreenFreePeriod struct {
+	Days []string   `json:"days"`
+	Time *TimeRange `json:"time,omitempty"`
+}
+
+// Next returns the period's next occurrence at or after t, in t's location,
+// for days beginning at dayStartHour. When t is inside one, that occurrence
+// is returned. ok is false when the period has no valid days.
+func (p ScreenFreePeriod) Next(t time.Time, dayStartHour int) (start, end time.Time, ok bool) {
+	days := make(map[time.Weekday]bool)
+	for _, d := range p.Days {
+		if wd, err := ParseWeekday(d); err == nil {
+			days[wd] = true
+		}
+	}
+
+	y, m, d := t.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	today := DayStartAt(t, dayStartHour)
+	for offset := -1; offset <= 7; offset++ {
+		if p.Time == nil {
+			day := today.AddDate(0, 0, offset)
+			start, end = day, day.AddDate(0, 0, 1)
+		} else {
+			day := midnight.AddDate(0, 0, offset)
+			start, end = p.Time.On(day)
+		}
+		if days[start.Weekday()] && end.After(t) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// HasDevice reports whether the device belongs to the user.
+func (u UserConfig) HasDevice(deviceID string) bool {
+	for _, d := range u.Devices {
+		if d == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+type AlertConfig struct {
+	// WebhookURL receives each alert as a JSON POST. Alerts are always logged.
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// AgentSilentMinutes is how long a device may go without a heartbeat
+	// before an alert fires. Defaults to 5.
+	AgentSilentMinutes int `json:"agent_silent_minutes,omitempty"`
+	// OfflineMinutes is how long a device may be unreachable during its
+	// active hours before an alert fires. Defaults to 15.
+	OfflineMinutes int `json:"offline_minutes,omitempty"`
+	// NewApps alerts the first time an app is seen on a device.
+	NewApps bool `json:"new_apps,omitempty"`
+	// UncategorizedMinutes alerts, once a day, about each app or domain no
+	// category matches that was used longer than this on the day before,
+	// and lists such apps in the weekly report. 0 disables it.
+	UncategorizedMinutes int `json:"uncategorized_minutes,omitempty"`
+}
+
+// EnforcementConfig controls what happens when a user runs out of time.
+type EnforcementConfig struct {
+	// GraceMinutes is how long usage may continue past a limit, with
+	// escalating warnings, before the limit is enforced. 0 enforces as
+	// soon as the limit is reached.
+	GraceMinutes int `json:"grace_minutes,omitempty"`
+	// WarnAtMinutes lists how many grace minutes remain at each warning.
+	// Defaults to [5, 2, 1]; values past the grace window are ignored.
+	WarnAtMinutes []int `json:"warn_at_minutes,omitempty"`
+	// WebhookURL receives each enforcement as a JSON POST, for blocking
+	// done outside the hub (a router rule, a smart plug).
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+// Hook events.
+const (
+	HookSessionStart = "session_start"
+	HookSessionEnd   = "session_end"
+	HookLimit        = "limit"
+)
+
+// HookConfig runs a command on session and limit events, with the event
+// as JSON on its stdin.
+type HookConfig struct {
+	// Command is the program and its arguments; it is not run through a
+	// shell.
+	Command []string `json:"command"`
+	// Events limits the hook to some of session_start, session_end and
+	// limit. Empty means all of them.
+	Events []string `json:"events,omitempty"`
+	// TimeoutSeconds bounds each run. Defaults to 10.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// Wants reports whether the hook runs for event.
+func (h HookConfig) Wants(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Grace returns the grace window as a duration.
+func (e EnforcementConfig) Grace() time.Duration {
+	return time.Duration(e.GraceMinutes) * time.Minute
+}
+
+// BlocklistConfig lists apps that are never allowed, whatever budget is
+// left. Each match raises an alert and notifies the device.
+type BlocklistConfig struct {
+	AppIDs   []string `json:"app_ids,omitempty"`   // Roku channel IDs or agent app IDs
+	AppNames []string `json:"app_names,omitempty"` // matched case-insensitively
+	// Domains match linux agent browser activity, including subdomains.
+	Domains []string `json:"domains,omitempty"`
+	// WindowClasses match linux agent window and Electron app activity.
+	WindowClasses []string `json:"window_classes,omitempty"`
+}
+
+// ReportConfig schedules the weekly per-user reports.
+type ReportConfig struct {
+	// Schedule is a cron expression ("minute hour dom month dow") in the
+	// config timezone, e.g. "0 8 * * 1" for Monday 08:00. Each run reports
+	// on the last whole tracking week. Empty disables scheduled reports.
+	Schedule string `json:"schedule,omitempty"`
+	// Formats to render: "html", "pdf". Defaults to ["html"].
+	Formats []string `json:"formats,omitempty"`
+	// OutputDir receives one file per user and format when set.
+	OutputDir string       `json:"output_dir,omitempty"`
+	Email     *EmailConfig `json:"email,omitempty"`
+}
+
+// EmailConfig sends reports as attachments over SMTP.
+type EmailConfig struct {
+	SMTPAddr string   `json:"smtp_addr"` // host:port
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// PausedMediaConfig decides how long paused playback still counts as
+// active before the session is ended as idle. Values are seconds; a
+// negative value keeps paused playback active indefinitely. An app rule
+// (by app ID or name) beats a category rule, which beats the default.
+type PausedMediaConfig struct {
+	// IdleAfterSeconds applies to apps without a rule. Unset keeps them
+	// active while paused, as before.
+	IdleAfterSeconds *int           `json:"idle_after_seconds,omitempty"`
+	Apps             map[string]int `json:"apps,omitempty"`
+	Categories       map[string]int `json:"categories,omitempty"`
+}
+
+// IdleAfter returns how long the app may stay paused before counting as
+// idle, and false if it never does.
+func (p *PausedMediaConfig) IdleAfter(appID, appName, category string) (time.Duration, bool) {
+	secs, ok := p.Apps[appID]
+	if !ok {
+		secs, ok = p.Apps[appName]
+	}
+	if !ok {
+		secs, ok = p.Categories[category]
+	}
+	if !ok && p.IdleAfterSeconds != nil {
+		secs, ok = *p.IdleAfterSeconds, true
+	}
+	if !ok || secs < 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// WatchSignalsConfig makes the hub check each Roku's device-info while an
+// app is active, for signs of whether anyone is actually watching.
+type WatchSignalsConfig struct {
+	// DisplayOff ends the session while the device reports its display
+	// off or in standby (any power-mode but PowerOn), as Roku TVs do when
+	// the panel is off or showing another input but the app keeps running.
+	DisplayOff bool `json:"display_off,omitempty"`
+	// Headphones keeps the app active while headphones are connected for
+	// private listening, even with the display off or playback paused past
+	// the paused-media policy: someone has the remote in hand.
+	Headphones bool `json:"headphones,omitempty"`
+}
+
+// SheetsConfig appends usage to a Google Sheet using a service account.
+// The sheet must be shared with the service account's client_email.
+type SheetsConfig struct {
+	// CredentialsFile is the service account JSON key downloaded from the
+	// Google Cloud console.
+	CredentialsFile string `json:"credentials_file"`
+	SpreadsheetID   string `json:"spreadsheet_id"`
+	// Sheet is the tab rows are appended to. Defaults to "Sessions" or
+	// "Daily" depending on Mode.
+	Sheet string `json:"sheet,omitempty"`
+	// Mode is "sessions" (one row per closed session) or "daily" (one row
+	// per device and category for each finished day). Defaults to
+	// "sessions".
+	Mode string `json:"mode,omitempty"`
+	// IntervalSeconds is how often new rows are appended. Defaults to 300.
+	IntervalSeconds int `json:"interval_seconds,omitempty"`
 }
 
 type Config struct {
@@ -21,6 +411,124 @@ type Config struct {
 	DayStartHour int            `json:"day_start_hour"`
 	Timezone     string         `json:"timezone"`
 	Devices      []DeviceConfig `json:"devices"`
+
+	// WeekStartDay is the day tracking weeks begin on, e.g. "saturday" or
+	// "sat", for weekly limits, reports and totals. Defaults to Monday.
+	WeekStartDay string `json:"week_start_day,omitempty"`
+
+	// ReadOnlyListen, when set, opens a second listener serving only the
+	// reporting routes (no device changes, audit log or debug data), for
+	// dashboards anyone in the house can reach. It takes a TCP address or
+	// "unix:/path/to/socket".
+	ReadOnlyListen string `json:"read_only_listen,omitempty"`
+
+	// AppNames overrides the built-in Roku app ID -> canonical name table.
+	AppNames map[string]string `json:"app_names,omitempty"`
+
+	// RawPollDays keeps every applied poll update in the raw_polls table
+	// for this many days, for debugging sessionization. 0 disables it.
+	RawPollDays int `json:"raw_poll_days,omitempty"`
+
+	// SplitSessionsAtDayStart stores a session crossing the day boundary
+	// (day_start_hour) as one session per day, so per-day totals never
+	// depend on whether a query clips sessions or attributes them by start
+	// time. Sessions stored before it was enabled are left as they are.
+	SplitSessionsAtDayStart bool `json:"split_sessions_at_day_start,omitempty"`
+
+	// SplitOn chooses which app change ends a session: "app_id" (the
+	// default), "app_name" for channels whose ID flips spuriously, or
+	// "both" for channels that keep one ID across differently named
+	// profiles.
+	SplitOn string `json:"split_on,omitempty"`
+
+	// StartupGraceSeconds is how long after hub startup a device that
+	// hasn't answered yet is reported as unknown instead of offline.
+	// Defaults to 300; negative disables it.
+	StartupGraceSeconds int `json:"startup_grace_seconds,omitempty"`
+
+	// MaxClockSkewSeconds is how far an agent's clock may drift from the hub
+	// before the device is flagged and a warning logged; pushes further
+	// off are refused. Defaults to 120.
+	MaxClockSkewSeconds int `json:"max_clock_skew_seconds,omitempty"`
+
+	Alerts AlertConfig `json:"alerts"`
+
+	Enforcement EnforcementConfig `json:"enforcement"`
+	Blocklist   BlocklistConfig   `json:"blocklist"`
+
+	// Hooks run commands on session and limit events, for scripting
+	// anything the hub doesn't do itself.
+	Hooks []HookConfig `json:"hooks,omitempty"`
+
+	Categories map[string]CategoryConfig `json:"categories,omitempty"`
+	Goals      []GoalConfig              `json:"goals,omitempty"`
+	Users      []UserConfig              `json:"users,omitempty"`
+
+	Reports ReportConfig `json:"reports"`
+
+	// PausedMedia, when set, makes the hub query each device's media
+	// player and end sessions left paused for too long.
+	PausedMedia *PausedMediaConfig `json:"paused_media,omitempty"`
+
+	// WatchSignals, when set, makes the hub query each device's
+	// device-info to tell an app being watched from one merely running.
+	WatchSignals *WatchSignalsConfig `json:"watch_signals,omitempty"`
+
+	// Sheets, when set, exports usage to a Google Sheet.
+	Sheets *SheetsConfig `json:"sheets,omitempty"`
+
+	// Display chooses how reports, the dashboard and the kiosk write
+	// durations, dates and times.
+	Display DisplayConfig `json:"display"`
+
+	// TLS, when set, serves http_listen and a TCP read_only_listen over
+	// HTTPS.
+	TLS *tlsutil.Config `json:"tls,omitempty"`
+
+	// DeviceCA enrolls agents for mutual TLS. It isn't read from the file;
+	// the hub loads it from its data directory at startup, and is nil
+	// until the first enrollment creates it.
+	DeviceCA *tlsutil.CA `json:"-"`
+
+	// APIKeys, when set, are the only credentials the hub API accepts;
+	// without any, the API is open to anyone who can reach it.
+	APIKeys []APIKeyConfig `json:"api_keys,omitempty"`
+}
+
+// API key scopes. A read key reaches the reporting routes; an admin key
+// reaches everything.
+const (
+	ScopeRead  = "read"
+	ScopeAdmin = "admin"
+)
+
+// APIKeyConfig is a bearer token for the hub API.
+type APIKeyConfig struct {
+	// Name identifies the key in logs and the audit log, e.g. "kitchen
+	// tablet" or "parents".
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Scope is "read" or "admin". Defaults to "read".
+	Scope string `json:"scope,omitempty"`
+}
+
+// DisplayConfig is the household's locale and clock preference for
+// anything people read.
+type DisplayConfig struct {
+	// Locale is a language with an optional region, e.g. "en-US", "de" or
+	// "fr-FR". English, German, Spanish, French and Dutch are supported;
+	// empty is English.
+	Locale string `json:"locale,omitempty"`
+	// Clock is "12h" or "24h". Empty uses the locale's usual clock, 24h
+	// without a region.
+	Clock string `json:"clock,omitempty"`
+}
+
+// Formatter returns the formatter for the display preferences, which
+// LoadConfig has checked.
+func (d DisplayConfig) Formatter() locale.Formatter {
+	f, _ := locale.New(d.Locale, d.Clock)
+	return f
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -28,7 +536,12 @@ func LoadConfig(path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
+	return ParseConfig(data)
+}
 
+// ParseConfig parses a config file's contents, filling in defaults and
+// validating it as LoadConfig does.
+func ParseConfig(data []byte) (*Config, error) {
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("unmarshal config: %w", err)
@@ -41,6 +554,18 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.DayStartHour == 0 {
 		cfg.DayStartHour = 7
 	}
+	if cfg.Alerts.AgentSilentMinutes == 0 {
+		cfg.Alerts.AgentSilentMinutes = 5
+	}
+	if cfg.Alerts.OfflineMinutes == 0 {
+		cfg.Alerts.OfflineMinutes = 15
+	}
+	if cfg.StartupGraceSeconds == 0 {
+		cfg.StartupGraceSeconds = 300
+	}
+	if cfg.Enforcement.WarnAtMinutes == nil {
+		cfg.Enforcement.WarnAtMinutes = []int{5, 2, 1}
+	}
 
 	// Basic validation
 	if cfg.DatabasePath == "" {
@@ -53,17 +578,387 @@ func LoadConfig(path string) (*Config, error) {
 		if d.ID == "" {
 			return nil, fmt.Errorf("devices[%d].id is required", i)
 		}
-		if d.BaseURL == "" {
-			return nil, fmt.Errorf("devices[%d].base_url is required", i)
+		if d.BaseURL == "" && d.Token == "" {
+			return nil, fmt.Errorf("devices[%d].base_url or token is required", i)
+		}
+		if d.PollIntervalSeconds < MinPollInterval.Seconds() {
+			return nil, fmt.Errorf("devices[%d].poll_interval_seconds must be >= %g", i, MinPollInterval.Seconds())
+		}
+		if !validSplitOn(d.SplitOn) {
+			return nil, fmt.Errorf("devices[%d].split_on must be app_id, app_name or both", i)
+		}
+		if d.TLSFingerprint != "" {
+			if !strings.HasPrefix(d.BaseURL, "https://") {
+				return nil, fmt.Errorf("devices[%d].tls_fingerprint needs an https base_url", i)
+			}
+			if !tlsutil.ValidFingerprint(d.TLSFingerprint) {
+				return nil, fmt.Errorf("devices[%d].tls_fingerprint must be a hex SHA-256 fingerprint", i)
+			}
+		}
+		if d.MTLS {
+			if !strings.HasPrefix(d.BaseURL, "https://") {
+				return nil, fmt.Errorf("devices[%d].mtls needs an https base_url", i)
+			}
+			if d.TLSFingerprint != "" {
+				return nil, fmt.Errorf("devices[%d]: mtls and tls_fingerprint can't both be set", i)
+			}
+		}
+		if d.CEC != nil && (d.CEC.BaseURL == "" || d.CEC.Input == "") {
+			return nil, fmt.Errorf("devices[%d].cec needs base_url and input", i)
 		}
-		if d.PollIntervalSeconds <= 0 {
-			return nil, fmt.Errorf("devices[%d].poll_interval_seconds must be > 0", i)
+		if d.MaxMinutes < 0 {
+			return nil, fmt.Errorf("devices[%d].max_minutes must be >= 0", i)
 		}
+		if err := ValidateGoals(fmt.Sprintf("devices[%d].goals", i), d.Goals); err != nil {
+			return nil, err
+		}
+		for j, l := range d.Launches {
+			if d.BaseURL == "" {
+				return nil, fmt.Errorf("devices[%d].launches needs a base_url", i)
+			}
+			if l.AppID == "" {
+				return nil, fmt.Errorf("devices[%d].launches[%d].app_id is required", i, j)
+			}
+			if _, err := cron.Parse(l.Schedule); err != nil {
+				return nil, fmt.Errorf("devices[%d].launches[%d].schedule: %w", i, j, err)
+			}
+			if l.MediaType != "" && l.ContentID == "" {
+				return nil, fmt.Errorf("devices[%d].launches[%d].media_type needs content_id", i, j)
+			}
+		}
+	}
+	if !validSplitOn(cfg.SplitOn) {
+		return nil, fmt.Errorf("split_on must be app_id, app_name or both")
+	}
+	if cfg.WeekStartDay != "" {
+		if _, err := ParseWeekday(cfg.WeekStartDay); err != nil {
+			return nil, fmt.Errorf("week_start_day: %w", err)
+		}
+	}
+
+	if err := ValidateGoals("goals", cfg.Goals); err != nil {
+		return nil, err
+	}
+	for name, c := range cfg.Categories {
+		if c.Productivity < -2 || c.Productivity > 2 {
+			return nil, fmt.Errorf("categories.%s.productivity must be between -2 and 2", name)
+		}
+		if !ValidColor(c.Color) {
+			return nil, fmt.Errorf("categories.%s.color must be #rgb or #rrggbb", name)
+		}
+	}
+
+	deviceIDs := make(map[string]bool)
+	for _, d := range cfg.Devices {
+		deviceIDs[d.ID] = true
+	}
+	userIDs := make(map[string]bool)
+	for i, u := range cfg.Users {
+		if u.ID == "" {
+			return nil, fmt.Errorf("users[%d].id is required", i)
+		}
+		if userIDs[u.ID] {
+			return nil, fmt.Errorf("users[%d].id %q is duplicated", i, u.ID)
+		}
+		userIDs[u.ID] = true
+		for _, d := range u.Devices {
+			if !deviceIDs[d] {
+				return nil, fmt.Errorf("users[%d] references unknown device %q", i, d)
+			}
+		}
+		if err := ValidateGoals(fmt.Sprintf("users[%d].goals", i), u.Goals); err != nil {
+			return nil, err
+		}
+		for j, p := range u.ScreenFree {
+			if len(p.Days) == 0 {
+				return nil, fmt.Errorf("users[%d].screen_free[%d].days is required", i, j)
+			}
+			for _, d := range p.Days {
+				if _, err := ParseWeekday(d); err != nil {
+					return nil, fmt.Errorf("users[%d].screen_free[%d]: %w", i, j, err)
+				}
+			}
+		}
+	}
+
+	if cfg.ReadOnlyListen != "" && cfg.ReadOnlyListen == cfg.HTTPListen {
+		return nil, fmt.Errorf("read_only_listen must differ from http_listen")
+	}
+	if cfg.RawPollDays < 0 {
+		return nil, fmt.Errorf("raw_poll_days must be >= 0")
+	}
+	if cfg.Enforcement.GraceMinutes < 0 {
+		return nil, fmt.Errorf("enforcement.grace_minutes must be >= 0")
+	}
+
+	for i, h := range cfg.Hooks {
+		if len(h.Command) == 0 {
+			return nil, fmt.Errorf("hooks[%d].command is required", i)
+		}
+		for _, e := range h.Events {
+			if e != HookSessionStart && e != HookSessionEnd && e != HookLimit {
+				return nil, fmt.Errorf("hooks[%d].events: unknown event %q", i, e)
+			}
+		}
+		if h.TimeoutSeconds < 0 {
+			return nil, fmt.Errorf("hooks[%d].timeout_seconds must be >= 0", i)
+		}
+	}
+
+	if cfg.Alerts.UncategorizedMinutes < 0 {
+		return nil, fmt.Errorf("alerts.uncategorized_minutes must be >= 0")
+	}
+
+	if err := validateReports(&cfg.Reports); err != nil {
+		return nil, err
+	}
+	if err := validateSheets(cfg.Sheets); err != nil {
+		return nil, err
+	}
+	if _, err := locale.New(cfg.Display.Locale, cfg.Display.Clock); err != nil {
+		return nil, fmt.Errorf("display: %w", err)
+	}
+	if err := validateAPIKeys(cfg.APIKeys); err != nil {
+		return nil, err
+	}
+	if err := cfg.TLS.Validate(); err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
 	}
 
 	return &cfg, nil
 }
 
+func validateReports(r *ReportConfig) error {
+	if r.Schedule == "" {
+		return nil
+	}
+	if _, err := cron.Parse(r.Schedule); err != nil {
+		return fmt.Errorf("reports.schedule: %w", err)
+	}
+	if len(r.Formats) == 0 {
+		r.Formats = []string{"html"}
+	}
+	for i, f := range r.Formats {
+		if f != "html" && f != "pdf" {
+			return fmt.Errorf("reports.formats[%d]: unknown format %q", i, f)
+		}
+	}
+	if r.OutputDir == "" && r.Email == nil {
+		return fmt.Errorf("reports needs output_dir or email")
+	}
+	if e := r.Email; e != nil {
+		if e.SMTPAddr == "" || e.From == "" || len(e.To) == 0 {
+			return fmt.Errorf("reports.email needs smtp_addr, from and to")
+		}
+	}
+	return nil
+}
+
+func validateAPIKeys(keys []APIKeyConfig) error {
+	names := make(map[string]bool)
+	tokens := make(map[string]bool)
+	for i := range keys {
+		k := &keys[i]
+		if k.Name == "" || k.Token == "" {
+			return fmt.Errorf("api_keys[%d] needs name and token", i)
+		}
+		if names[k.Name] {
+			return fmt.Errorf("api_keys[%d].name %q is duplicated", i, k.Name)
+		}
+		if tokens[k.Token] {
+			return fmt.Errorf("api_keys[%d].token is duplicated", i)
+		}
+		names[k.Name], tokens[k.Token] = true, true
+		switch k.Scope {
+		case "":
+			k.Scope = ScopeRead
+		case ScopeRead, ScopeAdmin:
+		default:
+			return fmt.Errorf("api_keys[%d].scope must be %s or %s", i, ScopeRead, ScopeAdmin)
+		}
+	}
+	return nil
+}
+
+func validateSheets(sh *SheetsConfig) error {
+	if sh == nil {
+		return nil
+	}
+	if sh.CredentialsFile == "" || sh.SpreadsheetID == "" {
+		return fmt.Errorf("sheets needs credentials_file and spreadsheet_id")
+	}
+	switch sh.Mode {
+	case "":
+		sh.Mode = "sessions"
+	case "sessions", "daily":
+	default:
+		return fmt.Errorf("sheets.mode: unknown mode %q", sh.Mode)
+	}
+	if sh.Sheet == "" {
+		sh.Sheet = "Sessions"
+		if sh.Mode == "daily" {
+			sh.Sheet = "Daily"
+		}
+	}
+	if sh.IntervalSeconds == 0 {
+		sh.IntervalSeconds = 300
+	}
+	if sh.IntervalSeconds < 0 {
+		return fmt.Errorf("sheets.interval_seconds must be > 0")
+	}
+	return nil
+}
+
+// ValidateGoals checks goals, reporting problems under path.
+func ValidateGoals(path string, goals []GoalConfig) error {
+	for i, g := range goals {
+		if g.Category == "" {
+			return fmt.Errorf("%s[%d].category is required", path, i)
+		}
+		if g.MaxMinutes <= 0 && g.MinMinutes <= 0 && g.MaxWeeklyMinutes <= 0 {
+			return fmt.Errorf("%s[%d] needs max_minutes, min_minutes or max_weekly_minutes", path, i)
+		}
+	}
+	return nil
+}
+
+// Attributed reports whether usage on deviceID under account counts for u.
+// An account claimed by a user belongs to that user alone; otherwise usage
+// goes to everyone sharing the device.
+func (c *Config) Attributed(u UserConfig, deviceID, account string) bool {
+	if account != "" {
+		for _, other := range c.Users {
+			for _, a := range other.Accounts {
+				if a == account {
+					return other.ID == u.ID
+				}
+			}
+		}
+	}
+	return u.HasDevice(deviceID)
+}
+
+// User returns the user with the given ID.
+func (c *Config) User(id string) (UserConfig, bool) {
+	for _, u := range c.Users {
+		if u.ID == id {
+			return u, true
+		}
+	}
+	return UserConfig{}, false
+}
+
+// Device returns the device with the given ID.
+func (c *Config) Device(id string) (DeviceConfig, bool) {
+	for _, d := range c.Devices {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return DeviceConfig{}, false
+}
+
+// DeviceTransport returns the HTTP transport for requests to devices. It
+// trusts the certificates pinned by the devices' tls_fingerprint, uses
+// mutual TLS with DeviceCA for mtls devices, and trusts any other https
+// device by the system roots.
+func (c *Config) DeviceTransport() http.RoundTripper {
+	trust := tlsutil.Trust{
+		Pins:     make(map[string][]string),
+		Enrolled: make(map[string][]string),
+		CA:       c.DeviceCA,
+	}
+	for _, d := range c.Devices {
+		u, err := url.Parse(d.BaseURL)
+		if err != nil {
+			continue
+		}
+		if d.TLSFingerprint != "" {
+			trust.Pins[u.Hostname()] = append(trust.Pins[u.Hostname()], d.TLSFingerprint)
+		}
+		if d.MTLS {
+			trust.Enrolled[u.Hostname()] = append(trust.Enrolled[u.Hostname()], d.ID)
+		}
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	trust.Apply(t)
+	return t
+}
+
+// DataDir returns the directory the hub keeps files besides its database
+// in, such as generated certificates; empty for an in-memory database.
+func (c *Config) DataDir() string {
+	if c.DatabasePath == ":memory:" {
+		return ""
+	}
+	return filepath.Dir(c.DatabasePath)
+}
+
+// UserByToken returns the user owning the given token.
+func (c *Config) UserByToken(token string) (UserConfig, bool) {
+	if token == "" {
+		return UserConfig{}, false
+	}
+	for _, u := range c.Users {
+		if u.Token != "" && subtle.ConstantTimeCompare([]byte(u.Token), []byte(token)) == 1 {
+			return u, true
+		}
+	}
+	return UserConfig{}, false
+}
+
+// APIKeyByToken returns the API key with the given token.
+func (c *Config) APIKeyByToken(token string) (APIKeyConfig, bool) {
+	if token == "" {
+		return APIKeyConfig{}, false
+	}
+	for _, k := range c.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(k.Token), []byte(token)) == 1 {
+			return k, true
+		}
+	}
+	return APIKeyConfig{}, false
+}
+
+// GoalsFor returns the goals that apply to a user.
+func (c *Config) GoalsFor(u UserConfig) []GoalConfig {
+	if len(u.Goals) > 0 {
+		return u.Goals
+	}
+	return c.Goals
+}
+
+// SplitOnFor returns the split mode for a device: its own split_on, else
+// the global one, else "app_id".
+func (c *Config) SplitOnFor(deviceID string) string {
+	for _, d := range c.Devices {
+		if d.ID == deviceID && d.SplitOn != "" {
+			return d.SplitOn
+		}
+	}
+	if c.SplitOn != "" {
+		return c.SplitOn
+	}
+	return "app_id"
+}
+
+func validSplitOn(v string) bool {
+	switch v {
+	case "", "app_id", "app_name", "both":
+		return true
+	}
+	return false
+}
+
+// StartupGrace returns how long devices may stay silent after startup
+// before counting as offline.
+func (c *Config) StartupGrace() time.Duration {
+	if c.StartupGraceSeconds < 0 {
+		return 0
+	}
+	return time.Duration(c.StartupGraceSeconds) * time.Second
+}
+
 // ResolveLocation returns the time.Location for the config timezone or system local.
 func (c *Config) ResolveLocation() (*time.Location, error) {
 	if c.Timezone == "" {
@@ -81,10 +976,51 @@ func (c *Config) ResolveLocation() (*time.Location, error) {
 // yesterday's day_start to now.
 func (c *Config) ComputeDayWindow(ctx context.Context, loc *time.Location) (time.Time, time.Time) {
 	now := time.Now().In(loc)
-	year, month, day := now.Date()
-	dayStart := time.Date(year, month, day, c.DayStartHour, 0, 0, 0, loc)
-	if now.Before(dayStart) {
+	return c.DayStart(now), now
+}
+
+// DayStart returns the start of the tracking day containing t.
+func (c *Config) DayStart(t time.Time) time.Time {
+	return DayStartAt(t, c.DayStartHour)
+}
+
+// NextDayStart returns the first day boundary strictly after t, in t's
+// location.
+func (c *Config) NextDayStart(t time.Time) time.Time {
+	return c.DayStart(t).AddDate(0, 0, 1)
+}
+
+// WeekStart returns the start of the tracking week containing t. Weeks
+// start on FirstWeekday at the day start hour.
+func (c *Config) WeekStart(t time.Time) time.Time {
+	return WeekStartAt(t, c.DayStartHour, c.FirstWeekday())
+}
+
+// FirstWeekday returns the day tracking weeks begin on, which LoadConfig
+// has checked.
+func (c *Config) FirstWeekday() time.Weekday {
+	if c.WeekStartDay == "" {
+		return time.Monday
+	}
+	d, _ := ParseWeekday(c.WeekStartDay)
+	return d
+}
+
+// WeekStartAt returns the start of the week beginning on first that
+// contains t, for days beginning at hour, in t's location.
+func WeekStartAt(t time.Time, hour int, first time.Weekday) time.Time {
+	day := DayStartAt(t, hour)
+	offset := (int(day.Weekday()) - int(first) + 7) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// DayStartAt returns the start of the day containing t for days beginning at
+// hour, in t's location.
+func DayStartAt(t time.Time, hour int) time.Time {
+	year, month, day := t.Date()
+	dayStart := time.Date(year, month, day, hour, 0, 0, 0, t.Location())
+	if t.Before(dayStart) {
 		dayStart = dayStart.AddDate(0, 0, -1)
 	}
-	return dayStart, now
+	return dayStart
 }
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Settings is the portable part of a hub's config: what it tracks and the
+// limits it applies, without local paths, listeners or credentials. It
+// uses the same JSON keys as Config, so a config file is also a valid
+// Settings document.
+type Settings struct {
+	Devices    []DeviceConfig            `json:"devices"`
+	Users      []UserConfig              `json:"users,omitempty"`
+	Categories map[string]CategoryConfig `json:"categories,omitempty"`
+	Goals      []GoalConfig              `json:"goals,omitempty"`
+	// Enforcement and Blocklist replace the target's wholesale when set.
+	Enforcement *EnforcementConfig `json:"enforcement,omitempty"`
+	Blocklist   *BlocklistConfig   `json:"blocklist,omitempty"`
+}
+
+// Settings returns the config's portable settings. User and device tokens
+// are left out; they are secrets of this hub. A push-only device without
+// its token can't be imported as a new device; MergeSettings skips it.
+func (c *Config) Settings() Settings {
+	s := Settings{
+		Categories:  c.Categories,
+		Goals:       c.Goals,
+		Enforcement: &c.Enforcement,
+		Blocklist:   &c.Blocklist,
+	}
+	for _, d := range c.Devices {
+		d.Token = ""
+		s.Devices = append(s.Devices, d)
+	}
+	for _, u := range c.Users {
+		u.Token = ""
+		s.Users = append(s.Users, u)
+	}
+	return s
+}
+
+// SettingsStats counts what MergeSettings added and replaced.
+type SettingsStats struct {
+	Added    int
+	Replaced int
+	// NeedToken lists new push-only devices that were skipped because
+	// their token was left out of the export; add them with a token of
+	// their own.
+	NeedToken []string
+}
+
+// MergeSettings merges s into the raw config file data and returns the new
+// file contents. Devices and users match by ID, categories by name and
+// goals by category; a match is replaced, anything else is added, and
+// entries only in the target are kept. A replaced device or user keeps
+// its token when s has none; a new device with neither a token nor a
+// base_url is skipped and listed in NeedToken. Enforcement and blocklist
+// are replaced when s has them. Other config keys are left untouched.
+func MergeSettings(data []byte, s Settings) ([]byte, SettingsStats, error) {
+	var stats SettingsStats
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, stats, fmt.Errorf("unmarshal config: %w", err)
+	}
+	if raw == nil {
+		raw = make(map[string]json.RawMessage)
+	}
+
+	var cur Settings
+	for key, v := range map[string]any{
+		"devices":    &cur.Devices,
+		"users":      &cur.Users,
+		"categories": &cur.Categories,
+		"goals":      &cur.Goals,
+	} {
+		if msg, ok := raw[key]; ok {
+			if err := json.Unmarshal(msg, v); err != nil {
+				return nil, stats, fmt.Errorf("unmarshal %s: %w", key, err)
+			}
+		}
+	}
+
+	for _, d := range s.Devices {
+		i := indexOf(len(cur.Devices), func(i int) bool { return cur.Devices[i].ID == d.ID })
+		if i < 0 {
+			if d.BaseURL == "" && d.Token == "" {
+				stats.NeedToken = append(stats.NeedToken, d.ID)
+				continue
+			}
+			cur.Devices = append(cur.Devices, d)
+			stats.Added++
+			continue
+		}
+		if d.Token == "" {
+			d.Token = cur.Devices[i].Token
+		}
+		cur.Devices[i] = d
+		stats.Replaced++
+	}
+
+	for _, u := range s.Users {
+		i := indexOf(len(cur.Users), func(i int) bool { return cur.Users[i].ID == u.ID })
+		if i < 0 {
+			cur.Users = append(cur.Users, u)
+			stats.Added++
+			continue
+		}
+		if u.Token == "" {
+			u.Token = cur.Users[i].Token
+		}
+		cur.Users[i] = u
+		stats.Replaced++
+	}
+
+	for name, c := range s.Categories {
+		if cur.Categories == nil {
+			cur.Categories = make(map[string]CategoryConfig)
+		}
+		if _, ok := cur.Categories[name]; ok {
+			stats.Replaced++
+		} else {
+			stats.Added++
+		}
+		cur.Categories[name] = c
+	}
+
+	for _, g := range s.Goals {
+		i := indexOf(len(cur.Goals), func(i int) bool { return cur.Goals[i].Category == g.Category })
+		if i < 0 {
+			cur.Goals = append(cur.Goals, g)
+			stats.Added++
+			continue
+		}
+		cur.Goals[i] = g
+		stats.Replaced++
+	}
+
+	if s.Enforcement != nil {
+		stats.Replaced++
+	}
+	if s.Blocklist != nil {
+		stats.Replaced++
+	}
+
+	for key, v := range map[string]any{
+		"devices":     cur.Devices,
+		"users":       cur.Users,
+		"categories":  cur.Categories,
+		"goals":       cur.Goals,
+		"enforcement": s.Enforcement,
+		"blocklist":   s.Blocklist,
+	} {
+		msg, err := json.Marshal(v)
+		if err != nil {
+			return nil, stats, fmt.Errorf("marshal %s: %w", key, err)
+		}
+		if string(msg) == "null" {
+			continue
+		}
+		raw[key] = msg
+	}
+
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, stats, fmt.Errorf("marshal config: %w", err)
+	}
+	return append(out, '\n'), stats, nil
+}
+
+func indexOf(n int, match func(int) bool) int {
+	for i := 0; i < n; i++ {
+		if match(i) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeRange is a daily clock-time window such as "07:00-21:00". Windows whose
+// end is before their start wrap past midnight ("21:00-07:00").
+type TimeRange struct {
+	Start int // minutes after midnight
+	End   int // minutes after midnight
+}
+
+// ParseTimeRange parses "HH:MM-HH:MM".
+func ParseTimeRange(s string) (TimeRange, error) {
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return TimeRange{}, fmt.Errorf("time range %q: expected HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(startStr)
+	if err != nil {
+		return TimeRange{}, fmt.Errorf("time range %q: %w", s, err)
+	}
+	end, err := parseClock(endStr)
+	if err != nil {
+		return TimeRange{}, fmt.Errorf("time range %q: %w", s, err)
+	}
+	return TimeRange{Start: start, End: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid clock time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether the clock time of t falls inside the range.
+func (r TimeRange) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if r.Start <= r.End {
+		return m >= r.Start && m < r.End
+	}
+	return m >= r.Start || m < r.End
+}
+
+// Next returns the next occurrence of the range at or after t, in t's
+// location. When t is already inside the range, the current occurrence is
+// returned and its start is not after t.
+func (r TimeRange) Next(t time.Time) (start, end time.Time) {
+	y, m, d := t.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+
+	// Check yesterday's, today's and tomorrow's occurrences.
+	for offset := -1; offset <= 1; offset++ {
+		start, end = r.On(midnight.AddDate(0, 0, offset))
+		if end.After(t) {
+			return start, end
+		}
+	}
+	return start, end
+}
+
+// On returns the occurrence of the range starting on the day of midnight.
+func (r TimeRange) On(midnight time.Time) (start, end time.Time) {
+	length := r.End - r.Start
+	if length <= 0 {
+		length += 24 * 60
+	}
+	start = midnight.Add(time.Duration(r.Start) * time.Minute)
+	return start, start.Add(time.Duration(length) * time.Minute)
+}
+
+// ParseWeekday parses a day name, full or abbreviated to three letters,
+// in any case.
+func ParseWeekday(s string) (time.Weekday, error) {
+	l := strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if l == name || l == name[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q", s)
+}
+
+func (r TimeRange) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", r.Start/60, r.Start%60, r.End/60, r.End%60)
+}
+
+func (r *TimeRange) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("time range must be a string: %w", err)
+	}
+	parsed, err := ParseTimeRange(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+func (r TimeRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
@@ -0,0 +1,163 @@
+// Package demo runs the hub against made-up devices, for trying out the
+// dashboard and API without any real ones. The devices follow a fixed
+// household routine, and history is generated from the same routine, so
+// live and past usage look alike.
+package demo
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"screentime-agent/internal/config"
+)
+
+// slotLength is how long a device stays on one app (or off) in the
+// routine.
+const slotLength = 20 * time.Minute
+
+// App is a channel a demo device plays.
+type App struct {
+	ID     string
+	Name   string
+	Weight int // relative chance of being picked
+}
+
+// Hours is a span of the day, [Start, End) in whole hours.
+type Hours struct {
+	Start, End int
+	// Busy is the chance the device is in use in any slot of the span.
+	Busy float64
+}
+
+// Profile is the routine of one demo device.
+type Profile struct {
+	ID       string
+	Name     string // display name
+	Apps     []App
+	Weekdays []Hours
+	Weekends []Hours
+}
+
+// Profiles are the demo household's devices.
+var Profiles = []Profile{
+	{
+		ID:   "living-room",
+		Name: "Living Room TV",
+		Apps: []App{
+			{ID: "12", Name: "Netflix", Weight: 5},
+			{ID: "2285", Name: "Hulu", Weight: 2},
+			{ID: "291097", Name: "Disney+", Weight: 3},
+			{ID: "837", Name: "YouTube", Weight: 3},
+			{ID: "151908", Name: "The Roku Channel", Weight: 1},
+		},
+		Weekdays: []Hours{{Start: 7, End: 8, Busy: 0.3}, {Start: 17, End: 22, Busy: 0.7}},
+		Weekends: []Hours{{Start: 8, End: 12, Busy: 0.6}, {Start: 14, End: 23, Busy: 0.6}},
+	},
+	{
+		ID:   "kids-room",
+		Name: "Kids' Room TV",
+		Apps: []App{
+			{ID: "23333", Name: "PBS KIDS", Weight: 4},
+			{ID: "837", Name: "YouTube", Weight: 4},
+			{ID: "291097", Name: "Disney+", Weight: 3},
+		},
+		Weekdays: []Hours{{Start: 15, End: 19, Busy: 0.5}},
+		Weekends: []Hours{{Start: 7, End: 11, Busy: 0.7}, {Start: 15, End: 19, Busy: 0.5}},
+	},
+}
+
+// Activity returns the app p's device shows at t, read in t's location, or
+// nil when it is on the home screen. It depends only on p and t, so every
+// caller agrees on it.
+func (p Profile) Activity(t time.Time) *App {
+	spans := p.Weekdays
+	if wd := t.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		spans = p.Weekends
+	}
+	var busy float64
+	for _, h := range spans {
+		if t.Hour() >= h.Start && t.Hour() < h.End {
+			busy = h.Busy
+		}
+	}
+	if busy == 0 {
+		return nil
+	}
+
+	slot := t.Truncate(slotLength)
+	h := fnv.New64a()
+	h.Write([]byte(p.ID))
+	binary.Write(h, binary.LittleEndian, slot.Unix())
+	rng := rand.New(rand.NewSource(int64(h.Sum64())))
+	if rng.Float64() >= busy {
+		return nil
+	}
+
+	total := 0
+	for _, a := range p.Apps {
+		total += a.Weight
+	}
+	n := rng.Intn(total)
+	for i := range p.Apps {
+		if n < p.Apps[i].Weight {
+			return &p.Apps[i]
+		}
+		n -= p.Apps[i].Weight
+	}
+	return nil
+}
+
+// Config returns the hub config for the demo household, with each profile
+// polled at its base URL in baseURLs.
+func Config(baseURLs map[string]string) (*config.Config, error) {
+	var devices []map[string]any
+	for _, p := range Profiles {
+		devices = append(devices, map[string]any{
+			"id":                    p.ID,
+			"display_name":          p.Name,
+			"base_url":              baseURLs[p.ID],
+			"poll_interval_seconds": 5,
+		})
+	}
+
+	raw := map[string]any{
+		// The hub opens its own in-memory database in demo mode.
+		"database_path":  ":memory:",
+		"day_start_hour": 5,
+		"devices":        devices,
+		"categories": map[string]any{
+			"entertainment": map[string]any{
+				"app_ids": []string{"12", "2285", "291097", "151908"},
+				"color":   "#e5533d",
+				"icon":    "🍿",
+			},
+			"video": map[string]any{
+				"app_ids": []string{"837"},
+				"color":   "#f2a33a",
+				"icon":    "📺",
+			},
+			"education": map[string]any{
+				"app_ids": []string{"23333"},
+				"color":   "#3d9be5",
+				"icon":    "📚",
+			},
+		},
+		"goals": []map[string]any{
+			{"category": "entertainment", "max_minutes": 120},
+			{"category": "education", "min_minutes": 30},
+		},
+		"users": []map[string]any{
+			{"id": "family", "name": "Family", "devices": []string{"living-room"}},
+			{"id": "kids", "name": "Kids", "devices": []string{"kids-room"}},
+		},
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("marshal demo config: %w", err)
+	}
+	return config.ParseConfig(data)
+}
@@ -0,0 +1,112 @@
+package demo
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"screentime-agent/internal/storage"
+)
+
+// homeScreen is what a Roku reports as its active app when nothing is
+// playing; the poller treats it as idle.
+const homeScreen = "Roku"
+
+// Serve starts a fake Roku for each profile on a loopback port, answering
+// the ECP queries the hub polls with the profile's routine in loc. It
+// returns each device's base URL; the devices stop when ctx is done.
+func Serve(ctx context.Context, loc *time.Location) (map[string]string, error) {
+	baseURLs := make(map[string]string)
+	for _, p := range Profiles {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("listen for demo device %s: %w", p.ID, err)
+		}
+		baseURLs[p.ID] = "http://" + ln.Addr().String()
+
+		srv := &http.Server{Handler: deviceHandler(p, loc)}
+		go func() {
+			if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+				log.Printf("demo device %s: %v", p.ID, err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			srv.Close()
+		}()
+	}
+	return baseURLs, nil
+}
+
+func deviceHandler(p Profile, loc *time.Location) http.Handler {
+	type xmlApp struct {
+		ID   string `xml:"id,attr,omitempty"`
+		Name string `xml:",chardata"`
+	}
+	type activeApp struct {
+		XMLName xml.Name `xml:"active-app"`
+		App     xmlApp   `xml:"app"`
+	}
+	type player struct {
+		XMLName xml.Name `xml:"player"`
+		State   string   `xml:"state,attr"`
+	}
+
+	writeXML := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, xml.Header)
+		if err := xml.NewEncoder(w).Encode(v); err != nil {
+			log.Printf("demo device %s: encode response: %v", p.ID, err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /query/active-app", func(w http.ResponseWriter, r *http.Request) {
+		resp := activeApp{App: xmlApp{Name: homeScreen}}
+		if a := p.Activity(time.Now().In(loc)); a != nil {
+			resp.App = xmlApp{ID: a.ID, Name: a.Name}
+		}
+		writeXML(w, resp)
+	})
+	mux.HandleFunc("GET /query/media-player", func(w http.ResponseWriter, r *http.Request) {
+		state := "close"
+		if p.Activity(time.Now().In(loc)) != nil {
+			state = "play"
+		}
+		writeXML(w, player{State: state})
+	})
+	// Scheduled launches are accepted and otherwise ignored.
+	mux.HandleFunc("POST /launch/{app}", func(w http.ResponseWriter, r *http.Request) {})
+	return mux
+}
+
+// GenerateHistory records days of each profile's routine before now in
+// store, as if the hub had polled the devices every minute, and leaves
+// whatever is playing at now open as the current session.
+func GenerateHistory(ctx context.Context, store *storage.SessionStore, loc *time.Location, now time.Time, days int) error {
+	start := now.AddDate(0, 0, -days).Truncate(time.Minute)
+	for _, p := range Profiles {
+		var last *App
+		for t := start; t.Before(now); t = t.Add(time.Minute) {
+			u := storage.PollUpdate{DeviceID: p.ID, State: "idle", Timestamp: t.UTC()}
+			a := p.Activity(t.In(loc))
+			if a != nil {
+				u.AppID, u.AppName, u.State = a.ID, a.Name, "active"
+			}
+			if a != nil && a != last {
+				if _, err := store.RecordAppSeen(ctx, p.ID, a.ID, a.Name, u.Timestamp); err != nil {
+					return err
+				}
+			}
+			last = a
+			if err := store.ApplyPoll(ctx, u); err != nil {
+				return fmt.Errorf("demo history for %s: %w", p.ID, err)
+			}
+		}
+	}
+	return nil
+}
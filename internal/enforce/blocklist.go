@@ -0,0 +1,67 @@
+package enforce
+
+import (
+	"strings"
+
+	"screentime-agent/internal/config"
+)
+
+// Blocklist matches apps that are never allowed.
+type Blocklist struct {
+	appIDs  map[string]bool
+	names   map[string]bool
+	domains []string
+	classes map[string]bool
+}
+
+// NewBlocklist builds a matcher from the config.
+func NewBlocklist(cfg config.BlocklistConfig) *Blocklist {
+	b := &Blocklist{
+		appIDs:  make(map[string]bool),
+		names:   make(map[string]bool),
+		classes: make(map[string]bool),
+	}
+	for _, id := range cfg.AppIDs {
+		b.appIDs[id] = true
+	}
+	for _, n := range cfg.AppNames {
+		b.names[strings.ToLower(n)] = true
+	}
+	for _, d := range cfg.Domains {
+		b.domains = append(b.domains, strings.ToLower(strings.TrimPrefix(d, ".")))
+	}
+	for _, c := range cfg.WindowClasses {
+		b.classes[strings.ToLower(c)] = true
+	}
+	return b
+}
+
+// Blocked reports whether the app is on the blocklist. Linux agent IDs are
+// unpacked so "browser:<category>:<domain>" matches domains and
+// "window:<class>" or "electron:<category>:<app>:..." match window classes.
+func (b *Blocklist) Blocked(appID, appName string) bool {
+	if b.appIDs[appID] || b.names[strings.ToLower(appName)] {
+		return true
+	}
+
+	kind, rest, _ := strings.Cut(appID, ":")
+	switch kind {
+	case "browser":
+		parts := strings.SplitN(rest, ":", 2)
+		if len(parts) < 2 {
+			return false
+		}
+		domain := strings.ToLower(parts[1])
+		for _, d := range b.domains {
+			if domain == d || strings.HasSuffix(domain, "."+d) {
+				return true
+			}
+		}
+	case "window":
+		return b.classes[strings.ToLower(rest)]
+	case "electron":
+		parts := strings.SplitN(rest, ":", 3)
+		return len(parts) >= 2 && b.classes[strings.ToLower(parts[1])]
+	}
+	return false
+}
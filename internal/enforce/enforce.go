@@ -0,0 +1,386 @@
+// Package enforce watches each user's budgets and warns, then enforces,
+// as limits run out.
+package enforce
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/alert"
+	"screentime-agent/internal/category"
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/limits"
+	"screentime-agent/internal/storage"
+)
+
+// checkInterval is how often budgets are evaluated.
+const checkInterval = 30 * time.Second
+
+// Watcher raises an alert and notifies the covered devices when a limit is
+// reached, at each warning step of the grace window, and when the limit is
+// enforced, at which point its enforcers act too. Limits are users' and
+// devices' own.
+type Watcher struct {
+	cfg        *config.Config
+	store      *storage.SessionStore
+	notifier   alert.Notifier
+	categories *category.Categorizer
+	loc        *time.Location
+	client     *http.Client
+	enforcers  []Enforcer
+
+	sent       map[stageKey]int     // last stage announced per budget period
+	screenFree map[string]time.Time // screen-free period last enforced per user
+}
+
+// stageKey identifies one budget period; start is the day or week start.
+// Exactly one of user and device is set. period keeps a daily and a weekly
+// budget apart on the first day of the week, when their starts match.
+type stageKey struct {
+	user, device, category, period string
+	start                          time.Time
+}
+
+// owner is whoever a budget belongs to, for announcing it.
+type owner struct {
+	name    string
+	userID  string // "" for a device's own budget
+	devices []config.DeviceConfig
+}
+
+// NewWatcher creates a watcher.
+func NewWatcher(cfg *config.Config, store *storage.SessionStore, notifier alert.Notifier, loc *time.Location) *Watcher {
+	w := &Watcher{
+		cfg:        cfg,
+		store:      store,
+		notifier:   notifier,
+		categories: category.New(cfg.Categories, store.AppCategory),
+		loc:        loc,
+		client:     &http.Client{Timeout: 3 * time.Second, Transport: cfg.DeviceTransport()},
+		sent:       make(map[stageKey]int),
+		screenFree: make(map[string]time.Time),
+	}
+	w.enforcers = NewEnforcers(cfg, w.client)
+	return w
+}
+
+// AddEnforcer adds e to the enforcers run when a budget is enforced.
+func (w *Watcher) AddEnforcer(e Enforcer) {
+	w.enforcers = append(w.enforcers, e)
+}
+
+// Run checks budgets every checkInterval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Check(ctx, time.Now()); err != nil {
+			log.Printf("enforce: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check evaluates every user's and device's daily and weekly budgets at
+// now and announces any stage reached since the last check, then enforces
+// screen-free periods.
+func (w *Watcher) Check(ctx context.Context, now time.Time) error {
+	now = now.In(w.loc)
+	dayStart := w.cfg.DayStart(now)
+	weekStart := w.cfg.WeekStart(now)
+	for k := range w.sent {
+		if !k.start.Equal(dayStart) && !k.start.Equal(weekStart) {
+			delete(w.sent, k)
+		}
+	}
+
+	today, err := w.store.GetUsageBetween(ctx, dayStart.UTC(), now.UTC(), nil)
+	if err != nil {
+		return fmt.Errorf("compute usage: %w", err)
+	}
+	week, err := w.store.GetUsageBetween(ctx, weekStart.UTC(), now.UTC(), nil)
+	if err != nil {
+		return fmt.Errorf("compute weekly usage: %w", err)
+	}
+
+	grace := w.cfg.Enforcement.Grace()
+	for _, u := range w.cfg.Users {
+		goals := w.cfg.GoalsFor(u)
+		budgets := append(
+			limits.Budgets(goals, w.userTotals(u, today), grace),
+			limits.WeeklyBudgets(goals, w.userTotals(u, week), grace)...)
+
+		o := owner{name: userName(u), userID: u.ID}
+		for _, d := range w.cfg.Devices {
+			if u.HasDevice(d.ID) {
+				o.devices = append(o.devices, d)
+			}
+		}
+		for _, b := range budgets {
+			w.advance(ctx, stageKey{user: u.ID}, o, b, dayStart, weekStart, now)
+		}
+	}
+
+	for _, d := range w.cfg.Devices {
+		if d.MaxMinutes <= 0 && len(d.Goals) == 0 {
+			continue
+		}
+		budgets := limits.DeviceBudgets(d, w.deviceTotals(d.ID, today), w.deviceTotals(d.ID, week), grace)
+		o := owner{name: d.ID, devices: []config.DeviceConfig{d}}
+		for _, b := range budgets {
+			w.advance(ctx, stageKey{device: d.ID}, o, b, dayStart, weekStart, now)
+		}
+	}
+	return w.checkScreenFree(ctx, now)
+}
+
+// checkScreenFree enforces each user's screen-free period in effect at now
+// once they are seen using a device in it. Sessions approved as
+// exceptions are allowed.
+func (w *Watcher) checkScreenFree(ctx context.Context, now time.Time) error {
+	var (
+		current []storage.CurrentSession
+		loaded  bool
+	)
+	for _, u := range w.cfg.Users {
+		sf, ok := limits.NextScreenFree(u.ScreenFree, now, w.cfg.DayStartHour)
+		if !ok || !sf.Active || w.screenFree[u.ID].Equal(sf.Start) {
+			continue
+		}
+		if !loaded {
+			var err error
+			if current, err = w.store.GetCurrentSessions(ctx); err != nil {
+				return fmt.Errorf("get current sessions: %w", err)
+			}
+			loaded = true
+		}
+
+		var inUse []config.DeviceConfig
+		for _, d := range w.cfg.Devices {
+			for _, cs := range current {
+				if cs.DeviceID == d.ID && cs.ExceptionLabel == "" && w.cfg.Attributed(u, cs.DeviceID, cs.Account) {
+					inUse = append(inUse, d)
+					break
+				}
+			}
+		}
+		if len(inUse) == 0 {
+			continue
+		}
+		w.screenFree[u.ID] = sf.Start
+		w.announceScreenFree(ctx, u, sf, inUse, now)
+	}
+	return nil
+}
+
+// announceScreenFree alerts parents that u is using devices during a
+// screen-free period, tells those devices and runs the enforcers.
+func (w *Watcher) announceScreenFree(ctx context.Context, u config.UserConfig, sf limits.Downtime, devices []config.DeviceConfig, now time.Time) {
+	until := w.cfg.Display.Formatter().WeekdayClock(sf.End.In(w.loc))
+	var ids []string
+	for _, d := range devices {
+		ids = append(ids, d.ID)
+	}
+	a := alert.Alert{
+		Kind:    "screen_free",
+		Message: fmt.Sprintf("%s is using %s during screen-free time (until %s)", userName(u), strings.Join(ids, ", "), until),
+		Time:    now.UTC(),
+	}
+	if err := w.notifier.Notify(ctx, a); err != nil {
+		log.Printf("enforce: notify error: %v", err)
+	}
+
+	n := Notification{Title: "Screen time", Message: fmt.Sprintf("It's screen-free time until %s.", until), Urgent: true}
+	for _, d := range devices {
+		if err := NotifyDevice(ctx, w.client, d, n); err != nil {
+			log.Printf("device %s notify error: %v", d.ID, err)
+		}
+	}
+
+	w.enforce(ctx, Enforcement{
+		Reason:    ReasonScreenFree,
+		UserID:    u.ID,
+		DeviceIDs: []string{},
+		Time:      now.UTC(),
+	}, devices)
+}
+
+// advance announces b if it has reached a stage not yet announced in its
+// period. key names the budget's owner.
+func (w *Watcher) advance(ctx context.Context, key stageKey, o owner, b limits.Budget, dayStart, weekStart, now time.Time) {
+	key.category, key.period, key.start = b.Category, b.Period, dayStart
+	if b.Period == limits.PeriodWeek {
+		key.start = weekStart
+	}
+	stage := w.stage(b)
+	if stage <= w.sent[key] {
+		return
+	}
+	w.sent[key] = stage
+	w.announce(ctx, o, b, stage == 1, now)
+}
+
+// deviceTotals sums one device's entries per category as counted toward
+// limits, whoever the usage is attributed to.
+func (w *Watcher) deviceTotals(deviceID string, entries []storage.UsageEntry) map[string]int64 {
+	var mine []storage.UsageEntry
+	for _, e := range entries {
+		if e.DeviceID == deviceID {
+			mine = append(mine, e)
+		}
+	}
+	return w.categories.LimitTotals(mine)
+}
+
+// userTotals sums the user's entries per category as counted toward
+// limits.
+func (w *Watcher) userTotals(u config.UserConfig, entries []storage.UsageEntry) map[string]int64 {
+	var mine []storage.UsageEntry
+	for _, e := range entries {
+		if w.cfg.Attributed(u, e.DeviceID, e.Account) {
+			mine = append(mine, e)
+		}
+	}
+	return w.categories.LimitTotals(mine)
+}
+
+// warnings returns the warning steps that fall inside the grace window,
+// largest first.
+func (w *Watcher) warnings() []int {
+	var out []int
+	for _, m := range w.cfg.Enforcement.WarnAtMinutes {
+		if m > 0 && m < w.cfg.Enforcement.GraceMinutes {
+			out = append(out, m)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(out)))
+	return out
+}
+
+// stage orders how far a budget has gone: 0 while time is left, 1 when the
+// limit is reached, one more for each warning step passed, and the last
+// stage once enforced.
+func (w *Watcher) stage(b limits.Budget) int {
+	warnings := w.warnings()
+	switch b.State {
+	case limits.StateOK:
+		return 0
+	case limits.StateEnforced:
+		return len(warnings) + 2
+	}
+	stage := 1
+	for _, m := range warnings {
+		if b.GraceRemainingSeconds <= int64(m)*60 {
+			stage++
+		}
+	}
+	return stage
+}
+
+// announce alerts parents and notifies the owner's devices, and runs the
+// enforcers once the budget is enforced. reached is true for the first
+// announcement after the limit is hit.
+func (w *Watcher) announce(ctx context.Context, o owner, b limits.Budget, reached bool, now time.Time) {
+	a := alert.Alert{Time: now.UTC()}
+	if o.userID == "" {
+		a.DeviceID = o.name
+	}
+	cat := b.Category
+	if cat == limits.CategoryAll {
+		cat = "screen"
+	}
+	urgent := false
+	switch b.State {
+	case limits.StateEnforced:
+		a.Kind = "limit_enforced"
+		a.Message = fmt.Sprintf("%s is out of %s time%s", o.name, cat, periodSuffix(b))
+		urgent = true
+	default:
+		a.Kind = "limit_grace"
+		mins := (b.GraceRemainingSeconds + 59) / 60
+		if reached {
+			a.Message = fmt.Sprintf("%s reached the %s limit%s; %d min to wrap up", o.name, cat, periodSuffix(b), mins)
+		} else {
+			a.Message = fmt.Sprintf("%s has %d min of %s grace left%s", o.name, mins, cat, periodSuffix(b))
+		}
+	}
+	if err := w.notifier.Notify(ctx, a); err != nil {
+		log.Printf("enforce: notify error: %v", err)
+	}
+
+	for _, d := range o.devices {
+		if d.NotifyPath == "" {
+			continue
+		}
+		if err := w.notifyDevice(ctx, d, b, urgent); err != nil {
+			log.Printf("device %s notify error: %v", d.ID, err)
+		}
+	}
+
+	if b.State != limits.StateEnforced {
+		return
+	}
+	w.enforce(ctx, Enforcement{
+		Reason:    ReasonLimit,
+		UserID:    o.userID,
+		DeviceIDs: []string{},
+		Category:  b.Category,
+		Period:    b.Period,
+		Time:      now.UTC(),
+	}, o.devices)
+}
+
+// enforce runs every enforcer for e on devices.
+func (w *Watcher) enforce(ctx context.Context, e Enforcement, devices []config.DeviceConfig) {
+	for _, d := range devices {
+		e.DeviceIDs = append(e.DeviceIDs, d.ID)
+	}
+	for _, en := range w.enforcers {
+		if err := en.Enforce(ctx, e); err != nil {
+			log.Printf("enforce: %v", err)
+		}
+	}
+}
+
+func (w *Watcher) notifyDevice(ctx context.Context, d config.DeviceConfig, b limits.Budget, urgent bool) error {
+	n := Notification{Title: "Screen time", Urgent: urgent}
+	what := b.Category
+	if what == limits.CategoryAll {
+		what = "this device"
+	}
+	if b.State == limits.StateEnforced {
+		when := " today"
+		if b.Period == limits.PeriodWeek {
+			when = " this week"
+		}
+		n.Message = fmt.Sprintf("Time's up for %s%s.", what, when)
+	} else {
+		n.Message = fmt.Sprintf("Time's up for %s. Please wrap up in the next %d min.", what, (b.GraceRemainingSeconds+59)/60)
+	}
+	return NotifyDevice(ctx, w.client, d, n)
+}
+
+// periodSuffix marks weekly budgets in messages; daily ones are the norm.
+func periodSuffix(b limits.Budget) string {
+	if b.Period == limits.PeriodWeek {
+		return " for the week"
+	}
+	return ""
+}
+
+func userName(u config.UserConfig) string {
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.ID
+}
@@ -0,0 +1,94 @@
+package enforce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/config"
+)
+
+// Enforcement reasons.
+const (
+	ReasonLimit      = "limit"       // a budget ran out
+	ReasonScreenFree = "screen_free" // used during a screen-free period
+)
+
+// Enforcement is a budget that has run out, or screen time in a
+// screen-free period, handed to each Enforcer.
+type Enforcement struct {
+	Reason string `json:"reason"`
+	// UserID is the user whose budget ran out, or "" for a device's own
+	// budget.
+	UserID string `json:"user_id,omitempty"`
+	// DeviceIDs are the devices the budget covers.
+	DeviceIDs []string `json:"device_ids"`
+	// Category and Period are the budget's; both are empty for
+	// ReasonScreenFree.
+	Category string    `json:"category,omitempty"`
+	Period   string    `json:"period,omitempty"` // "day" or "week"
+	Time     time.Time `json:"time"`
+}
+
+// Enforcer acts on an enforced budget, e.g. by blocking the devices it
+// covers.
+type Enforcer interface {
+	Enforce(ctx context.Context, e Enforcement) error
+}
+
+// NewEnforcers returns the enforcers configured in cfg: a webhook when
+// enforcement.webhook_url is set and a block request to every device with
+// a block_path.
+func NewEnforcers(cfg *config.Config, client *http.Client) []Enforcer {
+	out := []Enforcer{agentBlocker{cfg: cfg, client: client}}
+	if cfg.Enforcement.WebhookURL != "" {
+		out = append(out, webhookEnforcer{url: cfg.Enforcement.WebhookURL, client: client})
+	}
+	return out
+}
+
+// webhookEnforcer POSTs each enforcement as JSON.
+type webhookEnforcer struct {
+	url    string
+	client *http.Client
+}
+
+func (w webhookEnforcer) Enforce(ctx context.Context, e Enforcement) error {
+	if err := postJSON(ctx, w.client, w.url, "", e); err != nil {
+		return fmt.Errorf("post enforcement webhook: %w", err)
+	}
+	return nil
+}
+
+// BlockRequest is the body POSTed to a device's block_path.
+type BlockRequest struct {
+	Reason   string `json:"reason"`
+	Category string `json:"category,omitempty"`
+	Period   string `json:"period,omitempty"`
+	// Timestamp lets the agent refuse a replayed block.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// agentBlocker asks each covered device with a block_path to block.
+type agentBlocker struct {
+	cfg    *config.Config
+	client *http.Client
+}
+
+func (a agentBlocker) Enforce(ctx context.Context, e Enforcement) error {
+	var firstErr error
+	for _, id := range e.DeviceIDs {
+		d, ok := a.cfg.Device(id)
+		if !ok || d.BlockPath == "" {
+			continue
+		}
+		url := strings.TrimRight(d.BaseURL, "/") + d.BlockPath
+		req := BlockRequest{Reason: e.Reason, Category: e.Category, Period: e.Period, Timestamp: time.Now().UTC()}
+		if err := postJSON(ctx, a.client, url, d.Token, req); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("block device %s: %w", id, err)
+		}
+	}
+	return firstErr
+}
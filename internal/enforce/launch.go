@@ -0,0 +1,120 @@
+package enforce
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/cron"
+	"screentime-agent/internal/storage"
+)
+
+// Launcher starts channels on devices at their configured launch times,
+// the positive side of enforcement: steering a device to something
+// allowed instead of only blocking.
+type Launcher struct {
+	cfg    *config.Config
+	store  *storage.SessionStore
+	loc    *time.Location
+	client *http.Client
+}
+
+// NewLauncher creates a launcher for every device's launches.
+func NewLauncher(cfg *config.Config, store *storage.SessionStore, loc *time.Location) *Launcher {
+	return &Launcher{
+		cfg:    cfg,
+		store:  store,
+		loc:    loc,
+		client: &http.Client{Timeout: 5 * time.Second, Transport: cfg.DeviceTransport()},
+	}
+}
+
+// Run waits for each launch's scheduled times and launches it until ctx
+// is done.
+func (l *Launcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, d := range l.cfg.Devices {
+		for _, lc := range d.Launches {
+			schedule, err := cron.Parse(lc.Schedule)
+			if err != nil {
+				log.Printf("launch: device %s: %v", d.ID, err)
+				continue
+			}
+			wg.Add(1)
+			go func(d config.DeviceConfig, lc config.LaunchConfig) {
+				defer wg.Done()
+				l.runLaunch(ctx, d, lc, schedule)
+			}(d, lc)
+		}
+	}
+	wg.Wait()
+}
+
+func (l *Launcher) runLaunch(ctx context.Context, d config.DeviceConfig, lc config.LaunchConfig, schedule *cron.Schedule) {
+	for {
+		next := schedule.Next(time.Now().In(l.loc))
+		if next.IsZero() {
+			log.Printf("launch: device %s: schedule %q never fires", d.ID, schedule)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// A disabled device is left alone, as it is by the pollers.
+		enabled, err := l.store.GetDeviceEnabled(ctx, d.ID)
+		if err != nil {
+			log.Printf("launch: device %s: %v", d.ID, err)
+			continue
+		}
+		if !enabled {
+			continue
+		}
+		if err := Launch(ctx, l.client, d, lc); err != nil {
+			log.Printf("launch: device %s: %v", d.ID, err)
+			continue
+		}
+		log.Printf("launch: started %s on device %s", lc.AppID, d.ID)
+	}
+}
+
+// Launch asks the device to start lc's channel over ECP, deep-linking into
+// its content when set.
+func Launch(ctx context.Context, client *http.Client, d config.DeviceConfig, lc config.LaunchConfig) error {
+	u := strings.TrimRight(d.BaseURL, "/") + "/launch/" + url.PathEscape(lc.AppID)
+	q := url.Values{}
+	if lc.ContentID != "" {
+		q.Set("contentId", lc.ContentID)
+	}
+	if lc.MediaType != "" {
+		q.Set("mediaType", lc.MediaType)
+	}
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("launch %s: %w", lc.AppID, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("launch %s: status %d", lc.AppID, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package enforce
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/config"
+)
+
+// Notification is the body POSTed to a device's notify_path.
+type Notification struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	Urgent  bool   `json:"urgent,omitempty"`
+	// Timestamp lets the agent refuse a replayed notification.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// signatureHeader carries the HMAC-SHA256 of a body POSTed to an agent,
+// keyed with the device's token, as "sha256=<hex>". Agents refuse
+// notifications and blocks without it unless they run with mtls.
+const signatureHeader = "X-Screentime-Signature"
+
+// NotifyDevice sends n to the device's notify_path. Devices without one
+// are skipped.
+func NotifyDevice(ctx context.Context, client *http.Client, d config.DeviceConfig, n Notification) error {
+	if d.NotifyPath == "" {
+		return nil
+	}
+	n.Timestamp = time.Now().UTC()
+	if err := postJSON(ctx, client, strings.TrimRight(d.BaseURL, "/")+d.NotifyPath, d.Token, n); err != nil {
+		return fmt.Errorf("post notification: %w", err)
+	}
+	return nil
+}
+
+// postJSON POSTs v as JSON to url, signed with token when there is one,
+// failing on a non-2xx status.
+func postJSON(ctx context.Context, client *http.Client, url, token string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		mac := hmac.New(sha256.New, []byte(token))
+		mac.Write(body)
+		req.Header.Set(signatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Package hooks runs the commands configured in hooks on session and
+// limit events, handing each the event as JSON on stdin.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/enforce"
+	"screentime-agent/internal/storage"
+)
+
+// defaultTimeout bounds a hook run when timeout_seconds isn't set.
+const defaultTimeout = 10 * time.Second
+
+// Event is the payload a hook receives. Exactly one of Session and Limit
+// is set, matching Event.
+type Event struct {
+	// Event is "session_start", "session_end" or "limit".
+	Event   string                `json:"event"`
+	Time    time.Time             `json:"time"`
+	Session *storage.SessionEvent `json:"session,omitempty"`
+	Limit   *enforce.Enforcement  `json:"limit,omitempty"`
+}
+
+// Runner runs the configured hooks. Each run happens in the background so
+// a slow script never holds up polling or enforcement.
+type Runner struct {
+	hooks []config.HookConfig
+}
+
+// NewRunner returns a runner for cfg's hooks.
+func NewRunner(cfg *config.Config) *Runner {
+	return &Runner{hooks: cfg.Hooks}
+}
+
+// SessionEvent runs the hooks for a session starting or ending. It fits
+// storage.SessionStore.OnSessionEvent.
+func (r *Runner) SessionEvent(e storage.SessionEvent) {
+	t := e.StartTime
+	if e.EndTime != nil {
+		t = *e.EndTime
+	}
+	r.fire(Event{Event: e.Type, Time: t, Session: &e})
+}
+
+// Enforce runs the hooks for an enforced limit or screen-free period, as
+// an enforce.Enforcer.
+func (r *Runner) Enforce(ctx context.Context, e enforce.Enforcement) error {
+	r.fire(Event{Event: config.HookLimit, Time: e.Time, Limit: &e})
+	return nil
+}
+
+func (r *Runner) fire(e Event) {
+	var body []byte
+	for _, h := range r.hooks {
+		if !h.Wants(e.Event) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(e); err != nil {
+				log.Printf("hooks: marshal %s event: %v", e.Event, err)
+				return
+			}
+		}
+		go func(h config.HookConfig) {
+			if err := run(h, body); err != nil {
+				log.Printf("hooks: %v", err)
+			}
+		}(h)
+	}
+}
+
+// run runs one hook with body on stdin.
+func run(h config.HookConfig, body []byte) error {
+	timeout := defaultTimeout
+	if h.TimeoutSeconds > 0 {
+		timeout = time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if out = bytes.TrimSpace(out); len(out) > 0 {
+			return fmt.Errorf("run %s: %w: %s", h.Command[0], err, out)
+		}
+		return fmt.Errorf("run %s: %w", h.Command[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"net/http"
+	"time"
+)
+
+func (s *Server) handleApps(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var deviceID *string
+	if v := r.URL.Query().Get("device_id"); v != "" {
+		deviceID = &v
+	}
+
+	apps, err := s.store.GetApps(ctx, deviceID)
+	if err != nil {
+		writeInternalError(w, "failed to get apps", err)
+		return
+	}
+
+	type appResponse struct {
+		DeviceID     string    `json:"device_id"`
+		AppID        string    `json:"app_id"`
+		AppName      string    `json:"app_name"`
+		FirstSeen    time.Time `json:"first_seen"`
+		LastSeen     time.Time `json:"last_seen"`
+		TotalSeconds int64     `json:"total_seconds"`
+	}
+
+	resp := struct {
+		Apps []appResponse `json:"apps"`
+	}{}
+
+	for _, a := range apps {
+		resp.Apps = append(resp.Apps, appResponse{
+			DeviceID:     a.DeviceID,
+			AppID:        a.AppID,
+			AppName:      a.AppName,
+			FirstSeen:    a.FirstSeen,
+			LastSeen:     a.LastSeen,
+			TotalSeconds: a.TotalSeconds,
+		})
+	}
+
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleAppNames(w http.ResponseWriter, r *http.Request) {
+	names, err := s.store.GetAppNames(r.Context(), r.PathValue("device"), r.PathValue("app"))
+	if err != nil {
+		writeInternalError(w, "failed to get app names", err)
+		return
+	}
+	if len(names) == 0 {
+		writeNotFound(w, "app not found")
+		return
+	}
+
+	type nameResponse struct {
+		AppName   string    `json:"app_name"`
+		FirstSeen time.Time `json:"first_seen"`
+		LastSeen  time.Time `json:"last_seen"`
+	}
+
+	resp := struct {
+		Names []nameResponse `json:"names"`
+	}{}
+
+	for _, n := range names {
+		resp.Names = append(resp.Names, nameResponse{
+			AppName:   n.AppName,
+			FirstSeen: n.FirstSeen,
+			LastSeen:  n.LastSeen,
+		})
+	}
+
+	writeJSON(w, resp)
+}
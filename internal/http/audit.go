@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/storage"
+)
+
+// actorHeader names who is making an administrative request. Clients such
+// as a parent's dashboard should set it; without it the name of the API key
+// used, or else the remote address, is recorded.
+const actorHeader = "X-Screentime-Actor"
+
+func requestActor(r *http.Request) string {
+	if v := strings.TrimSpace(r.Header.Get(actorHeader)); v != "" {
+		return v
+	}
+	if name := apiKeyName(r); name != "" {
+		return name
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// audit records an administrative action taken by r. Failures are logged
+// rather than failing a change that has already been made.
+func (s *Server) audit(ctx context.Context, r *http.Request, action, target string, details map[string]string) {
+	e := storage.AuditEntry{
+		Time:    time.Now(),
+		Actor:   requestActor(r),
+		Action:  action,
+		Target:  target,
+		Details: details,
+	}
+	if err := s.store.RecordAudit(ctx, e); err != nil {
+		log.Printf("audit %s %s by %s error: %v", action, target, e.Actor, err)
+	}
+}
+
+type auditResponse struct {
+	ID      int64             `json:"id"`
+	Time    time.Time         `json:"time"`
+	Actor   string            `json:"actor"`
+	Action  string            `json:"action"`
+	Target  string            `json:"target"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// handleAudit lists administrative actions, newest first. It accepts since
+// and until (RFC3339), actor and limit (default 100).
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	parseTimePtr := func(name string) (*time.Time, bool) {
+		v := q.Get(name)
+		if v == "" {
+			return nil, true
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeInvalidParameter(w, name)
+			return nil, false
+		}
+		return &t, true
+	}
+	since, ok := parseTimePtr("since")
+	if !ok {
+		return
+	}
+	until, ok := parseTimePtr("until")
+	if !ok {
+		return
+	}
+
+	var actor *string
+	if v := q.Get("actor"); v != "" {
+		actor = &v
+	}
+
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeInvalidParameter(w, "limit")
+			return
+		}
+		limit = n
+	}
+
+	loc, err := s.requestLocation(r)
+	if err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
+
+	entries, err := s.store.GetAuditLog(r.Context(), since, until, actor, limit)
+	if err != nil {
+		writeInternalError(w, "failed to get audit log", err)
+		return
+	}
+
+	resp := struct {
+		Entries []auditResponse `json:"entries"`
+	}{Entries: []auditResponse{}}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, auditResponse{
+			ID:      e.ID,
+			Time:    e.Time.In(loc),
+			Actor:   e.Actor,
+			Action:  e.Action,
+			Target:  e.Target,
+			Details: e.Details,
+		})
+	}
+	writeJSONFields(w, r, resp)
+}
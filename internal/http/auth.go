@@ -0,0 +1,59 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"screentime-agent/internal/config"
+)
+
+// apiKeyHeader is an alternative to "Authorization: Bearer" for clients
+// that already use that header for something else.
+const apiKeyHeader = "X-API-Key"
+
+// ownAuthRoutes check credentials of their own rather than an API key: a
+// user's token for /me, a device's signature for /ingest and an
+// enrollment token for /enroll.
+var ownAuthRoutes = map[string]bool{
+	"/me":          true,
+	"/me/view":     true,
+	"POST /ingest": true,
+	"POST /enroll": true,
+}
+
+type apiKeyContextKey struct{}
+
+// apiKeyName returns the name of the API key r was authorized with, if
+// any.
+func apiKeyName(r *http.Request) string {
+	name, _ := r.Context().Value(apiKeyContextKey{}).(string)
+	return name
+}
+
+// requireScope wraps the handler for pattern so that, once API keys are
+// configured, it only runs for requests carrying a key with scope. Admin
+// keys satisfy any scope. Browsers can't set headers on EventSource or
+// WebSocket requests, so the key may also be given as ?token=.
+func (s *Server) requireScope(pattern, scope string, handler http.HandlerFunc) http.HandlerFunc {
+	if len(s.cfg.APIKeys) == 0 || ownAuthRoutes[pattern] {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimSpace(r.Header.Get(apiKeyHeader))
+		if token == "" {
+			token = bearerToken(r)
+		}
+		k, ok := s.cfg.APIKeyByToken(token)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="screentime"`)
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid or missing API key", nil)
+			return
+		}
+		if scope == config.ScopeAdmin && k.Scope != config.ScopeAdmin {
+			writeError(w, http.StatusForbidden, codeForbidden, "API key "+k.Name+" is read-only", nil)
+			return
+		}
+		handler(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, k.Name)))
+	}
+}
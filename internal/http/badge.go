@@ -0,0 +1,69 @@
+package http
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+)
+
+func (s *Server) handleBadge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := strings.CutSuffix(r.PathValue("file"), ".svg")
+	if !ok {
+		writeNotFound(w, "not found")
+		return
+	}
+	u, ok := s.cfg.User(userID)
+	if !ok {
+		writeNotFound(w, "unknown user")
+		return
+	}
+
+	dayStart, nowLocal := s.cfg.ComputeDayWindow(ctx, s.loc)
+	totals, err := s.userCategoryTotals(ctx, u, dayStart.UTC(), nowLocal.UTC())
+	if err != nil {
+		writeInternalError(w, "failed to compute usage", err)
+		return
+	}
+	var total int64
+	for _, secs := range totals {
+		total += secs
+	}
+
+	label := "screen time today"
+	if name := r.URL.Query().Get("label"); name != "" {
+		label = name
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	_, _ = w.Write([]byte(renderBadge(label, s.format.Duration(total), "#4c1")))
+}
+
+// renderBadge draws a shields.io-style flat badge. Text widths are estimated
+// from character count, which is close enough for short ASCII labels.
+func renderBadge(label, value, color string) string {
+	const charW, pad = 7, 10
+	lw := len(label)*charW + pad
+	vw := len(value)*charW + pad
+	total := lw + vw
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>
+<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>
+</g>
+</svg>
+`,
+		total, html.EscapeString(label), html.EscapeString(value),
+		total,
+		lw, lw, vw, color, total,
+		lw/2, html.EscapeString(label), lw/2, html.EscapeString(label),
+		lw+vw/2, html.EscapeString(value), lw+vw/2, html.EscapeString(value),
+	)
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"bytes"
+	"image/color"
+	"net/http"
+	"sort"
+
+	"screentime-agent/internal/chart"
+)
+
+func (s *Server) buildDailyChart(r *http.Request) (*chart.Chart, error) {
+	ctx := r.Context()
+	dayStart, nowLocal := s.cfg.ComputeDayWindow(ctx, s.loc)
+
+	entries, err := s.store.GetUsageBetween(ctx, dayStart.UTC(), nowLocal.UTC(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	perDevice := make(map[string]map[string]int64)
+	for _, e := range entries {
+		cat := s.categories.Categorize(e.AppID, e.AppName)
+		if perDevice[e.DeviceID] == nil {
+			perDevice[e.DeviceID] = make(map[string]int64)
+		}
+		perDevice[e.DeviceID][cat] += e.TotalSeconds
+	}
+
+	c := &chart.Chart{
+		Title:  "Screen time " + s.format.Day(dayStart),
+		Colors: make(map[string]color.RGBA),
+		Format: func(v float64) string { return s.format.Duration(int64(v)) },
+	}
+	for name, d := range s.runner.CategoryDisplays() {
+		if col, ok := chart.ParseColor(d.Color); ok {
+			c.Colors[name] = col
+		}
+	}
+
+	for _, d := range s.cfg.Devices {
+		bar := chart.Bar{Label: d.ID}
+		cats := perDevice[d.ID]
+		names := make([]string, 0, len(cats))
+		for name := range cats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			bar.Segments = append(bar.Segments, chart.Segment{Label: name, Value: float64(cats[name])})
+		}
+		c.Bars = append(c.Bars, bar)
+	}
+
+	return c, nil
+}
+
+func (s *Server) handleDailyChartPNG(w http.ResponseWriter, r *http.Request) {
+	c, err := s.buildDailyChart(r)
+	if err != nil {
+		writeInternalError(w, "failed to compute usage", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := c.RenderPNG(&buf); err != nil {
+		writeInternalError(w, "failed to render chart", err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (s *Server) handleDailyChartSVG(w http.ResponseWriter, r *http.Request) {
+	c, err := s.buildDailyChart(r)
+	if err != nil {
+		writeInternalError(w, "failed to compute usage", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := c.RenderSVG(&buf); err != nil {
+		writeInternalError(w, "failed to render chart", err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// confirmTTL is how long a confirmation token stays valid.
+const confirmTTL = 5 * time.Minute
+
+// enrollTTL is how long an agent enrollment token stays valid; long enough
+// to walk over to the machine and run the enroll command.
+const enrollTTL = 30 * time.Minute
+
+type pendingConfirm struct {
+	token   string
+	expires time.Time
+}
+
+// confirmations hands out single-use tokens that destructive requests must
+// echo back, so a stray DELETE can't wipe data on its own. Agent
+// enrollment tokens work the same way.
+type confirmations struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	pending map[string]pendingConfirm // keyed by action, e.g. "delete-data:tv"
+}
+
+func newConfirmations(ttl time.Duration) *confirmations {
+	return &confirmations{ttl: ttl, pending: make(map[string]pendingConfirm)}
+}
+
+// issue creates a token for action, replacing any earlier one.
+func (c *confirmations) issue(action string, now time.Time) (string, time.Time) {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	p := pendingConfirm{token: hex.EncodeToString(b), expires: now.Add(c.ttl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[action] = p
+	return p.token, p.expires
+}
+
+// consume reports whether token is the live token for action and, if so,
+// invalidates it.
+func (c *confirmations) consume(action, token string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.pending[action]
+	if !ok || now.After(p.expires) {
+		delete(c.pending, action)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(p.token), []byte(token)) != 1 {
+		return false
+	}
+	delete(c.pending, action)
+	return true
+}
@@ -0,0 +1,234 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/storage"
+)
+
+// maxDisplayNameLength bounds a display name set through the API, in
+// characters.
+const maxDisplayNameLength = 64
+
+type deviceResponse struct {
+	DeviceID            string            `json:"device_id"`
+	DisplayName         string            `json:"display_name,omitempty"`
+	BaseURL             string            `json:"base_url,omitempty"`
+	PollIntervalSeconds float64           `json:"poll_interval_seconds,omitempty"`
+	Tags                []string          `json:"tags,omitempty"`
+	Enabled             bool              `json:"enabled"`
+	InConfig            bool              `json:"in_config"`
+	Source              string            `json:"source,omitempty"`
+	FirstSeen           *time.Time        `json:"first_seen,omitempty"`
+	LastSeen            *time.Time        `json:"last_seen,omitempty"`
+	Metadata            map[string]string `json:"metadata,omitempty"`
+}
+
+func (s *Server) findDevice(id string) (config.DeviceConfig, bool) {
+	return s.cfg.Device(id)
+}
+
+func (s *Server) newDeviceResponse(reg storage.Device) deviceResponse {
+	dr := deviceResponse{
+		DeviceID:    reg.DeviceID,
+		Enabled:     reg.Enabled,
+		Source:      reg.Source,
+		FirstSeen:   reg.FirstSeen,
+		LastSeen:    reg.LastSeen,
+		Metadata:    reg.Metadata,
+		DisplayName: reg.DisplayName,
+	}
+	if d, ok := s.findDevice(reg.DeviceID); ok {
+		if dr.DisplayName == "" {
+			dr.DisplayName = d.DisplayName
+		}
+		dr.InConfig = true
+		dr.BaseURL = d.BaseURL
+		dr.PollIntervalSeconds = d.PollIntervalSeconds
+		dr.Tags = d.Tags
+	}
+	return dr
+}
+
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	devices, err := s.store.GetDevices(ctx)
+	if err != nil {
+		writeInternalError(w, "failed to get devices", err)
+		return
+	}
+
+	resp := struct {
+		Devices []deviceResponse `json:"devices"`
+	}{}
+
+	for _, d := range devices {
+		resp.Devices = append(resp.Devices, s.newDeviceResponse(d))
+	}
+
+	writeJSON(w, resp)
+}
+
+func (s *Server) handlePatchDevice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+
+	if _, ok := s.findDevice(id); !ok {
+		writeNotFound(w, "unknown device")
+		return
+	}
+
+	// An empty display_name goes back to the config's.
+	var req struct {
+		Enabled     *bool   `json:"enabled"`
+		DisplayName *string `json:"display_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if req.DisplayName != nil {
+		*req.DisplayName = strings.TrimSpace(*req.DisplayName)
+		if utf8.RuneCountInString(*req.DisplayName) > maxDisplayNameLength {
+			writeError(w, http.StatusBadRequest, codeBadRequest,
+				"display_name must be at most "+strconv.Itoa(maxDisplayNameLength)+" characters", nil)
+			return
+		}
+	}
+
+	if req.Enabled != nil {
+		if err := s.store.SetDeviceEnabled(ctx, id, *req.Enabled); err != nil {
+			writeInternalError(w, "failed to update device", err)
+			return
+		}
+		action := "device.disable"
+		if *req.Enabled {
+			action = "device.enable"
+		}
+		s.audit(ctx, r, action, id, nil)
+	}
+	if req.DisplayName != nil {
+		err := s.store.SetDeviceDisplayName(ctx, id, *req.DisplayName)
+		s.forgetDeviceNames()
+		if err != nil {
+			writeInternalError(w, "failed to update device", err)
+			return
+		}
+		s.audit(ctx, r, "device.rename", id, map[string]string{"display_name": *req.DisplayName})
+	}
+
+	devices, err := s.store.GetDevices(ctx)
+	if err != nil {
+		writeInternalError(w, "failed to get device", err)
+		return
+	}
+	for _, d := range devices {
+		if d.DeviceID == id {
+			writeJSON(w, s.newDeviceResponse(d))
+			return
+		}
+	}
+	writeNotFound(w, "unknown device")
+}
+
+// handleDeleteDeviceData removes all usage data for a device. The first
+// request returns 428 with a confirm token; repeating it with
+// ?confirm=<token> within a few minutes performs the deletion.
+func (s *Server) handleDeleteDeviceData(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+
+	devices, err := s.store.GetDevices(ctx)
+	if err != nil {
+		writeInternalError(w, "failed to get devices", err)
+		return
+	}
+	known := false
+	for _, d := range devices {
+		if d.DeviceID == id {
+			known = true
+			break
+		}
+	}
+	if !known {
+		writeNotFound(w, "unknown device")
+		return
+	}
+
+	action := "delete-data:" + id
+	now := time.Now()
+	token := r.URL.Query().Get("confirm")
+	if token == "" {
+		token, expires := s.confirms.issue(action, now)
+		writeError(w, http.StatusPreconditionRequired, codeConfirmRequired,
+			"repeat the request with ?confirm= set to confirm_token to delete all data for "+id,
+			map[string]any{"confirm_token": token, "expires_at": expires.UTC()})
+		return
+	}
+	if !s.confirms.consume(action, token, now) {
+		writeInvalidParameter(w, "confirm")
+		return
+	}
+
+	deleted, err := s.store.DeleteDeviceData(ctx, id)
+	if err != nil {
+		writeInternalError(w, "failed to delete device data", err)
+		return
+	}
+	log.Printf("deleted all data for device %s: %v", id, deleted)
+
+	details := make(map[string]string, len(deleted))
+	for table, n := range deleted {
+		details[table] = strconv.FormatInt(n, 10)
+	}
+	s.audit(ctx, r, "device.delete_data", id, details)
+
+	resp := struct {
+		DeviceID string           `json:"device_id"`
+		Deleted  map[string]int64 `json:"deleted"`
+	}{
+		DeviceID: id,
+		Deleted:  deleted,
+	}
+	writeJSON(w, resp)
+}
+
+// deviceNames returns each device's display name: the one set through
+// PATCH /devices/{id}, else the config's. Devices with neither are left
+// out. The names are cached until a rename, so /status and /ws ticks
+// don't query for them; callers must not modify the map.
+func (s *Server) deviceNames(ctx context.Context) (map[string]string, error) {
+	s.namesMu.Lock()
+	defer s.namesMu.Unlock()
+	if s.names != nil {
+		return s.names, nil
+	}
+
+	names, err := s.store.GetDeviceDisplayNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range s.cfg.Devices {
+		if _, ok := names[d.ID]; !ok && d.DisplayName != "" {
+			names[d.ID] = d.DisplayName
+		}
+	}
+	s.names = names
+	return names, nil
+}
+
+// forgetDeviceNames drops the cached display names after a rename.
+func (s *Server) forgetDeviceNames() {
+	s.namesMu.Lock()
+	s.names = nil
+	s.namesMu.Unlock()
+}
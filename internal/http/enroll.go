@@ -0,0 +1,96 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"screentime-agent/internal/tlsutil"
+)
+
+// handleEnrollment starts enrolling a device's agent for mutual TLS. It
+// returns a single-use token for the agent's enroll command, and the
+// fingerprint of the hub's CA for the agent to check what it receives
+// against.
+func (s *Server) handleEnrollment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.findDevice(id); !ok {
+		writeNotFound(w, "unknown device")
+		return
+	}
+	ca, err := s.deviceCA(true)
+	if err != nil {
+		writeInternalError(w, "failed to create device CA", err)
+		return
+	}
+
+	token, expires := s.enrolls.issue("enroll:"+id, time.Now())
+	s.audit(r.Context(), r, "device.enrollment", id, nil)
+
+	writeJSON(w, struct {
+		DeviceID      string    `json:"device_id"`
+		Token         string    `json:"token"`
+		ExpiresAt     time.Time `json:"expires_at"`
+		CAFingerprint string    `json:"ca_fingerprint"`
+	}{
+		DeviceID:      id,
+		Token:         token,
+		ExpiresAt:     expires.UTC(),
+		CAFingerprint: ca.Fingerprint(),
+	})
+}
+
+// handleEnroll is called by an agent's enroll command with the token from
+// handleEnrollment and a certificate request. It answers with the agent's
+// certificate, naming the device, and the CA certificate, which the agent
+// then requires of every client. The token is the only credential.
+func (s *Server) handleEnroll(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DeviceID string `json:"device_id"`
+		Token    string `json:"token"`
+		CSR      string `json:"csr"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid request body", err.Error())
+		return
+	}
+	ca, _ := s.deviceCA(false)
+	if ca == nil || !s.enrolls.consume("enroll:"+req.DeviceID, req.Token, time.Now()) {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid or expired enrollment token", nil)
+		return
+	}
+
+	cert, err := ca.SignAgent([]byte(req.CSR), req.DeviceID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid certificate request", err.Error())
+		return
+	}
+	s.audit(r.Context(), r, "device.enroll", req.DeviceID, nil)
+
+	writeJSON(w, struct {
+		DeviceID    string `json:"device_id"`
+		Certificate string `json:"certificate"`
+		CA          string `json:"ca"`
+	}{
+		DeviceID:    req.DeviceID,
+		Certificate: string(cert),
+		CA:          string(ca.CertPEM()),
+	})
+}
+
+// deviceCA returns the hub's device CA, creating it in the data directory
+// when create is set and there is none yet. Devices polled over mutual TLS
+// only present the hub's certificate from the CA once the hub restarts.
+func (s *Server) deviceCA(create bool) (*tlsutil.CA, error) {
+	s.caMu.Lock()
+	defer s.caMu.Unlock()
+	if s.cfg.DeviceCA != nil || !create {
+		return s.cfg.DeviceCA, nil
+	}
+	ca, err := tlsutil.LoadCA(s.cfg.DataDir())
+	if err != nil {
+		return nil, err
+	}
+	s.cfg.DeviceCA = ca
+	return ca, nil
+}
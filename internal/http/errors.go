@@ -0,0 +1,45 @@
+package http
+
+import (
+	"log"
+	"net/http"
+)
+
+// Error codes used in apiError.Code. Clients should switch on these rather
+// than on messages.
+const (
+	codeBadRequest       = "bad_request"
+	codeInvalidParameter = "invalid_parameter"
+	codeNotFound         = "not_found"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeConfirmRequired  = "confirmation_required"
+	codeInternal         = "internal"
+)
+
+// apiError is the body of every error response.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string, details any) {
+	writeJSONStatus(w, status, apiError{Code: code, Message: message, Details: details})
+}
+
+// writeInternalError logs err and responds with a generic 500; internal
+// details stay in the log rather than the response.
+func writeInternalError(w http.ResponseWriter, message string, err error) {
+	log.Printf("%s: %v", message, err)
+	writeError(w, http.StatusInternalServerError, codeInternal, message, nil)
+}
+
+func writeInvalidParameter(w http.ResponseWriter, name string) {
+	writeError(w, http.StatusBadRequest, codeInvalidParameter, "invalid "+name+" parameter",
+		map[string]string{"parameter": name})
+}
+
+func writeNotFound(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusNotFound, codeNotFound, message, nil)
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type exceptionResponse struct {
+	DeviceID       string    `json:"device_id"`
+	DisplayName    string    `json:"display_name,omitempty"`
+	AppID          string    `json:"app_id"`
+	AppName        string    `json:"app_name"`
+	StartTime      time.Time `json:"start_time"`
+	ExceptionLabel string    `json:"exception_label"`
+}
+
+// handlePutException marks the device's running session as an approved
+// exception (e.g. a family movie) so it doesn't count toward limits. The
+// body is {"label": "..."}; the label shows up in reports.
+func (s *Server) handlePutException(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Label string `json:"label"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid request body", err.Error())
+		return
+	}
+	req.Label = strings.TrimSpace(req.Label)
+	if req.Label == "" {
+		req.Label = "approved"
+	}
+	s.setException(w, r, req.Label)
+}
+
+// handleDeleteException removes the approved-exception mark from the
+// device's running session.
+func (s *Server) handleDeleteException(w http.ResponseWriter, r *http.Request) {
+	s.setException(w, r, "")
+}
+
+func (s *Server) setException(w http.ResponseWriter, r *http.Request, label string) {
+	id := r.PathValue("id")
+	if _, ok := s.findDevice(id); !ok {
+		writeNotFound(w, "unknown device")
+		return
+	}
+
+	cs, ok, err := s.store.SetCurrentException(r.Context(), id, label)
+	if err != nil {
+		writeInternalError(w, "failed to update session", err)
+		return
+	}
+	if !ok {
+		writeNotFound(w, "no running session")
+		return
+	}
+
+	details := map[string]string{"app_id": cs.AppID, "app_name": cs.AppName}
+	if label == "" {
+		s.audit(r.Context(), r, "exception.clear", id, details)
+	} else {
+		details["label"] = label
+		s.audit(r.Context(), r, "exception.set", id, details)
+	}
+	names, err := s.deviceNames(r.Context())
+	if err != nil {
+		writeInternalError(w, "failed to get device names", err)
+		return
+	}
+	writeJSON(w, exceptionResponse{
+		DeviceID:       cs.DeviceID,
+		DisplayName:    names[cs.DeviceID],
+		AppID:          cs.AppID,
+		AppName:        cs.AppName,
+		StartTime:      cs.StartTime.In(s.loc),
+		ExceptionLabel: cs.ExceptionLabel,
+	})
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// rescueTimeHeader matches the CSV from RescueTime's Analytic Data API with
+// perspective=interval and resolution_time=hour.
+var rescueTimeHeader = []string{"Date", "Time Spent (seconds)", "Number of People", "Activity", "Category", "Productivity"}
+
+// handleRescueTimeCSV exports hourly per-app usage in RescueTime's CSV
+// format, so scripts written against RescueTime exports keep working.
+// Activity is the app name; productivity comes from the category config.
+func (s *Server) handleRescueTimeCSV(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	var deviceID *string
+	if v := q.Get("device_id"); v != "" {
+		deviceID = &v
+	}
+
+	loc, err := s.requestLocation(r)
+	if err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
+
+	period := q.Get("period")
+	if period == "" {
+		period = "last7d"
+	}
+	start, end, err := resolvePeriod(period, time.Now().In(loc), s.cfg.DayStartHour, s.cfg.FirstWeekday())
+	if err != nil {
+		writeInvalidParameter(w, "period")
+		return
+	}
+
+	buckets, err := makeBuckets(start, end, "hour", s.cfg.DayStartHour)
+	if err != nil {
+		writeInvalidParameter(w, "period")
+		return
+	}
+	rows, err := s.store.GetUsageBuckets(ctx, buckets, deviceID)
+	if err != nil {
+		writeInternalError(w, "failed to compute usage", err)
+		return
+	}
+
+	// RescueTime has one row per activity per hour, so merge the same app
+	// across devices.
+	type key struct {
+		bucket   int
+		activity string
+	}
+	totals := make(map[key]int64)
+	categories := make(map[key]string)
+	for _, row := range rows {
+		k := key{row.Bucket, row.AppName}
+		totals[k] += row.TotalSeconds
+		if _, ok := categories[k]; !ok {
+			categories[k] = s.categories.Categorize(row.AppID, row.AppName)
+		}
+	}
+
+	keys := make([]key, 0, len(totals))
+	for k, secs := range totals {
+		if secs > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].bucket != keys[j].bucket {
+			return keys[i].bucket < keys[j].bucket
+		}
+		if totals[keys[i]] != totals[keys[j]] {
+			return totals[keys[i]] > totals[keys[j]]
+		}
+		return keys[i].activity < keys[j].activity
+	})
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+		"screentime-rescuetime-"+start.Format("2006-01-02")+".csv"))
+
+	cw := csv.NewWriter(w)
+	cw.Write(rescueTimeHeader)
+	for _, k := range keys {
+		cat := categories[k]
+		cw.Write([]string{
+			buckets[k.bucket].Start.Format("2006-01-02T15:04:05"),
+			strconv.FormatInt(totals[k], 10),
+			"1",
+			k.activity,
+			cat,
+			strconv.Itoa(s.cfg.Categories[cat].Productivity),
+		})
+	}
+	cw.Flush()
+}
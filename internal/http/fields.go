@@ -0,0 +1,71 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// writeJSONFields writes v, trimmed to the columns named in the fields query
+// parameter. Only objects inside arrays (the records) are trimmed; envelope
+// keys and nested record lists are kept so the response shape is unchanged.
+// Field names match case-insensitively with underscores ignored, so
+// "end_reason" selects both end_reason and EndReason.
+func writeJSONFields(w http.ResponseWriter, r *http.Request, v any) {
+	raw := r.URL.Query().Get("fields")
+	if raw == "" {
+		writeJSON(w, v)
+		return
+	}
+
+	keep := make(map[string]bool)
+	for _, f := range strings.Split(raw, ",") {
+		if f = normalizeField(f); f != "" {
+			keep[f] = true
+		}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		writeInternalError(w, "failed to encode response", err)
+		return
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		writeInternalError(w, "failed to encode response", err)
+		return
+	}
+
+	writeJSON(w, pruneFields(generic, keep, false))
+}
+
+func normalizeField(f string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(f), "_", ""))
+}
+
+func pruneFields(v any, keep map[string]bool, inRecord bool) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if _, isList := child.([]any); isList {
+				t[k] = pruneFields(child, keep, false)
+				continue
+			}
+			if inRecord && !keep[normalizeField(k)] {
+				delete(t, k)
+				continue
+			}
+			if !inRecord {
+				t[k] = pruneFields(child, keep, false)
+			}
+		}
+		return t
+	case []any:
+		for i, child := range t {
+			t[i] = pruneFields(child, keep, true)
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type gapResponse struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Seconds int64     `json:"seconds"`
+	// Reason is why the session before the gap ended: "idle", "offline",
+	// "paused" and so on. Empty when nothing came before.
+	Reason string `json:"reason"`
+	// IdleReason says why the device went idle, e.g. "lock" or
+	// "no_input", when the session ended as idle for a known reason.
+	IdleReason string `json:"idle_reason,omitempty"`
+}
+
+// handleDeviceGaps lists the stretches a device had no activity, idle or
+// offline, over since/until (RFC 3339) or a named period (default today),
+// for checking e.g. that nothing ran overnight. Gaps shorter than
+// min_seconds (default 60) are left out. Hub downtime in the range is
+// listed separately as unmonitored, since nothing was watched then.
+func (s *Server) handleDeviceGaps(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	id := r.PathValue("id")
+
+	if _, ok := s.findDevice(id); !ok {
+		devices, err := s.store.GetDevices(ctx)
+		if err != nil {
+			writeInternalError(w, "failed to get devices", err)
+			return
+		}
+		known := false
+		for _, d := range devices {
+			if d.DeviceID == id {
+				known = true
+				break
+			}
+		}
+		if !known {
+			writeNotFound(w, "unknown device")
+			return
+		}
+	}
+
+	loc, err := s.requestLocation(r)
+	if err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
+	now := time.Now().In(loc)
+
+	start, end, err := resolvePeriod(q.Get("period"), now, s.cfg.DayStartHour, s.cfg.FirstWeekday())
+	if err != nil {
+		writeInvalidParameter(w, "period")
+		return
+	}
+	if v := q.Get("since"); v != "" {
+		if start, err = time.Parse(time.RFC3339, v); err != nil {
+			writeInvalidParameter(w, "since")
+			return
+		}
+		start = start.In(loc)
+	}
+	if v := q.Get("until"); v != "" {
+		if end, err = time.Parse(time.RFC3339, v); err != nil {
+			writeInvalidParameter(w, "until")
+			return
+		}
+		end = end.In(loc)
+	}
+	// What hasn't happened yet isn't a gap.
+	if end.After(now) {
+		end = now
+	}
+	if !start.Before(end) {
+		writeInvalidParameter(w, "since")
+		return
+	}
+
+	minSeconds := int64(60)
+	if v := q.Get("min_seconds"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			writeInvalidParameter(w, "min_seconds")
+			return
+		}
+		minSeconds = n
+	}
+
+	gaps, err := s.store.GetGaps(ctx, id, start, end)
+	if err != nil {
+		writeInternalError(w, "failed to get gaps", err)
+		return
+	}
+
+	resp := struct {
+		DeviceID     string        `json:"device_id"`
+		Start        time.Time     `json:"start"`
+		End          time.Time     `json:"end"`
+		Gaps         []gapResponse `json:"gaps"`
+		TotalSeconds int64         `json:"total_seconds"`
+		Unmonitored  []unmonitored `json:"unmonitored,omitempty"`
+	}{
+		DeviceID: id,
+		Start:    start,
+		End:      end,
+		Gaps:     []gapResponse{},
+	}
+	for _, g := range gaps {
+		secs := int64(g.End.Sub(g.Start).Seconds())
+		if secs < minSeconds {
+			continue
+		}
+		resp.Gaps = append(resp.Gaps, gapResponse{
+			Start:      g.Start.In(loc),
+			End:        g.End.In(loc),
+			Seconds:    secs,
+			Reason:     g.Reason,
+			IdleReason: g.IdleReason,
+		})
+		resp.TotalSeconds += secs
+	}
+
+	resp.Unmonitored, err = s.buildUnmonitored(ctx, start, end)
+	if err != nil {
+		writeInternalError(w, "failed to get hub downtime", err)
+		return
+	}
+
+	writeJSONFields(w, r, resp)
+}
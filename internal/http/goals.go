@@ -0,0 +1,110 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"screentime-agent/internal/config"
+)
+
+type goalProgress struct {
+	Category         string  `json:"category"`
+	Kind             string  `json:"kind"` // "max" or "min"
+	TargetMinutes    int     `json:"target_minutes"`
+	UsedSeconds      int64   `json:"used_seconds"`
+	ProgressPercent  float64 `json:"progress_percent"`
+	ProjectedMinutes float64 `json:"projected_minutes"`
+	Status           string  `json:"status"` // on_track, at_risk, exceeded, met
+}
+
+func (s *Server) handleGoalsProgress(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var deviceID *string
+	if v := r.URL.Query().Get("device_id"); v != "" {
+		deviceID = &v
+	}
+
+	loc, err := s.requestLocation(r)
+	if err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
+	dayStart, nowLocal := s.cfg.ComputeDayWindow(ctx, loc)
+
+	entries, err := s.store.GetUsageBetween(ctx, dayStart.UTC(), nowLocal.UTC(), deviceID)
+	if err != nil {
+		writeInternalError(w, "failed to compute usage", err)
+		return
+	}
+	totals := s.categories.Totals(entries)
+
+	elapsed := nowLocal.Sub(dayStart)
+	dayLength := dayStart.AddDate(0, 0, 1).Sub(dayStart)
+
+	resp := struct {
+		DayStart        time.Time                         `json:"day_start"`
+		Now             time.Time                         `json:"now"`
+		Goals           []goalProgress                    `json:"goals"`
+		CategoryDisplay map[string]config.CategoryDisplay `json:"category_display,omitempty"`
+	}{
+		DayStart:        dayStart,
+		Now:             nowLocal,
+		CategoryDisplay: s.runner.CategoryDisplays(),
+	}
+
+	for _, g := range s.cfg.Goals {
+		used := totals[g.Category]
+		if g.MaxMinutes > 0 {
+			resp.Goals = append(resp.Goals, newGoalProgress(g.Category, "max", g.MaxMinutes, used, elapsed, dayLength))
+		}
+		if g.MinMinutes > 0 {
+			resp.Goals = append(resp.Goals, newGoalProgress(g.Category, "min", g.MinMinutes, used, elapsed, dayLength))
+		}
+	}
+
+	writeJSON(w, resp)
+}
+
+// newGoalProgress projects today's usage linearly over the rest of the day.
+func newGoalProgress(category, kind string, targetMinutes int, usedSecs int64, elapsed, dayLength time.Duration) goalProgress {
+	used := float64(usedSecs) / 60
+	target := float64(targetMinutes)
+
+	projected := used
+	if elapsed > 0 {
+		projected = used * float64(dayLength) / float64(elapsed)
+	}
+
+	gp := goalProgress{
+		Category:         category,
+		Kind:             kind,
+		TargetMinutes:    targetMinutes,
+		UsedSeconds:      usedSecs,
+		ProgressPercent:  math.Round(used/target*1000) / 10,
+		ProjectedMinutes: math.Round(projected*10) / 10,
+	}
+
+	switch kind {
+	case "max":
+		switch {
+		case used >= target:
+			gp.Status = "exceeded"
+		case projected > target:
+			gp.Status = "at_risk"
+		default:
+			gp.Status = "on_track"
+		}
+	case "min":
+		switch {
+		case used >= target:
+			gp.Status = "met"
+		case projected >= target:
+			gp.Status = "on_track"
+		default:
+			gp.Status = "at_risk"
+		}
+	}
+	return gp
+}
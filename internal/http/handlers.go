@@ -4,29 +4,128 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"screentime-agent/internal/category"
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/locale"
 	"screentime-agent/internal/storage"
 )
 
-func (s *Server) registerRoutes(mux *http.ServeMux) {
-	var endpoints []string
+// apiPrefix is the current API version. Routes are also served at their
+// legacy unversioned paths, marked deprecated, until clients migrate.
+const apiPrefix = "/v1"
+
+// registerRoutes adds every route to mux. When readOnly is set, the
+// reporting routes are also added to it, for a listener untrusted clients
+// may reach.
+func (s *Server) registerRoutes(mux, readOnly *http.ServeMux) {
+	var endpoints, readOnlyEndpoints []string
+
+	add := func(pattern string, handler http.HandlerFunc) {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			method, path = "", pattern
+		} else {
+			method += " "
+		}
+		endpoints = append(endpoints, method+apiPrefix+path)
+		mux.HandleFunc(method+apiPrefix+path, handler)
+		mux.HandleFunc(method+path, deprecated(handler))
+	}
+
+	// Routes that change things need an admin key once API keys are
+	// configured.
+	register := func(pattern string, handler http.HandlerFunc) {
+		add(pattern, s.requireScope(pattern, config.ScopeAdmin, handler))
+	}
+
+	// Reporting routes change nothing, so they are also served read-only
+	// (GET and HEAD only, versioned paths only). The read-only listener
+	// is meant for the LAN without keys, so only the main mux checks
+	// scope.
+	registerReadOnly := func(pattern string, handler http.HandlerFunc) {
+		add(pattern, s.requireScope(pattern, config.ScopeRead, handler))
+		if readOnly == nil {
+			return
+		}
+		path := pattern
+		if _, p, ok := strings.Cut(pattern, " "); ok {
+			path = p
+		}
+		readOnlyEndpoints = append(readOnlyEndpoints, "GET "+apiPrefix+path)
+		readOnly.HandleFunc("GET "+apiPrefix+path, handler)
+	}
 
-	register := func(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	// Operational endpoints are not part of the versioned API.
+	registerUnversioned := func(pattern string, handler http.HandlerFunc) {
 		endpoints = append(endpoints, pattern)
 		mux.HandleFunc(pattern, handler)
 	}
 
-	register("/healthz", s.handleHealthz)
-	register("/status", s.handleStatus)
-	register("/sessions", s.handleSessions)
-	register("/usage/today", s.handleUsageToday)
+	registerUnversioned("/healthz", s.handleHealthz)
+	registerUnversioned("/metrics", s.requireScope("/metrics", config.ScopeRead, s.handleMetrics))
+
+	registerReadOnly("/status", s.handleStatus)
+	registerReadOnly("/sessions", s.handleSessions)
+	registerReadOnly("GET /sessions/{id}/titles", s.handleSessionTitles)
+	register("POST /sessions/recategorize", s.handleRecategorize)
+	registerReadOnly("/usage", s.handleUsage)
+	registerReadOnly("/usage/today", s.handleUsageToday)
+	registerReadOnly("GET /usage/trends", s.handleUsageTrends)
+	registerReadOnly("GET /users/{id}/sessions", s.handleUserSessions)
+	registerReadOnly("GET /users/{id}/usage", s.handleUserUsage)
+	registerReadOnly("GET /devices", s.handleDevices)
+	registerReadOnly("GET /devices/{id}/gaps", s.handleDeviceGaps)
+	register("PATCH /devices/{id}", s.handlePatchDevice)
+	register("POST /devices/{id}/enrollment", s.handleEnrollment)
+	register("POST /enroll", s.handleEnroll)
+	register("DELETE /devices/{id}/data", s.handleDeleteDeviceData)
+	register("PUT /devices/{id}/current/exception", s.handlePutException)
+	register("DELETE /devices/{id}/current/exception", s.handleDeleteException)
+	registerReadOnly("/apps", s.handleApps)
+	registerReadOnly("GET /apps/{device}/{app}/names", s.handleAppNames)
+	register("PUT /apps/{app}/category", s.handlePutAppCategory)
+	register("DELETE /apps/{app}/category", s.handleDeleteAppCategory)
+	registerReadOnly("/goals/progress", s.handleGoalsProgress)
+	registerReadOnly("GET /reports/weekly", s.handleWeeklyReport)
+	registerReadOnly("GET /reports/uncategorized", s.handleUncategorizedReport)
+	registerReadOnly("/me", s.handleMe)
+	registerReadOnly("/me/view", s.handleMeView)
+	registerReadOnly("/kiosk", s.handleKiosk)
+	registerReadOnly("/kiosk/events", s.handleKioskEvents)
+	registerReadOnly("GET /ws", s.handleWS)
+	registerReadOnly("/charts/daily.png", s.handleDailyChartPNG)
+	registerReadOnly("/charts/daily.svg", s.handleDailyChartSVG)
+	registerReadOnly("/badge/{file}", s.handleBadge)
+	registerReadOnly("GET /limits", s.handleLimits)
+	registerReadOnly("GET /household/summary", s.handleHouseholdSummary)
+	register("POST /limits/simulate", s.handleSimulateLimits)
+	register("GET /settings", s.handleSettings)
+	register("GET /audit", s.handleAudit)
+	register("/pollers", s.handlePollers)
+	register("POST /ingest", s.handleIngest)
+	register("GET /raw-polls", s.handleRawPolls)
+	registerReadOnly("GET /export/rescuetime.csv", s.handleRescueTimeCSV)
 
 	// Root endpoint lists all endpoints (including itself)
-	endpoints = append([]string{"/"}, endpoints...)
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
+	endpoints = append([]string{"/", apiPrefix + "/"}, endpoints...)
+	mux.HandleFunc("/", listEndpoints(endpoints))
+	mux.HandleFunc(apiPrefix+"/", listEndpoints(endpoints))
+
+	if readOnly != nil {
+		readOnlyEndpoints = append([]string{"GET /", "GET " + apiPrefix + "/"}, readOnlyEndpoints...)
+		readOnly.HandleFunc("GET /", listEndpoints(readOnlyEndpoints))
+		readOnly.HandleFunc("GET "+apiPrefix+"/", listEndpoints(readOnlyEndpoints))
+		readOnly.HandleFunc("GET /healthz", s.handleHealthz)
+	}
+}
+
+func listEndpoints(endpoints []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && r.URL.Path != apiPrefix+"/" {
+			writeNotFound(w, "not found")
 			return
 		}
 		resp := struct {
@@ -35,7 +134,16 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 			Endpoints: endpoints,
 		}
 		writeJSON(w, resp)
-	})
+	}
+}
+
+// deprecated wraps a legacy route, pointing clients at its versioned successor.
+func deprecated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+apiPrefix+r.URL.Path+">; rel=\"successor-version\"")
+		handler(w, r)
+	}
 }
 
 func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
@@ -45,18 +153,26 @@ func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	cur, err := s.store.GetCurrentSessions(ctx)
+	cur, err := s.status.get(ctx)
+	if err != nil {
+		writeInternalError(w, "failed to get status", err)
+		return
+	}
+	names, err := s.deviceNames(ctx)
 	if err != nil {
-		http.Error(w, "failed to get status", http.StatusInternalServerError)
+		writeInternalError(w, "failed to get device names", err)
 		return
 	}
 
 	resp := struct {
 		Devices []struct {
 			DeviceID     string    `json:"device_id"`
+			DisplayName  string    `json:"display_name,omitempty"`
+			Slot         string    `json:"slot,omitempty"`
 			AppID        string    `json:"app_id"`
 			AppName      string    `json:"app_name"`
 			State        string    `json:"state"`
+			IdleReason   string    `json:"idle_reason,omitempty"`
 			StartTime    time.Time `json:"start_time"`
 			LastSeenTime time.Time `json:"last_seen_time"`
 		} `json:"devices"`
@@ -65,16 +181,22 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	for _, cs := range cur {
 		resp.Devices = append(resp.Devices, struct {
 			DeviceID     string    `json:"device_id"`
+			DisplayName  string    `json:"display_name,omitempty"`
+			Slot         string    `json:"slot,omitempty"`
 			AppID        string    `json:"app_id"`
 			AppName      string    `json:"app_name"`
 			State        string    `json:"state"`
+			IdleReason   string    `json:"idle_reason,omitempty"`
 			StartTime    time.Time `json:"start_time"`
 			LastSeenTime time.Time `json:"last_seen_time"`
 		}{
 			DeviceID:     cs.DeviceID,
+			DisplayName:  names[cs.DeviceID],
+			Slot:         cs.Slot,
 			AppID:        cs.AppID,
 			AppName:      cs.AppName,
 			State:        cs.State,
+			IdleReason:   cs.IdleReason,
 			StartTime:    cs.StartTime,
 			LastSeenTime: cs.LastSeenTime,
 		})
@@ -84,13 +206,18 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	q := r.URL.Query()
-
 	var deviceID *string
-	if v := q.Get("device_id"); v != "" {
+	if v := r.URL.Query().Get("device_id"); v != "" {
 		deviceID = &v
 	}
+	s.serveSessions(w, r, deviceID, nil)
+}
+
+// serveSessions writes closed sessions matching the request's filters.
+// When keep is set, only sessions on devices it accepts are included.
+func (s *Server) serveSessions(w http.ResponseWriter, r *http.Request, deviceID *string, keep func(string) bool) {
+	ctx := r.Context()
+	q := r.URL.Query()
 
 	parseTimePtr := func(name string) (*time.Time, error) {
 		v := q.Get(name)
@@ -104,30 +231,77 @@ func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 		return &t, nil
 	}
 
-	since, err := parseTimePtr("since")
-	if err != nil {
-		http.Error(w, "invalid since parameter", http.StatusBadRequest)
+	filter := storage.SessionFilter{DeviceID: deviceID}
+	var err error
+	if filter.Since, err = parseTimePtr("since"); err != nil {
+		writeInvalidParameter(w, "since")
 		return
 	}
-	until, err := parseTimePtr("until")
+	if filter.Until, err = parseTimePtr("until"); err != nil {
+		writeInvalidParameter(w, "until")
+		return
+	}
+	if v := q.Get("min_duration"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			writeInvalidParameter(w, "min_duration")
+			return
+		}
+		filter.MinDuration = time.Duration(secs) * time.Second
+	}
+	if v := q.Get("category"); v != "" {
+		filter.Categories = []string{v}
+		// Sessions stored before categories were recorded have none.
+		if v == category.Uncategorized {
+			filter.Categories = append(filter.Categories, "")
+		}
+	}
+
+	loc, err := s.requestLocation(r)
 	if err != nil {
-		http.Error(w, "invalid until parameter", http.StatusBadRequest)
+		writeInvalidParameter(w, "tz")
 		return
 	}
 
-	sessions, err := s.store.GetSessions(ctx, deviceID, since, until)
+	sessions, err := s.store.GetSessions(ctx, filter)
 	if err != nil {
-		http.Error(w, "failed to get sessions", http.StatusInternalServerError)
+		writeInternalError(w, "failed to get sessions", err)
+		return
+	}
+	if keep != nil {
+		mine := sessions[:0]
+		for _, se := range sessions {
+			if keep(se.DeviceID) {
+				mine = append(mine, se)
+			}
+		}
+		sessions = mine
+	}
+	names, err := s.deviceNames(ctx)
+	if err != nil {
+		writeInternalError(w, "failed to get device names", err)
 		return
 	}
 
 	resp := struct {
-		Sessions []storage.Session `json:"sessions"`
+		Sessions []sessionResponse `json:"sessions"`
 	}{
-		Sessions: sessions,
+		Sessions: make([]sessionResponse, len(sessions)),
+	}
+	for i, se := range sessions {
+		se.StartTime = se.StartTime.In(loc)
+		se.EndTime = se.EndTime.In(loc)
+		resp.Sessions[i] = sessionResponse{Session: se, DisplayName: names[se.DeviceID]}
 	}
 
-	writeJSON(w, resp)
+	writeJSONFields(w, r, resp)
+}
+
+// sessionResponse is a closed session with its device's display name,
+// named like the session's own fields.
+type sessionResponse struct {
+	storage.Session
+	DisplayName string `json:",omitempty"`
 }
 
 func (s *Server) handleUsageToday(w http.ResponseWriter, r *http.Request) {
@@ -146,37 +320,79 @@ func (s *Server) handleUsageToday(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Temporarily override day_start_hour for this calculation
-	origHour := s.cfg.DayStartHour
-	s.cfg.DayStartHour = dayStartHour
-	dayStart, nowLocal := s.cfg.ComputeDayWindow(ctx, s.loc)
-	s.cfg.DayStartHour = origHour
+	loc, err := s.requestLocation(r)
+	if err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
 
-	dayStartUTC := dayStart.UTC()
-	nowUTC := nowLocal.UTC()
+	nowLocal := time.Now().In(loc)
+	dayStart := config.DayStartAt(nowLocal, dayStartHour)
 
-	entries, err := s.store.GetUsageBetween(ctx, dayStartUTC, nowUTC, deviceID)
+	entries, err := s.store.GetUsageBetween(ctx, dayStart.UTC(), nowLocal.UTC(), deviceID)
 	if err != nil {
-		http.Error(w, "failed to compute usage", http.StatusInternalServerError)
+		writeInternalError(w, "failed to compute usage", err)
 		return
 	}
 
-	// Group by device
-	type appUsage struct {
-		AppID        string `json:"app_id"`
-		AppName      string `json:"app_name"`
-		TotalSeconds int64  `json:"total_seconds"`
-		Duration     struct {
-			Hours   int64 `json:"hours"`
-			Minutes int64 `json:"minutes"`
-		} `json:"duration"`
+	names, err := s.deviceNames(ctx)
+	if err != nil {
+		writeInternalError(w, "failed to get device names", err)
+		return
 	}
+	devices := groupUsageByDevice(entries, s.format, names)
 
-	type deviceUsage struct {
-		DeviceID string      `json:"device_id"`
-		Apps     []appUsage  `json:"apps"`
+	resp := struct {
+		DayStart        time.Time                         `json:"day_start"`
+		Now             time.Time                         `json:"now"`
+		DeviceUsage     []deviceUsage                     `json:"device_usage"`
+		Current         []currentActivity                 `json:"current,omitempty"`
+		Unmonitored     []unmonitored                     `json:"unmonitored,omitempty"`
+		CategoryDisplay map[string]config.CategoryDisplay `json:"category_display,omitempty"`
+	}{
+		DayStart:        dayStart,
+		Now:             nowLocal,
+		DeviceUsage:     devices,
+		CategoryDisplay: s.runner.CategoryDisplays(),
 	}
 
+	resp.Unmonitored, err = s.buildUnmonitored(ctx, dayStart, nowLocal)
+	if err != nil {
+		writeInternalError(w, "failed to get hub downtime", err)
+		return
+	}
+
+	if q.Get("include") == "current" {
+		resp.Current, err = s.buildCurrentActivity(ctx, deviceID, dayStart, nowLocal, names)
+		if err != nil {
+			writeInternalError(w, "failed to get current activity", err)
+			return
+		}
+	}
+
+	writeJSONFields(w, r, resp)
+}
+
+type appUsage struct {
+	AppID        string `json:"app_id"`
+	AppName      string `json:"app_name"`
+	TotalSeconds int64  `json:"total_seconds"`
+	Duration     struct {
+		Hours   int64  `json:"hours"`
+		Minutes int64  `json:"minutes"`
+		Text    string `json:"text"` // in the configured locale
+	} `json:"duration"`
+}
+
+type deviceUsage struct {
+	DeviceID    string     `json:"device_id"`
+	DisplayName string     `json:"display_name,omitempty"`
+	Apps        []appUsage `json:"apps"`
+}
+
+// groupUsageByDevice groups usage entries per device, naming each device
+// from names.
+func groupUsageByDevice(entries []storage.UsageEntry, f locale.Formatter, names map[string]string) []deviceUsage {
 	deviceMap := make(map[string][]appUsage)
 
 	for _, e := range entries {
@@ -190,6 +406,7 @@ func (s *Server) handleUsageToday(w http.ResponseWriter, r *http.Request) {
 		}
 		au.Duration.Hours = hours
 		au.Duration.Minutes = minutes
+		au.Duration.Text = f.Duration(e.TotalSeconds)
 
 		deviceMap[e.DeviceID] = append(deviceMap[e.DeviceID], au)
 	}
@@ -197,26 +414,21 @@ func (s *Server) handleUsageToday(w http.ResponseWriter, r *http.Request) {
 	var devices []deviceUsage
 	for devID, apps := range deviceMap {
 		devices = append(devices, deviceUsage{
-			DeviceID: devID,
-			Apps:     apps,
+			DeviceID:    devID,
+			DisplayName: names[devID],
+			Apps:        apps,
 		})
 	}
-
-	resp := struct {
-		DayStart    time.Time    `json:"day_start"`
-		Now         time.Time    `json:"now"`
-		DeviceUsage []deviceUsage `json:"device_usage"`
-	}{
-		DayStart:    dayStart,
-		Now:         nowLocal,
-		DeviceUsage: devices,
-	}
-
-	writeJSON(w, resp)
+	return devices
 }
 
 func writeJSON(w http.ResponseWriter, v any) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 	_ = enc.Encode(v)
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/limits"
+	"screentime-agent/internal/storage"
+)
+
+type householdUser struct {
+	UserID       string           `json:"user_id"`
+	Name         string           `json:"name,omitempty"`
+	TodaySeconds int64            `json:"today_seconds"`
+	Remaining    []budgetResponse `json:"remaining"`
+	ScreenFree   bool             `json:"screen_free"`
+}
+
+type householdDevice struct {
+	DeviceID       string    `json:"device_id"`
+	DisplayName    string    `json:"display_name,omitempty"`
+	UserIDs        []string  `json:"user_ids"`
+	AppID          string    `json:"app_id"`
+	AppName        string    `json:"app_name"`
+	Category       string    `json:"category"`
+	StartTime      time.Time `json:"start_time"`
+	SessionSeconds int64     `json:"session_seconds"`
+}
+
+type householdDowntime struct {
+	UserID string `json:"user_id"`
+	// Kind is "downtime" or "screen_free".
+	Kind   string    `json:"kind"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Active bool      `json:"active"`
+}
+
+// handleHouseholdSummary combines every user into the one payload a
+// home-screen widget needs: total screen time today, each user's progress
+// against their limits, the devices in use right now and the downtimes
+// and screen-free periods in effect or coming up, soonest first.
+func (s *Server) handleHouseholdSummary(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dayStart, nowLocal := s.cfg.ComputeDayWindow(ctx, s.loc)
+
+	today, err := s.store.GetUsageBetween(ctx, dayStart.UTC(), nowLocal.UTC(), nil)
+	if err != nil {
+		writeInternalError(w, "failed to compute usage", err)
+		return
+	}
+	week, err := s.store.GetUsageBetween(ctx, s.cfg.WeekStart(nowLocal).UTC(), nowLocal.UTC(), nil)
+	if err != nil {
+		writeInternalError(w, "failed to compute weekly usage", err)
+		return
+	}
+	cur, err := s.store.GetCurrentSessions(ctx)
+	if err != nil {
+		writeInternalError(w, "failed to get current sessions", err)
+		return
+	}
+	names, err := s.deviceNames(ctx)
+	if err != nil {
+		writeInternalError(w, "failed to get device names", err)
+		return
+	}
+
+	resp := struct {
+		DayStart          time.Time                         `json:"day_start"`
+		Now               time.Time                         `json:"now"`
+		TotalSecondsToday int64                             `json:"total_seconds_today"`
+		Users             []householdUser                   `json:"users"`
+		ActiveDevices     []householdDevice                 `json:"active_devices"`
+		Downtimes         []householdDowntime               `json:"downtimes"`
+		CategoryDisplay   map[string]config.CategoryDisplay `json:"category_display,omitempty"`
+	}{
+		DayStart:        dayStart,
+		Now:             nowLocal,
+		Users:           []householdUser{},
+		ActiveDevices:   []householdDevice{},
+		Downtimes:       []householdDowntime{},
+		CategoryDisplay: s.runner.CategoryDisplays(),
+	}
+
+	for _, e := range today {
+		resp.TotalSecondsToday += e.TotalSeconds
+	}
+
+	grace := s.cfg.Enforcement.Grace()
+	for _, u := range s.cfg.Users {
+		hu := householdUser{UserID: u.ID, Name: u.Name}
+		for _, e := range today {
+			if s.cfg.Attributed(u, e.DeviceID, e.Account) {
+				hu.TodaySeconds += e.TotalSeconds
+			}
+		}
+
+		goals := s.cfg.GoalsFor(u)
+		budgets := append(
+			limits.Budgets(goals, s.userTotals(u, today), grace),
+			limits.WeeklyBudgets(goals, s.userTotals(u, week), grace)...)
+		if dt, ok := limits.NextDowntime(u.Downtime, nowLocal); ok {
+			resp.Downtimes = append(resp.Downtimes, householdDowntime{
+				UserID: u.ID, Kind: "downtime", Start: dt.Start, End: dt.End, Active: dt.Active,
+			})
+		}
+		if sf, ok := limits.NextScreenFree(u.ScreenFree, nowLocal, s.cfg.DayStartHour); ok {
+			resp.Downtimes = append(resp.Downtimes, householdDowntime{
+				UserID: u.ID, Kind: "screen_free", Start: sf.Start, End: sf.End, Active: sf.Active,
+			})
+			if sf.Active {
+				hu.ScreenFree = true
+				budgets = limits.ScreenFree(budgets)
+			}
+		}
+		hu.Remaining = s.newBudgetResponses(budgets)
+		if hu.Remaining == nil {
+			hu.Remaining = []budgetResponse{}
+		}
+		resp.Users = append(resp.Users, hu)
+	}
+	sort.SliceStable(resp.Downtimes, func(i, j int) bool {
+		return resp.Downtimes[i].Start.Before(resp.Downtimes[j].Start)
+	})
+
+	for _, cs := range cur {
+		if cs.Slot != storage.PrimarySlot || cs.State != "active" {
+			continue
+		}
+		hd := householdDevice{
+			DeviceID:       cs.DeviceID,
+			DisplayName:    names[cs.DeviceID],
+			UserIDs:        []string{},
+			AppID:          cs.AppID,
+			AppName:        cs.AppName,
+			Category:       s.categories.Categorize(cs.AppID, cs.AppName),
+			StartTime:      cs.StartTime.In(s.loc),
+			SessionSeconds: int64(cs.LastSeenTime.Sub(cs.StartTime).Seconds()),
+		}
+		for _, u := range s.cfg.Users {
+			if s.cfg.Attributed(u, cs.DeviceID, cs.Account) {
+				hd.UserIDs = append(hd.UserIDs, u.ID)
+			}
+		}
+		resp.ActiveDevices = append(resp.ActiveDevices, hd)
+	}
+
+	writeJSONFields(w, r, resp)
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/poller"
+	"screentime-agent/internal/storage"
+)
+
+// maxIngestBody bounds a pushed update; one is a few hundred bytes.
+const maxIngestBody = 64 << 10
+
+// signatureHeader carries the HMAC-SHA256 of an ingest body, keyed with
+// the device's token, as "sha256=<hex>".
+const signatureHeader = "X-Screentime-Signature"
+
+// ingestRequest is a poll update pushed by an agent.
+type ingestRequest struct {
+	DeviceID string `json:"device_id"`
+	AppID    string `json:"app_id"`
+	AppName  string `json:"app_name"`
+	State    string `json:"state"`
+	// Timestamp is the agent's time of the observation. It must be
+	// within the clock skew tolerance of hub time and later than the
+	// device's last push for the slot; anything else is refused as a
+	// possible replay.
+	Timestamp time.Time `json:"timestamp"`
+	Account   string    `json:"account,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Slot      string    `json:"slot,omitempty"`
+	// IdleReason says why an idle device is idle: "screensaver", "lock",
+	// "no_input", "paused_media", "ignored_window" or "no_window".
+	IdleReason string `json:"idle_reason,omitempty"`
+	// Hostname and AgentVersion identify the pushing machine and agent
+	// build; they are stored as device metadata.
+	Hostname     string `json:"hostname,omitempty"`
+	AgentVersion string `json:"agent_version,omitempty"`
+}
+
+// validSignature reports whether sig is "sha256=" and the hex HMAC of body
+// under token.
+func validSignature(body []byte, token, sig string) bool {
+	hexSum, ok := strings.CutPrefix(sig, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(hexSum)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// handleIngest applies a poll update pushed by an agent the hub can't
+// reach. The body is signed with the device's token.
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid request body", err.Error())
+		return
+	}
+	var req ingestRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	d, ok := s.cfg.Device(req.DeviceID)
+	if !ok || d.Token == "" || !validSignature(body, d.Token, r.Header.Get(signatureHeader)) {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid or missing signature", nil)
+		return
+	}
+
+	switch req.State {
+	case "active", "idle", "offline", "paused", "display_off", "other_input", "unknown":
+	default:
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid state", map[string]string{"state": req.State})
+		return
+	}
+	if req.IdleReason != "" && !storage.ValidIdleReason(req.IdleReason) {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid idle_reason", map[string]string{"idle_reason": req.IdleReason})
+		return
+	}
+
+	u := storage.PollUpdate{
+		AppID:      req.AppID,
+		AppName:    req.AppName,
+		State:      req.State,
+		Timestamp:  req.Timestamp,
+		Account:    req.Account,
+		Title:      req.Title,
+		Slot:       req.Slot,
+		IdleReason: req.IdleReason,
+	}
+	if err := s.runner.Ingest(r.Context(), d, u); errors.Is(err, poller.ErrPushRejected) {
+		// The signature only proves who sent the body, not when; a
+		// stale or repeated timestamp may be a replayed capture.
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "stale or replayed update", err.Error())
+		return
+	} else if err != nil {
+		writeInternalError(w, "failed to apply update", err)
+		return
+	}
+	s.runner.RecordIdentity(r.Context(), d.ID, req.Hostname, req.AgentVersion)
+	w.WriteHeader(http.StatusNoContent)
+}
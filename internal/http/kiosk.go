@@ -0,0 +1,206 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"screentime-agent/internal/storage"
+)
+
+// kioskInterval is how often the kiosk event stream pushes a new snapshot.
+const kioskInterval = 5 * time.Second
+
+type kioskDevice struct {
+	DeviceID       string `json:"device_id"`
+	DisplayName    string `json:"display_name,omitempty"`
+	AppName        string `json:"app_name,omitempty"`
+	State          string `json:"state"`
+	SessionSeconds int64  `json:"session_seconds"`
+	SessionText    string `json:"session_text,omitempty"`
+}
+
+type kioskSnapshot struct {
+	Now     time.Time     `json:"now"`
+	Clock   string        `json:"clock"`
+	Devices []kioskDevice `json:"devices"`
+	Users   []meResponse  `json:"users"`
+}
+
+func (s *Server) buildKioskSnapshot(ctx context.Context) (kioskSnapshot, error) {
+	now := time.Now().In(s.loc)
+	snap := kioskSnapshot{Now: now, Clock: s.format.Clock(now)}
+
+	cur, err := s.store.GetCurrentSessions(ctx)
+	if err != nil {
+		return snap, err
+	}
+	names, err := s.deviceNames(ctx)
+	if err != nil {
+		return snap, err
+	}
+	byDevice := make(map[string]kioskDevice)
+	for _, cs := range cur {
+		if cs.Slot != storage.PrimarySlot {
+			continue
+		}
+		secs := int64(cs.LastSeenTime.Sub(cs.StartTime).Seconds())
+		byDevice[cs.DeviceID] = kioskDevice{
+			DeviceID:       cs.DeviceID,
+			AppName:        cs.AppName,
+			State:          cs.State,
+			SessionSeconds: secs,
+			SessionText:    s.format.Duration(secs),
+		}
+	}
+	for _, d := range s.cfg.Devices {
+		kd, ok := byDevice[d.ID]
+		if !ok {
+			kd = kioskDevice{DeviceID: d.ID, State: "idle"}
+		}
+		kd.DisplayName = names[d.ID]
+		snap.Devices = append(snap.Devices, kd)
+	}
+
+	for _, u := range s.cfg.Users {
+		me, err := s.buildMe(ctx, u)
+		if err != nil {
+			return snap, err
+		}
+		if dt := me.NextDowntime; dt != nil && !dt.Active {
+			s.setStartsIn(dt, now)
+		}
+		snap.Users = append(snap.Users, me)
+	}
+
+	return snap, nil
+}
+
+// setStartsIn fills in how long after now dt starts, "in 25m" in English.
+func (s *Server) setStartsIn(dt *downtimeResponse, now time.Time) {
+	secs := int64(dt.Start.Sub(now).Seconds())
+	if secs < 0 {
+		secs = 0
+	}
+	dt.StartsInText = fmt.Sprintf(s.format.Labels().In, s.format.Duration(secs))
+}
+
+func (s *Server) handleKioskEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, codeInternal, "streaming unsupported", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	send := func() error {
+		snap, err := s.buildKioskSnapshot(ctx)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(snap)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if err := send(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(kioskInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := send(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) handleKiosk(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(kioskPage))
+}
+
+// kioskPage is a dependency-free page for always-on tablets and e-ink
+// screens: high contrast, large type, no animation.
+const kioskPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Screen time</title>
+<style>
+body { font-family: sans-serif; background: #fff; color: #000; margin: 1.5em; font-size: 1.5em; }
+h2 { border-bottom: 3px solid #000; margin-top: 1.2em; }
+table { width: 100%; border-collapse: collapse; }
+td { padding: 0.3em 0; }
+.num { text-align: right; font-weight: bold; }
+.out { text-decoration: underline; }
+#clock { font-size: 2.5em; font-weight: bold; }
+</style>
+</head>
+<body>
+<div id="clock"></div>
+<h2>Now playing</h2>
+<table id="devices"></table>
+<h2>Remaining today</h2>
+<div id="users"></div>
+<script>
+function esc(s) {
+  var d = document.createElement("div");
+  d.textContent = s;
+  return d.innerHTML;
+}
+// category draws a category with its configured color and icon.
+function category(u, name) {
+  var d = (u.category_display || {})[name] || {};
+  return (d.color ? "<span style='color: " + esc(d.color) + "'>&#9632;</span> " : "") +
+    (d.icon ? esc(d.icon) + " " : "") + esc(name);
+}
+function render(snap) {
+  document.getElementById("clock").textContent = snap.clock;
+  var rows = "";
+  (snap.devices || []).forEach(function (d) {
+    var what = d.state === "active" ? esc(d.app_name) + " for " + esc(d.session_text) : d.state;
+    rows += "<tr><td>" + esc(d.display_name || d.device_id) + "</td><td class=num>" + what + "</td></tr>";
+  });
+  document.getElementById("devices").innerHTML = rows;
+  var users = "";
+  (snap.users || []).forEach(function (u) {
+    users += "<h3>" + esc(u.name || u.user_id) + "</h3><table>";
+    (u.remaining || []).forEach(function (b) {
+      users += "<tr><td>" + category(u, b.category) + "</td><td class='num" + (b.remaining_seconds === 0 ? " out" : "") + "'>" + esc(b.remaining_text) + "</td></tr>";
+    });
+    var dt = u.next_downtime;
+    if (dt) {
+      users += "<tr><td>bedtime</td><td class=num>" + (dt.active ? "now, until " + esc(dt.end_text) : esc(dt.starts_in_text)) + "</td></tr>";
+    }
+    users += "</table>";
+  });
+  document.getElementById("users").innerHTML = users;
+}
+var es = new EventSource("kiosk/events" + location.search);
+es.onmessage = function (e) { render(JSON.parse(e.data)); };
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,262 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/limits"
+	"screentime-agent/internal/storage"
+)
+
+// handleLimits reports the time left in every user's and device's budgets
+// today and this week, none during a user's screen-free period. A device's
+// max_minutes budget has category "*".
+func (s *Server) handleLimits(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dayStart, nowLocal := s.cfg.ComputeDayWindow(ctx, s.loc)
+	weekStart := s.cfg.WeekStart(nowLocal)
+
+	today, err := s.store.GetUsageBetween(ctx, dayStart.UTC(), nowLocal.UTC(), nil)
+	if err != nil {
+		writeInternalError(w, "failed to compute usage", err)
+		return
+	}
+	week, err := s.store.GetUsageBetween(ctx, weekStart.UTC(), nowLocal.UTC(), nil)
+	if err != nil {
+		writeInternalError(w, "failed to compute weekly usage", err)
+		return
+	}
+
+	type userLimits struct {
+		UserID     string            `json:"user_id"`
+		Name       string            `json:"name,omitempty"`
+		Remaining  []budgetResponse  `json:"remaining"`
+		ScreenFree *downtimeResponse `json:"screen_free,omitempty"` // when in effect
+	}
+	type deviceLimits struct {
+		DeviceID  string           `json:"device_id"`
+		Remaining []budgetResponse `json:"remaining"`
+	}
+	resp := struct {
+		DayStart  time.Time      `json:"day_start"`
+		WeekStart time.Time      `json:"week_start"`
+		Now       time.Time      `json:"now"`
+		Users     []userLimits   `json:"users"`
+		Devices   []deviceLimits `json:"devices"`
+	}{
+		DayStart:  dayStart,
+		WeekStart: weekStart,
+		Now:       nowLocal,
+		Users:     []userLimits{},
+		Devices:   []deviceLimits{},
+	}
+
+	grace := s.cfg.Enforcement.Grace()
+	for _, u := range s.cfg.Users {
+		goals := s.cfg.GoalsFor(u)
+		budgets := append(
+			limits.Budgets(goals, s.userTotals(u, today), grace),
+			limits.WeeklyBudgets(goals, s.userTotals(u, week), grace)...)
+		ul := userLimits{UserID: u.ID, Name: u.Name}
+		if sf, ok := limits.NextScreenFree(u.ScreenFree, nowLocal, s.cfg.DayStartHour); ok && sf.Active {
+			ul.ScreenFree = s.newDowntime(sf.Start, sf.End, true, true)
+			budgets = limits.ScreenFree(budgets)
+		}
+		if len(budgets) == 0 && ul.ScreenFree == nil {
+			continue
+		}
+		ul.Remaining = s.newBudgetResponses(budgets)
+		if ul.Remaining == nil {
+			ul.Remaining = []budgetResponse{}
+		}
+		resp.Users = append(resp.Users, ul)
+	}
+	for _, d := range s.cfg.Devices {
+		if d.MaxMinutes <= 0 && len(d.Goals) == 0 {
+			continue
+		}
+		budgets := limits.DeviceBudgets(d, s.deviceTotals(d.ID, today), s.deviceTotals(d.ID, week), grace)
+		resp.Devices = append(resp.Devices, deviceLimits{DeviceID: d.ID, Remaining: s.newBudgetResponses(budgets)})
+	}
+
+	writeJSONFields(w, r, resp)
+}
+
+// deviceTotals sums one device's entries per category as counted toward
+// limits, whoever they are attributed to.
+func (s *Server) deviceTotals(deviceID string, entries []storage.UsageEntry) map[string]int64 {
+	var mine []storage.UsageEntry
+	for _, e := range entries {
+		if e.DeviceID == deviceID {
+			mine = append(mine, e)
+		}
+	}
+	return s.categories.LimitTotals(mine)
+}
+
+// maxSimulateWeeks bounds how much history one simulation reads.
+const maxSimulateWeeks = 52
+
+type limitOutcome struct {
+	Category       string      `json:"category"`
+	Period         string      `json:"period"`
+	LimitMinutes   int64       `json:"limit_minutes"`
+	Periods        int         `json:"periods"`
+	Hit            int         `json:"hit"`
+	HitPercent     float64     `json:"hit_percent"`
+	Enforced       int         `json:"enforced"`
+	AverageMinutes float64     `json:"average_minutes"`
+	MaxMinutes     float64     `json:"max_minutes"`
+	HitStarts      []time.Time `json:"hit_starts"`
+}
+
+// handleSimulateLimits evaluates proposed limits against the last weeks of
+// usage and reports how often each user would have hit them. The body is
+// optional; anything it leaves out comes from the current config:
+//
+//	{"weeks": 4, "goals": [...], "users": {"<id>": [...]}, "grace_minutes": 10}
+//
+// goals replaces the global goals and users replaces individual users'
+// goals. Only whole weeks before the current one are evaluated.
+func (s *Server) handleSimulateLimits(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Weeks        int                            `json:"weeks"`
+		Goals        []config.GoalConfig            `json:"goals"`
+		Users        map[string][]config.GoalConfig `json:"users"`
+		GraceMinutes *int                           `json:"grace_minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	if req.Weeks == 0 {
+		req.Weeks = 4
+	}
+	if req.Weeks < 0 || req.Weeks > maxSimulateWeeks {
+		writeInvalidParameter(w, "weeks")
+		return
+	}
+	if req.Goals == nil {
+		req.Goals = s.cfg.Goals
+	}
+	if err := config.ValidateGoals("goals", req.Goals); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid goals", err.Error())
+		return
+	}
+	for id, goals := range req.Users {
+		if _, ok := s.cfg.User(id); !ok {
+			writeNotFound(w, fmt.Sprintf("user %s not found", id))
+			return
+		}
+		if err := config.ValidateGoals("users."+id, goals); err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "invalid goals", err.Error())
+			return
+		}
+	}
+	grace := s.cfg.Enforcement.Grace()
+	if req.GraceMinutes != nil {
+		if *req.GraceMinutes < 0 {
+			writeInvalidParameter(w, "grace_minutes")
+			return
+		}
+		grace = time.Duration(*req.GraceMinutes) * time.Minute
+	}
+
+	proposed := *s.cfg
+	proposed.Goals = req.Goals
+	proposed.Users = nil
+	for _, u := range s.cfg.Users {
+		if goals, ok := req.Users[u.ID]; ok {
+			u.Goals = goals
+		}
+		proposed.Users = append(proposed.Users, u)
+	}
+
+	until := s.cfg.WeekStart(time.Now().In(s.loc))
+	since := until.AddDate(0, 0, -7*req.Weeks)
+
+	// One usage query per day; weeks are summed from the days.
+	type userPeriods struct {
+		days, weeks []limits.PeriodTotals
+	}
+	periods := make(map[string]*userPeriods)
+	for _, u := range proposed.Users {
+		periods[u.ID] = &userPeriods{}
+	}
+	for day := since; day.Before(until); day = s.cfg.NextDayStart(day) {
+		entries, err := s.store.GetUsageBetween(r.Context(), day.UTC(), s.cfg.NextDayStart(day).UTC(), nil)
+		if err != nil {
+			writeInternalError(w, "failed to compute usage", err)
+			return
+		}
+		week := s.cfg.WeekStart(day)
+		for _, u := range proposed.Users {
+			p := periods[u.ID]
+			totals := s.userTotals(u, entries)
+			p.days = append(p.days, limits.PeriodTotals{Start: day, Totals: totals})
+
+			if n := len(p.weeks); n == 0 || !p.weeks[n-1].Start.Equal(week) {
+				p.weeks = append(p.weeks, limits.PeriodTotals{Start: week, Totals: make(map[string]int64)})
+			}
+			for cat, secs := range totals {
+				p.weeks[len(p.weeks)-1].Totals[cat] += secs
+			}
+		}
+	}
+
+	type userResult struct {
+		UserID string         `json:"user_id"`
+		Limits []limitOutcome `json:"limits"`
+	}
+	resp := struct {
+		Since        time.Time    `json:"since"`
+		Until        time.Time    `json:"until"`
+		Weeks        int          `json:"weeks"`
+		GraceMinutes int          `json:"grace_minutes"`
+		Users        []userResult `json:"users"`
+	}{
+		Since:        since,
+		Until:        until,
+		Weeks:        req.Weeks,
+		GraceMinutes: int(grace.Minutes()),
+		Users:        []userResult{},
+	}
+	for _, u := range proposed.Users {
+		p := periods[u.ID]
+		res := userResult{UserID: u.ID, Limits: []limitOutcome{}}
+		for _, o := range limits.Simulate(proposed.GoalsFor(u), p.days, p.weeks, grace) {
+			res.Limits = append(res.Limits, newLimitOutcome(o))
+		}
+		resp.Users = append(resp.Users, res)
+	}
+
+	writeJSON(w, resp)
+}
+
+func newLimitOutcome(o limits.Outcome) limitOutcome {
+	lo := limitOutcome{
+		Category:       o.Category,
+		Period:         o.Period,
+		LimitMinutes:   o.LimitSeconds / 60,
+		Periods:        o.Periods,
+		Hit:            o.Hit,
+		Enforced:       o.Enforced,
+		AverageMinutes: math.Round(float64(o.AverageUsedSeconds)/6) / 10,
+		MaxMinutes:     math.Round(float64(o.MaxUsedSeconds)/6) / 10,
+		HitStarts:      o.HitStarts,
+	}
+	if lo.HitStarts == nil {
+		lo.HitStarts = []time.Time{}
+	}
+	if o.Periods > 0 {
+		lo.HitPercent = math.Round(float64(o.Hit)/float64(o.Periods)*1000) / 10
+	}
+	return lo
+}
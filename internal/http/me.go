@@ -0,0 +1,247 @@
+package http
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/limits"
+	"screentime-agent/internal/locale"
+	"screentime-agent/internal/storage"
+)
+
+type budgetResponse struct {
+	Category         string `json:"category"`
+	Period           string `json:"period"` // "day" or "week"
+	LimitMinutes     int64  `json:"limit_minutes"`
+	UsedMinutes      int64  `json:"used_minutes"`
+	RemainingMinutes int64  `json:"remaining_minutes"`
+	RemainingSeconds int64  `json:"remaining_seconds"`
+	// State is "ok", "grace", "enforced" or "screen_free"; during grace
+	// the countdown to enforcement is in GraceRemainingSeconds.
+	State                 string `json:"state"`
+	GraceRemainingSeconds int64  `json:"grace_remaining_seconds"`
+
+	// The same amounts written out in the configured locale.
+	LimitText          string `json:"limit_text"`
+	UsedText           string `json:"used_text"`
+	RemainingText      string `json:"remaining_text"`
+	GraceRemainingText string `json:"grace_remaining_text,omitempty"`
+}
+
+type downtimeResponse struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Active bool      `json:"active"`
+	// StartText and EndText are the times in the configured locale, with
+	// the weekday for periods that recur weekly.
+	StartText string `json:"start_text"`
+	EndText   string `json:"end_text"`
+	// StartsInText says how long until the period starts, "in 25m" in
+	// English. The kiosk sets it.
+	StartsInText string `json:"starts_in_text,omitempty"`
+}
+
+// newDowntime describes a downtime or screen-free period; weekly ones are
+// written with their weekday.
+func (s *Server) newDowntime(start, end time.Time, active, weekly bool) *downtimeResponse {
+	text := s.format.Clock
+	if weekly {
+		text = s.format.WeekdayClock
+	}
+	return &downtimeResponse{
+		Start:     start,
+		End:       end,
+		Active:    active,
+		StartText: text(start),
+		EndText:   text(end),
+	}
+}
+
+type meResponse struct {
+	UserID       string            `json:"user_id"`
+	Name         string            `json:"name,omitempty"`
+	DayStart     time.Time         `json:"day_start"`
+	Now          time.Time         `json:"now"`
+	Remaining    []budgetResponse  `json:"remaining"`
+	NextDowntime *downtimeResponse `json:"next_downtime,omitempty"`
+	// NextScreenFree is the current or next screen-free period. While one
+	// is active nothing is left of any budget.
+	NextScreenFree  *downtimeResponse                 `json:"next_screen_free,omitempty"`
+	CategoryDisplay map[string]config.CategoryDisplay `json:"category_display,omitempty"`
+}
+
+// bearerToken returns the token from the Authorization header, or from the
+// token query parameter so the HTML view can be bookmarked on a kid's device.
+func bearerToken(r *http.Request) string {
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(v)
+	}
+	return r.URL.Query().Get("token")
+}
+
+// userCategoryTotals sums usage per category across all of a user's
+// devices and accounts, as counted toward limits.
+func (s *Server) userCategoryTotals(ctx context.Context, u config.UserConfig, start, end time.Time) (map[string]int64, error) {
+	entries, err := s.store.GetUsageBetween(ctx, start, end, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.userTotals(u, entries), nil
+}
+
+// userTotals sums the entries attributed to u per category, as counted
+// toward limits.
+func (s *Server) userTotals(u config.UserConfig, entries []storage.UsageEntry) map[string]int64 {
+	var mine []storage.UsageEntry
+	for _, e := range entries {
+		if s.cfg.Attributed(u, e.DeviceID, e.Account) {
+			mine = append(mine, e)
+		}
+	}
+	return s.categories.LimitTotals(mine)
+}
+
+func (s *Server) newBudgetResponses(budgets []limits.Budget) []budgetResponse {
+	var out []budgetResponse
+	for _, b := range budgets {
+		br := budgetResponse{
+			Category:              b.Category,
+			Period:                b.Period,
+			LimitMinutes:          b.LimitSeconds / 60,
+			UsedMinutes:           b.UsedSeconds / 60,
+			RemainingMinutes:      b.RemainingSeconds / 60,
+			RemainingSeconds:      b.RemainingSeconds,
+			State:                 b.State,
+			GraceRemainingSeconds: b.GraceRemainingSeconds,
+			LimitText:             s.format.Duration(b.LimitSeconds),
+			UsedText:              s.format.Duration(b.UsedSeconds),
+			RemainingText:         s.format.Duration(b.RemainingSeconds),
+		}
+		if b.GraceRemainingSeconds > 0 {
+			// Rounded up so the page never says 0 while time is still left.
+			br.GraceRemainingText = s.format.Duration(b.GraceRemainingSeconds + 59)
+		}
+		out = append(out, br)
+	}
+	return out
+}
+
+func (s *Server) buildMe(ctx context.Context, u config.UserConfig) (meResponse, error) {
+	dayStart, nowLocal := s.cfg.ComputeDayWindow(ctx, s.loc)
+
+	totals, err := s.userCategoryTotals(ctx, u, dayStart.UTC(), nowLocal.UTC())
+	if err != nil {
+		return meResponse{}, err
+	}
+	weekTotals, err := s.userCategoryTotals(ctx, u, s.cfg.WeekStart(nowLocal).UTC(), nowLocal.UTC())
+	if err != nil {
+		return meResponse{}, err
+	}
+
+	resp := meResponse{
+		UserID:          u.ID,
+		Name:            u.Name,
+		DayStart:        dayStart,
+		Now:             nowLocal,
+		CategoryDisplay: s.runner.CategoryDisplays(),
+	}
+
+	goals, grace := s.cfg.GoalsFor(u), s.cfg.Enforcement.Grace()
+	budgets := append(limits.Budgets(goals, totals, grace), limits.WeeklyBudgets(goals, weekTotals, grace)...)
+
+	if dt, ok := limits.NextDowntime(u.Downtime, nowLocal); ok {
+		resp.NextDowntime = s.newDowntime(dt.Start, dt.End, dt.Active, false)
+	}
+	if sf, ok := limits.NextScreenFree(u.ScreenFree, nowLocal, s.cfg.DayStartHour); ok {
+		resp.NextScreenFree = s.newDowntime(sf.Start, sf.End, sf.Active, true)
+		if sf.Active {
+			budgets = limits.ScreenFree(budgets)
+		}
+	}
+	resp.Remaining = s.newBudgetResponses(budgets)
+
+	return resp, nil
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	u, ok := s.cfg.UserByToken(bearerToken(r))
+	if !ok {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid or missing token", nil)
+		return
+	}
+
+	resp, err := s.buildMe(r.Context(), u)
+	if err != nil {
+		writeInternalError(w, "failed to compute remaining time", err)
+		return
+	}
+
+	writeJSON(w, resp)
+}
+
+var meTemplate = template.Must(template.New("me").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>Screen time</title>
+<style>
+body { font-family: sans-serif; margin: 2em; font-size: 1.4em; }
+.category { margin-bottom: 1em; }
+.left { font-size: 2em; font-weight: bold; }
+.out { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Hi{{with .Name}} {{.}}{{end}}!</h1>
+{{range .Remaining}}
+<div class="category">
+  <div>{{.Category}}{{if eq .Period "week"}} {{$.Labels.ThisWeek}}{{end}}</div>
+  {{if eq .State "grace"}}
+  <div class="left out">{{printf $.Labels.TimesUp .GraceRemainingText}}</div>
+  {{else}}
+  <div class="left{{if eq .RemainingMinutes 0}} out{{end}}">{{printf $.Labels.Left .RemainingText}}</div>
+  {{end}}
+  <div>{{printf $.Labels.UsedOf .UsedText .LimitText}}</div>
+</div>
+{{else}}
+<p>No limits today.</p>
+{{end}}
+{{with .NextDowntime}}
+{{if .Active}}<p class="out">Downtime until {{.EndText}}</p>{{else}}<p>Next downtime at {{.StartText}}</p>{{end}}
+{{end}}
+{{with .NextScreenFree}}
+{{if .Active}}<p class="out">Screen-free until {{.EndText}}</p>{{else}}<p>Next screen-free time {{.StartText}}</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+func (s *Server) handleMeView(w http.ResponseWriter, r *http.Request) {
+	u, ok := s.cfg.UserByToken(bearerToken(r))
+	if !ok {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid or missing token", nil)
+		return
+	}
+
+	resp, err := s.buildMe(r.Context(), u)
+	if err != nil {
+		writeInternalError(w, "failed to compute remaining time", err)
+		return
+	}
+
+	page := struct {
+		meResponse
+		Labels locale.Labels
+	}{resp, s.format.Labels()}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := meTemplate.Execute(w, page); err != nil {
+		log.Printf("render me page: %v", err)
+	}
+}
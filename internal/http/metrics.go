@@ -0,0 +1,198 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"screentime-agent/internal/category"
+	"screentime-agent/internal/poller"
+	"screentime-agent/internal/storage"
+)
+
+func (s *Server) handlePollers(w http.ResponseWriter, r *http.Request) {
+	type pollerResponse struct {
+		DeviceID        string    `json:"device_id"`
+		IntervalSeconds float64   `json:"interval_seconds"`
+		Polls           int64     `json:"polls"`
+		Errors          int64     `json:"errors"`
+		LastPollTime    time.Time `json:"last_poll_time"`
+		LastLatencyMS   float64   `json:"last_latency_ms"`
+		P50MS           float64   `json:"p50_ms"`
+		P90MS           float64   `json:"p90_ms"`
+		P99MS           float64   `json:"p99_ms"`
+		Slow            bool      `json:"slow"`
+		ClockSkewMS     float64   `json:"clock_skew_ms"`
+		ClockSkewed     bool      `json:"clock_skewed"`
+		LastHeartbeat   time.Time `json:"last_heartbeat"`
+		AgentSilent     bool      `json:"agent_silent"`
+		PendingWrites   int       `json:"pending_writes"`
+		StalePolls      int64     `json:"stale_polls"`
+
+		// Set while the device is failing.
+		ErrorKind    string     `json:"error_kind,omitempty"`
+		Error        string     `json:"error,omitempty"`
+		FailingSince *time.Time `json:"failing_since,omitempty"`
+		Status       string     `json:"status"`
+	}
+
+	resp := struct {
+		Pollers []pollerResponse `json:"pollers"`
+	}{}
+
+	for _, st := range s.runner.Stats() {
+		pr := pollerResponse{
+			DeviceID:        st.DeviceID,
+			IntervalSeconds: st.Interval.Seconds(),
+			Polls:           st.Polls,
+			Errors:          st.Errors,
+			LastPollTime:    st.LastPollTime,
+			LastLatencyMS:   ms(st.LastLatency),
+			P50MS:           ms(st.P50),
+			P90MS:           ms(st.P90),
+			P99MS:           ms(st.P99),
+			Slow:            st.Slow,
+			ClockSkewMS:     ms(st.ClockSkew),
+			ClockSkewed:     st.SkewExceeded,
+			LastHeartbeat:   st.LastHeartbeat,
+			AgentSilent:     st.AgentSilent,
+			PendingWrites:   st.PendingWrites,
+			StalePolls:      st.StalePolls,
+			Status:          "ok",
+		}
+		if f := st.Failure; f != nil {
+			since := st.FailingSince.In(s.loc)
+			pr.ErrorKind = string(f.Kind)
+			pr.Error = f.Error()
+			pr.FailingSince = &since
+			pr.Status = fmt.Sprintf("%s since %s", f.Kind.Describe(), s.format.Clock(since))
+		}
+		if st.Unknown {
+			pr.Status = "waiting for device"
+		}
+		resp.Pollers = append(resp.Pollers, pr)
+	}
+
+	writeJSON(w, resp)
+}
+
+// sessionLengthBuckets are the upper bounds of the session length
+// histogram, spanning a quick check to a binge.
+var sessionLengthBuckets = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 4 * time.Hour,
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	lengths, err := s.store.GetSessionLengths(r.Context(), sessionLengthBuckets)
+	if err != nil {
+		writeInternalError(w, "failed to get session lengths", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	stats := s.runner.Stats()
+
+	fmt.Fprintln(w, "# HELP screentime_poll_duration_seconds Latency of device polls.")
+	fmt.Fprintln(w, "# TYPE screentime_poll_duration_seconds summary")
+	for _, st := range stats {
+		for _, q := range []struct {
+			label string
+			value time.Duration
+		}{{"0.5", st.P50}, {"0.9", st.P90}, {"0.99", st.P99}} {
+			fmt.Fprintf(w, "screentime_poll_duration_seconds{device=%q,quantile=%q} %g\n", st.DeviceID, q.label, q.value.Seconds())
+		}
+		fmt.Fprintf(w, "screentime_poll_duration_seconds_sum{device=%q} %g\n", st.DeviceID, st.LatencySum.Seconds())
+		fmt.Fprintf(w, "screentime_poll_duration_seconds_count{device=%q} %d\n", st.DeviceID, st.Polls)
+	}
+
+	fmt.Fprintln(w, "# HELP screentime_agent_clock_skew_seconds Device clock minus hub clock at last poll.")
+	fmt.Fprintln(w, "# TYPE screentime_agent_clock_skew_seconds gauge")
+	for _, st := range stats {
+		fmt.Fprintf(w, "screentime_agent_clock_skew_seconds{device=%q} %g\n", st.DeviceID, st.ClockSkew.Seconds())
+	}
+
+	fmt.Fprintln(w, "# HELP screentime_poll_errors_total Device polls that returned an error.")
+	fmt.Fprintln(w, "# TYPE screentime_poll_errors_total counter")
+	for _, st := range stats {
+		fmt.Fprintf(w, "screentime_poll_errors_total{device=%q} %d\n", st.DeviceID, st.Errors)
+	}
+
+	fmt.Fprintln(w, "# HELP screentime_pending_writes Polls waiting to be stored after a storage error.")
+	fmt.Fprintln(w, "# TYPE screentime_pending_writes gauge")
+	for _, st := range stats {
+		fmt.Fprintf(w, "screentime_pending_writes{device=%q} %d\n", st.DeviceID, st.PendingWrites)
+	}
+
+	fmt.Fprintln(w, "# HELP screentime_dropped_writes_total Polls lost because the retry queue was full.")
+	fmt.Fprintln(w, "# TYPE screentime_dropped_writes_total counter")
+	for _, st := range stats {
+		fmt.Fprintf(w, "screentime_dropped_writes_total{device=%q} %d\n", st.DeviceID, st.DroppedWrites)
+	}
+
+	fmt.Fprintln(w, "# HELP screentime_stale_polls_total Polls left out for arriving older than the session they would change.")
+	fmt.Fprintln(w, "# TYPE screentime_stale_polls_total counter")
+	for _, st := range stats {
+		fmt.Fprintf(w, "screentime_stale_polls_total{device=%q} %d\n", st.DeviceID, st.StalePolls)
+	}
+
+	fmt.Fprintln(w, "# HELP screentime_ws_clients Clients connected to the live status WebSocket.")
+	fmt.Fprintln(w, "# TYPE screentime_ws_clients gauge")
+	fmt.Fprintf(w, "screentime_ws_clients %d\n", s.wsClients.Load())
+
+	fmt.Fprintln(w, "# HELP screentime_poll_failures_total Failed device polls by cause.")
+	fmt.Fprintln(w, "# TYPE screentime_poll_failures_total counter")
+	for _, st := range stats {
+		kinds := make([]string, 0, len(st.FailuresByKind))
+		for k := range st.FailuresByKind {
+			kinds = append(kinds, string(k))
+		}
+		sort.Strings(kinds)
+		for _, k := range kinds {
+			fmt.Fprintf(w, "screentime_poll_failures_total{device=%q,kind=%q} %d\n", st.DeviceID, k, st.FailuresByKind[poller.ErrorKind(k)])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP screentime_session_duration_seconds Length of closed sessions.")
+	fmt.Fprintln(w, "# TYPE screentime_session_duration_seconds histogram")
+	for _, l := range mergeUncategorized(lengths) {
+		cat := l.Category
+		for i, b := range sessionLengthBuckets {
+			fmt.Fprintf(w, "screentime_session_duration_seconds_bucket{device=%q,category=%q,le=\"%g\"} %d\n", l.DeviceID, cat, b.Seconds(), l.Counts[i])
+		}
+		fmt.Fprintf(w, "screentime_session_duration_seconds_bucket{device=%q,category=%q,le=\"+Inf\"} %d\n", l.DeviceID, cat, l.Count)
+		fmt.Fprintf(w, "screentime_session_duration_seconds_sum{device=%q,category=%q} %d\n", l.DeviceID, cat, l.TotalSeconds)
+		fmt.Fprintf(w, "screentime_session_duration_seconds_count{device=%q,category=%q} %d\n", l.DeviceID, cat, l.Count)
+	}
+}
+
+// mergeUncategorized folds sessions stored before categories were recorded
+// into each device's uncategorized sessions, so each series appears once.
+func mergeUncategorized(lengths []storage.SessionLengths) []storage.SessionLengths {
+	var out []storage.SessionLengths
+	index := make(map[[2]string]int)
+	for _, l := range lengths {
+		if l.Category == "" {
+			l.Category = category.Uncategorized
+		}
+		k := [2]string{l.DeviceID, l.Category}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(out)
+			out = append(out, l)
+			continue
+		}
+		out[i].Count += l.Count
+		out[i].TotalSeconds += l.TotalSeconds
+		for j := range l.Counts {
+			out[i].Counts[j] += l.Counts[j]
+		}
+	}
+	return out
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/storage"
+)
+
+// resolvePeriod turns a named period into a [start, end) window in now's
+// location. Days begin at dayStartHour, so "today" at 06:00 with a 07:00 day
+// start still means the day that began yesterday morning; weeks begin on
+// firstDay.
+func resolvePeriod(name string, now time.Time, dayStartHour int, firstDay time.Weekday) (time.Time, time.Time, error) {
+	today := config.DayStartAt(now, dayStartHour)
+
+	switch name {
+	case "", "today":
+		return today, now, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, nil
+	case "last7d":
+		return today.AddDate(0, 0, -6), now, nil
+	case "this_week":
+		return config.WeekStartAt(now, dayStartHour, firstDay), now, nil
+	case "this_month":
+		y, m, _ := today.Date()
+		return time.Date(y, m, 1, dayStartHour, 0, 0, 0, now.Location()), now, nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown period %q", name)
+	}
+}
+
+// requestLocation returns the timezone named by the tz query parameter,
+// falling back to the configured timezone.
+func (s *Server) requestLocation(r *http.Request) (*time.Location, error) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		return s.loc, nil
+	}
+	return time.LoadLocation(tz)
+}
+
+// makeBuckets splits [start, end) into hour or day buckets aligned to the
+// clock in start's location. Day buckets begin at dayStartHour. The first
+// and last buckets are clipped to the range.
+func makeBuckets(start, end time.Time, granularity string, dayStartHour int) ([]storage.Bucket, error) {
+	var (
+		cursor time.Time
+		next   func(time.Time) time.Time
+	)
+	switch granularity {
+	case "hour":
+		y, m, d := start.Date()
+		cursor = time.Date(y, m, d, start.Hour(), 0, 0, 0, start.Location())
+		next = func(t time.Time) time.Time { return t.Add(time.Hour) }
+	case "day":
+		cursor = config.DayStartAt(start, dayStartHour)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	default:
+		return nil, fmt.Errorf("unknown granularity %q", granularity)
+	}
+
+	var out []storage.Bucket
+	for cursor.Before(end) {
+		b := storage.Bucket{Start: cursor, End: next(cursor)}
+		if b.Start.Before(start) {
+			b.Start = start
+		}
+		if b.End.After(end) {
+			b.End = end
+		}
+		out = append(out, b)
+		if len(out) > storage.MaxBuckets {
+			return nil, fmt.Errorf("range needs more than %d buckets", storage.MaxBuckets)
+		}
+		cursor = next(cursor)
+	}
+	return out, nil
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"screentime-agent/internal/storage"
+)
+
+// handleRawPolls lists recorded poll observations, oldest first. Without
+// since it covers the last hour; limit defaults to 1000.
+func (s *Server) handleRawPolls(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.RawPollDays <= 0 {
+		writeNotFound(w, "raw poll recording is disabled")
+		return
+	}
+
+	q := r.URL.Query()
+
+	var deviceID *string
+	if v := q.Get("device_id"); v != "" {
+		deviceID = &v
+	}
+
+	until := time.Now()
+	if v := q.Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeInvalidParameter(w, "until")
+			return
+		}
+		until = t
+	}
+	since := until.Add(-time.Hour)
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeInvalidParameter(w, "since")
+			return
+		}
+		since = t
+	}
+
+	limit := 1000
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeInvalidParameter(w, "limit")
+			return
+		}
+		limit = n
+	}
+
+	loc, err := s.requestLocation(r)
+	if err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
+
+	polls, err := s.store.GetRawPolls(r.Context(), deviceID, since, until, limit)
+	if err != nil {
+		writeInternalError(w, "failed to get raw polls", err)
+		return
+	}
+	for i := range polls {
+		polls[i].Timestamp = polls[i].Timestamp.In(loc)
+	}
+
+	writeJSONFields(w, r, struct {
+		Polls []storage.PollUpdate `json:"polls"`
+	}{Polls: polls})
+}
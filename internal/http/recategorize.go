@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"screentime-agent/internal/storage"
+)
+
+// handleRecategorize re-runs the current category rules over every stored
+// session and updates the category stored with it. With dry_run=true
+// nothing is written and the response is the diff that would be applied.
+func (s *Server) handleRecategorize(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeInvalidParameter(w, "dry_run")
+			return
+		}
+		dryRun = b
+	}
+
+	changes, err := s.store.Recategorize(ctx, s.categories.Categorize, dryRun)
+	if err != nil {
+		writeInternalError(w, "failed to recategorize sessions", err)
+		return
+	}
+
+	resp := struct {
+		DryRun   bool                     `json:"dry_run"`
+		Sessions int64                    `json:"sessions"`
+		Changes  []storage.CategoryChange `json:"changes"`
+	}{
+		DryRun:  dryRun,
+		Changes: changes,
+	}
+	if resp.Changes == nil {
+		resp.Changes = []storage.CategoryChange{}
+	}
+	for _, c := range changes {
+		resp.Sessions += c.Sessions
+	}
+
+	if !dryRun && len(changes) > 0 {
+		s.audit(ctx, r, "sessions.recategorize", "sessions", map[string]string{
+			"groups":   strconv.Itoa(len(changes)),
+			"sessions": strconv.FormatInt(resp.Sessions, 10),
+		})
+	}
+
+	writeJSON(w, resp)
+}
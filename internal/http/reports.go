@@ -0,0 +1,166 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/report"
+)
+
+type weeklyCategory struct {
+	Category        string   `json:"category"`
+	Seconds         int64    `json:"seconds"`
+	PreviousSeconds int64    `json:"previous_seconds"`
+	ChangePercent   *float64 `json:"change_percent"`
+	DailySeconds    []int64  `json:"daily_seconds"`
+}
+
+type weeklyApp struct {
+	AppID   string `json:"app_id"`
+	AppName string `json:"app_name"`
+	Seconds int64  `json:"seconds"`
+}
+
+type weeklyLimit struct {
+	Category     string `json:"category"`
+	LimitMinutes int    `json:"limit_minutes"`
+	DaysWithin   int    `json:"days_within"`
+	DaysOver     int    `json:"days_over"`
+}
+
+type weeklyUser struct {
+	UserID               string           `json:"user_id"`
+	Name                 string           `json:"name"`
+	TotalSeconds         int64            `json:"total_seconds"`
+	PreviousTotalSeconds int64            `json:"previous_total_seconds"`
+	ChangePercent        *float64         `json:"change_percent"`
+	DailySeconds         []int64          `json:"daily_seconds"`
+	Categories           []weeklyCategory `json:"categories"`
+	TopApps              []weeklyApp      `json:"top_apps"`
+	Limits               []weeklyLimit    `json:"limits"`
+	// Uncategorized is set when alerts.uncategorized_minutes is.
+	Uncategorized []uncategorizedApp `json:"uncategorized,omitempty"`
+}
+
+// handleWeeklyReport compares a tracking week with the one before it for
+// each user, per category, with per-day series for sparklines. It is the
+// same data the scheduled report renders. week is any date in the week
+// (YYYY-MM-DD, default today); user limits the response to one user. A
+// week still in progress is compared with the whole previous week and
+// reported as incomplete.
+func (s *Server) handleWeeklyReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	loc, err := s.requestLocation(r)
+	if err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
+	now := time.Now().In(loc)
+	day := now
+	if v := q.Get("week"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			writeInvalidParameter(w, "week")
+			return
+		}
+		// Noon keeps an early day start hour from moving the date back a day.
+		day = t.Add(12 * time.Hour)
+	}
+	start := s.cfg.WeekStart(day)
+	end := start.AddDate(0, 0, 7)
+
+	users := s.reports.Users()
+	if v := q.Get("user"); v != "" {
+		u, ok := s.cfg.User(v)
+		if !ok {
+			writeNotFound(w, "user not found")
+			return
+		}
+		users = []config.UserConfig{u}
+	}
+
+	resp := struct {
+		WeekStart         time.Time                         `json:"week_start"`
+		WeekEnd           time.Time                         `json:"week_end"`
+		PreviousWeekStart time.Time                         `json:"previous_week_start"`
+		Complete          bool                              `json:"complete"`
+		Days              []time.Time                       `json:"days"`
+		Users             []weeklyUser                      `json:"users"`
+		CategoryDisplay   map[string]config.CategoryDisplay `json:"category_display,omitempty"`
+	}{
+		WeekStart:         start,
+		WeekEnd:           end,
+		PreviousWeekStart: start.AddDate(0, 0, -7),
+		Complete:          !end.After(now),
+		Users:             []weeklyUser{},
+		CategoryDisplay:   s.runner.CategoryDisplays(),
+	}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		resp.Days = append(resp.Days, d)
+	}
+
+	for _, u := range users {
+		rep, err := s.reports.Build(ctx, u, end)
+		if err != nil {
+			writeInternalError(w, "failed to build report", err)
+			return
+		}
+		resp.Users = append(resp.Users, newWeeklyUser(rep))
+	}
+
+	writeJSONFields(w, r, resp)
+}
+
+func newWeeklyUser(rep *report.Weekly) weeklyUser {
+	wu := weeklyUser{
+		UserID:               rep.UserID,
+		Name:                 rep.Name,
+		TotalSeconds:         rep.TotalSeconds,
+		PreviousTotalSeconds: rep.PreviousTotalSeconds,
+		ChangePercent:        changePercent(rep.TotalSeconds, rep.PreviousTotalSeconds),
+		Categories:           []weeklyCategory{},
+		TopApps:              []weeklyApp{},
+		Limits:               []weeklyLimit{},
+	}
+	for _, d := range rep.Days {
+		wu.DailySeconds = append(wu.DailySeconds, d.TotalSeconds)
+	}
+	for _, c := range rep.Categories {
+		wu.Categories = append(wu.Categories, weeklyCategory{
+			Category:        c.Category,
+			Seconds:         c.Seconds,
+			PreviousSeconds: c.PreviousSeconds,
+			ChangePercent:   changePercent(c.Seconds, c.PreviousSeconds),
+			DailySeconds:    c.Days,
+		})
+	}
+	for _, a := range rep.TopApps {
+		wu.TopApps = append(wu.TopApps, weeklyApp{AppID: a.AppID, AppName: a.AppName, Seconds: a.Seconds})
+	}
+	for _, c := range rep.Compliance {
+		wu.Limits = append(wu.Limits, weeklyLimit{
+			Category:     c.Category,
+			LimitMinutes: c.LimitMinutes,
+			DaysWithin:   c.DaysWithin,
+			DaysOver:     c.DaysOver,
+		})
+	}
+	if rep.Uncategorized != nil {
+		wu.Uncategorized = newUncategorizedApps(rep.Uncategorized)
+	}
+	return wu
+}
+
+// changePercent is the change from previous to current, rounded to a tenth
+// of a percent, or nil when there was no previous usage.
+func changePercent(current, previous int64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	pct := math.Round(float64(current-previous)/float64(previous)*1000) / 10
+	return &pct
+}
@@ -4,59 +4,163 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"screentime-agent/internal/category"
 	"screentime-agent/internal/config"
+	"screentime-agent/internal/locale"
+	"screentime-agent/internal/poller"
+	"screentime-agent/internal/report"
 	"screentime-agent/internal/storage"
 )
 
 type Server struct {
 	cfg        *config.Config
 	store      *storage.SessionStore
+	runner     *poller.Runner
+	categories *category.Categorizer
+	reports    *report.Builder
 	loc        *time.Location
+	format     locale.Formatter
+	confirms   *confirmations
+	enrolls    *confirmations
+	status     *statusCache
 	httpServer *http.Server
+
+	// names caches deviceNames until PATCH /devices/{id} renames one.
+	namesMu sync.Mutex
+	names   map[string]string
+
+	// caMu guards cfg.DeviceCA, which the first enrollment creates.
+	caMu sync.Mutex
+
+	// closing is closed when the server starts shutting down, to end
+	// /ws streams, which Shutdown does not wait for or interrupt.
+	closing   chan struct{}
+	wsClients atomic.Int64
+
+	// readOnlyServer, when configured, serves only reporting routes.
+	readOnlyServer *http.Server
+
+	// tlsFingerprint is the certificate fingerprint when serving HTTPS.
+	tlsFingerprint string
 }
 
-func NewServer(cfg *config.Config, store *storage.SessionStore) (*Server, error) {
+func NewServer(cfg *config.Config, store *storage.SessionStore, runner *poller.Runner) (*Server, error) {
 	loc, err := cfg.ResolveLocation()
 	if err != nil {
 		return nil, fmt.Errorf("resolve timezone: %w", err)
 	}
 
 	s := &Server{
-		cfg:   cfg,
-		store: store,
-		loc:   loc,
+		cfg:        cfg,
+		store:      store,
+		runner:     runner,
+		categories: category.New(cfg.Categories, store.AppCategory),
+		reports:    report.NewBuilder(cfg, store),
+		loc:        loc,
+		format:     cfg.Display.Formatter(),
+		confirms:   newConfirmations(confirmTTL),
+		enrolls:    newConfirmations(enrollTTL),
+		status:     newStatusCache(store),
+		closing:    make(chan struct{}),
 	}
+	s.reports.DisplayWith(runner.CategoryDisplays)
 
 	mux := http.NewServeMux()
-	s.registerRoutes(mux)
+	var readOnly *http.ServeMux
+	if cfg.ReadOnlyListen != "" {
+		readOnly = http.NewServeMux()
+		s.readOnlyServer = &http.Server{
+			Addr:    cfg.ReadOnlyListen,
+			Handler: readOnly,
+		}
+	}
+	s.registerRoutes(mux, readOnly)
 
 	s.httpServer = &http.Server{
 		Addr:    cfg.HTTPListen,
 		Handler: mux,
 	}
 
+	if cfg.TLS != nil {
+		// A generated certificate is kept next to the database.
+		tlsCfg, fp, err := cfg.TLS.Load(cfg.DataDir())
+		if err != nil {
+			return nil, err
+		}
+		s.httpServer.TLSConfig = tlsCfg
+		if s.readOnlyServer != nil {
+			s.readOnlyServer.TLSConfig = tlsCfg
+		}
+		s.tlsFingerprint = fp
+	}
+
 	return s, nil
 }
 
 // Start runs the HTTP server until ctx is canceled or the server fails.
 func (s *Server) Start(ctx context.Context) error {
-	errCh := make(chan error, 1)
+	errCh := make(chan error, 2)
+
+	if len(s.cfg.APIKeys) == 0 {
+		log.Printf("no api_keys configured; the HTTP API is open to anyone who can reach it")
+	}
+
+	if s.tlsFingerprint != "" {
+		log.Printf("serving HTTPS with certificate fingerprint %s", s.tlsFingerprint)
+	}
 
 	go func() {
 		log.Printf("HTTP server listening on %s", s.cfg.HTTPListen)
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.httpServer.TLSConfig != nil {
+			err = s.httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = s.httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errCh <- err
 		}
 	}()
 
+	if s.readOnlyServer != nil {
+		go func() {
+			ln, err := listen(s.cfg.ReadOnlyListen)
+			if err != nil {
+				errCh <- fmt.Errorf("read-only listener: %w", err)
+				return
+			}
+			log.Printf("read-only HTTP server listening on %s", s.cfg.ReadOnlyListen)
+			// Unix sockets are local already; TLS is for TCP listeners.
+			if s.readOnlyServer.TLSConfig != nil && !strings.HasPrefix(s.cfg.ReadOnlyListen, "unix:") {
+				err = s.readOnlyServer.ServeTLS(ln, "", "")
+			} else {
+				err = s.readOnlyServer.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				errCh <- fmt.Errorf("read-only listener: %w", err)
+			}
+		}()
+	}
+
 	select {
 	case <-ctx.Done():
 		// Shutdown
+		close(s.closing)
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
+		if s.readOnlyServer != nil {
+			if err := s.readOnlyServer.Shutdown(shutdownCtx); err != nil {
+				log.Printf("read-only HTTP server shutdown error: %v", err)
+			}
+		}
 		if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
 			return fmt.Errorf("http shutdown: %w", err)
 		}
@@ -66,3 +170,16 @@ func (s *Server) Start(ctx context.Context) error {
 		return fmt.Errorf("http server error: %w", err)
 	}
 }
+
+// listen opens addr, which is a TCP address or "unix:" followed by a socket
+// path. A stale socket file left by an earlier run is replaced.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("remove stale socket: %w", err)
+	}
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,10 @@
+package http
+
+import "net/http"
+
+// handleSettings returns the hub's portable settings (devices, users,
+// categories and limits) for `screentime-agent import-settings` on another
+// hub. User and device tokens are not included.
+func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.cfg.Settings())
+}
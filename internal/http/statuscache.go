@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"screentime-agent/internal/storage"
+)
+
+// statusCacheTTL is how long a current_sessions read is reused. It is short
+// enough that dashboards still look live.
+const statusCacheTTL = 500 * time.Millisecond
+
+// statusCall is one in-flight or completed current_sessions read.
+type statusCall struct {
+	done    chan struct{}
+	fetched time.Time
+	cur     []storage.CurrentSession
+	err     error
+}
+
+// statusCache coalesces concurrent /status reads so that any number of
+// polling dashboards cost one SQLite query per TTL.
+type statusCache struct {
+	store *storage.SessionStore
+
+	mu   sync.Mutex
+	last *statusCall
+}
+
+func newStatusCache(store *storage.SessionStore) *statusCache {
+	return &statusCache{store: store}
+}
+
+// get returns the current sessions, joining a read already in flight or
+// reusing one that finished within statusCacheTTL. Callers must not modify
+// the returned slice.
+func (c *statusCache) get(ctx context.Context) ([]storage.CurrentSession, error) {
+	c.mu.Lock()
+	call := c.last
+	if call == nil || !call.valid(time.Now()) {
+		call = &statusCall{done: make(chan struct{})}
+		c.last = call
+		// The read outlives the request that started it, since other
+		// viewers may be waiting on it.
+		go c.fetch(context.WithoutCancel(ctx), call)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.cur, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *statusCache) fetch(ctx context.Context, call *statusCall) {
+	call.cur, call.err = c.store.GetCurrentSessions(ctx)
+	call.fetched = time.Now()
+	close(call.done)
+
+	// Errors are not cached; the next viewer retries.
+	if call.err != nil {
+		c.mu.Lock()
+		if c.last == call {
+			c.last = nil
+		}
+		c.mu.Unlock()
+	}
+}
+
+// valid reports whether call is still running or finished recently enough
+// to be shared. It must be called with the cache lock held.
+func (call *statusCall) valid(now time.Time) bool {
+	select {
+	case <-call.done:
+		return now.Sub(call.fetched) < statusCacheTTL
+	default:
+		return true
+	}
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// handleSessionTitles lists the titles seen during a closed session, each
+// lasting until the next one or the end of the session. Sessions from
+// agents that don't track titles have none.
+func (s *Server) handleSessionTitles(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeInvalidParameter(w, "id")
+		return
+	}
+
+	loc, err := s.requestLocation(r)
+	if err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
+
+	se, err := s.store.GetSession(r.Context(), id)
+	if err != nil {
+		writeInternalError(w, "failed to get session", err)
+		return
+	}
+	if se == nil {
+		writeNotFound(w, "session not found")
+		return
+	}
+
+	events, err := s.store.GetTitleEvents(r.Context(), se.DeviceID, se.AppID, se.StartTime, se.EndTime)
+	if err != nil {
+		writeInternalError(w, "failed to get title events", err)
+		return
+	}
+
+	names, err := s.deviceNames(r.Context())
+	if err != nil {
+		writeInternalError(w, "failed to get device names", err)
+		return
+	}
+
+	type titleResponse struct {
+		Title           string    `json:"title"`
+		Start           time.Time `json:"start"`
+		End             time.Time `json:"end"`
+		DurationSeconds int64     `json:"duration_seconds"`
+	}
+
+	resp := struct {
+		SessionID   int64           `json:"session_id"`
+		DeviceID    string          `json:"device_id"`
+		DisplayName string          `json:"display_name,omitempty"`
+		AppID       string          `json:"app_id"`
+		AppName     string          `json:"app_name"`
+		Titles      []titleResponse `json:"titles"`
+	}{
+		SessionID:   se.ID,
+		DeviceID:    se.DeviceID,
+		DisplayName: names[se.DeviceID],
+		AppID:       se.AppID,
+		AppName:     se.AppName,
+		Titles:      []titleResponse{},
+	}
+
+	for i, e := range events {
+		end := se.EndTime
+		if i+1 < len(events) {
+			end = events[i+1].Time
+		}
+		resp.Titles = append(resp.Titles, titleResponse{
+			Title:           e.Title,
+			Start:           e.Time.In(loc),
+			End:             end.In(loc),
+			DurationSeconds: int64(end.Sub(e.Time).Seconds()),
+		})
+	}
+
+	writeJSONFields(w, r, resp)
+}
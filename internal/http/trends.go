@@ -0,0 +1,158 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/limits"
+	"screentime-agent/internal/storage"
+)
+
+// Trend windows, in days.
+const (
+	shortTrendWindow = 7
+	longTrendWindow  = 30
+)
+
+// maxTrendDays bounds the span of one trends request.
+const maxTrendDays = 366
+
+type trendPoint struct {
+	Date          string `json:"date"`
+	Seconds       int64  `json:"seconds"`
+	Avg7dSeconds  int64  `json:"avg_7d_seconds"`
+	Avg30dSeconds int64  `json:"avg_30d_seconds"`
+}
+
+type categoryTrend struct {
+	Category string       `json:"category"`
+	Days     []trendPoint `json:"days"`
+}
+
+type userTrend struct {
+	UserID     string          `json:"user_id"`
+	Name       string          `json:"name,omitempty"`
+	Categories []categoryTrend `json:"categories"`
+}
+
+// handleUsageTrends reports each user's daily usage per category with its
+// trailing 7- and 30-day averages, over the last days whole days (default
+// 90). Category "*" is all of a user's usage. user_id and category narrow
+// the result. Averages always cover full windows, reaching back before
+// the span as needed, so the first points aren't skewed by missing days.
+func (s *Server) handleUsageTrends(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	days := 90
+	if v := q.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTrendDays {
+			writeInvalidParameter(w, "days")
+			return
+		}
+		days = n
+	}
+	users := s.cfg.Users
+	if v := q.Get("user_id"); v != "" {
+		u, ok := s.cfg.User(v)
+		if !ok {
+			writeNotFound(w, "user not found")
+			return
+		}
+		users = []config.UserConfig{u}
+	}
+	only := q.Get("category")
+
+	until := s.cfg.DayStart(time.Now().In(s.loc))
+	since := until.AddDate(0, 0, -days)
+	from := since.AddDate(0, 0, -(longTrendWindow - 1))
+
+	// Daily totals per user, from the start of the first window.
+	var dayStarts []time.Time
+	daily := make(map[string][]map[string]int64)
+	for day := from; day.Before(until); day = s.cfg.NextDayStart(day) {
+		entries, err := s.store.GetUsageBetween(ctx, day.UTC(), s.cfg.NextDayStart(day).UTC(), nil)
+		if err != nil {
+			writeInternalError(w, "failed to compute usage", err)
+			return
+		}
+		dayStarts = append(dayStarts, day)
+		for _, u := range users {
+			var mine []storage.UsageEntry
+			for _, e := range entries {
+				if s.cfg.Attributed(u, e.DeviceID, e.Account) {
+					mine = append(mine, e)
+				}
+			}
+			totals := s.categories.Totals(mine)
+			var all int64
+			for _, secs := range totals {
+				all += secs
+			}
+			totals[limits.CategoryAll] = all
+			daily[u.ID] = append(daily[u.ID], totals)
+		}
+	}
+
+	resp := struct {
+		Since           time.Time                         `json:"since"`
+		Until           time.Time                         `json:"until"`
+		Days            int                               `json:"days"`
+		Users           []userTrend                       `json:"users"`
+		CategoryDisplay map[string]config.CategoryDisplay `json:"category_display,omitempty"`
+	}{
+		Since:           since,
+		Until:           until,
+		Days:            days,
+		Users:           []userTrend{},
+		CategoryDisplay: s.runner.CategoryDisplays(),
+	}
+
+	first := len(dayStarts) - days
+	for _, u := range users {
+		totals := daily[u.ID]
+		cats := map[string]bool{limits.CategoryAll: true}
+		for _, t := range totals[first:] {
+			for cat := range t {
+				cats[cat] = true
+			}
+		}
+		names := make([]string, 0, len(cats))
+		for cat := range cats {
+			if only == "" || cat == only {
+				names = append(names, cat)
+			}
+		}
+		sort.Strings(names)
+
+		ut := userTrend{UserID: u.ID, Name: u.Name, Categories: []categoryTrend{}}
+		for _, cat := range names {
+			ct := categoryTrend{Category: cat}
+			for i := first; i < len(totals); i++ {
+				ct.Days = append(ct.Days, trendPoint{
+					Date:          dayStarts[i].Format(time.DateOnly),
+					Seconds:       totals[i][cat],
+					Avg7dSeconds:  trailingAverage(totals, i, shortTrendWindow, cat),
+					Avg30dSeconds: trailingAverage(totals, i, longTrendWindow, cat),
+				})
+			}
+			ut.Categories = append(ut.Categories, ct)
+		}
+		resp.Users = append(resp.Users, ut)
+	}
+
+	writeJSONFields(w, r, resp)
+}
+
+// trailingAverage averages cat over the window days ending with day i.
+func trailingAverage(totals []map[string]int64, i, window int, cat string) int64 {
+	var sum int64
+	for j := i - window + 1; j <= i; j++ {
+		sum += totals[j][cat]
+	}
+	return sum / int64(window)
+}
@@ -0,0 +1,189 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/category"
+	"screentime-agent/internal/report"
+)
+
+type uncategorizedApp struct {
+	AppID     string   `json:"app_id"`
+	AppName   string   `json:"app_name"`
+	DeviceIDs []string `json:"device_ids"`
+	Seconds   int64    `json:"seconds"`
+	Days      int      `json:"days"`
+	// CategoryURL is where to PUT {"category": "..."} to assign one.
+	CategoryURL string `json:"category_url"`
+}
+
+func newUncategorizedApps(apps []report.UncategorizedApp) []uncategorizedApp {
+	out := []uncategorizedApp{}
+	for _, a := range apps {
+		out = append(out, uncategorizedApp{
+			AppID:       a.AppID,
+			AppName:     a.AppName,
+			DeviceIDs:   a.DeviceIDs,
+			Seconds:     a.Seconds,
+			Days:        a.Days,
+			CategoryURL: apiPrefix + "/apps/" + url.PathEscape(a.AppID) + "/category",
+		})
+	}
+	return out
+}
+
+// handleUncategorizedReport lists the apps and domains no category matches
+// that were used longer than min_minutes (default
+// alerts.uncategorized_minutes) on a tracking day, most used first. date
+// is any day in the range (YYYY-MM-DD, default today) and days how many
+// days it covers, ending with date (default 1).
+func (s *Server) handleUncategorizedReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	loc, err := s.requestLocation(r)
+	if err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
+	day := time.Now().In(loc)
+	if v := q.Get("date"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			writeInvalidParameter(w, "date")
+			return
+		}
+		// Noon keeps an early day start hour from moving the date back a day.
+		day = t.Add(12 * time.Hour)
+	}
+	days := 1
+	if v := q.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 31 {
+			writeInvalidParameter(w, "days")
+			return
+		}
+		days = n
+	}
+	minMinutes := s.cfg.Alerts.UncategorizedMinutes
+	if v := q.Get("min_minutes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeInvalidParameter(w, "min_minutes")
+			return
+		}
+		minMinutes = n
+	}
+
+	end := s.cfg.NextDayStart(day)
+	start := end.AddDate(0, 0, -days)
+	apps, err := s.reports.Uncategorized(r.Context(), start, end, minMinutes)
+	if err != nil {
+		writeInternalError(w, "failed to build report", err)
+		return
+	}
+
+	writeJSONFields(w, r, struct {
+		Start      time.Time          `json:"start"`
+		End        time.Time          `json:"end"`
+		MinMinutes int                `json:"min_minutes"`
+		Apps       []uncategorizedApp `json:"apps"`
+	}{
+		Start:      start,
+		End:        end,
+		MinMinutes: minMinutes,
+		Apps:       newUncategorizedApps(apps),
+	})
+}
+
+// categoryNames returns the categories the hub knows of, sorted: those
+// from the config, goals and agents, and the built-in ones.
+func (s *Server) categoryNames() []string {
+	seen := map[string]bool{category.Calls: true}
+	for name := range s.cfg.Categories {
+		seen[name] = true
+	}
+	for _, g := range s.cfg.Goals {
+		seen[g.Category] = true
+	}
+	for name := range s.runner.CategoryDisplays() {
+		seen[name] = true
+	}
+	delete(seen, category.Uncategorized)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handlePutAppCategory assigns an app a category on every device, over the
+// config's rules, and recategorizes its stored sessions. The body is
+// {"category": "..."}. The app ID is path-escaped, as in the category_url
+// of /reports/uncategorized.
+func (s *Server) handlePutAppCategory(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Category string `json:"category"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid request body", err.Error())
+		return
+	}
+	req.Category = strings.TrimSpace(req.Category)
+	if known := s.categoryNames(); !slices.Contains(known, req.Category) {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "unknown category",
+			map[string]any{"category": req.Category, "categories": known})
+		return
+	}
+	s.setAppCategory(w, r, req.Category)
+}
+
+// handleDeleteAppCategory removes an app's assigned category, leaving it
+// to the config's rules again.
+func (s *Server) handleDeleteAppCategory(w http.ResponseWriter, r *http.Request) {
+	s.setAppCategory(w, r, "")
+}
+
+func (s *Server) setAppCategory(w http.ResponseWriter, r *http.Request, cat string) {
+	ctx := r.Context()
+	appID := r.PathValue("app")
+
+	if err := s.store.SetAppCategory(ctx, appID, cat, time.Now()); err != nil {
+		writeInternalError(w, "failed to set app category", err)
+		return
+	}
+	changes, err := s.store.RecategorizeApp(ctx, appID, s.categories.Categorize)
+	if err != nil {
+		writeInternalError(w, "failed to recategorize sessions", err)
+		return
+	}
+	var sessions int64
+	for _, c := range changes {
+		sessions += c.Sessions
+	}
+
+	if cat == "" {
+		s.audit(ctx, r, "app.uncategorize", appID, nil)
+	} else {
+		s.audit(ctx, r, "app.categorize", appID, map[string]string{"category": cat})
+	}
+
+	// Sessions counts the stored sessions whose category changed.
+	writeJSON(w, struct {
+		AppID    string `json:"app_id"`
+		Category string `json:"category,omitempty"`
+		Sessions int64  `json:"sessions"`
+	}{
+		AppID:    appID,
+		Category: cat,
+		Sessions: sessions,
+	})
+}
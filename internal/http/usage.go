@@ -0,0 +1,268 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/limits"
+	"screentime-agent/internal/storage"
+)
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	var deviceID *string
+	if v := r.URL.Query().Get("device_id"); v != "" {
+		deviceID = &v
+	}
+	s.serveUsage(w, r, deviceID, nil)
+}
+
+// serveUsage writes per-device usage for the request's period. When keep
+// is set, only devices it accepts are included.
+func (s *Server) serveUsage(w http.ResponseWriter, r *http.Request, deviceID *string, keep func(string) bool) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	loc, err := s.requestLocation(r)
+	if err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
+
+	period := q.Get("period")
+	if period == "" {
+		period = "today"
+	}
+	start, end, err := resolvePeriod(period, time.Now().In(loc), s.cfg.DayStartHour, s.cfg.FirstWeekday())
+	if err != nil {
+		writeInvalidParameter(w, "period")
+		return
+	}
+
+	entries, err := s.store.GetUsageBetween(ctx, start.UTC(), end.UTC(), deviceID)
+	if err != nil {
+		writeInternalError(w, "failed to compute usage", err)
+		return
+	}
+	if keep != nil {
+		mine := entries[:0]
+		for _, e := range entries {
+			if keep(e.DeviceID) {
+				mine = append(mine, e)
+			}
+		}
+		entries = mine
+	}
+
+	names, err := s.deviceNames(ctx)
+	if err != nil {
+		writeInternalError(w, "failed to get device names", err)
+		return
+	}
+
+	resp := struct {
+		Period          string                            `json:"period"`
+		Start           time.Time                         `json:"start"`
+		End             time.Time                         `json:"end"`
+		Granularity     string                            `json:"granularity,omitempty"`
+		DeviceUsage     []deviceUsage                     `json:"device_usage"`
+		Series          []seriesBucket                    `json:"series,omitempty"`
+		Unmonitored     []unmonitored                     `json:"unmonitored,omitempty"`
+		CategoryDisplay map[string]config.CategoryDisplay `json:"category_display,omitempty"`
+	}{
+		Period:          period,
+		Start:           start,
+		End:             end,
+		DeviceUsage:     groupUsageByDevice(entries, s.format, names),
+		CategoryDisplay: s.runner.CategoryDisplays(),
+	}
+
+	resp.Unmonitored, err = s.buildUnmonitored(ctx, start, end)
+	if err != nil {
+		writeInternalError(w, "failed to get hub downtime", err)
+		return
+	}
+
+	if g := q.Get("granularity"); g != "" {
+		buckets, err := makeBuckets(start, end, g, s.cfg.DayStartHour)
+		if err != nil {
+			writeInvalidParameter(w, "granularity")
+			return
+		}
+		series, err := s.buildSeries(ctx, buckets, deviceID, keep, names)
+		if err != nil {
+			writeInternalError(w, "failed to compute usage series", err)
+			return
+		}
+		resp.Granularity = g
+		resp.Series = series
+	}
+
+	writeJSONFields(w, r, resp)
+}
+
+// unmonitored is a stretch of a response's period when the hub was down,
+// so usage in it went unrecorded rather than not happening.
+type unmonitored struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Seconds int64     `json:"seconds"`
+}
+
+// buildUnmonitored returns the hub downtime in [start, end), clipped to it
+// and in start's location.
+func (s *Server) buildUnmonitored(ctx context.Context, start, end time.Time) ([]unmonitored, error) {
+	down, err := s.store.GetDowntime(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var out []unmonitored
+	for _, d := range down {
+		from, to := d.StartTime, d.EndTime
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		out = append(out, unmonitored{
+			Start:   from.In(start.Location()),
+			End:     to.In(start.Location()),
+			Seconds: int64(to.Sub(from).Seconds()),
+		})
+	}
+	return out, nil
+}
+
+type seriesApp struct {
+	DeviceID     string `json:"device_id"`
+	DisplayName  string `json:"display_name,omitempty"`
+	AppID        string `json:"app_id"`
+	AppName      string `json:"app_name"`
+	Category     string `json:"category"`
+	TotalSeconds int64  `json:"total_seconds"`
+}
+
+type seriesBucket struct {
+	Start      time.Time        `json:"start"`
+	End        time.Time        `json:"end"`
+	Apps       []seriesApp      `json:"apps"`
+	Categories map[string]int64 `json:"categories"`
+}
+
+func (s *Server) buildSeries(ctx context.Context, buckets []storage.Bucket, deviceID *string, keep func(string) bool, names map[string]string) ([]seriesBucket, error) {
+	rows, err := s.store.GetUsageBuckets(ctx, buckets, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]seriesBucket, len(buckets))
+	for i, b := range buckets {
+		out[i] = seriesBucket{
+			Start:      b.Start,
+			End:        b.End,
+			Apps:       []seriesApp{},
+			Categories: map[string]int64{},
+		}
+	}
+	for _, row := range rows {
+		if keep != nil && !keep(row.DeviceID) {
+			continue
+		}
+		cat := s.categories.Categorize(row.AppID, row.AppName)
+		sb := &out[row.Bucket]
+		sb.Apps = append(sb.Apps, seriesApp{
+			DeviceID:     row.DeviceID,
+			DisplayName:  names[row.DeviceID],
+			AppID:        row.AppID,
+			AppName:      row.AppName,
+			Category:     cat,
+			TotalSeconds: row.TotalSeconds,
+		})
+		sb.Categories[cat] += row.TotalSeconds
+	}
+	return out, nil
+}
+
+type currentActivity struct {
+	DeviceID         string    `json:"device_id"`
+	DisplayName      string    `json:"display_name,omitempty"`
+	Slot             string    `json:"slot,omitempty"`
+	AppID            string    `json:"app_id"`
+	AppName          string    `json:"app_name"`
+	Category         string    `json:"category"`
+	State            string    `json:"state"`
+	IdleReason       string    `json:"idle_reason,omitempty"`
+	StartTime        time.Time `json:"start_time"`
+	SessionSeconds   int64     `json:"session_seconds"`
+	ExceptionLabel   string    `json:"exception_label,omitempty"`
+	RemainingSeconds *int64    `json:"remaining_seconds,omitempty"`
+	// GraceRemainingSeconds counts down to enforcement once
+	// RemainingSeconds reaches zero.
+	GraceRemainingSeconds *int64 `json:"grace_remaining_seconds,omitempty"`
+}
+
+// buildCurrentActivity snapshots the running sessions per device and slot.
+// When the device belongs to a user with a budget for the app's category,
+// the time left in that budget is included. Devices are named from names.
+func (s *Server) buildCurrentActivity(ctx context.Context, deviceID *string, dayStart, now time.Time, names map[string]string) ([]currentActivity, error) {
+	cur, err := s.store.GetCurrentSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	budgets := make(map[string]map[string][]limits.Budget) // user -> category
+	var out []currentActivity
+	for _, cs := range cur {
+		if deviceID != nil && cs.DeviceID != *deviceID {
+			continue
+		}
+		ca := currentActivity{
+			DeviceID:       cs.DeviceID,
+			DisplayName:    names[cs.DeviceID],
+			Slot:           cs.Slot,
+			AppID:          cs.AppID,
+			AppName:        cs.AppName,
+			Category:       s.categories.Categorize(cs.AppID, cs.AppName),
+			State:          cs.State,
+			IdleReason:     cs.IdleReason,
+			StartTime:      cs.StartTime.In(now.Location()),
+			SessionSeconds: int64(cs.LastSeenTime.Sub(cs.StartTime).Seconds()),
+			ExceptionLabel: cs.ExceptionLabel,
+		}
+
+		for _, u := range s.cfg.Users {
+			if !s.cfg.Attributed(u, cs.DeviceID, cs.Account) {
+				continue
+			}
+			if _, ok := budgets[u.ID]; !ok {
+				totals, err := s.userCategoryTotals(ctx, u, dayStart.UTC(), now.UTC())
+				if err != nil {
+					return nil, err
+				}
+				weekTotals, err := s.userCategoryTotals(ctx, u, s.cfg.WeekStart(now).UTC(), now.UTC())
+				if err != nil {
+					return nil, err
+				}
+				goals, grace := s.cfg.GoalsFor(u), s.cfg.Enforcement.Grace()
+				budgets[u.ID] = make(map[string][]limits.Budget)
+				for _, b := range append(limits.Budgets(goals, totals, grace), limits.WeeklyBudgets(goals, weekTotals, grace)...) {
+					budgets[u.ID][b.Category] = append(budgets[u.ID][b.Category], b)
+				}
+			}
+			// The tightest of the user's daily and weekly budgets wins.
+			for _, b := range budgets[u.ID][ca.Category] {
+				if ca.RemainingSeconds == nil || b.RemainingSeconds < *ca.RemainingSeconds ||
+					b.RemainingSeconds == *ca.RemainingSeconds && b.GraceRemainingSeconds < *ca.GraceRemainingSeconds {
+					secs, grace := b.RemainingSeconds, b.GraceRemainingSeconds
+					ca.RemainingSeconds = &secs
+					ca.GraceRemainingSeconds = &grace
+				}
+			}
+		}
+
+		out = append(out, ca)
+	}
+	return out, nil
+}
@@ -0,0 +1,40 @@
+package http
+
+import "net/http"
+
+// userFilter resolves the {id} path value to a user and returns the
+// device_id filter and device predicate for the user-scoped endpoints. A
+// device_id outside the user's devices simply matches nothing.
+func (s *Server) userFilter(w http.ResponseWriter, r *http.Request) (*string, func(string) bool, bool) {
+	u, ok := s.cfg.User(r.PathValue("id"))
+	if !ok {
+		writeNotFound(w, "user not found")
+		return nil, nil, false
+	}
+
+	var deviceID *string
+	if v := r.URL.Query().Get("device_id"); v != "" {
+		deviceID = &v
+	}
+	return deviceID, u.HasDevice, true
+}
+
+// handleUserSessions lists closed sessions across all of a user's devices,
+// with the same filters as /sessions.
+func (s *Server) handleUserSessions(w http.ResponseWriter, r *http.Request) {
+	deviceID, keep, ok := s.userFilter(w, r)
+	if !ok {
+		return
+	}
+	s.serveSessions(w, r, deviceID, keep)
+}
+
+// handleUserUsage reports usage across all of a user's devices, with the
+// same parameters as /usage.
+func (s *Server) handleUserUsage(w http.ResponseWriter, r *http.Request) {
+	deviceID, keep, ok := s.userFilter(w, r)
+	if !ok {
+		return
+	}
+	s.serveUsage(w, r, deviceID, keep)
+}
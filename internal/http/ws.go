@@ -0,0 +1,326 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/storage"
+	"screentime-agent/pkg/websocket"
+)
+
+const (
+	// wsStatusInterval is how often /ws checks for session changes; reads
+	// go through the status cache, so many viewers cost one query.
+	wsStatusInterval = time.Second
+	// wsUsageInterval is how often /ws recomputes today's usage.
+	wsUsageInterval = 10 * time.Second
+	// wsPingInterval is how often /ws pings the client. A client that has
+	// sent nothing, not even a pong, for wsPongWait is dropped.
+	wsPingInterval = 20 * time.Second
+	wsPongWait     = 2*wsPingInterval + 5*time.Second
+	wsWriteTimeout = 10 * time.Second
+)
+
+// wsSession is a current session as streamed by /ws. Ended is set, and
+// nothing else but the device and slot, when a session goes away.
+type wsSession struct {
+	DeviceID     string     `json:"device_id"`
+	DisplayName  string     `json:"display_name,omitempty"`
+	Slot         string     `json:"slot,omitempty"`
+	AppID        string     `json:"app_id,omitempty"`
+	AppName      string     `json:"app_name,omitempty"`
+	State        string     `json:"state,omitempty"`
+	IdleReason   string     `json:"idle_reason,omitempty"`
+	StartTime    *time.Time `json:"start_time,omitempty"`
+	LastSeenTime *time.Time `json:"last_seen_time,omitempty"`
+	Ended        bool       `json:"ended,omitempty"`
+}
+
+// wsUsage is one app's usage since the start of the day. Seconds is the
+// change since the last message, and equals TotalSeconds in a snapshot.
+type wsUsage struct {
+	DeviceID     string `json:"device_id"`
+	DisplayName  string `json:"display_name,omitempty"`
+	AppID        string `json:"app_id"`
+	AppName      string `json:"app_name"`
+	Account      string `json:"account,omitempty"`
+	Seconds      int64  `json:"seconds"`
+	TotalSeconds int64  `json:"total_seconds"`
+}
+
+// wsMessage is one message sent on /ws. A "snapshot" carries every
+// current session and all of today's usage; it is sent on connect and when
+// the day rolls over. "session" carries sessions that started, changed app
+// or state, or ended, and "usage" the apps whose usage grew.
+type wsMessage struct {
+	Type     string      `json:"type"`
+	Now      time.Time   `json:"now"`
+	DayStart *time.Time  `json:"day_start,omitempty"`
+	Sessions []wsSession `json:"sessions,omitempty"`
+	Usage    []wsUsage   `json:"usage,omitempty"`
+}
+
+type wsSessionKey struct{ deviceID, slot string }
+
+type wsUsageKey struct{ deviceID, appID, account string }
+
+// wsStream tracks what one /ws client has been sent, so that later
+// messages carry only what changed.
+type wsStream struct {
+	s        *Server
+	deviceID *string
+	loc      *time.Location
+
+	dayStart time.Time
+	sessions map[wsSessionKey]storage.CurrentSession
+	usage    map[wsUsageKey]int64
+}
+
+func (st *wsStream) keep(deviceID string) bool {
+	return st.deviceID == nil || *st.deviceID == deviceID
+}
+
+// snapshot returns everything as of now and remembers it as sent.
+func (st *wsStream) snapshot(ctx context.Context, now time.Time) (wsMessage, error) {
+	st.dayStart = config.DayStartAt(now, st.s.cfg.DayStartHour)
+	st.sessions = make(map[wsSessionKey]storage.CurrentSession)
+	st.usage = make(map[wsUsageKey]int64)
+
+	dayStart := st.dayStart.In(st.loc)
+	msg := wsMessage{Type: "snapshot", Now: now.In(st.loc), DayStart: &dayStart, Sessions: []wsSession{}, Usage: []wsUsage{}}
+	sessions, err := st.sessionChanges(ctx)
+	if err != nil {
+		return msg, err
+	}
+	msg.Sessions = append(msg.Sessions, sessions...)
+	usage, err := st.usageChanges(ctx, now)
+	if err != nil {
+		return msg, err
+	}
+	msg.Usage = append(msg.Usage, usage...)
+	return msg, nil
+}
+
+// sessionChanges returns the sessions that differ from those last sent.
+// Only a new app, state, idle reason or start counts; LastSeenTime moves on
+// every poll.
+func (st *wsStream) sessionChanges(ctx context.Context) ([]wsSession, error) {
+	cur, err := st.s.status.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []wsSession
+	seen := make(map[wsSessionKey]bool)
+	for _, cs := range cur {
+		if !st.keep(cs.DeviceID) {
+			continue
+		}
+		k := wsSessionKey{cs.DeviceID, cs.Slot}
+		seen[k] = true
+		if prev, ok := st.sessions[k]; ok && prev.AppID == cs.AppID && prev.AppName == cs.AppName &&
+			prev.State == cs.State && prev.IdleReason == cs.IdleReason && prev.StartTime.Equal(cs.StartTime) {
+			continue
+		}
+		st.sessions[k] = cs
+		start, lastSeen := cs.StartTime.In(st.loc), cs.LastSeenTime.In(st.loc)
+		out = append(out, wsSession{
+			DeviceID:     cs.DeviceID,
+			Slot:         cs.Slot,
+			AppID:        cs.AppID,
+			AppName:      cs.AppName,
+			State:        cs.State,
+			IdleReason:   cs.IdleReason,
+			StartTime:    &start,
+			LastSeenTime: &lastSeen,
+		})
+	}
+	for k := range st.sessions {
+		if !seen[k] {
+			delete(st.sessions, k)
+			out = append(out, wsSession{DeviceID: k.deviceID, Slot: k.slot, Ended: true})
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	names, err := st.s.deviceNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].DisplayName = names[out[i].DeviceID]
+	}
+	return out, nil
+}
+
+// usageChanges returns the apps whose usage today grew since last sent.
+func (st *wsStream) usageChanges(ctx context.Context, now time.Time) ([]wsUsage, error) {
+	entries, err := st.s.store.GetUsageBetween(ctx, st.dayStart.UTC(), now.UTC(), st.deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []wsUsage
+	for _, e := range entries {
+		k := wsUsageKey{e.DeviceID, e.AppID, e.Account}
+		prev, ok := st.usage[k]
+		if ok && e.TotalSeconds <= prev {
+			continue
+		}
+		st.usage[k] = e.TotalSeconds
+		out = append(out, wsUsage{
+			DeviceID:     e.DeviceID,
+			AppID:        e.AppID,
+			AppName:      e.AppName,
+			Account:      e.Account,
+			Seconds:      e.TotalSeconds - prev,
+			TotalSeconds: e.TotalSeconds,
+		})
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	names, err := st.s.deviceNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].DisplayName = names[out[i].DeviceID]
+	}
+	return out, nil
+}
+
+// handleWS streams live status over a WebSocket: a snapshot on connect,
+// then session and usage changes as they happen. The server pings the
+// client and answers its pings; the client need not send anything else.
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	st := &wsStream{s: s}
+	if v := r.URL.Query().Get("device_id"); v != "" {
+		st.deviceID = &v
+	}
+	var err error
+	if st.loc, err = s.requestLocation(r); err != nil {
+		writeInvalidParameter(w, "tz")
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		log.Printf("ws: %s: %v", r.RemoteAddr, err)
+		return
+	}
+	s.wsClients.Add(1)
+	defer s.wsClients.Add(-1)
+
+	// The request context ends with the handler, not with the hijacked
+	// connection, so the reader below cancels it when the client goes.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	extend := func() { conn.SetReadDeadline(time.Now().Add(wsPongWait)) }
+	conn.OnPong = extend
+	extend()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				// Closes from either end need no mention.
+				if !errors.Is(err, websocket.ErrClosed) && !errors.Is(err, net.ErrClosed) {
+					log.Printf("ws: %s: %v", conn.RemoteAddr(), err)
+				}
+				return
+			}
+			// Clients have nothing to say yet; any message shows they are
+			// still there.
+			extend()
+		}
+	}()
+
+	send := func(msg wsMessage) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(websocket.OpText, data, wsWriteTimeout)
+	}
+
+	if err := s.streamWS(ctx, st, send, conn); err != nil && ctx.Err() == nil {
+		log.Printf("ws: %s: %v", conn.RemoteAddr(), err)
+	}
+	conn.Close(websocket.CloseGoingAway, "")
+}
+
+// streamWS sends st's messages with send until ctx is done or a send
+// fails, pinging conn in between.
+func (s *Server) streamWS(ctx context.Context, st *wsStream, send func(wsMessage) error, conn *websocket.Conn) error {
+	msg, err := st.snapshot(ctx, time.Now().In(s.loc))
+	if err != nil {
+		return err
+	}
+	if err := send(msg); err != nil {
+		return err
+	}
+
+	status := time.NewTicker(wsStatusInterval)
+	defer status.Stop()
+	usage := time.NewTicker(wsUsageInterval)
+	defer usage.Stop()
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.closing:
+			return nil
+
+		case <-ping.C:
+			if err := conn.Ping(wsWriteTimeout); err != nil {
+				return err
+			}
+
+		case <-status.C:
+			now := time.Now().In(s.loc)
+			if !config.DayStartAt(now, s.cfg.DayStartHour).Equal(st.dayStart) {
+				if msg, err = st.snapshot(ctx, now); err != nil {
+					return err
+				}
+				if err := send(msg); err != nil {
+					return err
+				}
+				continue
+			}
+			sessions, err := st.sessionChanges(ctx)
+			if err != nil {
+				return err
+			}
+			if len(sessions) > 0 {
+				if err := send(wsMessage{Type: "session", Now: now.In(st.loc), Sessions: sessions}); err != nil {
+					return err
+				}
+			}
+
+		case <-usage.C:
+			now := time.Now().In(s.loc)
+			deltas, err := st.usageChanges(ctx, now)
+			if err != nil {
+				return err
+			}
+			if len(deltas) > 0 {
+				if err := send(wsMessage{Type: "usage", Now: now.In(st.loc), Usage: deltas}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package limits evaluates per-user time budgets and downtime against usage.
+package limits
+
+import (
+	"sort"
+	"time"
+
+	"screentime-agent/internal/config"
+)
+
+// Budget states.
+const (
+	StateOK       = "ok"       // time left in the budget
+	StateGrace    = "grace"    // limit reached, inside the grace window
+	StateEnforced = "enforced" // limit and grace both used up
+	// StateScreenFree marks a budget with nothing left because a
+	// screen-free period is in effect.
+	StateScreenFree = "screen_free"
+)
+
+// Budget periods.
+const (
+	PeriodDay  = "day"
+	PeriodWeek = "week"
+)
+
+// CategoryAll is the category of a device's budget for all its usage.
+const CategoryAll = "*"
+
+// Budget is the state of one category ceiling for a user or device.
+type Budget struct {
+	Category         string
+	Period           string // PeriodDay or PeriodWeek
+	LimitSeconds     int64
+	UsedSeconds      int64
+	RemainingSeconds int64
+	// GraceRemainingSeconds is how much of the grace window is left; the
+	// whole window until the limit is reached.
+	GraceRemainingSeconds int64
+	State                 string
+}
+
+// Budgets turns the max_minutes goals into budgets against today's
+// per-category totals. Usage past a limit first eats into the grace window
+// before the budget counts as enforced.
+func Budgets(goals []config.GoalConfig, totals map[string]int64, grace time.Duration) []Budget {
+	return budgets(PeriodDay, goals, totals, grace, func(g config.GoalConfig) int { return g.MaxMinutes })
+}
+
+// WeeklyBudgets is Budgets for the max_weekly_minutes goals against this
+// week's per-category totals.
+func WeeklyBudgets(goals []config.GoalConfig, totals map[string]int64, grace time.Duration) []Budget {
+	return budgets(PeriodWeek, goals, totals, grace, func(g config.GoalConfig) int { return g.MaxWeeklyMinutes })
+}
+
+// DeviceBudgets turns a device's own limits into daily and weekly budgets
+// against the per-category totals of all usage on it today and this week.
+// max_minutes becomes a CategoryAll budget against the device's total.
+func DeviceBudgets(d config.DeviceConfig, today, week map[string]int64, grace time.Duration) []Budget {
+	goals := d.Goals
+	if d.MaxMinutes > 0 {
+		goals = append(goals[:len(goals):len(goals)], config.GoalConfig{Category: CategoryAll, MaxMinutes: d.MaxMinutes})
+	}
+	return append(Budgets(goals, withAll(today), grace), WeeklyBudgets(goals, withAll(week), grace)...)
+}
+
+// withAll copies totals, adding their sum under CategoryAll.
+func withAll(totals map[string]int64) map[string]int64 {
+	out := make(map[string]int64, len(totals)+1)
+	for cat, secs := range totals {
+		out[cat] = secs
+		out[CategoryAll] += secs
+	}
+	return out
+}
+
+func budgets(period string, goals []config.GoalConfig, totals map[string]int64, grace time.Duration, minutes func(config.GoalConfig) int) []Budget {
+	graceSecs := int64(grace.Seconds())
+
+	var out []Budget
+	for _, g := range goals {
+		if minutes(g) <= 0 {
+			continue
+		}
+		limit := int64(minutes(g)) * 60
+		used := totals[g.Category]
+		b := Budget{
+			Category:              g.Category,
+			Period:                period,
+			LimitSeconds:          limit,
+			UsedSeconds:           used,
+			RemainingSeconds:      limit - used,
+			GraceRemainingSeconds: graceSecs,
+			State:                 StateOK,
+		}
+		if b.RemainingSeconds <= 0 {
+			over := -b.RemainingSeconds
+			b.RemainingSeconds = 0
+			b.GraceRemainingSeconds = graceSecs - over
+			b.State = StateGrace
+			if b.GraceRemainingSeconds <= 0 {
+				b.GraceRemainingSeconds = 0
+				b.State = StateEnforced
+			}
+		}
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Category < out[j].Category })
+	return out
+}
+
+// Downtime is one occurrence of a downtime window.
+type Downtime struct {
+	Start  time.Time
+	End    time.Time
+	Active bool
+}
+
+// NextDowntime returns the current downtime if one is in effect, otherwise
+// the soonest upcoming one. ok is false when the user has no downtime.
+func NextDowntime(ranges []config.TimeRange, now time.Time) (Downtime, bool) {
+	var best Downtime
+	found := false
+	for _, r := range ranges {
+		start, end := r.Next(now)
+		dt := Downtime{Start: start, End: end, Active: !start.After(now)}
+		if !found || dt.Active && !best.Active || dt.Active == best.Active && dt.Start.Before(best.Start) {
+			best = dt
+			found = true
+		}
+	}
+	return best, found
+}
+
+// NextScreenFree is NextDowntime for screen-free periods, for days
+// beginning at dayStartHour.
+func NextScreenFree(periods []config.ScreenFreePeriod, now time.Time, dayStartHour int) (Downtime, bool) {
+	var best Downtime
+	found := false
+	for _, p := range periods {
+		start, end, ok := p.Next(now, dayStartHour)
+		if !ok {
+			continue
+		}
+		dt := Downtime{Start: start, End: end, Active: !start.After(now)}
+		if !found || dt.Active && !best.Active || dt.Active == best.Active && dt.Start.Before(best.Start) {
+			best = dt
+			found = true
+		}
+	}
+	return best, found
+}
+
+// ScreenFree zeroes what is left of budgets while a screen-free period is
+// in effect, however little was used.
+func ScreenFree(budgets []Budget) []Budget {
+	out := make([]Budget, len(budgets))
+	for i, b := range budgets {
+		b.RemainingSeconds = 0
+		b.GraceRemainingSeconds = 0
+		b.State = StateScreenFree
+		out[i] = b
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package limits
+
+import (
+	"sort"
+	"time"
+
+	"screentime-agent/internal/config"
+)
+
+// PeriodTotals is one day's or week's per-category usage.
+type PeriodTotals struct {
+	Start  time.Time
+	Totals map[string]int64
+}
+
+// Outcome is how one budget would have fared over a run of past periods.
+type Outcome struct {
+	Category           string
+	Period             string // PeriodDay or PeriodWeek
+	LimitSeconds       int64
+	Periods            int         // periods evaluated
+	Hit                int         // periods the limit was reached
+	Enforced           int         // periods the grace window ran out too
+	AverageUsedSeconds int64       // mean usage per period
+	MaxUsedSeconds     int64       // heaviest period
+	HitStarts          []time.Time // start of each period the limit was reached
+}
+
+// Simulate evaluates goals against past usage as if they had been in
+// effect: days against max_minutes, weeks against max_weekly_minutes.
+func Simulate(goals []config.GoalConfig, days, weeks []PeriodTotals, grace time.Duration) []Outcome {
+	var out []Outcome
+	out = append(out, simulate(PeriodDay, days, func(totals map[string]int64) []Budget {
+		return Budgets(goals, totals, grace)
+	})...)
+	out = append(out, simulate(PeriodWeek, weeks, func(totals map[string]int64) []Budget {
+		return WeeklyBudgets(goals, totals, grace)
+	})...)
+	return out
+}
+
+func simulate(period string, periods []PeriodTotals, budgets func(map[string]int64) []Budget) []Outcome {
+	byCategory := make(map[string]*Outcome)
+	used := make(map[string]int64)
+	for _, p := range periods {
+		for _, b := range budgets(p.Totals) {
+			o, ok := byCategory[b.Category]
+			if !ok {
+				o = &Outcome{Category: b.Category, Period: period, LimitSeconds: b.LimitSeconds}
+				byCategory[b.Category] = o
+			}
+			o.Periods++
+			used[b.Category] += b.UsedSeconds
+			if b.UsedSeconds > o.MaxUsedSeconds {
+				o.MaxUsedSeconds = b.UsedSeconds
+			}
+			if b.State == StateOK {
+				continue
+			}
+			o.Hit++
+			o.HitStarts = append(o.HitStarts, p.Start)
+			if b.State == StateEnforced {
+				o.Enforced++
+			}
+		}
+	}
+
+	out := make([]Outcome, 0, len(byCategory))
+	for cat, o := range byCategory {
+		o.AverageUsedSeconds = used[cat] / int64(o.Periods)
+		out = append(out, *o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Category < out[j].Category })
+	return out
+}
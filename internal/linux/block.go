@@ -0,0 +1,56 @@
+package linux
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// blockTimeout bounds how long the block command may run.
+const blockTimeout = 10 * time.Second
+
+// handleBlock runs the configured block command when the hub enforces a
+// budget or a screen-free period. The body is JSON:
+// {"reason", "category", "period", "timestamp"}.
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	if len(s.config.BlockCommand) == 0 {
+		http.Error(w, "no block_command configured", http.StatusNotImplemented)
+		return
+	}
+
+	var b struct {
+		Reason   string `json:"reason"`
+		Category string `json:"category"`
+		Period   string `json:"period"`
+	}
+	if !s.readHubRequest(w, r, "/block", &b) {
+		return
+	}
+
+	if err := runBlockCommand(s.config.BlockCommand, b.Reason, b.Category, b.Period); err != nil {
+		log.Printf("error running block command: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func runBlockCommand(command []string, reason, category, period string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), blockTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"SCREENTIME_BLOCK_REASON="+reason,
+		"SCREENTIME_BLOCK_CATEGORY="+category,
+		"SCREENTIME_BLOCK_PERIOD="+period,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", command[0], err, out)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"screentime-agent/pkg/mozlz4"
 )
@@ -15,12 +16,14 @@ import (
 // BrowserDetector detects the active browser tab URL
 type BrowserDetector struct {
 	firefoxRecoveryPath string
+	chromiumDebugPort   int
 }
 
 // NewBrowserDetector creates a new browser detector
-func NewBrowserDetector(firefoxProfile string) *BrowserDetector {
+func NewBrowserDetector(firefoxProfile string, chromiumDebugPort int) *BrowserDetector {
 	return &BrowserDetector{
 		firefoxRecoveryPath: firefoxProfile,
+		chromiumDebugPort:   chromiumDebugPort,
 	}
 }
 
@@ -132,16 +135,45 @@ func extractDomain(rawURL string) string {
 	return host
 }
 
-// DetectChromium gets the active tab from Chromium-based browsers
-// This is a placeholder - Chromium doesn't have a simple session file like Firefox
-func (b *BrowserDetector) DetectChromium() (*BrowserTab, error) {
-	// Chromium stores session data in LevelDB format which is more complex to parse.
-	// For now, we'll return nil and fall back to window title.
-	// Future enhancement: use native messaging or browser extension
-	return nil, nil
+// DetectChromium gets the active tab from a Chromium-based browser, over
+// the DevTools protocol when a debugging port is configured, finding it by
+// the focused window's title, and otherwise from its session file. The file is only flushed every few seconds, so the
+// tab can lag a switch; it's a fallback for browsers without another source.
+func (b *BrowserDetector) DetectChromium(windowTitle string) (*BrowserTab, error) {
+	var devtoolsErr error
+	if b.chromiumDebugPort > 0 {
+		tab, err := DetectChromiumDevTools(b.chromiumDebugPort, windowTitle)
+		if err == nil {
+			return tab, nil
+		}
+		devtoolsErr = err
+	}
+
+	sessionPath, err := FindChromiumSessionPath()
+	if err != nil {
+		if devtoolsErr != nil {
+			return nil, fmt.Errorf("%w (devtools: %v)", err, devtoolsErr)
+		}
+		return nil, err
+	}
+
+	f, err := os.Open(sessionPath)
+	if err != nil {
+		return nil, fmt.Errorf("open session file: %w", err)
+	}
+	defer f.Close()
+
+	tab, err := parseSNSS(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", sessionPath, err)
+	}
+	return tab, nil
 }
 
-// FindChromiumSessionPath attempts to find the Chromium session path
+// FindChromiumSessionPath finds the most recently written session file of
+// the Chromium-based browsers' default profiles, which is the browser in
+// use. Newer versions keep timestamped files under Sessions/; older ones
+// write "Current Session" in the profile itself.
 func FindChromiumSessionPath() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -149,19 +181,32 @@ func FindChromiumSessionPath() (string, error) {
 	}
 
 	// Try common locations
-	paths := []string{
-		filepath.Join(home, ".config", "chromium", "Default", "Current Session"),
-		filepath.Join(home, ".config", "google-chrome", "Default", "Current Session"),
-		filepath.Join(home, ".config", "BraveSoftware", "Brave-Browser", "Default", "Current Session"),
+	profiles := []string{
+		filepath.Join(home, ".config", "chromium", "Default"),
+		filepath.Join(home, ".config", "google-chrome", "Default"),
+		filepath.Join(home, ".config", "BraveSoftware", "Brave-Browser", "Default"),
+		filepath.Join(home, ".config", "vivaldi", "Default"),
+		filepath.Join(home, ".config", "opera"),
 	}
 
-	for _, p := range paths {
-		if _, err := os.Stat(p); err == nil {
-			return p, nil
+	var (
+		newest   string
+		newestAt time.Time
+	)
+	for _, profile := range profiles {
+		candidates, _ := filepath.Glob(filepath.Join(profile, "Sessions", "Session_*"))
+		candidates = append(candidates, filepath.Join(profile, "Current Session"))
+		for _, p := range candidates {
+			info, err := os.Stat(p)
+			if err != nil || !info.ModTime().After(newestAt) {
+				continue
+			}
+			newest, newestAt = p, info.ModTime()
 		}
 	}
 
-	return "", fmt.Errorf("no chromium session found")
+	if newest == "" {
+		return "", fmt.Errorf("no chromium session found")
+	}
+	return newest, nil
 }
-
-
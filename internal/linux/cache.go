@@ -0,0 +1,53 @@
+package linux
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultDetectionCacheMS is how long a detection is reused when the config
+// doesn't say otherwise. It is short enough for sub-second hub polling while
+// still absorbing several pollers or dashboards asking at once.
+const DefaultDetectionCacheMS = 200
+
+// detectionCache reuses the last detection for a short TTL, which ttl
+// returns afresh on each call. Callers that arrive while a detection is
+// running wait for it rather than starting another, so concurrent requests
+// cost one round of DBus and file work.
+type detectionCache struct {
+	ttl    func() time.Duration
+	detect func() Activity
+
+	mu   sync.Mutex
+	last Activity
+	at   time.Time
+}
+
+func newDetectionCache(ttl func() time.Duration, detect func() Activity) *detectionCache {
+	return &detectionCache{ttl: ttl, detect: detect}
+}
+
+// Invalidate drops the cached activity so the next Get detects afresh.
+func (c *detectionCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.at = time.Time{}
+}
+
+// Get returns the cached activity if it is still fresh, otherwise a new
+// detection. fresh reports whether this call ran the detection.
+func (c *detectionCache) Get() (a Activity, at time.Time, fresh bool) {
+	start := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A detection that finished after this call started is as good as one
+	// of our own, whatever the TTL.
+	if !c.at.IsZero() && (c.at.After(start) || time.Since(c.at) < c.ttl()) {
+		return c.last, c.at, false
+	}
+	c.last = c.detect()
+	c.at = time.Now()
+	return c.last, c.at, true
+}
@@ -0,0 +1,119 @@
+package linux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CallConfig lists what counts as a video call
+type CallConfig struct {
+	// WindowClasses are lowercase substrings of conferencing app window classes.
+	WindowClasses []string `json:"window_classes,omitempty"`
+	// Domains are browser tab domains used for calls.
+	Domains []string `json:"domains,omitempty"`
+}
+
+// DefaultCallConfig returns the common conferencing apps and sites
+func DefaultCallConfig() CallConfig {
+	return CallConfig{
+		WindowClasses: []string{"zoom", "teams", "webex"},
+		Domains:       []string{"meet.google.com", "teams.microsoft.com", "teams.live.com", "zoom.us"},
+	}
+}
+
+// Call is an in-progress video call
+type Call struct {
+	App string // window class or tab domain
+}
+
+// CallDetector recognises a focused conferencing app that is using the
+// camera or microphone
+type CallDetector struct {
+	config CallConfig
+}
+
+// NewCallDetector creates a new call detector
+func NewCallDetector(cfg CallConfig) *CallDetector {
+	return &CallDetector{config: cfg}
+}
+
+// Detect reports a call if the focused window (or, for browsers, the tab)
+// belongs to a conferencing app and a capture stream is running. The
+// capture check is only made once the window matches, since it shells out.
+func (c *CallDetector) Detect(info *WindowInfo, tab func() *BrowserTab) (*Call, error) {
+	if info == nil {
+		return nil, nil
+	}
+
+	app := ""
+	if info.IsBrowser() {
+		if t := tab(); t != nil {
+			for _, d := range c.config.Domains {
+				if t.Domain == d || strings.HasSuffix(t.Domain, "."+d) {
+					app = t.Domain
+					break
+				}
+			}
+		}
+	} else {
+		for _, class := range c.config.WindowClasses {
+			if strings.Contains(info.Instance, class) {
+				app = info.Instance
+				break
+			}
+		}
+	}
+	if app == "" {
+		return nil, nil
+	}
+
+	inUse, err := captureInUse()
+	if err != nil {
+		return nil, err
+	}
+	if !inUse {
+		return nil, nil
+	}
+	return &Call{App: app}, nil
+}
+
+// pwNode is the subset of a pw-dump object we care about
+type pwNode struct {
+	Type string `json:"type"`
+	Info struct {
+		State string         `json:"state"`
+		Props map[string]any `json:"props"`
+	} `json:"info"`
+}
+
+// captureInUse asks PipeWire whether any microphone or camera capture
+// stream is currently running
+func captureInUse() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "pw-dump").Output()
+	if err != nil {
+		return false, fmt.Errorf("pw-dump: %w", err)
+	}
+
+	var nodes []pwNode
+	if err := json.Unmarshal(out, &nodes); err != nil {
+		return false, fmt.Errorf("parse pw-dump: %w", err)
+	}
+
+	for _, n := range nodes {
+		if n.Type != "PipeWire:Interface:Node" || n.Info.State != "running" {
+			continue
+		}
+		switch n.Info.Props["media.class"] {
+		case "Stream/Input/Audio", "Stream/Input/Video":
+			return true, nil
+		}
+	}
+	return false, nil
+}
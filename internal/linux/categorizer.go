@@ -1,7 +1,9 @@
 package linux
 
 import (
+	"sort"
 	"strings"
+	"unicode"
 )
 
 // Categorizer categorizes URLs based on domain patterns
@@ -46,4 +48,60 @@ func (c *Categorizer) Categorize(domain string) string {
 	return "uncategorized"
 }
 
+// CategorizeWorkspace returns the category for an Electron app workspace.
+// Rules are written as "app:workspace" (e.g., "discord:Homework Help") or
+// just "workspace" to match it in any app.
+// Returns "uncategorized" if no match is found
+func (c *Categorizer) CategorizeWorkspace(app, workspace string) string {
+	if workspace == "" {
+		return "uncategorized"
+	}
+
+	qualified := strings.ToLower(app + ":" + workspace)
+	bare := strings.ToLower(workspace)
+
+	for categoryName, category := range c.categories {
+		for _, w := range category.Workspaces {
+			wl := strings.ToLower(w)
+			if wl == qualified || wl == bare {
+				return categoryName
+			}
+		}
+	}
+
+	return "uncategorized"
+}
+
+// CategorizeTitle finds a category domain named in a window title, for
+// when the browser's URL can't be read: either the domain itself
+// ("khanacademy.org") or, as a whole word, its name without the top-level
+// domain ("YouTube" for youtube.com). It returns the matching domain and
+// its category.
+func (c *Categorizer) CategorizeTitle(title string) (domain, category string, ok bool) {
+	titleLower := strings.ToLower(title)
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(titleLower, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[w] = true
+	}
+
+	names := make([]string, 0, len(c.categories))
+	for name := range c.categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
+	for _, categoryName := range names {
+		for _, d := range c.categories[categoryName].Domains {
+			dl := strings.ToLower(d)
+			if strings.Contains(titleLower, dl) {
+				return d, categoryName, true
+			}
+			if name, tld, ok := strings.Cut(dl, "."); ok && !strings.Contains(tld, ".") && words[name] {
+				return d, categoryName, true
+			}
+		}
+	}
+	return "", "", false
+}
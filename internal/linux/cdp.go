@@ -0,0 +1,199 @@
+package linux
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"screentime-agent/pkg/websocket"
+)
+
+// cdpTimeout bounds one DevTools detection, connection included.
+const cdpTimeout = 2 * time.Second
+
+// tabStateExpression is evaluated in a page to tell whether it is the one
+// the user is looking at, when several tabs share the focused window's
+// title.
+const tabStateExpression = `document.visibilityState === "visible" && document.hasFocus()`
+
+// DetectChromiumDevTools gets the active tab from a Chromium-based browser
+// started with --remote-debugging-port=port, over the DevTools protocol.
+// Unlike the session file it is current the moment a tab is switched.
+// The tab is the page whose title the focused window's title starts with,
+// as Chromium titles its windows after the active tab; only when several
+// pages share that title is each of them asked whether it has focus.
+func DetectChromiumDevTools(port int, windowTitle string) (*BrowserTab, error) {
+	wsURL, err := cdpBrowserURL(port)
+	if err != nil {
+		return nil, err
+	}
+	c, err := dialCDP(wsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	var targets struct {
+		TargetInfos []struct {
+			TargetID string `json:"targetId"`
+			Type     string `json:"type"`
+			Title    string `json:"title"`
+			URL      string `json:"url"`
+		} `json:"targetInfos"`
+	}
+	if err := c.call("", "Target.getTargets", nil, &targets); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	var tabs []*BrowserTab
+	for _, t := range targets.TargetInfos {
+		if t.Type != "page" || strings.HasPrefix(t.URL, "devtools://") || !titledAfter(windowTitle, t.Title) {
+			continue
+		}
+		ids = append(ids, t.TargetID)
+		tabs = append(tabs, &BrowserTab{URL: t.URL, Title: t.Title, Domain: extractDomain(t.URL)})
+	}
+	switch len(tabs) {
+	case 0:
+		return nil, fmt.Errorf("no tab titled like the window %q", windowTitle)
+	case 1:
+		return tabs[0], nil
+	}
+
+	for i, id := range ids {
+		focused, err := c.tabFocused(id)
+		if err != nil {
+			return nil, err
+		}
+		if focused {
+			return tabs[i], nil
+		}
+	}
+	return tabs[0], nil
+}
+
+// titledAfter reports whether a browser window's title names the tab
+// title, as "<tab title> - Google Chrome" does.
+func titledAfter(windowTitle, tabTitle string) bool {
+	return tabTitle != "" && strings.HasPrefix(windowTitle, tabTitle+" - ")
+}
+
+// tabFocused evaluates tabStateExpression in the page targetID.
+func (c *cdpConn) tabFocused(targetID string) (bool, error) {
+	var attached struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := c.call("", "Target.attachToTarget", map[string]any{"targetId": targetID, "flatten": true}, &attached); err != nil {
+		return false, err
+	}
+	var eval struct {
+		Result struct {
+			Value any `json:"value"`
+		} `json:"result"`
+	}
+	evalErr := c.call(attached.SessionID, "Runtime.evaluate", map[string]any{
+		"expression":    tabStateExpression,
+		"returnByValue": true,
+	}, &eval)
+	if err := c.call("", "Target.detachFromTarget", map[string]any{"sessionId": attached.SessionID}, nil); err != nil {
+		return false, err
+	}
+	if evalErr != nil {
+		return false, nil
+	}
+	focused, _ := eval.Result.Value.(bool)
+	return focused, nil
+}
+
+// cdpBrowserURL returns the browser's DevTools WebSocket URL.
+func cdpBrowserURL(port int) (string, error) {
+	client := &http.Client{Timeout: cdpTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/json/version", port))
+	if err != nil {
+		return "", fmt.Errorf("devtools version: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("devtools version: %s", resp.Status)
+	}
+	var v struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", fmt.Errorf("parse devtools version: %w", err)
+	}
+	if v.WebSocketDebuggerURL == "" {
+		return "", fmt.Errorf("devtools version has no webSocketDebuggerUrl")
+	}
+	return v.WebSocketDebuggerURL, nil
+}
+
+// cdpConn exchanges DevTools protocol messages with a local browser.
+type cdpConn struct {
+	ws     *websocket.Conn
+	nextID int
+}
+
+func dialCDP(rawURL string) (*cdpConn, error) {
+	ws, err := websocket.Dial(rawURL, cdpTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("devtools: %w", err)
+	}
+	ws.MaxMessageSize = 16 << 20
+	ws.SetReadDeadline(time.Now().Add(cdpTimeout))
+	return &cdpConn{ws: ws}, nil
+}
+
+func (c *cdpConn) Close() error {
+	return c.ws.Close(websocket.CloseNormal, "")
+}
+
+// call sends a command, in sessionID's target when set, and decodes its
+// result into out, skipping the events that arrive meanwhile.
+func (c *cdpConn) call(sessionID, method string, params, out any) error {
+	c.nextID++
+	msg := map[string]any{"id": c.nextID, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	if sessionID != "" {
+		msg["sessionId"] = sessionID
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", method, err)
+	}
+	if err := c.ws.WriteMessage(websocket.OpText, data, cdpTimeout); err != nil {
+		return fmt.Errorf("send %s: %w", method, err)
+	}
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("read %s: %w", method, err)
+		}
+		var resp struct {
+			ID     int             `json:"id"`
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return fmt.Errorf("parse %s: %w", method, err)
+		}
+		if resp.ID != c.nextID {
+			continue
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %s", method, resp.Error.Message)
+		}
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, out)
+	}
+}
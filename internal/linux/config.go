@@ -3,29 +3,108 @@ package linux
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+
+	"screentime-agent/internal/tlsutil"
 )
 
-// Category defines URL matching rules for a category
+// Category defines URL and Electron workspace matching rules for a category
 type Category struct {
 	Domains        []string `json:"domains,omitempty"`
 	DomainSuffixes []string `json:"domain_suffixes,omitempty"`
+	// Workspaces match Discord servers or Slack workspaces, optionally
+	// qualified by app: "discord:Homework Help".
+	Workspaces []string `json:"workspaces,omitempty"`
+	// Color ("#rrggbb") and Icon (an emoji or icon name) are sent to the
+	// hub, which returns them with usage for dashboards to draw with.
+	Color string `json:"color,omitempty"`
+	Icon  string `json:"icon,omitempty"`
 }
 
 // Config holds the Linux agent configuration
 type Config struct {
+	// Version is the config format version; older files are upgraded and
+	// rewritten on load. Files from before it was added have none.
+	Version            int                 `json:"version"`
 	Listen             string              `json:"listen"`
+	// Hostname is reported to the hub to tell machines apart; empty uses
+	// the system hostname.
 	Hostname           string              `json:"hostname"`
 	Categories         map[string]Category `json:"categories"`
 	IdleWindowPatterns []string            `json:"idle_window_patterns"`
 	IgnoredWindows     []string            `json:"ignored_windows"`
 	FirefoxProfile     string              `json:"firefox_profile,omitempty"`
+	HistorySize        int                 `json:"history_size,omitempty"`
+
+	// ChromiumDebugPort is the --remote-debugging-port the Chromium-based
+	// browser was started with. When set the active tab is read over the
+	// DevTools protocol, falling back to the session file.
+	ChromiumDebugPort int `json:"chromium_debug_port,omitempty"`
+
+	// DetectionCacheMS is how long a detection is reused for further
+	// requests. 0 still coalesces concurrent requests but never reuses.
+	DetectionCacheMS int `json:"detection_cache_ms"`
+
+	// WindowEvents has the compositor push focus changes to the agent
+	// instead of being queried on every request.
+	WindowEvents bool `json:"window_events"`
+
+	// Detectors lists the enabled detectors in priority order; the first
+	// one to report an activity wins. Omitted detectors are disabled.
+	Detectors []string `json:"detectors,omitempty"`
+
+	// ExecDetectors defines custom detectors by name; list a name in
+	// Detectors to enable it at that priority.
+	ExecDetectors map[string]ExecDetectorConfig `json:"exec_detectors,omitempty"`
+
+	Games GameConfig `json:"games"`
+	Calls CallConfig `json:"calls"`
+
+	// WineNamesFile is a JSON object mapping Windows executable names
+	// (e.g. "witcher3.exe") to friendly game names. Empty means
+	// ~/.config/screentime-agent/wine-games.json.
+	WineNamesFile string `json:"wine_names_file,omitempty"`
+
+	Privacy PrivacyConfig `json:"privacy"`
+
+	// TrackTitles sends the window or tab title with the active app, so
+	// the hub can record title changes within a session without splitting
+	// it. Titles are redacted like names when privacy is enabled.
+	TrackTitles bool `json:"track_titles"`
+
+	LowPower LowPowerConfig `json:"low_power"`
+
+	// BlockCommand runs when the hub enforces a budget covering this
+	// machine (its block_path set to "/block"), e.g.
+	// ["loginctl", "lock-session"], or during a screen-free period. The
+	// reason ("limit" or "screen_free") and a budget's category and period
+	// are passed in SCREENTIME_BLOCK_REASON, SCREENTIME_BLOCK_CATEGORY and
+	// SCREENTIME_BLOCK_PERIOD.
+	BlockCommand []string `json:"block_command,omitempty"`
+
+	// HubToken is this device's token on the hub. Notifications and
+	// blocks are only accepted signed with it, or over mtls; without
+	// either /notify and /block refuse everything.
+	HubToken string `json:"hub_token,omitempty"`
+
+	// TLS serves the agent over HTTPS. With self_signed the certificate is
+	// generated next to the default config file; pin its fingerprint, which
+	// is logged on start, in the hub's tls_fingerprint for this device.
+	TLS *tlsutil.Config `json:"tls,omitempty"`
+
+	// MTLS serves the agent over mutual TLS with the certificate the hub
+	// issued when it was enrolled ("linux-agent enroll"), and answers only
+	// clients with a certificate from the hub's CA. Set mtls on the hub's
+	// device as well.
+	MTLS bool `json:"mtls,omitempty"`
 }
 
 // DefaultConfig returns a config with sensible defaults
 func DefaultConfig() *Config {
 	return &Config{
+		Version:  ConfigVersion,
 		Listen:   ":8060",
 		Hostname: "",
 		Categories: map[string]Category{
@@ -39,6 +118,13 @@ func DefaultConfig() *Config {
 		},
 		IdleWindowPatterns: []string{"screensaver", "lock screen", "xscreensaver"},
 		IgnoredWindows:     []string{},
+		HistorySize:        DefaultHistorySize,
+		DetectionCacheMS:   DefaultDetectionCacheMS,
+		WindowEvents:       true,
+		Detectors:          append([]string(nil), DefaultDetectors...),
+		Games:              DefaultGameConfig(),
+		Calls:              DefaultCallConfig(),
+		LowPower:           DefaultLowPowerConfig(),
 	}
 }
 
@@ -51,7 +137,18 @@ func DefaultConfigPath() (string, error) {
 	return filepath.Join(home, ".config", "screentime-agent", "config.json"), nil
 }
 
-// LoadConfig loads the config from the given path
+// configDir returns the directory of the default config file, where
+// certificates are kept, or "" if there is no home directory.
+func configDir() string {
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return ""
+	}
+	return filepath.Dir(path)
+}
+
+// LoadConfig loads the config from the given path. A file in an older
+// format is upgraded and written back.
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -61,14 +158,75 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
 
+	upgraded, from, err := upgradeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
 	cfg := DefaultConfig()
-	if err := json.Unmarshal(data, cfg); err != nil {
+	if err := json.Unmarshal(upgraded, cfg); err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
 
+	if err := cfg.validateDetectors(); err != nil {
+		return nil, err
+	}
+	switch cfg.LowPower.Mode {
+	case LowPowerAuto, LowPowerAlways, LowPowerOff:
+	default:
+		return nil, fmt.Errorf("low_power.mode: must be auto, always or off")
+	}
+	if err := cfg.TLS.Validate(); err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	if cfg.MTLS && cfg.TLS != nil {
+		return nil, fmt.Errorf("tls and mtls can't both be set")
+	}
+
+	if from < ConfigVersion {
+		if err := rewriteUpgraded(path, data, upgraded, from); err != nil {
+			log.Printf("config: upgraded from version %d in memory only: %v", from, err)
+		}
+	}
+
 	return cfg, nil
 }
 
+// validateDetectors rejects unknown or repeated detector names and
+// incomplete custom detectors
+func (c *Config) validateDetectors() error {
+	if len(c.Detectors) == 0 {
+		return fmt.Errorf("detectors: at least one detector must be enabled")
+	}
+	for name, e := range c.ExecDetectors {
+		if builtinDetector(name) {
+			return fmt.Errorf("exec_detectors: %q is a built-in detector", name)
+		}
+		if len(e.Command) == 0 || e.Command[0] == "" {
+			return fmt.Errorf("exec_detectors.%s: command is required", name)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, name := range c.Detectors {
+		if _, ok := c.ExecDetectors[name]; !ok && !builtinDetector(name) {
+			return fmt.Errorf("detectors: unknown detector %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("detectors: %q listed more than once", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func builtinDetector(name string) bool {
+	switch name {
+	case DetectorCalls, DetectorSteam, DetectorGames, DetectorBrowser, DetectorWindow, DetectorMPRIS:
+		return true
+	}
+	return false
+}
+
 // DefaultFirefoxRecoveryPath finds the Firefox recovery.jsonlz4 file
 func DefaultFirefoxRecoveryPath() (string, error) {
 	home, err := os.UserHomeDir()
@@ -0,0 +1,147 @@
+package linux
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// ConfigVersion is the current config format version.
+const ConfigVersion = 1
+
+// configUpgrades[v] rewrites a version v config into version v+1. Each
+// works on the raw top-level keys so it can move or rename settings the
+// current Config no longer has.
+var configUpgrades = []func(raw map[string]json.RawMessage) error{
+	// Version 0 files predate the version field; the settings they can
+	// hold are unchanged.
+	0: func(map[string]json.RawMessage) error { return nil },
+}
+
+// upgradeConfig brings a config file's JSON up to ConfigVersion, returning
+// it and the version it was written in.
+func upgradeConfig(data []byte) ([]byte, int, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, fmt.Errorf("parse config: %w", err)
+	}
+
+	var from int
+	if v, ok := raw["version"]; ok {
+		if err := json.Unmarshal(v, &from); err != nil {
+			return nil, 0, fmt.Errorf("parse config: version: %w", err)
+		}
+	}
+	if from < 0 || from > ConfigVersion {
+		return nil, 0, fmt.Errorf("config version %d is not supported by this agent (newest is %d)", from, ConfigVersion)
+	}
+	if from == ConfigVersion {
+		return data, from, nil
+	}
+
+	for v := from; v < ConfigVersion; v++ {
+		if err := configUpgrades[v](raw); err != nil {
+			return nil, 0, fmt.Errorf("upgrade config from version %d: %w", v, err)
+		}
+	}
+	raw["version"] = json.RawMessage(fmt.Sprint(ConfigVersion))
+
+	upgraded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, 0, fmt.Errorf("upgrade config: %w", err)
+	}
+	return upgraded, from, nil
+}
+
+// rewriteUpgraded replaces the config file at path with its upgraded JSON,
+// keeping the original next to it as <path>.v<from>.bak. Only the user's
+// own settings are written, not the defaults filled in around them, so
+// later changes to the defaults still apply, and keys this agent doesn't
+// know survive.
+func rewriteUpgraded(path string, original, upgraded []byte, from int) error {
+	mode := os.FileMode(0o600)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	backup := fmt.Sprintf("%s.v%d.bak", path, from)
+	if err := os.WriteFile(backup, original, mode); err != nil {
+		return fmt.Errorf("back up config: %w", err)
+	}
+
+	var data bytes.Buffer
+	if err := json.Indent(&data, upgraded, "", "  "); err != nil {
+		return fmt.Errorf("indent config: %w", err)
+	}
+	data.WriteByte('\n')
+	if err := writeFileAtomic(path, data.Bytes(), mode, true); err != nil {
+		return err
+	}
+	log.Printf("config: upgraded %s from version %d to %d, original kept in %s", path, from, ConfigVersion, backup)
+	return nil
+}
+
+// MarshalConfig renders cfg as an indented config file.
+func MarshalConfig(cfg *Config) ([]byte, error) {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// WriteConfig creates a config file at path holding cfg, creating its
+// directory as needed. It never replaces an existing file, and a reader
+// never sees it half written.
+func WriteConfig(path string, cfg *Config) error {
+	data, err := MarshalConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	return writeFileAtomic(path, data, 0o600, false)
+}
+
+// writeFileAtomic writes data to a temporary file beside path and moves it
+// into place. Without replace, an existing file at path is an error.
+func writeFileAtomic(path string, data []byte, mode os.FileMode, replace bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp config: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write temp config: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("chmod temp config: %w", err)
+	}
+
+	if replace {
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return fmt.Errorf("replace config: %w", err)
+		}
+		return nil
+	}
+	// A hard link fails rather than clobbering an existing file.
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("config %s already exists", path)
+		}
+		return fmt.Errorf("create config: %w", err)
+	}
+	return nil
+}
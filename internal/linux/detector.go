@@ -7,59 +7,249 @@ import (
 
 // Activity represents the current activity on the machine
 type Activity struct {
-	ID    string // e.g., "steam:12345", "browser:homework", "window:code"
+	ID    string // e.g., "steam:12345", "game:minecraft", "browser:homework:khanacademy.org", "electron:chat:discord:Server", "window:code"
 	Name  string // Human-readable name
 	State string // "active", "idle", "offline"
+
+	// Account identifies who is signed in to the platform running the
+	// activity, e.g. "steam:76561198000000000", so the hub can tell apart
+	// users sharing the machine. Empty when unknown.
+	Account string
+
+	// Title is the window or tab title behind the activity, reported to
+	// the hub only with Config.TrackTitles. Empty for non-window sources.
+	Title string
+
+	// Secondary holds background signals that lost out to the primary
+	// activity, e.g. a Steam game running behind a focused Discord window.
+	Secondary []Activity
 }
 
-// Detector orchestrates activity detection with priority:
-// 1. Steam game (if running)
-// 2. Browser tab (if browser is focused)
-// 3. Window title (fallback)
+// Detector names accepted in Config.Detectors
+const (
+	DetectorCalls   = "calls"
+	DetectorSteam   = "steam"
+	DetectorGames   = "games"
+	DetectorBrowser = "browser"
+	DetectorWindow  = "window"
+	DetectorMPRIS   = "mpris"
+)
+
+// DefaultDetectors is the detection order used when the config doesn't
+// specify one:
+// 1. Video call (conferencing app focused with camera or mic in use)
+// 2. Steam game (if running)
+// 3. Non-Steam game (if the focused window looks like one)
+// 4. Browser tab (if browser is focused)
+// 5. Window title (fallback)
+var DefaultDetectors = []string{DetectorCalls, DetectorSteam, DetectorGames, DetectorBrowser, DetectorWindow}
+
+// Detector orchestrates activity detection, asking each enabled source in
+// the configured priority order until one reports an activity
 type Detector struct {
-	config   *Config
-	steam    *SteamDetector
-	window   *WindowDetector
-	browser  *BrowserDetector
-	category *Categorizer
+	config  *Config
+	sources []namedSource
+	window  *WindowDetector
+	browser *BrowserDetector
+	mpris   *MPRISDetector
+	privacy *Redactor
+	power   *PowerMonitor // set in auto low-power mode
+	lock    *LockMonitor  // nil without logind
+}
+
+// namedSource is a Source registered under its name in Config.Detectors.
+type namedSource struct {
+	Source
+	name string
+	// background sources report activity that can continue behind the
+	// focused window, so they're still asked after another source wins.
+	background bool
 }
 
 // NewDetector creates a new activity detector
 func NewDetector(cfg *Config) (*Detector, error) {
-	window, err := NewWindowDetector()
+	wine, err := NewWineResolver(cfg.WineNamesFile)
+	if err != nil {
+		return nil, fmt.Errorf("create wine resolver: %w", err)
+	}
+	privacy, err := NewRedactor(cfg.Privacy)
 	if err != nil {
-		return nil, fmt.Errorf("create window detector: %w", err)
+		return nil, err
+	}
+	category := NewCategorizer(cfg.Categories)
+
+	d := &Detector{
+		config:  cfg,
+		privacy: privacy,
+	}
+
+	// Call, game, browser and window detection inspect the focused window;
+	// call detection also looks at browser tabs even when browser activity
+	// isn't reported on its own.
+	var needWindow, needBrowser bool
+	for _, name := range cfg.Detectors {
+		src := namedSource{name: name}
+		switch name {
+		case DetectorCalls:
+			src.Source = callSource{NewCallDetector(cfg.Calls)}
+			needWindow, needBrowser = true, true
+		case DetectorSteam:
+			src.Source = steamSource{NewSteamDetector()}
+			src.background = true
+		case DetectorGames:
+			src.Source = gameSource{games: NewGameDetector(cfg.Games), wine: wine}
+			needWindow = true
+		case DetectorBrowser:
+			src.Source = browserSource{category: category}
+			needWindow, needBrowser = true, true
+		case DetectorWindow:
+			src.Source = windowSource{wine: wine, category: category}
+			needWindow = true
+		case DetectorMPRIS:
+			mpris, err := NewMPRISDetector()
+			if err != nil {
+				d.Close()
+				return nil, fmt.Errorf("create mpris detector: %w", err)
+			}
+			d.mpris = mpris
+			src.Source = mprisSource{mpris}
+			src.background = true
+		default:
+			e := cfg.ExecDetectors[name]
+			src.Source = newExecSource(name, e)
+			src.background = e.Background
+		}
+		d.sources = append(d.sources, src)
+	}
+
+	if cfg.LowPower.Mode == LowPowerAuto {
+		power, err := NewPowerMonitor()
+		if err != nil {
+			log.Printf("power source unknown, low-power mode disabled: %v", err)
+		} else {
+			d.power = power
+		}
+	}
+
+	if needBrowser {
+		d.browser = NewBrowserDetector(cfg.FirefoxProfile, cfg.ChromiumDebugPort)
+	}
+
+	if needWindow {
+		window, err := NewWindowDetector()
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("create window detector: %w", err)
+		}
+		d.window = window
+
+		if lock, err := NewLockMonitor(); err != nil {
+			log.Printf("screen lock state unknown, going by lock screen titles: %v", err)
+		} else {
+			d.lock = lock
+		}
+
+		if cfg.WindowEvents {
+			if err := window.Subscribe(); err != nil {
+				log.Printf("window events unavailable, querying on each request: %v", err)
+			}
+		}
 	}
 
-	return &Detector{
-		config:   cfg,
-		steam:    NewSteamDetector(),
-		window:   window,
-		browser:  NewBrowserDetector(cfg.FirefoxProfile),
-		category: NewCategorizer(cfg.Categories),
-	}, nil
+	return d, nil
 }
 
-// Detect returns the current activity
+// LowPower reports whether low-power mode is in effect.
+func (d *Detector) LowPower() bool {
+	switch d.config.LowPower.Mode {
+	case LowPowerAlways:
+		return true
+	case LowPowerAuto:
+		return d.power != nil && d.power.OnBattery()
+	}
+	return false
+}
+
+// Detect returns the current activity along with any secondary signals
 func (d *Detector) Detect() Activity {
-	// Priority 1: Check for running Steam game
-	game, err := d.steam.Detect()
+	p := &Probe{LowPower: d.LowPower(), d: d}
+	primary, winner := d.detectPrimary(p)
+
+	for i, src := range d.sources {
+		if !src.background || i == winner {
+			continue
+		}
+		if a := d.ask(src, p); a != nil && a.ID != primary.ID {
+			primary.Secondary = append(primary.Secondary, *a)
+		}
+	}
+
+	// Redact before anything leaves the detector, so neither the hub nor
+	// the debug history ever sees a raw title.
+	return d.privacy.Apply(primary)
+}
+
+// detectPrimary asks the sources in priority order and returns the winning
+// activity and the index of the source that produced it, or -1.
+func (d *Detector) detectPrimary(p *Probe) (Activity, int) {
+	for i, src := range d.sources {
+		if a := d.ask(src, p); a != nil {
+			return *a, i
+		}
+	}
+	return Activity{
+		ID:    "idle:none",
+		Name:  "Nothing Detected",
+		State: "idle",
+	}, -1
+}
+
+// ask runs one source, logging its error; a source may report an activity
+// along with an error.
+func (d *Detector) ask(src namedSource, p *Probe) *Activity {
+	a, err := src.Activity(p)
+	if err != nil {
+		log.Printf("%s detection error: %v", src.name, err)
+	}
+	return a
+}
+
+// MediaState reports the focused window's playback in Roku media-player
+// terms ("play", "pause", "stop" or "none") so the hub can apply its
+// paused-media policy. Without the mpris detector and a window detector
+// enabled the state is always "none".
+func (d *Detector) MediaState() string {
+	if d.mpris == nil || d.window == nil {
+		return "none"
+	}
+	info, err := d.window.Detect()
+	if err != nil {
+		log.Printf("window detection error: %v", err)
+		return "none"
+	}
+	state, err := d.mpris.PlaybackState(info)
 	if err != nil {
-		log.Printf("steam detection error: %v", err)
+		log.Printf("mpris playback state error: %v", err)
+		return "none"
 	}
-	if game != nil {
-		return Activity{
-			ID:    fmt.Sprintf("steam:%s", game.AppID),
-			Name:  game.Name,
-			State: "active",
+	return state
+}
+
+// focusedWindow returns the active window, or a terminal activity when the
+// window itself says the machine is idle or detection failed
+func (d *Detector) focusedWindow() (*WindowInfo, *Activity) {
+	if d.lock != nil && d.lock.Locked() {
+		return nil, &Activity{
+			ID:    "idle:lock",
+			Name:  "Screen Locked",
+			State: "idle",
 		}
 	}
 
-	// Get the active window for further detection
 	windowInfo, err := d.window.Detect()
 	if err != nil {
 		log.Printf("window detection error: %v", err)
-		return Activity{
+		return nil, &Activity{
 			ID:    "unknown",
 			Name:  "Unknown",
 			State: "offline",
@@ -68,7 +258,7 @@ func (d *Detector) Detect() Activity {
 
 	// No window focused = idle
 	if windowInfo == nil {
-		return Activity{
+		return nil, &Activity{
 			ID:    "idle:no-window",
 			Name:  "No Window",
 			State: "idle",
@@ -77,8 +267,12 @@ func (d *Detector) Detect() Activity {
 
 	// Check if window indicates idle state (screensaver, lock screen, etc.)
 	if windowInfo.IsIdle(d.config.IdleWindowPatterns) {
-		return Activity{
-			ID:    "idle:screensaver",
+		id := "idle:screensaver"
+		if d.lock == nil && windowInfo.IsLockScreen() {
+			id = "idle:lock"
+		}
+		return nil, &Activity{
+			ID:    id,
 			Name:  windowInfo.Title,
 			State: "idle",
 		}
@@ -86,35 +280,21 @@ func (d *Detector) Detect() Activity {
 
 	// Check if window should be ignored
 	if windowInfo.IsIgnored(d.config.IgnoredWindows) {
-		return Activity{
+		return nil, &Activity{
 			ID:    "idle:ignored",
 			Name:  windowInfo.Title,
 			State: "idle",
 		}
 	}
 
-	// Priority 2: If browser is focused, get the active tab
-	if windowInfo.IsBrowser() {
-		tab, err := d.browser.DetectFirefox()
-		if err != nil {
-			log.Printf("firefox detection error: %v", err)
-		}
-		if tab != nil && tab.Domain != "" {
-			category := d.category.Categorize(tab.Domain)
-			return Activity{
-				ID:    fmt.Sprintf("browser:%s", category),
-				Name:  tab.Domain,
-				State: "active",
-			}
-		}
-		// Couldn't get tab info, fall through to window title
-	}
+	return windowInfo, nil
+}
 
-	// Priority 3: Fall back to window title
-	return Activity{
-		ID:    fmt.Sprintf("window:%s", windowInfo.Instance),
-		Name:  windowInfo.Title,
-		State: "active",
+// OnWindowChange registers fn to be called whenever the compositor reports
+// a focus or title change. It is never called while windows are polled.
+func (d *Detector) OnWindowChange(fn func()) {
+	if d.window != nil {
+		d.window.OnChange(fn)
 	}
 }
 
@@ -123,6 +303,13 @@ func (d *Detector) Close() {
 	if d.window != nil {
 		d.window.Close()
 	}
+	if d.mpris != nil {
+		d.mpris.Close()
+	}
+	if d.power != nil {
+		d.power.Close()
+	}
+	if d.lock != nil {
+		d.lock.Close()
+	}
 }
-
-
@@ -0,0 +1,93 @@
+package linux
+
+import (
+	"strings"
+)
+
+// ElectronContext is what an Electron chat app's window title says about
+// where the user is
+type ElectronContext struct {
+	App       string // "discord", "slack"
+	Workspace string // Discord server or Slack workspace; empty for DMs
+	Channel   string
+}
+
+// electronParsers extract context from window titles, keyed by the
+// lowercase window class
+var electronParsers = map[string]func(title string) (ElectronContext, bool){
+	"discord": parseDiscordTitle,
+	"slack":   parseSlackTitle,
+}
+
+// ParseElectronTitle extracts workspace and channel from a known Electron
+// app's window title
+func ParseElectronTitle(info *WindowInfo) (ElectronContext, bool) {
+	if info == nil {
+		return ElectronContext{}, false
+	}
+	parse, ok := electronParsers[info.Instance]
+	if !ok {
+		return ElectronContext{}, false
+	}
+	ctx, ok := parse(info.Title)
+	if ok {
+		ctx.App = info.Instance
+	}
+	return ctx, ok
+}
+
+// parseDiscordTitle handles both title layouts Discord has shipped:
+//
+//	"#general | Server Name - Discord"
+//	"Discord | #general | Server Name"
+//
+// Direct messages ("@someone - Discord") have no workspace.
+func parseDiscordTitle(title string) (ElectronContext, bool) {
+	var parts []string
+	if rest, ok := strings.CutPrefix(title, "Discord | "); ok {
+		parts = strings.Split(rest, " | ")
+	} else if rest, ok := strings.CutSuffix(title, " - Discord"); ok {
+		parts = strings.Split(rest, " | ")
+	} else {
+		return ElectronContext{}, false
+	}
+
+	var ctx ElectronContext
+	ctx.Channel = strings.TrimSpace(parts[0])
+	if len(parts) > 1 {
+		ctx.Workspace = strings.TrimSpace(parts[len(parts)-1])
+	}
+	return ctx, ctx.Channel != ""
+}
+
+// parseSlackTitle handles "general (Channel) - Acme - Slack" and the older
+// "Slack | general | Acme".
+func parseSlackTitle(title string) (ElectronContext, bool) {
+	if rest, ok := strings.CutPrefix(title, "Slack | "); ok {
+		parts := strings.Split(rest, " | ")
+		if len(parts) < 2 {
+			return ElectronContext{}, false
+		}
+		return ElectronContext{
+			Channel:   strings.TrimSpace(parts[0]),
+			Workspace: strings.TrimSpace(parts[len(parts)-1]),
+		}, true
+	}
+
+	rest, ok := strings.CutSuffix(title, " - Slack")
+	if !ok {
+		return ElectronContext{}, false
+	}
+	parts := strings.Split(rest, " - ")
+	if len(parts) < 2 {
+		return ElectronContext{}, false
+	}
+	channel := parts[0]
+	if i := strings.LastIndex(channel, " ("); i >= 0 {
+		channel = channel[:i]
+	}
+	return ElectronContext{
+		Channel:   strings.TrimSpace(channel),
+		Workspace: strings.TrimSpace(parts[len(parts)-1]),
+	}, true
+}
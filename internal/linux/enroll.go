@@ -0,0 +1,110 @@
+package linux
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"screentime-agent/internal/tlsutil"
+)
+
+// EnrollOptions says which hub to enroll with and how.
+type EnrollOptions struct {
+	// HubURL is the hub's address, e.g. "https://hub.lan:8080".
+	HubURL string
+	// DeviceID and Token are the device configured on the hub and the
+	// token its POST /v1/devices/{id}/enrollment returned.
+	DeviceID string
+	Token    string
+	// CAFingerprint must match the CA the hub sends back, as its
+	// enrollment response showed it. It is what keeps a machine in the
+	// middle from handing the agent a CA of its own.
+	CAFingerprint string
+	// HubFingerprint pins the hub's own certificate when it serves a
+	// self-signed one.
+	HubFingerprint string
+}
+
+// Enroll has the hub issue this agent a certificate for mutual TLS and
+// keeps it, with its key and the hub's CA, in the config directory, where
+// the mtls setting looks for them. It returns the CA's fingerprint.
+func Enroll(ctx context.Context, opts EnrollOptions) (string, error) {
+	dir := configDir()
+	if dir == "" {
+		return "", errors.New("no home directory to keep the certificate in")
+	}
+	u, err := url.Parse(opts.HubURL)
+	if err != nil {
+		return "", fmt.Errorf("parse hub url: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/enroll"
+	}
+	if !tlsutil.ValidFingerprint(opts.CAFingerprint) {
+		return "", errors.New("the hub CA fingerprint from the enrollment response is required")
+	}
+
+	csrPEM, keyPEM, err := tlsutil.NewCSR(opts.DeviceID)
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(map[string]string{
+		"device_id": opts.DeviceID,
+		"token":     opts.Token,
+		"csr":       string(csrPEM),
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.HubFingerprint != "" {
+		tlsutil.Trust{Pins: map[string][]string{u.Hostname(): {opts.HubFingerprint}}}.Apply(transport)
+	}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: transport}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("enroll: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("enroll: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var out struct {
+		Certificate string `json:"certificate"`
+		CA          string `json:"ca"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode enroll response: %w", err)
+	}
+	caFP, err := tlsutil.PEMFingerprint([]byte(out.CA))
+	if err != nil {
+		return "", fmt.Errorf("hub ca: %w", err)
+	}
+	if !tlsutil.SameFingerprint(caFP, opts.CAFingerprint) {
+		return "", fmt.Errorf("hub ca has fingerprint %s, not %s", caFP, opts.CAFingerprint)
+	}
+	if _, err := tls.X509KeyPair([]byte(out.Certificate), keyPEM); err != nil {
+		return "", fmt.Errorf("issued certificate: %w", err)
+	}
+
+	if err := tlsutil.SaveEnrolled(dir, []byte(out.Certificate), keyPEM, []byte(out.CA)); err != nil {
+		return "", err
+	}
+	return caFP, nil
+}
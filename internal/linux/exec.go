@@ -0,0 +1,99 @@
+package linux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// DefaultExecTimeoutMS bounds how long a custom detector may run.
+const DefaultExecTimeoutMS = 1000
+
+// ExecDetectorConfig defines a custom detector: a command that prints the
+// current activity as JSON, {"id": "...", "name": "...", "state": "active"},
+// or nothing (or {}) when it has nothing to report. state may be "active",
+// "idle" or "offline" and defaults to "active". The focused window is
+// passed in SCREENTIME_WINDOW_TITLE, SCREENTIME_WINDOW_CLASS and
+// SCREENTIME_WINDOW_PID when a window detector is running, and
+// SCREENTIME_LOW_POWER is 1 in low-power mode.
+type ExecDetectorConfig struct {
+	Command   []string `json:"command"`
+	TimeoutMS int      `json:"timeout_ms,omitempty"`
+	// Background keeps the detector running after a higher-priority one
+	// wins, reporting its activity as secondary, like Steam and MPRIS.
+	Background bool `json:"background,omitempty"`
+}
+
+// execSource runs a custom detector command on each detection.
+type execSource struct {
+	name    string
+	command []string
+	timeout time.Duration
+}
+
+func newExecSource(name string, cfg ExecDetectorConfig) execSource {
+	timeout := cfg.TimeoutMS
+	if timeout <= 0 {
+		timeout = DefaultExecTimeoutMS
+	}
+	return execSource{
+		name:    name,
+		command: cfg.Command,
+		timeout: time.Duration(timeout) * time.Millisecond,
+	}
+}
+
+func (s execSource) Activity(p *Probe) (*Activity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...)
+	cmd.Env = os.Environ()
+	if info, _ := p.Window(); info != nil {
+		cmd.Env = append(cmd.Env,
+			"SCREENTIME_WINDOW_TITLE="+info.Title,
+			"SCREENTIME_WINDOW_CLASS="+info.Class,
+			"SCREENTIME_WINDOW_PID="+strconv.Itoa(info.PID),
+		)
+	}
+	if p.LowPower {
+		cmd.Env = append(cmd.Env, "SCREENTIME_LOW_POWER=1")
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("run %s: %w", s.command[0], err)
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	var a struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(out, &a); err != nil {
+		return nil, fmt.Errorf("parse %s output: %w", s.name, err)
+	}
+	if a.ID == "" {
+		return nil, nil
+	}
+	switch a.State {
+	case "":
+		a.State = "active"
+	case "active", "idle", "offline":
+	default:
+		return nil, fmt.Errorf("%s reported unknown state %q", s.name, a.State)
+	}
+	if a.Name == "" {
+		a.Name = a.ID
+	}
+	return &Activity{ID: a.ID, Name: a.Name, State: a.State}, nil
+}
@@ -0,0 +1,151 @@
+package linux
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GameConfig tunes detection of games launched outside Steam
+type GameConfig struct {
+	// Binaries maps a lowercase substring of a process name or command line
+	// to a friendly game name, e.g. "minecraft" -> "Minecraft". Entries in
+	// the config file are added to the built-in list.
+	Binaries map[string]string `json:"binaries,omitempty"`
+	// GPUBusyPercent is the GPU load above which a fullscreen window is
+	// assumed to be a game.
+	GPUBusyPercent int `json:"gpu_busy_percent,omitempty"`
+	// SustainSeconds is how long the GPU must stay busy before it counts.
+	SustainSeconds int `json:"sustain_seconds,omitempty"`
+}
+
+// DefaultGameConfig returns the built-in list of common standalone games
+// and emulators
+func DefaultGameConfig() GameConfig {
+	return GameConfig{
+		Binaries: map[string]string{
+			"minecraft":   "Minecraft",
+			"retroarch":   "RetroArch",
+			"dolphin-emu": "Dolphin",
+			"pcsx2":       "PCSX2",
+			"rpcs3":       "RPCS3",
+			"ppsspp":      "PPSSPP",
+			"ryujinx":     "Ryujinx",
+			"cemu":        "Cemu",
+		},
+		GPUBusyPercent: 50,
+		SustainSeconds: 30,
+	}
+}
+
+// Game is a non-Steam game identified by heuristics
+type Game struct {
+	Slug string // stable identifier, e.g., "minecraft"
+	Name string
+}
+
+// GameDetector recognises games by the focused window's process, or by a
+// fullscreen window paired with sustained GPU load
+type GameDetector struct {
+	config GameConfig
+
+	mu        sync.Mutex
+	busySince time.Time
+}
+
+// NewGameDetector creates a new game detector
+func NewGameDetector(cfg GameConfig) *GameDetector {
+	return &GameDetector{config: cfg}
+}
+
+// Detect classifies the focused window as a game, or returns nil
+func (g *GameDetector) Detect(info *WindowInfo, now time.Time) *Game {
+	gpuBusy := g.sampleGPU(now)
+
+	if info == nil {
+		return nil
+	}
+
+	if info.PID > 0 {
+		if game := g.matchProcess(info.PID); game != nil {
+			return game
+		}
+	}
+
+	if info.Fullscreen && gpuBusy && !info.IsBrowser() {
+		name := info.Title
+		if name == "" {
+			name = info.Class
+		}
+		return &Game{Slug: info.Instance, Name: name}
+	}
+
+	return nil
+}
+
+// matchProcess checks the process name and command line against the
+// configured binaries, longest pattern first so "minecraft-launcher" can
+// be told apart from "minecraft"
+func (g *GameDetector) matchProcess(pid int) *Game {
+	procDir := filepath.Join("/proc", strconv.Itoa(pid))
+	comm, _ := os.ReadFile(filepath.Join(procDir, "comm"))
+	cmdline, _ := os.ReadFile(filepath.Join(procDir, "cmdline"))
+	haystack := strings.ToLower(strings.TrimSpace(string(comm)) + " " +
+		strings.ReplaceAll(string(cmdline), "\x00", " "))
+
+	patterns := make([]string, 0, len(g.config.Binaries))
+	for p := range g.config.Binaries {
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+
+	for _, p := range patterns {
+		if strings.Contains(haystack, strings.ToLower(p)) {
+			return &Game{Slug: strings.ToLower(p), Name: g.config.Binaries[p]}
+		}
+	}
+	return nil
+}
+
+// sampleGPU records the current GPU load and reports whether it has stayed
+// above the threshold for the sustain period. GPUs that don't expose
+// gpu_busy_percent (most non-AMD drivers) never count as busy.
+func (g *GameDetector) sampleGPU(now time.Time) bool {
+	busy, ok := gpuBusyPercent()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !ok || busy < g.config.GPUBusyPercent {
+		g.busySince = time.Time{}
+		return false
+	}
+	if g.busySince.IsZero() {
+		g.busySince = now
+	}
+	return now.Sub(g.busySince) >= time.Duration(g.config.SustainSeconds)*time.Second
+}
+
+// gpuBusyPercent returns the highest load across all DRM cards
+func gpuBusyPercent() (int, bool) {
+	paths, _ := filepath.Glob("/sys/class/drm/card*/device/gpu_busy_percent")
+	best, found := 0, false
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			continue
+		}
+		if !found || v > best {
+			best, found = v, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,79 @@
+package linux
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is how many detections the agent remembers when the
+// config doesn't say otherwise. At the hub's default 5s poll interval this
+// covers a little over an hour.
+const DefaultHistorySize = 1000
+
+// HistoryEntry is a single detection result kept for debugging
+type HistoryEntry struct {
+	Time  time.Time `json:"time"`
+	ID    string    `json:"id"`
+	Name  string    `json:"name"`
+	State string    `json:"state"`
+
+	Secondary []string `json:"secondary,omitempty"`
+}
+
+// History is a fixed-size ring buffer of recent detections
+type History struct {
+	mu      sync.Mutex
+	entries []HistoryEntry
+	next    int
+	full    bool
+}
+
+// NewHistory creates a history holding at most size entries
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &History{entries: make([]HistoryEntry, size)}
+}
+
+// Record adds an activity to the history, evicting the oldest if full
+func (h *History) Record(t time.Time, a Activity) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e := HistoryEntry{
+		Time:  t,
+		ID:    a.ID,
+		Name:  a.Name,
+		State: a.State,
+	}
+	for _, sec := range a.Secondary {
+		e.Secondary = append(e.Secondary, sec.ID)
+	}
+	h.entries[h.next] = e
+	h.next++
+	if h.next == len(h.entries) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+// Since returns the entries recorded at or after t, oldest first
+func (h *History) Since(t time.Time) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var ordered []HistoryEntry
+	if h.full {
+		ordered = append(ordered, h.entries[h.next:]...)
+	}
+	ordered = append(ordered, h.entries[:h.next]...)
+
+	out := make([]HistoryEntry, 0, len(ordered))
+	for _, e := range ordered {
+		if !e.Time.Before(t) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package linux
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// signatureHeader carries the HMAC-SHA256 of a body the hub POSTs, keyed
+// with hub_token, as "sha256=<hex>".
+const signatureHeader = "X-Screentime-Signature"
+
+// maxHubRequest bounds a notification or block body.
+const maxHubRequest = 64 << 10
+
+// maxHubSkew is how far a hub request's timestamp may be from agent time.
+const maxHubSkew = 5 * time.Minute
+
+// readHubRequest decodes a command the hub POSTed to path into v, after
+// checking it really came from the hub: signed with hub_token, or over
+// mutual TLS, which only lets the hub's certificate in. Signed requests
+// must carry a timestamp later than the last one for path, so a captured
+// request can't be replayed. It writes the error response itself and
+// reports whether v was filled in.
+func (s *Server) readHubRequest(w http.ResponseWriter, r *http.Request, path string, v any) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if s.config.HubToken == "" && !s.config.MTLS {
+		http.Error(w, "set hub_token or mtls to accept commands from the hub", http.StatusForbidden)
+		return false
+	}
+	// Browsers can send other content types cross-origin without asking,
+	// but not application/json.
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHubRequest))
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+	if s.config.HubToken != "" {
+		if !validSignature(body, s.config.HubToken, r.Header.Get(signatureHeader)) {
+			http.Error(w, "invalid or missing signature", http.StatusUnauthorized)
+			return false
+		}
+		var stamp struct {
+			Timestamp time.Time `json:"timestamp"`
+		}
+		if err := json.Unmarshal(body, &stamp); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return false
+		}
+		if !s.freshHubRequest(path, stamp.Timestamp) {
+			http.Error(w, "stale or replayed request", http.StatusUnauthorized)
+			return false
+		}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// freshHubRequest reports whether at is within maxHubSkew of now and later
+// than the last request for path, and records it if so.
+func (s *Server) freshHubRequest(path string, at time.Time) bool {
+	if at.IsZero() {
+		return false
+	}
+	if skew := time.Since(at); skew > maxHubSkew || skew < -maxHubSkew {
+		return false
+	}
+	s.hubMu.Lock()
+	defer s.hubMu.Unlock()
+	if !at.After(s.hubSeen[path]) {
+		return false
+	}
+	if s.hubSeen == nil {
+		s.hubSeen = make(map[string]time.Time)
+	}
+	s.hubSeen[path] = at
+	return true
+}
+
+// validSignature reports whether sig is "sha256=" and the hex HMAC of body
+// under token.
+func validSignature(body []byte, token, sig string) bool {
+	hexSum, ok := strings.CutPrefix(sig, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(hexSum)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,91 @@
+package linux
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	login1Name         = "org.freedesktop.login1"
+	login1Path         = "/org/freedesktop/login1"
+	login1ManagerIface = "org.freedesktop.login1.Manager"
+	login1SessionIface = "org.freedesktop.login1.Session"
+)
+
+// LockMonitor follows logind's LockedHint for the agent's graphical
+// session on the system bus, which the screen locker sets whatever its
+// window is called.
+type LockMonitor struct {
+	conn    *dbus.Conn
+	path    dbus.ObjectPath
+	signals chan *dbus.Signal
+	locked  atomic.Bool
+}
+
+// NewLockMonitor finds the agent's session, reads whether it is locked
+// and watches for changes.
+func NewLockMonitor() (*LockMonitor, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("connect to system bus: %w", err)
+	}
+
+	// "auto" is the caller's session or, for an agent started outside
+	// one as a user service, the user's display session.
+	var path dbus.ObjectPath
+	if err := conn.Object(login1Name, login1Path).Call(login1ManagerIface+".GetSession", 0, "auto").Store(&path); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("find logind session: %w", err)
+	}
+	v, err := conn.Object(login1Name, path).GetProperty(login1SessionIface + ".LockedHint")
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read logind LockedHint: %w", err)
+	}
+	locked, _ := v.Value().(bool)
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+	); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("watch logind session: %w", err)
+	}
+
+	m := &LockMonitor{conn: conn, path: path, signals: make(chan *dbus.Signal, 8)}
+	m.locked.Store(locked)
+	conn.Signal(m.signals)
+	go m.watch()
+	return m, nil
+}
+
+func (m *LockMonitor) watch() {
+	for sig := range m.signals {
+		if sig.Path != m.path || len(sig.Body) < 2 {
+			continue
+		}
+		if iface, _ := sig.Body[0].(string); iface != login1SessionIface {
+			continue
+		}
+		changed, _ := sig.Body[1].(map[string]dbus.Variant)
+		if v, ok := changed["LockedHint"]; ok {
+			locked, _ := v.Value().(bool)
+			m.locked.Store(locked)
+		}
+	}
+}
+
+// Locked reports whether the session's screen is locked.
+func (m *LockMonitor) Locked() bool {
+	return m.locked.Load()
+}
+
+// Close stops watching.
+func (m *LockMonitor) Close() {
+	m.conn.RemoveSignal(m.signals)
+	m.conn.Close()
+	close(m.signals)
+}
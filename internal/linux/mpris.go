@@ -0,0 +1,165 @@
+package linux
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const mprisPrefix = "org.mpris.MediaPlayer2."
+
+// MediaInfo describes media playing through an MPRIS-capable player
+type MediaInfo struct {
+	Player string // bus name suffix, e.g., "spotify" or "firefox"
+	Title  string
+}
+
+// MPRISDetector finds media that is currently playing via MPRIS
+type MPRISDetector struct {
+	conn *dbus.Conn
+}
+
+// NewMPRISDetector creates a new MPRIS detector
+func NewMPRISDetector() (*MPRISDetector, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("connect to session bus: %w", err)
+	}
+	return &MPRISDetector{conn: conn}, nil
+}
+
+// players returns the bus names of every MPRIS player
+func (m *MPRISDetector) players() ([]string, error) {
+	var names []string
+	obj := m.conn.Object("org.freedesktop.DBus", "/org/freedesktop/DBus")
+	if err := obj.Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		return nil, fmt.Errorf("list bus names: %w", err)
+	}
+
+	var out []string
+	for _, name := range names {
+		if strings.HasPrefix(name, mprisPrefix) {
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+// playbackStatus returns a player's PlaybackStatus ("Playing", "Paused" or
+// "Stopped"), or "" if it can't be read
+func (m *MPRISDetector) playbackStatus(name string) string {
+	status, err := m.conn.Object(name, "/org/mpris/MediaPlayer2").
+		GetProperty("org.mpris.MediaPlayer2.Player.PlaybackStatus")
+	if err != nil {
+		return ""
+	}
+	s, _ := status.Value().(string)
+	return s
+}
+
+// PlaybackState reports the playback of the player behind the focused
+// window in Roku media-player terms: "play", "pause" or "stop", or "none"
+// when no player belongs to it. A player paused in the background says
+// nothing about what's in front, so it doesn't count.
+func (m *MPRISDetector) PlaybackState(focused *WindowInfo) (string, error) {
+	if focused == nil {
+		return "none", nil
+	}
+	names, err := m.players()
+	if err != nil {
+		return "", err
+	}
+
+	state := "none"
+	for _, name := range names {
+		if !m.owns(name, focused) {
+			continue
+		}
+		switch m.playbackStatus(name) {
+		case "Playing":
+			return "play", nil
+		case "Paused":
+			state = "pause"
+		default:
+			if state == "none" {
+				state = "stop"
+			}
+		}
+	}
+	return state, nil
+}
+
+// owns reports whether a player belongs to the window: the same process,
+// or a bus name, desktop entry or identity naming the window's class.
+func (m *MPRISDetector) owns(name string, w *WindowInfo) bool {
+	if w.PID > 0 {
+		var pid uint32
+		err := m.conn.BusObject().Call("org.freedesktop.DBus.GetConnectionUnixProcessID", 0, name).Store(&pid)
+		if err == nil && int(pid) == w.PID {
+			return true
+		}
+	}
+
+	candidates := []string{playerName(name)}
+	player := m.conn.Object(name, "/org/mpris/MediaPlayer2")
+	for _, prop := range []string{"org.mpris.MediaPlayer2.DesktopEntry", "org.mpris.MediaPlayer2.Identity"} {
+		if v, err := player.GetProperty(prop); err == nil {
+			if s, _ := v.Value().(string); s != "" {
+				// Desktop entries may be reverse-DNS, e.g. "org.gnome.Totem".
+				candidates = append(candidates, s, s[strings.LastIndex(s, ".")+1:])
+			}
+		}
+	}
+	for _, c := range candidates {
+		if strings.EqualFold(c, w.Class) || strings.EqualFold(c, w.Instance) {
+			return true
+		}
+	}
+	return false
+}
+
+// playerName returns a player's bus name without the MPRIS prefix or the
+// per-process suffix browsers add ("firefox.instance_1_23"), so it is
+// stable across restarts.
+func playerName(name string) string {
+	name = strings.TrimPrefix(name, mprisPrefix)
+	if i := strings.Index(name, ".instance"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// Detect returns the first player reporting PlaybackStatus "Playing", or nil
+func (m *MPRISDetector) Detect() (*MediaInfo, error) {
+	names, err := m.players()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if m.playbackStatus(name) != "Playing" {
+			continue
+		}
+		player := m.conn.Object(name, "/org/mpris/MediaPlayer2")
+
+		info := &MediaInfo{Player: playerName(name)}
+		if md, err := player.GetProperty("org.mpris.MediaPlayer2.Player.Metadata"); err == nil {
+			if meta, ok := md.Value().(map[string]dbus.Variant); ok {
+				if title, ok := meta["xesam:title"]; ok {
+					info.Title, _ = title.Value().(string)
+				}
+			}
+		}
+		return info, nil
+	}
+
+	return nil, nil
+}
+
+// Close closes the DBus connection
+func (m *MPRISDetector) Close() {
+	if m.conn != nil {
+		m.conn.Close()
+	}
+}
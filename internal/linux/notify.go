@@ -0,0 +1,55 @@
+package linux
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// DesktopNotifier shows notifications through org.freedesktop.Notifications
+type DesktopNotifier struct {
+	conn *dbus.Conn
+
+	mu   sync.Mutex
+	last uint32 // ID of the previous notification, replaced by the next one
+}
+
+// NewDesktopNotifier connects to the session bus
+func NewDesktopNotifier() (*DesktopNotifier, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("connect to session bus: %w", err)
+	}
+	return &DesktopNotifier{conn: conn}, nil
+}
+
+// Notify shows a notification, replacing the previous one so escalating
+// warnings don't pile up. Urgent notifications stay until dismissed.
+func (n *DesktopNotifier) Notify(title, message string, urgent bool) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	urgency := byte(1)
+	timeout := int32(-1) // server default
+	if urgent {
+		urgency = 2
+		timeout = 0 // never expire
+	}
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(urgency)}
+
+	obj := n.conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	call := obj.Call("org.freedesktop.Notifications.Notify", 0,
+		"screentime-agent", n.last, "", title, message, []string{}, hints, timeout)
+	if call.Err != nil {
+		return fmt.Errorf("notify: %w", call.Err)
+	}
+	return call.Store(&n.last)
+}
+
+// Close closes the DBus connection
+func (n *DesktopNotifier) Close() {
+	if n.conn != nil {
+		n.conn.Close()
+	}
+}
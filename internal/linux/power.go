@@ -0,0 +1,116 @@
+package linux
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Low-power modes accepted in LowPowerConfig.Mode
+const (
+	LowPowerAuto   = "auto"   // while UPower reports the machine on battery
+	LowPowerAlways = "always" // regardless of power source
+	LowPowerOff    = "off"
+)
+
+// LowPowerConfig controls low-power mode. While it is in effect, detections
+// are reused for longer, Firefox session files and Steam logs aren't read,
+// and a focused browser is reported by its window instead of its tab.
+type LowPowerConfig struct {
+	Mode string `json:"mode"`
+	// DetectionCacheMS replaces detection_cache_ms in low-power mode.
+	DetectionCacheMS int `json:"detection_cache_ms"`
+}
+
+// DefaultLowPowerConfig enters low-power mode on battery and detects at
+// most every five seconds while in it.
+func DefaultLowPowerConfig() LowPowerConfig {
+	return LowPowerConfig{
+		Mode:             LowPowerAuto,
+		DetectionCacheMS: 5000,
+	}
+}
+
+const (
+	upowerName  = "org.freedesktop.UPower"
+	upowerPath  = "/org/freedesktop/UPower"
+	upowerIface = "org.freedesktop.UPower"
+)
+
+// PowerMonitor follows UPower's OnBattery property on the system bus.
+type PowerMonitor struct {
+	conn      *dbus.Conn
+	signals   chan *dbus.Signal
+	onBattery atomic.Bool
+}
+
+// NewPowerMonitor reads the current power source and watches for changes.
+func NewPowerMonitor() (*PowerMonitor, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("connect to system bus: %w", err)
+	}
+
+	v, err := conn.Object(upowerName, upowerPath).GetProperty(upowerIface + ".OnBattery")
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read upower OnBattery: %w", err)
+	}
+	onBattery, _ := v.Value().(bool)
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(upowerPath),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+	); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("watch upower: %w", err)
+	}
+
+	p := &PowerMonitor{conn: conn, signals: make(chan *dbus.Signal, 8)}
+	p.set(onBattery)
+	conn.Signal(p.signals)
+	go p.watch()
+	return p, nil
+}
+
+func (p *PowerMonitor) watch() {
+	for sig := range p.signals {
+		if sig.Path != upowerPath || len(sig.Body) < 2 {
+			continue
+		}
+		if iface, _ := sig.Body[0].(string); iface != upowerIface {
+			continue
+		}
+		changed, _ := sig.Body[1].(map[string]dbus.Variant)
+		if v, ok := changed["OnBattery"]; ok {
+			onBattery, _ := v.Value().(bool)
+			p.set(onBattery)
+		}
+	}
+}
+
+func (p *PowerMonitor) set(onBattery bool) {
+	if p.onBattery.Swap(onBattery) == onBattery {
+		return
+	}
+	if onBattery {
+		log.Printf("power: on battery, entering low-power mode")
+	} else {
+		log.Printf("power: on mains, leaving low-power mode")
+	}
+}
+
+// OnBattery reports whether the machine is running on battery.
+func (p *PowerMonitor) OnBattery() bool {
+	return p.onBattery.Load()
+}
+
+// Close stops watching.
+func (p *PowerMonitor) Close() {
+	p.conn.RemoveSignal(p.signals)
+	p.conn.Close()
+	close(p.signals)
+}
@@ -0,0 +1,153 @@
+package linux
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PrivacyConfig controls what the agent reveals about window titles
+type PrivacyConfig struct {
+	// Enabled turns on redaction. Browser windows then never report a page
+	// title, only the tab's domain and category (or the browser name),
+	// and chat servers and workspaces are hashed in activity IDs.
+	Enabled bool `json:"enabled"`
+	// TitlePatterns are regular expressions; titles matching any of them
+	// are redacted. Empty means every window title is redacted.
+	TitlePatterns []string `json:"title_patterns,omitempty"`
+	// TitleMode is "hash" (default) or "truncate".
+	TitleMode string `json:"title_mode,omitempty"`
+	// TruncateLength is how many characters "truncate" keeps. Defaults to 16.
+	TruncateLength int `json:"truncate_length,omitempty"`
+}
+
+// Redactor applies the privacy config to detected activities
+type Redactor struct {
+	config   PrivacyConfig
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor compiles the privacy config
+func NewRedactor(cfg PrivacyConfig) (*Redactor, error) {
+	r := &Redactor{config: cfg}
+	switch cfg.TitleMode {
+	case "":
+		r.config.TitleMode = "hash"
+	case "hash", "truncate":
+	default:
+		return nil, fmt.Errorf("privacy: unknown title_mode %q", cfg.TitleMode)
+	}
+	if r.config.TruncateLength <= 0 {
+		r.config.TruncateLength = 16
+	}
+	for _, p := range cfg.TitlePatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("privacy: title pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Apply redacts an activity and its secondary signals
+func (r *Redactor) Apply(a Activity) Activity {
+	if !r.config.Enabled {
+		return a
+	}
+
+	a = r.apply(a)
+	for i := range a.Secondary {
+		a.Secondary[i] = r.apply(a.Secondary[i])
+	}
+	return a
+}
+
+func (r *Redactor) apply(a Activity) Activity {
+	id := r.redactID(a.ID, a.Name)
+	a.Name = r.redactName(a.ID, a.Name)
+	a.Title = r.redactTitle(a.ID, a.Title)
+	a.ID = id
+	return a
+}
+
+// redactID hashes the server or workspace in electron IDs, which would
+// otherwise name it even with the name redacted. Hashing keeps the ID
+// stable, so sessions still split between workspaces.
+func (r *Redactor) redactID(id, name string) string {
+	kind, rest, _ := strings.Cut(id, ":")
+	if kind != "electron" {
+		return id
+	}
+	parts := strings.SplitN(rest, ":", 3)
+	if len(parts) < 3 || parts[2] == "dm" {
+		return id
+	}
+	if !r.matches(parts[2]) && !r.matches(name) {
+		return id
+	}
+	return fmt.Sprintf("electron:%s:%s:%s", parts[0], parts[1], hash(parts[2]))
+}
+
+func (r *Redactor) redactName(id, name string) string {
+	kind, rest, _ := strings.Cut(id, ":")
+	switch kind {
+	case "browser", "steam", "game", "call":
+		// Already a domain or a product name, not a title
+		return name
+	case "window":
+		// A browser whose tab couldn't be read would otherwise leak the
+		// page title through the window title.
+		if (&WindowInfo{Instance: rest}).IsBrowser() {
+			return rest
+		}
+	}
+
+	return r.redact(name)
+}
+
+// redactTitle redacts a tracked title. Page titles are never reported for
+// browsers, like their names.
+func (r *Redactor) redactTitle(id, title string) string {
+	kind, rest, _ := strings.Cut(id, ":")
+	if kind == "browser" || (kind == "window" && (&WindowInfo{Instance: rest}).IsBrowser()) {
+		return ""
+	}
+	return r.redact(title)
+}
+
+func (r *Redactor) redact(name string) string {
+	if !r.matches(name) {
+		return name
+	}
+	if r.config.TitleMode == "truncate" {
+		runes := []rune(name)
+		if len(runes) > r.config.TruncateLength {
+			return string(runes[:r.config.TruncateLength]) + "…"
+		}
+		return name
+	}
+	return "redacted:" + hash(name)
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:6])
+}
+
+func (r *Redactor) matches(name string) bool {
+	if name == "" {
+		return false
+	}
+	if len(r.patterns) == 0 {
+		return true
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,53 +2,169 @@ package linux
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"screentime-agent/internal/tlsutil"
 )
 
+// Version is the agent's version, reported to the hub. Release builds set
+// it with -ldflags "-X screentime-agent/internal/linux.Version=...".
+var Version = "dev"
+
 // Server provides the Roku-compatible HTTP API
 type Server struct {
 	detector *Detector
 	config   *Config
+	hostname string
+	history  *History
+	cache    *detectionCache
 	server   *http.Server
+
+	// categories is the display metadata sent with every answer, sorted
+	// by name.
+	categories []xmlCategory
+
+	notifyOnce sync.Once
+	notifier   *DesktopNotifier
+
+	// hubSeen is the latest signed request timestamp per path.
+	hubMu   sync.Mutex
+	hubSeen map[string]time.Time
 }
 
-// activeAppResponse matches the Roku XML format
+// activeAppResponse matches the Roku XML format. Secondary, Categories and
+// the hostname, version, account and title attributes are extensions real
+// Roku devices never send; Roku parsers ignore unknown elements and
+// attributes.
 type activeAppResponse struct {
-	XMLName xml.Name `xml:"active-app"`
-	App     struct {
-		ID   string `xml:"id,attr"`
-		Name string `xml:",chardata"`
-	} `xml:"app"`
+	XMLName    xml.Name      `xml:"active-app"`
+	Hostname   string        `xml:"hostname,attr,omitempty"`
+	Version    string        `xml:"version,attr,omitempty"`
+	App        xmlApp        `xml:"app"`
+	Secondary  []xmlApp      `xml:"secondary>app,omitempty"`
+	Categories []xmlCategory `xml:"categories>category,omitempty"`
+}
+
+// xmlCategory is the display metadata of a configured category.
+type xmlCategory struct {
+	Name  string `xml:"name,attr"`
+	Color string `xml:"color,attr,omitempty"`
+	Icon  string `xml:"icon,attr,omitempty"`
+}
+
+type xmlApp struct {
+	ID      string `xml:"id,attr"`
+	Account string `xml:"account,attr,omitempty"`
+	Title   string `xml:"title,attr,omitempty"`
+	Name    string `xml:",chardata"`
+}
+
+// mediaPlayerResponse matches the Roku /query/media-player format, reduced
+// to the playback state.
+type mediaPlayerResponse struct {
+	XMLName xml.Name `xml:"player"`
+	Error   bool     `xml:"error,attr"`
+	State   string   `xml:"state,attr"`
 }
 
 // NewServer creates a new HTTP server
 func NewServer(cfg *Config, detector *Detector) *Server {
-	return &Server{
+	s := &Server{
 		detector: detector,
 		config:   cfg,
+		hostname: cfg.Hostname,
+		history:  NewHistory(cfg.HistorySize),
 	}
+	if s.hostname == "" {
+		if h, err := os.Hostname(); err == nil {
+			s.hostname = h
+		}
+	}
+	for name, c := range cfg.Categories {
+		if c.Color != "" || c.Icon != "" {
+			s.categories = append(s.categories, xmlCategory{Name: name, Color: c.Color, Icon: c.Icon})
+		}
+	}
+	sort.Slice(s.categories, func(i, j int) bool { return s.categories[i].Name < s.categories[j].Name })
+	s.cache = newDetectionCache(s.cacheTTL, detector.Detect)
+
+	// Detect on every pushed window change so switches between hub polls
+	// still show up in the history and the next poll never sees a stale
+	// cache entry. Low-power mode waits for the cache to expire instead.
+	detector.OnWindowChange(func() {
+		if detector.LowPower() {
+			return
+		}
+		go func() {
+			s.cache.Invalidate()
+			if a, at, fresh := s.cache.Get(); fresh {
+				s.history.Record(at, a)
+			}
+		}()
+	})
+	return s
+}
+
+// cacheTTL is how long a detection is reused, longer in low-power mode.
+func (s *Server) cacheTTL() time.Duration {
+	ms := s.config.DetectionCacheMS
+	if s.detector.LowPower() {
+		ms = s.config.LowPower.DetectionCacheMS
+	}
+	return time.Duration(ms) * time.Millisecond
 }
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/query/active-app", s.handleActiveApp)
+	mux.HandleFunc("/query/media-player", s.handleMediaPlayer)
+	mux.HandleFunc("/notify", s.handleNotify)
+	mux.HandleFunc("/block", s.handleBlock)
 	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/debug/history", s.handleHistory)
 
 	s.server = &http.Server{
 		Addr:    s.config.Listen,
 		Handler: mux,
 	}
 
+	if s.config.TLS != nil {
+		tlsCfg, fp, err := s.config.TLS.Load(configDir())
+		if err != nil {
+			return err
+		}
+		s.server.TLSConfig = tlsCfg
+		log.Printf("Starting Linux agent on %s over HTTPS, certificate fingerprint %s", s.config.Listen, fp)
+		return s.server.ListenAndServeTLS("", "")
+	}
+	if s.config.MTLS {
+		tlsCfg, err := tlsutil.LoadEnrolled(configDir())
+		if err != nil {
+			return fmt.Errorf("mtls: %w (run linux-agent enroll first)", err)
+		}
+		s.server.TLSConfig = tlsCfg
+		log.Printf("Starting Linux agent on %s over mutual TLS", s.config.Listen)
+		return s.server.ListenAndServeTLS("", "")
+	}
+
 	log.Printf("Starting Linux agent on %s", s.config.Listen)
 	return s.server.ListenAndServe()
 }
 
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
+	if s.notifier != nil {
+		s.notifier.Close()
+	}
 	if s.server != nil {
 		return s.server.Shutdown(ctx)
 	}
@@ -56,11 +172,22 @@ func (s *Server) Shutdown(ctx context.Context) error {
 }
 
 func (s *Server) handleActiveApp(w http.ResponseWriter, r *http.Request) {
-	activity := s.detector.Detect()
+	activity, at, fresh := s.cache.Get()
+	if fresh {
+		s.history.Record(at, activity)
+	}
 
-	resp := activeAppResponse{}
+	resp := activeAppResponse{Hostname: s.hostname, Version: Version}
 	resp.App.ID = activity.ID
 	resp.App.Name = activity.Name
+	resp.App.Account = activity.Account
+	if s.config.TrackTitles {
+		resp.App.Title = activity.Title
+	}
+	for _, a := range activity.Secondary {
+		resp.Secondary = append(resp.Secondary, xmlApp{ID: a.ID, Account: a.Account, Name: a.Name})
+	}
+	resp.Categories = s.categories
 
 	w.Header().Set("Content-Type", "application/xml")
 
@@ -75,6 +202,84 @@ func (s *Server) handleActiveApp(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleMediaPlayer(w http.ResponseWriter, r *http.Request) {
+	resp := mediaPlayerResponse{State: s.detector.MediaState()}
+
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("error encoding response: %v", err)
+	}
+}
+
+// handleNotify shows a desktop notification sent by the hub, e.g. a limit
+// warning. The body is JSON: {"title", "message", "urgent", "timestamp"}.
+func (s *Server) handleNotify(w http.ResponseWriter, r *http.Request) {
+	var n struct {
+		Title   string `json:"title"`
+		Message string `json:"message"`
+		Urgent  bool   `json:"urgent"`
+	}
+	if !s.readHubRequest(w, r, "/notify", &n) {
+		return
+	}
+	if n.Message == "" {
+		http.Error(w, "invalid notification", http.StatusBadRequest)
+		return
+	}
+
+	s.notifyOnce.Do(func() {
+		notifier, err := NewDesktopNotifier()
+		if err != nil {
+			log.Printf("desktop notifications unavailable: %v", err)
+			return
+		}
+		s.notifier = notifier
+	})
+	if s.notifier == nil {
+		http.Error(w, "notifications unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := s.notifier.Notify(n.Title, n.Message, n.Urgent); err != nil {
+		log.Printf("error showing notification: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHistory returns recent detections, by default over the last hour.
+// ?since= accepts either an RFC3339 timestamp or a Go duration like "15m".
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().Add(-time.Hour)
+	if v := r.URL.Query().Get("since"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			since = t
+		} else {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp := struct {
+		Since   time.Time      `json:"since"`
+		Entries []HistoryEntry `json:"entries"`
+	}{
+		Since:   since,
+		Entries: s.history.Since(since),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(resp); err != nil {
+		log.Printf("error encoding history: %v", err)
+	}
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	fmt.Fprintln(w, "ok")
@@ -0,0 +1,264 @@
+package linux
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+// SNSS is the command log Chromium-based browsers write their session to:
+// a "SNSS" magic and version, then commands of a uint16 size, a one-byte
+// id and a payload. Replaying the commands rebuilds the windows and tabs.
+// Only the commands that locate the active tab are decoded.
+const (
+	snssMagic             = "SNSS"
+	snssVersion           = 1
+	snssVersionWithMarker = 3
+)
+
+// SNSS command ids, from Chromium's session_service_commands.cc.
+const (
+	snssSetTabWindow          = 0
+	snssSetTabIndexInWindow   = 2
+	snssUpdateTabNavigation   = 6
+	snssSetSelectedNavIndex   = 7
+	snssSetSelectedTabInIndex = 8
+	snssTabClosed             = 16
+	snssWindowClosed          = 17
+	snssSetActiveWindow       = 20
+)
+
+// snssMaxNavigations bounds the back history kept per tab.
+const snssMaxNavigations = 64
+
+type snssNavigation struct {
+	url   string
+	title string
+}
+
+type snssTab struct {
+	window      int32
+	index       int // position in the window
+	selectedNav int
+	navs        map[int]snssNavigation
+}
+
+type snssSession struct {
+	tabs         map[int32]*snssTab
+	selectedTab  map[int32]int // window -> selected tab position
+	activeWindow int32
+	hasActive    bool
+	lastWindow   int32 // most recent window to change its selection
+}
+
+// parseSNSS replays a session file and returns the selected tab of the
+// active window. A truncated final command, as when the browser is
+// mid-write, ends the replay rather than failing it.
+func parseSNSS(r io.Reader) (*BrowserTab, error) {
+	br := bufio.NewReader(r)
+
+	var header [8]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if string(header[:4]) != snssMagic {
+		return nil, fmt.Errorf("not a session file")
+	}
+	switch v := binary.LittleEndian.Uint32(header[4:]); v {
+	case snssVersion, snssVersionWithMarker:
+	default:
+		// Versions 2 and 4 are encrypted.
+		return nil, fmt.Errorf("unsupported session version %d", v)
+	}
+
+	s := &snssSession{
+		tabs:        make(map[int32]*snssTab),
+		selectedTab: make(map[int32]int),
+	}
+	for {
+		var size uint16
+		if err := binary.Read(br, binary.LittleEndian, &size); err != nil {
+			break
+		}
+		if size == 0 {
+			continue
+		}
+		cmd := make([]byte, size)
+		if _, err := io.ReadFull(br, cmd); err != nil {
+			break
+		}
+		s.apply(cmd[0], cmd[1:])
+	}
+
+	return s.activeTab()
+}
+
+func (s *snssSession) tab(id int32) *snssTab {
+	t, ok := s.tabs[id]
+	if !ok {
+		t = &snssTab{index: -1, selectedNav: -1, navs: make(map[int]snssNavigation)}
+		s.tabs[id] = t
+	}
+	return t
+}
+
+// apply replays one command. Malformed payloads are ignored like unknown
+// commands; the session is best effort.
+func (s *snssSession) apply(id byte, p []byte) {
+	switch id {
+	case snssSetTabWindow:
+		if len(p) >= 8 {
+			s.tab(snssInt32(p[4:])).window = snssInt32(p)
+		}
+	case snssSetTabIndexInWindow:
+		if len(p) >= 8 {
+			s.tab(snssInt32(p)).index = int(snssInt32(p[4:]))
+		}
+	case snssUpdateTabNavigation:
+		tabID, index, nav, err := snssNavigationPickle(p)
+		if err != nil {
+			return
+		}
+		t := s.tab(tabID)
+		if _, ok := t.navs[index]; ok || len(t.navs) < snssMaxNavigations {
+			t.navs[index] = nav
+		}
+	case snssSetSelectedNavIndex:
+		if len(p) >= 8 {
+			s.tab(snssInt32(p)).selectedNav = int(snssInt32(p[4:]))
+		}
+	case snssSetSelectedTabInIndex:
+		if len(p) >= 8 {
+			w := snssInt32(p)
+			s.selectedTab[w] = int(snssInt32(p[4:]))
+			s.lastWindow = w
+		}
+	case snssTabClosed:
+		if len(p) >= 4 {
+			delete(s.tabs, snssInt32(p))
+		}
+	case snssWindowClosed:
+		if len(p) >= 4 {
+			w := snssInt32(p)
+			delete(s.selectedTab, w)
+			for id, t := range s.tabs {
+				if t.window == w {
+					delete(s.tabs, id)
+				}
+			}
+			if s.hasActive && s.activeWindow == w {
+				s.hasActive = false
+			}
+		}
+	case snssSetActiveWindow:
+		if len(p) >= 4 {
+			s.activeWindow, s.hasActive = snssInt32(p), true
+		}
+	}
+}
+
+// activeTab finds the selected tab of the active window, falling back to
+// the window that most recently changed tabs.
+func (s *snssSession) activeTab() (*BrowserTab, error) {
+	window := s.lastWindow
+	if s.hasActive {
+		window = s.activeWindow
+	}
+	selected, ok := s.selectedTab[window]
+	if !ok {
+		return nil, fmt.Errorf("no selected tab in session")
+	}
+
+	for _, t := range s.tabs {
+		if t.window != window || t.index != selected {
+			continue
+		}
+		nav, ok := t.navs[t.selectedNav]
+		if !ok {
+			// Without a selection the newest entry is the current page.
+			best := -1
+			for i := range t.navs {
+				if i > best {
+					best = i
+				}
+			}
+			if best < 0 {
+				return nil, fmt.Errorf("no entries in tab")
+			}
+			nav = t.navs[best]
+		}
+		return &BrowserTab{
+			URL:    nav.url,
+			Title:  nav.title,
+			Domain: extractDomain(nav.url),
+		}, nil
+	}
+	return nil, fmt.Errorf("selected tab not in session")
+}
+
+// snssNavigationPickle decodes the start of an UpdateTabNavigation
+// payload, a base::Pickle: tab id, navigation index, URL and UTF-16 title.
+func snssNavigationPickle(p []byte) (tabID int32, index int, nav snssNavigation, err error) {
+	// Skip the pickle's uint32 payload size.
+	if len(p) < 4 {
+		return 0, 0, nav, errors.New("short pickle")
+	}
+	pr := &pickleReader{b: p[4:]}
+	tabID = pr.int32()
+	index = int(pr.int32())
+	nav.url = string(pr.bytes(int(pr.int32())))
+	nav.title = pr.string16()
+	return tabID, index, nav, pr.err
+}
+
+func snssInt32(b []byte) int32 {
+	return int32(binary.LittleEndian.Uint32(b))
+}
+
+// pickleReader reads 4-byte-aligned base::Pickle fields, recording the
+// first error.
+type pickleReader struct {
+	b   []byte
+	err error
+}
+
+func (r *pickleReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b) {
+		r.err = errors.New("pickle field out of range")
+		return nil
+	}
+	out := r.b[:n]
+	aligned := (n + 3) &^ 3
+	if aligned > len(r.b) {
+		aligned = len(r.b)
+	}
+	r.b = r.b[aligned:]
+	return out
+}
+
+func (r *pickleReader) int32() int32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return snssInt32(b)
+}
+
+func (r *pickleReader) string16() string {
+	n := int(r.int32())
+	b := r.bytes(n * 2)
+	if b == nil {
+		return ""
+	}
+	u := make([]uint16, n)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,251 @@
+package linux
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Source is one detector in the priority chain. Activity returns what the
+// source sees, or nil to leave the decision to the next source.
+type Source interface {
+	Activity(p *Probe) (*Activity, error)
+}
+
+// Probe carries one detection round's shared lookups, so sources that need
+// the focused window or the active browser tab don't each fetch it.
+type Probe struct {
+	// LowPower is set when expensive probes should be skipped.
+	LowPower bool
+
+	d *Detector
+
+	windowInfo    *WindowInfo
+	windowState   *Activity
+	windowChecked bool
+
+	tab        *BrowserTab
+	tabChecked bool
+}
+
+// Window returns the focused window, or a terminal activity when the
+// window itself says the machine is idle or detection failed. Both are nil
+// when no source needs windows.
+func (p *Probe) Window() (*WindowInfo, *Activity) {
+	if p.d.window == nil {
+		return nil, nil
+	}
+	if !p.windowChecked {
+		p.windowInfo, p.windowState = p.d.focusedWindow()
+		p.windowChecked = true
+	}
+	return p.windowInfo, p.windowState
+}
+
+// Tab returns the active tab of the focused browser: Firefox's from its
+// recovery file, any other browser's from its Chromium session file. It is
+// never read in low-power mode.
+func (p *Probe) Tab() *BrowserTab {
+	if p.LowPower || p.d.browser == nil {
+		return nil
+	}
+	if !p.tabChecked {
+		var err error
+		if info, _ := p.Window(); info.IsChromium() {
+			p.tab, err = p.d.browser.DetectChromium(info.Title)
+			if err != nil {
+				log.Printf("chromium detection error: %v", err)
+			}
+		} else {
+			p.tab, err = p.d.browser.DetectFirefox()
+			if err != nil {
+				log.Printf("firefox detection error: %v", err)
+			}
+		}
+		p.tabChecked = true
+	}
+	return p.tab
+}
+
+// callSource reports a focused conferencing app using the camera or mic.
+type callSource struct {
+	calls *CallDetector
+}
+
+func (s callSource) Activity(p *Probe) (*Activity, error) {
+	// An idle or unknown window just means no call; leave the terminal
+	// state for the sources that report windows.
+	info, state := p.Window()
+	if state != nil {
+		return nil, nil
+	}
+	call, err := s.calls.Detect(info, p.Tab)
+	if call == nil {
+		return nil, err
+	}
+	return &Activity{
+		ID:    fmt.Sprintf("call:%s", call.App),
+		Name:  call.App,
+		State: "active",
+	}, err
+}
+
+// steamSource reports the running Steam game. Reading Steam's logs is
+// skipped in low-power mode.
+type steamSource struct {
+	steam *SteamDetector
+}
+
+func (s steamSource) Activity(p *Probe) (*Activity, error) {
+	if p.LowPower {
+		return nil, nil
+	}
+	game, err := s.steam.Detect()
+	if game == nil {
+		return nil, err
+	}
+	a := &Activity{
+		ID:    fmt.Sprintf("steam:%s", game.AppID),
+		Name:  game.Name,
+		State: "active",
+	}
+	if game.Account != nil {
+		a.Account = "steam:" + game.Account.SteamID
+	}
+	return a, err
+}
+
+// mprisSource reports playing media.
+type mprisSource struct {
+	mpris *MPRISDetector
+}
+
+func (s mprisSource) Activity(p *Probe) (*Activity, error) {
+	media, err := s.mpris.Detect()
+	if media == nil {
+		return nil, err
+	}
+	name := media.Title
+	if name == "" {
+		name = media.Player
+	}
+	return &Activity{
+		ID:    fmt.Sprintf("media:%s", media.Player),
+		Name:  name,
+		State: "active",
+	}, err
+}
+
+// gameSource reports a focused window that looks like a game.
+type gameSource struct {
+	games *GameDetector
+	wine  *WineResolver
+}
+
+func (s gameSource) Activity(p *Probe) (*Activity, error) {
+	info, state := p.Window()
+	if state != nil {
+		return state, nil
+	}
+	// Anything running under Wine/Proton with a window is a game for our
+	// purposes; name it after its executable.
+	if exe, gameName, ok := s.wine.Resolve(info); ok {
+		return &Activity{
+			ID:    fmt.Sprintf("game:%s", exe),
+			Name:  gameName,
+			State: "active",
+		}, nil
+	}
+	if game := s.games.Detect(info, time.Now()); game != nil {
+		return &Activity{
+			ID:    fmt.Sprintf("game:%s", game.Slug),
+			Name:  game.Name,
+			State: "active",
+		}, nil
+	}
+	return nil, nil
+}
+
+// browserSource reports the active tab of a focused browser. In low-power
+// mode it stands aside and the browser is reported as a window.
+type browserSource struct {
+	category *Categorizer
+}
+
+func (s browserSource) Activity(p *Probe) (*Activity, error) {
+	if p.LowPower {
+		return nil, nil
+	}
+	info, state := p.Window()
+	if state != nil {
+		return state, nil
+	}
+	if !info.IsBrowser() {
+		return nil, nil
+	}
+	tab := p.Tab()
+	if tab == nil || tab.Domain == "" {
+		// Couldn't get tab info, fall through to the next source
+		return nil, nil
+	}
+	category := s.category.Categorize(tab.Domain)
+	// Include the domain so the hub sees a new app (and starts a
+	// new session) on every tab switch, not just category changes.
+	return &Activity{
+		ID:    fmt.Sprintf("browser:%s:%s", category, tab.Domain),
+		Name:  tab.Domain,
+		State: "active",
+		Title: tab.Title,
+	}, nil
+}
+
+// windowSource reports the focused window; it always has an answer.
+type windowSource struct {
+	wine     *WineResolver
+	category *Categorizer
+}
+
+func (s windowSource) Activity(p *Probe) (*Activity, error) {
+	info, state := p.Window()
+	if state != nil {
+		return state, nil
+	}
+	if exe, exeName, ok := s.wine.Resolve(info); ok {
+		return &Activity{
+			ID:    fmt.Sprintf("window:%s", exe),
+			Name:  exeName,
+			State: "active",
+			Title: info.Title,
+		}, nil
+	}
+	if a := s.electron(info); a != nil {
+		a.Title = info.Title
+		return a, nil
+	}
+	return &Activity{
+		ID:    fmt.Sprintf("window:%s", info.Instance),
+		Name:  info.Title,
+		State: "active",
+		Title: info.Title,
+	}, nil
+}
+
+// electron identifies the server or workspace in chat apps whose window
+// class alone says nothing. Channels go in the name only, so moving
+// between channels doesn't split the session.
+func (s windowSource) electron(info *WindowInfo) *Activity {
+	ec, ok := ParseElectronTitle(info)
+	if !ok {
+		return nil
+	}
+	category := s.category.CategorizeWorkspace(ec.App, ec.Workspace)
+	workspace := ec.Workspace
+	if workspace == "" {
+		workspace = "dm"
+	}
+	return &Activity{
+		ID:    fmt.Sprintf("electron:%s:%s:%s", category, ec.App, workspace),
+		Name:  fmt.Sprintf("%s: %s %s", info.Class, workspace, ec.Channel),
+		State: "active",
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"os/user"
@@ -16,17 +18,53 @@ import (
 	"time"
 )
 
+// Steam store lookups are rate limited to steamBurst requests at once,
+// refilled one every steamRefill, well within what the store allows.
+// Failed lookups aren't retried for a while: unknown apps (tools,
+// delisted games) for steamMissTTL, network and server errors for
+// steamErrorTTL.
+const (
+	steamBurst    = 5
+	steamRefill   = 10 * time.Second
+	steamMissTTL  = 6 * time.Hour
+	steamErrorTTL = time.Minute
+)
+
+// errSteamNoData is returned for apps the store has no details for.
+var errSteamNoData = errors.New("steam store has no data")
+
 // SteamDetector detects currently running Steam games
 type SteamDetector struct {
 	mu        sync.RWMutex
 	nameCache map[string]string // appID -> game name
 	client    *http.Client
+
+	// misses holds when failed lookups may be retried, by appID, and
+	// lookups the running ones, so concurrent polls share one request.
+	misses  map[string]time.Time
+	lookups map[string]*steamLookup
+
+	// tokens and refilled are the store rate limiter's state.
+	tokens   int
+	refilled time.Time
+
+	// account is the active account as of loginusers.vdf's accountMod.
+	account    *SteamAccount
+	accountMod time.Time
+}
+
+// steamLookup is a store request other callers for the same app wait on.
+type steamLookup struct {
+	done chan struct{}
+	name string
+	err  error
 }
 
 // SteamGame represents a running Steam game
 type SteamGame struct {
-	AppID string
-	Name  string
+	AppID   string
+	Name    string
+	Account *SteamAccount // nil if Steam has no signed-in account on record
 }
 
 // NewSteamDetector creates a new Steam game detector
@@ -34,6 +72,10 @@ func NewSteamDetector() *SteamDetector {
 	return &SteamDetector{
 		nameCache: make(map[string]string),
 		client:    &http.Client{Timeout: 5 * time.Second},
+		misses:    make(map[string]time.Time),
+		lookups:   make(map[string]*steamLookup),
+		tokens:    steamBurst,
+		refilled:  time.Now(),
 	}
 }
 
@@ -69,7 +111,13 @@ func (s *SteamDetector) Detect() (*SteamGame, error) {
 		name = "Steam Game " + appID
 	}
 
-	return &SteamGame{AppID: appID, Name: name}, nil
+	// On a shared PC the game belongs to whoever is signed in to Steam.
+	account, err := s.ActiveAccount()
+	if err != nil {
+		log.Printf("steam account error: %v", err)
+	}
+
+	return &SteamGame{AppID: appID, Name: name, Account: account}, nil
 }
 
 func steamLogPath() (string, error) {
@@ -112,15 +160,73 @@ func (s *SteamDetector) runningGameFromLog(r io.Reader) (string, error) {
 	return currentAppID, scanner.Err()
 }
 
+// lookupGameName returns the store name of appID, from the cache when it
+// can. Concurrent lookups of one app share a request, and none is made for
+// an app that recently failed or while the rate limit is used up.
 func (s *SteamDetector) lookupGameName(appID string) (string, error) {
-	// Check cache first
-	s.mu.RLock()
+	s.mu.Lock()
 	if name, ok := s.nameCache[appID]; ok {
-		s.mu.RUnlock()
+		s.mu.Unlock()
 		return name, nil
 	}
-	s.mu.RUnlock()
+	if l, ok := s.lookups[appID]; ok {
+		s.mu.Unlock()
+		<-l.done
+		return l.name, l.err
+	}
+	now := time.Now()
+	if retry, ok := s.misses[appID]; ok && now.Before(retry) {
+		s.mu.Unlock()
+		return "", fmt.Errorf("steam lookup for appID %s failed recently", appID)
+	}
+	if !s.takeToken(now) {
+		s.mu.Unlock()
+		return "", fmt.Errorf("steam lookup for appID %s: rate limited", appID)
+	}
+	l := &steamLookup{done: make(chan struct{})}
+	s.lookups[appID] = l
+	s.mu.Unlock()
+
+	l.name, l.err = s.fetchGameName(appID)
+
+	s.mu.Lock()
+	delete(s.lookups, appID)
+	switch {
+	case l.err == nil:
+		s.nameCache[appID] = l.name
+		delete(s.misses, appID)
+	case errors.Is(l.err, errSteamNoData):
+		s.misses[appID] = time.Now().Add(steamMissTTL)
+	default:
+		s.misses[appID] = time.Now().Add(steamErrorTTL)
+	}
+	s.mu.Unlock()
+	close(l.done)
 
+	return l.name, l.err
+}
+
+// takeToken reports whether a store request may be made now, using up a
+// token if so. It must be called with mu held.
+func (s *SteamDetector) takeToken(now time.Time) bool {
+	if n := int(now.Sub(s.refilled) / steamRefill); n > 0 {
+		s.tokens = min(s.tokens+n, steamBurst)
+		s.refilled = s.refilled.Add(time.Duration(n) * steamRefill)
+	}
+	if s.tokens == 0 {
+		return false
+	}
+	if s.tokens == steamBurst {
+		// A full bucket starts refilling from now, not from when it
+		// filled up.
+		s.refilled = now
+	}
+	s.tokens--
+	return true
+}
+
+// fetchGameName asks the Steam store for appID's name.
+func (s *SteamDetector) fetchGameName(appID string) (string, error) {
 	// Query Steam API
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -136,6 +242,9 @@ func (s *SteamDetector) lookupGameName(appID string) (string, error) {
 		return "", err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("steam store returned status %d", resp.StatusCode)
+	}
 
 	var details map[string]struct {
 		Success bool `json:"success"`
@@ -149,18 +258,10 @@ func (s *SteamDetector) lookupGameName(appID string) (string, error) {
 	}
 
 	info, ok := details[appID]
-	if !ok || !info.Success {
-		return "", fmt.Errorf("no data for appID %s", appID)
+	if !ok || !info.Success || info.Data.Name == "" {
+		return "", fmt.Errorf("appID %s: %w", appID, errSteamNoData)
 	}
-
-	name := info.Data.Name
-
-	// Cache the result
-	s.mu.Lock()
-	s.nameCache[appID] = name
-	s.mu.Unlock()
-
-	return name, nil
+	return info.Data.Name, nil
 }
 
 
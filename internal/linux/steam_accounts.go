@@ -0,0 +1,188 @@
+package linux
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SteamAccount is a Steam account that has signed in on this machine.
+type SteamAccount struct {
+	SteamID     string // 64-bit SteamID
+	AccountName string
+	PersonaName string
+}
+
+// ActiveAccount returns the account most recently signed in to Steam, or
+// nil if Steam has no record of one. The answer is cached until
+// loginusers.vdf changes.
+func (s *SteamDetector) ActiveAccount() (*SteamAccount, error) {
+	path, err := steamLoginUsersPath()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("stat steam login users: %w", err)
+	}
+
+	s.mu.RLock()
+	if info.ModTime().Equal(s.accountMod) {
+		acct := s.account
+		s.mu.RUnlock()
+		return acct, nil
+	}
+	s.mu.RUnlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open steam login users: %w", err)
+	}
+	defer f.Close()
+
+	acct, err := activeSteamAccount(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse steam login users: %w", err)
+	}
+
+	s.mu.Lock()
+	s.account, s.accountMod = acct, info.ModTime()
+	s.mu.Unlock()
+	return acct, nil
+}
+
+func steamLoginUsersPath() (string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(u.HomeDir, ".local", "share", "Steam", "config", "loginusers.vdf"), nil
+}
+
+// activeSteamAccount picks the account marked MostRecent in loginusers.vdf,
+// falling back to the one with the latest sign-in Timestamp.
+func activeSteamAccount(r io.Reader) (*SteamAccount, error) {
+	tokens, err := vdfTokens(r)
+	if err != nil {
+		return nil, err
+	}
+	root, _, err := parseVDF(tokens)
+	if err != nil {
+		return nil, err
+	}
+	users, _ := root["users"].(vdfNode)
+
+	var (
+		best   *SteamAccount
+		bestTS int64 = -1
+	)
+	for id, v := range users {
+		fields, ok := v.(vdfNode)
+		if !ok {
+			continue
+		}
+		acct := &SteamAccount{
+			SteamID:     id,
+			AccountName: fields.str("AccountName"),
+			PersonaName: fields.str("PersonaName"),
+		}
+		if fields.str("MostRecent") == "1" {
+			return acct, nil
+		}
+		ts, _ := strconv.ParseInt(fields.str("Timestamp"), 10, 64)
+		if ts > bestTS {
+			best, bestTS = acct, ts
+		}
+	}
+	return best, nil
+}
+
+// vdfNode is a section of a Valve KeyValues file; values are strings or
+// nested sections.
+type vdfNode map[string]any
+
+func (n vdfNode) str(key string) string {
+	s, _ := n[key].(string)
+	return s
+}
+
+// parseVDF parses key/value pairs up to the closing brace of the current
+// section, returning the section and the tokens after it.
+func parseVDF(tokens []string) (vdfNode, []string, error) {
+	n := make(vdfNode)
+	for len(tokens) > 0 {
+		key := tokens[0]
+		tokens = tokens[1:]
+		switch key {
+		case "}":
+			return n, tokens, nil
+		case "{":
+			return nil, nil, fmt.Errorf("section without a key")
+		}
+		if len(tokens) == 0 {
+			return nil, nil, fmt.Errorf("key %q has no value", key)
+		}
+		if tokens[0] != "{" {
+			n[key] = tokens[0]
+			tokens = tokens[1:]
+			continue
+		}
+		child, rest, err := parseVDF(tokens[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[key], tokens = child, rest
+	}
+	return n, nil, nil
+}
+
+// vdfTokens splits KeyValues text into quoted strings and braces, dropping
+// // comments and unquoted conditionals like [$WIN32].
+func vdfTokens(r io.Reader) ([]string, error) {
+	var tokens []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for {
+			line = strings.TrimLeft(line, " \t\r")
+			if line == "" || strings.HasPrefix(line, "//") {
+				break
+			}
+			switch line[0] {
+			case '{', '}':
+				tokens = append(tokens, line[:1])
+				line = line[1:]
+			case '"':
+				var b strings.Builder
+				i := 1
+				for ; i < len(line) && line[i] != '"'; i++ {
+					if line[i] == '\\' && i+1 < len(line) {
+						i++
+					}
+					b.WriteByte(line[i])
+				}
+				if i >= len(line) {
+					return nil, fmt.Errorf("unterminated string")
+				}
+				tokens = append(tokens, b.String())
+				line = line[i+1:]
+			default:
+				end := strings.IndexAny(line, " \t\"{}")
+				if end < 0 {
+					end = len(line)
+				}
+				line = line[end:]
+			}
+		}
+	}
+	return tokens, scanner.Err()
+}
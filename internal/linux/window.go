@@ -3,17 +3,22 @@ package linux
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"os/exec"
 	"strings"
+	"sync"
 
 	"github.com/godbus/dbus/v5"
 )
 
 // WindowInfo contains information about the active window
 type WindowInfo struct {
-	Title    string
-	Class    string // WM_CLASS instance name (e.g., "firefox", "chromium")
-	Instance string // WM_CLASS class name
+	Title      string
+	Class      string // WM_CLASS instance name (e.g., "firefox", "chromium")
+	Instance   string // WM_CLASS class name
+	PID        int    // owning process, 0 if the backend can't tell
+	Fullscreen bool
 }
 
 // CompositorType represents the detected Wayland compositor
@@ -23,12 +28,37 @@ const (
 	CompositorUnknown CompositorType = iota
 	CompositorGNOME
 	CompositorKDE
+	CompositorSway // sway or another compositor speaking its IPC protocol
+	CompositorHyprland
+	CompositorX11 // an EWMH window manager such as Xfce or i3
 )
 
 // WindowDetector detects the currently active window
 type WindowDetector struct {
 	conn       *dbus.Conn
 	compositor CompositorType
+
+	// backends are tried in order by query; active is the one that last
+	// answered.
+	backendMu sync.Mutex
+	backends  []*windowBackend
+	active    int
+	processes *ProcessHeuristic
+
+	// Set once Subscribe succeeds; current is then kept up to date by the
+	// compositor instead of being queried.
+	mu       sync.Mutex
+	pushed   bool
+	current  *WindowInfo
+	onChange func()
+
+	// ipcEvents is the IPC connection sway or Hyprland pushes window
+	// events on.
+	ipcEvents net.Conn
+
+	// kwinScript is the file holding the KWin focus script, removed on
+	// unhook since KWin may read it after loading returns.
+	kwinScript string
 }
 
 // NewWindowDetector creates a new window detector
@@ -38,18 +68,19 @@ func NewWindowDetector() (*WindowDetector, error) {
 		return nil, fmt.Errorf("connect to session bus: %w", err)
 	}
 
-	detector := &WindowDetector{conn: conn}
+	detector := &WindowDetector{conn: conn, processes: NewProcessHeuristic()}
 	detector.compositor = detector.detectCompositor()
 
 	if detector.compositor == CompositorUnknown {
 		conn.Close()
-		return nil, fmt.Errorf("unsupported compositor: could not detect GNOME or KDE")
+		return nil, fmt.Errorf("unsupported compositor: could not detect GNOME, KDE, sway, Hyprland or an X11 session")
 	}
+	detector.backends = detector.windowBackends()
 
 	return detector, nil
 }
 
-// detectCompositor determines which Wayland compositor is running
+// detectCompositor determines which compositor or X11 session is running
 func (w *WindowDetector) detectCompositor() CompositorType {
 	// Check XDG_CURRENT_DESKTOP first
 	desktop := os.Getenv("XDG_CURRENT_DESKTOP")
@@ -61,6 +92,12 @@ func (w *WindowDetector) detectCompositor() CompositorType {
 	if strings.Contains(desktopLower, "kde") || strings.Contains(desktopLower, "plasma") {
 		return CompositorKDE
 	}
+	if swaySocket() != "" {
+		return CompositorSway
+	}
+	if hyprlandDir() != "" {
+		return CompositorHyprland
+	}
 
 	// Fallback: check if DBus services are available
 	if w.isDBusServiceAvailable("org.gnome.Shell") {
@@ -70,6 +107,14 @@ func (w *WindowDetector) detectCompositor() CompositorType {
 		return CompositorKDE
 	}
 
+	// Any other X session is asked through EWMH, which Xfce, i3 and most
+	// other window managers support.
+	if os.Getenv("DISPLAY") != "" {
+		if _, err := exec.LookPath("xprop"); err == nil {
+			return CompositorX11
+		}
+	}
+
 	return CompositorUnknown
 }
 
@@ -91,14 +136,10 @@ func (w *WindowDetector) isDBusServiceAvailable(service string) bool {
 
 // Detect returns information about the currently active window
 func (w *WindowDetector) Detect() (*WindowInfo, error) {
-	switch w.compositor {
-	case CompositorGNOME:
-		return w.detectGNOME()
-	case CompositorKDE:
-		return w.detectKDE()
-	default:
-		return nil, fmt.Errorf("unsupported compositor")
+	if info, ok := w.cached(); ok {
+		return info, nil
 	}
+	return w.query()
 }
 
 // detectGNOME gets active window info using GNOME Shell's Eval method
@@ -112,7 +153,9 @@ func (w *WindowDetector) detectGNOME() (*WindowInfo, error) {
 			if (!win) return JSON.stringify({});
 			return JSON.stringify({
 				title: win.get_title() || '',
-				wmClass: win.get_wm_class() || ''
+				wmClass: win.get_wm_class() || '',
+				pid: win.get_pid() || 0,
+				fullscreen: win.is_fullscreen()
 			});
 		})()
 	`
@@ -130,8 +173,10 @@ func (w *WindowDetector) detectGNOME() (*WindowInfo, error) {
 
 	// Parse the JSON result
 	var data struct {
-		Title   string `json:"title"`
-		WMClass string `json:"wmClass"`
+		Title      string `json:"title"`
+		WMClass    string `json:"wmClass"`
+		PID        int    `json:"pid"`
+		Fullscreen bool   `json:"fullscreen"`
 	}
 
 	// The result is a JSON string, need to unquote it first
@@ -151,12 +196,56 @@ func (w *WindowDetector) detectGNOME() (*WindowInfo, error) {
 	}
 
 	return &WindowInfo{
-		Title:    data.Title,
-		Class:    data.WMClass,
-		Instance: strings.ToLower(data.WMClass),
+		Title:      data.Title,
+		Class:      data.WMClass,
+		Instance:   strings.ToLower(data.WMClass),
+		PID:        data.PID,
+		Fullscreen: data.Fullscreen,
 	}, nil
 }
 
+// detectGNOMEExtension gets active window info from the Window Calls
+// extension, which keeps working where Eval is restricted (GNOME 41+).
+func (w *WindowDetector) detectGNOMEExtension() (*WindowInfo, error) {
+	obj := w.conn.Object("org.gnome.Shell", "/org/gnome/Shell/Extensions/Windows")
+
+	var result string
+	if err := obj.Call("org.gnome.Shell.Extensions.Windows.List", 0).Store(&result); err != nil {
+		return nil, fmt.Errorf("window calls list: %w", err)
+	}
+
+	var windows []struct {
+		ID      uint32 `json:"id"`
+		WMClass string `json:"wm_class"`
+		PID     int    `json:"pid"`
+		Focus   bool   `json:"focus"`
+		Title   string `json:"title"`
+	}
+	if err := json.Unmarshal([]byte(result), &windows); err != nil {
+		return nil, fmt.Errorf("parse window calls list: %w", err)
+	}
+
+	for _, win := range windows {
+		if !win.Focus {
+			continue
+		}
+		title := win.Title
+		if title == "" {
+			// Newer versions leave titles out of List.
+			if err := obj.Call("org.gnome.Shell.Extensions.Windows.GetTitle", 0, win.ID).Store(&title); err != nil {
+				return nil, fmt.Errorf("window calls title: %w", err)
+			}
+		}
+		return &WindowInfo{
+			Title:    title,
+			Class:    win.WMClass,
+			Instance: strings.ToLower(win.WMClass),
+			PID:      win.PID,
+		}, nil
+	}
+	return nil, nil
+}
+
 // detectKDE gets active window info using KWin's DBus interface
 func (w *WindowDetector) detectKDE() (*WindowInfo, error) {
 	obj := w.conn.Object("org.kde.KWin", "/KWin")
@@ -168,7 +257,9 @@ func (w *WindowDetector) detectKDE() (*WindowInfo, error) {
 			if (!client) return JSON.stringify({});
 			return JSON.stringify({
 				title: client.caption || '',
-				wmClass: client.resourceClass || ''
+				wmClass: client.resourceClass || '',
+				pid: client.pid || 0,
+				fullscreen: client.fullScreen || false
 			});
 		})()
 	`
@@ -222,8 +313,10 @@ func (w *WindowDetector) detectKDEViaScript(script string) (*WindowInfo, error)
 	obj.Call("org.kde.kwin.Scripting.unloadScript", 0, "screentime")
 
 	var data struct {
-		Title   string `json:"title"`
-		WMClass string `json:"wmClass"`
+		Title      string `json:"title"`
+		WMClass    string `json:"wmClass"`
+		PID        int    `json:"pid"`
+		Fullscreen bool   `json:"fullscreen"`
 	}
 	if err := json.Unmarshal([]byte(result), &data); err != nil {
 		return nil, fmt.Errorf("parse kde result: %w", err)
@@ -234,9 +327,11 @@ func (w *WindowDetector) detectKDEViaScript(script string) (*WindowInfo, error)
 	}
 
 	return &WindowInfo{
-		Title:    data.Title,
-		Class:    data.WMClass,
-		Instance: strings.ToLower(data.WMClass),
+		Title:      data.Title,
+		Class:      data.WMClass,
+		Instance:   strings.ToLower(data.WMClass),
+		PID:        data.PID,
+		Fullscreen: data.Fullscreen,
 	}, nil
 }
 
@@ -310,6 +405,12 @@ func (w *WindowDetector) detectKDEViaSupportInfo() (*WindowInfo, error) {
 
 // Close closes the DBus connection
 func (w *WindowDetector) Close() {
+	w.unhook()
+	w.mu.Lock()
+	if w.ipcEvents != nil {
+		w.ipcEvents.Close()
+	}
+	w.mu.Unlock()
 	if w.conn != nil {
 		w.conn.Close()
 	}
@@ -321,7 +422,7 @@ func (info *WindowInfo) IsBrowser() bool {
 		return false
 	}
 	lower := strings.ToLower(info.Instance)
-	browsers := []string{"firefox", "chromium", "chrome", "brave", "brave-browser", "vivaldi", "opera"}
+	browsers := []string{"firefox", "chromium", "chrome", "brave", "brave-browser", "vivaldi", "opera", "msedge", "edgemac", "safari"}
 	for _, b := range browsers {
 		if lower == b || strings.Contains(lower, b) {
 			return true
@@ -330,6 +431,15 @@ func (info *WindowInfo) IsBrowser() bool {
 	return false
 }
 
+// IsChromium returns true for browsers that keep a Chromium session file
+func (info *WindowInfo) IsChromium() bool {
+	if !info.IsBrowser() {
+		return false
+	}
+	lower := strings.ToLower(info.Instance)
+	return !strings.Contains(lower, "firefox") && !strings.Contains(lower, "safari")
+}
+
 // IsIdle returns true if the window indicates an idle state
 func (info *WindowInfo) IsIdle(patterns []string) bool {
 	if info == nil {
@@ -345,6 +455,13 @@ func (info *WindowInfo) IsIdle(patterns []string) bool {
 	return false
 }
 
+// IsLockScreen returns true if an idle window is a lock screen rather than
+// a screensaver, going by its title. It is the fallback for when the
+// platform can't say whether the screen is locked.
+func (info *WindowInfo) IsLockScreen() bool {
+	return info != nil && strings.Contains(strings.ToLower(info.Title), "lock")
+}
+
 // IsIgnored returns true if the window should be ignored
 func (info *WindowInfo) IsIgnored(ignoredWindows []string) bool {
 	if info == nil {
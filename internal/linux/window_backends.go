@@ -0,0 +1,90 @@
+package linux
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// backendRetryInterval is how long a failed window backend is passed over
+// before it is tried again, so a preferred backend that comes back (an
+// extension re-enabled, a restarted shell) is picked up again.
+const backendRetryInterval = 30 * time.Second
+
+// windowBackend is one way of asking for the focused window.
+type windowBackend struct {
+	name  string
+	query func() (*WindowInfo, error)
+
+	failedAt time.Time // zero while healthy
+}
+
+// windowBackends returns the ways to query the compositor, best first. The
+// X11 and process fallbacks work whatever the compositor, with less detail.
+func (w *WindowDetector) windowBackends() []*windowBackend {
+	var backends []*windowBackend
+	switch w.compositor {
+	case CompositorGNOME:
+		backends = append(backends,
+			&windowBackend{name: "gnome-extension", query: w.detectGNOMEExtension},
+			&windowBackend{name: "gnome-eval", query: w.detectGNOME},
+		)
+	case CompositorKDE:
+		backends = append(backends, &windowBackend{name: "kwin", query: w.detectKDE})
+	case CompositorSway:
+		backends = append(backends, &windowBackend{name: "sway", query: w.detectSway})
+	case CompositorHyprland:
+		backends = append(backends, &windowBackend{name: "hyprland", query: w.detectHyprland})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		backends = append(backends, &windowBackend{name: "x11", query: detectX11})
+	}
+	backends = append(backends, &windowBackend{name: "process", query: w.processes.Detect})
+	return backends
+}
+
+// query asks the backends in order for the active window. A backend that
+// fails is skipped for backendRetryInterval, so one broken mid-session
+// costs a single failed call before the next one takes over. When every
+// backend is failing they are all tried again.
+func (w *WindowDetector) query() (*WindowInfo, error) {
+	w.backendMu.Lock()
+	defer w.backendMu.Unlock()
+
+	now := time.Now()
+	candidates := make([]int, 0, len(w.backends))
+	for i, b := range w.backends {
+		if b.failedAt.IsZero() || now.Sub(b.failedAt) >= backendRetryInterval {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		for i := range w.backends {
+			candidates = append(candidates, i)
+		}
+	}
+
+	var errs []error
+	for _, i := range candidates {
+		b := w.backends[i]
+		info, err := b.query()
+		if err != nil {
+			if b.failedAt.IsZero() {
+				log.Printf("window: %s backend failed: %v", b.name, err)
+			}
+			b.failedAt = now
+			errs = append(errs, fmt.Errorf("%s: %w", b.name, err))
+			continue
+		}
+
+		b.failedAt = time.Time{}
+		if i != w.active {
+			log.Printf("window: switching from the %s to the %s backend", w.backends[w.active].name, b.name)
+			w.active = i
+		}
+		return info, nil
+	}
+	return nil, errors.Join(errs...)
+}
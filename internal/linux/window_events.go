@@ -0,0 +1,350 @@
+package linux
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// The agent owns this name on the session bus so compositor hooks can push
+// focus changes to it.
+const (
+	agentBusName   = "org.screentime.Agent"
+	agentPath      = "/org/screentime/Agent"
+	agentInterface = "org.screentime.Agent1"
+)
+
+// kwinFocusPlugin is the plugin name the KWin focus script is loaded under.
+const kwinFocusPlugin = "screentime-focus"
+
+// focusPayload is what the compositor hooks send: the same fields the
+// polling scripts return.
+type focusPayload struct {
+	Title      string `json:"title"`
+	WMClass    string `json:"wmClass"`
+	PID        int    `json:"pid"`
+	Fullscreen bool   `json:"fullscreen"`
+}
+
+// gnomeFocusScript hooks focus and title changes inside GNOME Shell. The
+// handler survives agent restarts, so it is only installed once per shell.
+const gnomeFocusScript = `
+	(function() {
+		if (global._screentimeFocus) return 'installed';
+		const Gio = imports.gi.Gio;
+		const GLib = imports.gi.GLib;
+		let tracked = null;
+		let titleId = 0;
+		const report = () => {
+			const win = global.display.focus_window;
+			const data = win ? {
+				title: win.get_title() || '',
+				wmClass: win.get_wm_class() || '',
+				pid: win.get_pid() || 0,
+				fullscreen: win.is_fullscreen()
+			} : {};
+			Gio.DBus.session.call('` + agentBusName + `', '` + agentPath + `',
+				'` + agentInterface + `', 'FocusChanged',
+				new GLib.Variant('(s)', [JSON.stringify(data)]), null,
+				Gio.DBusCallFlags.NO_AUTO_START, -1, null, null);
+		};
+		const track = () => {
+			try {
+				if (tracked && titleId) tracked.disconnect(titleId);
+			} catch (e) {}
+			tracked = global.display.focus_window;
+			titleId = tracked ? tracked.connect('notify::title', report) : 0;
+			report();
+		};
+		global._screentimeFocus = global.display.connect('notify::focus-window', track);
+		track();
+		return 'installed';
+	})()
+`
+
+// kwinFocusScript does the same for KWin 5 and 6.
+const kwinFocusScript = `
+function report() {
+	var w = workspace.activeWindow || workspace.activeClient;
+	var data = w ? {
+		title: w.caption || '',
+		wmClass: String(w.resourceClass || ''),
+		pid: w.pid || 0,
+		fullscreen: !!w.fullScreen
+	} : {};
+	callDBus('` + agentBusName + `', '` + agentPath + `', '` + agentInterface + `',
+		'FocusChanged', JSON.stringify(data));
+}
+var tracked = null;
+function track() {
+	if (tracked) tracked.captionChanged.disconnect(report);
+	tracked = workspace.activeWindow || workspace.activeClient;
+	if (tracked) tracked.captionChanged.connect(report);
+	report();
+}
+(workspace.windowActivated || workspace.clientActivated).connect(track);
+track();
+`
+
+// focusReceiver is exported on the session bus for the compositor hooks.
+type focusReceiver struct {
+	w *WindowDetector
+}
+
+// FocusChanged is called by the compositor hook with a JSON focusPayload.
+func (r focusReceiver) FocusChanged(payload string) *dbus.Error {
+	var data focusPayload
+	if err := json.Unmarshal([]byte(payload), &data); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("parse focus payload: %w", err))
+	}
+
+	var info *WindowInfo
+	if data.Title != "" || data.WMClass != "" {
+		info = &WindowInfo{
+			Title:      data.Title,
+			Class:      data.WMClass,
+			Instance:   strings.ToLower(data.WMClass),
+			PID:        data.PID,
+			Fullscreen: data.Fullscreen,
+		}
+	}
+	r.w.setPushed(info)
+	return nil
+}
+
+// Subscribe switches the detector from querying the compositor on every
+// request to caching the window the compositor pushes on each focus or
+// title change. On error the detector keeps polling.
+func (w *WindowDetector) Subscribe() error {
+	if w.compositor == CompositorX11 {
+		return fmt.Errorf("no focus hook for X11 sessions")
+	}
+	reply, err := w.conn.RequestName(agentBusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("request bus name: %w", err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("bus name %s is already taken", agentBusName)
+	}
+	if err := w.conn.Export(focusReceiver{w}, agentPath, agentInterface); err != nil {
+		return fmt.Errorf("export focus receiver: %w", err)
+	}
+
+	if err := w.hook(); err != nil {
+		return err
+	}
+	if w.compositor == CompositorSway || w.compositor == CompositorHyprland {
+		// Their own event streams report when they go away.
+		return nil
+	}
+	if err := w.watchCompositor(); err != nil {
+		log.Printf("window: compositor restarts won't be noticed: %v", err)
+	}
+	return nil
+}
+
+// hook seeds the cache and installs the compositor's focus hook.
+func (w *WindowDetector) hook() error {
+	// Seed the cache before the first push arrives.
+	info, err := w.query()
+	if err != nil {
+		return fmt.Errorf("query initial window: %w", err)
+	}
+	w.setPushed(info)
+
+	switch w.compositor {
+	case CompositorGNOME:
+		err = w.hookGNOME()
+	case CompositorKDE:
+		err = w.hookKDE()
+	case CompositorSway:
+		err = w.hookSway()
+	case CompositorHyprland:
+		err = w.hookHyprland()
+	default:
+		err = fmt.Errorf("unsupported compositor")
+	}
+	if err != nil {
+		w.setPolling()
+		return err
+	}
+	return nil
+}
+
+// setPolling drops the pushed window so Detect queries the backends again.
+func (w *WindowDetector) setPolling() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pushed = false
+	w.current = nil
+}
+
+// compositorBusName is the name the compositor owns on the session bus.
+func (w *WindowDetector) compositorBusName() string {
+	if w.compositor == CompositorKDE {
+		return "org.kde.KWin"
+	}
+	return "org.gnome.Shell"
+}
+
+// watchCompositor follows the compositor's bus name. A restarted shell
+// drops the focus hook, so the detector goes back to querying, which fails
+// over to another backend while the shell is away, and hooks the new
+// shell once it is up.
+func (w *WindowDetector) watchCompositor() error {
+	name := w.compositorBusName()
+	if err := w.conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, name),
+	); err != nil {
+		return fmt.Errorf("watch %s: %w", name, err)
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	w.conn.Signal(signals)
+	go func() {
+		for sig := range signals {
+			if sig.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(sig.Body) < 3 {
+				continue
+			}
+			if n, _ := sig.Body[0].(string); n != name {
+				continue
+			}
+			w.setPolling()
+			if owner, _ := sig.Body[2].(string); owner == "" {
+				log.Printf("window: %s left the bus, querying other backends", name)
+				continue
+			}
+			go w.rehook(name)
+		}
+	}()
+	return nil
+}
+
+// rehook installs the focus hook in a restarted compositor, giving it a
+// few seconds to finish starting.
+func (w *WindowDetector) rehook(name string) {
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		time.Sleep(2 * time.Second)
+		if err = w.hook(); err == nil {
+			log.Printf("window: %s restarted, following focus changes again", name)
+			return
+		}
+	}
+	log.Printf("window: %s restarted, querying on each request: %v", name, err)
+}
+
+// OnChange registers fn to be called after every pushed window change.
+func (w *WindowDetector) OnChange(fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = fn
+}
+
+func (w *WindowDetector) setPushed(info *WindowInfo) {
+	w.mu.Lock()
+	w.pushed = true
+	w.current = info
+	fn := w.onChange
+	w.mu.Unlock()
+
+	if fn != nil {
+		fn()
+	}
+}
+
+// cached returns the last pushed window and whether pushes are active.
+func (w *WindowDetector) cached() (*WindowInfo, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.pushed {
+		return nil, false
+	}
+	if w.current == nil {
+		return nil, true
+	}
+	info := *w.current
+	return &info, true
+}
+
+func (w *WindowDetector) hookGNOME() error {
+	obj := w.conn.Object("org.gnome.Shell", "/org/gnome/Shell")
+
+	var success bool
+	var result string
+	if err := obj.Call("org.gnome.Shell.Eval", 0, gnomeFocusScript).Store(&success, &result); err != nil {
+		return fmt.Errorf("gnome shell eval: %w", err)
+	}
+	if !success {
+		return fmt.Errorf("gnome shell eval failed: %s", result)
+	}
+	return nil
+}
+
+func (w *WindowDetector) hookKDE() error {
+	// A fresh file only this user can read, so nobody else can plant a
+	// symlink or a script of their own for KWin to load.
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	f, err := os.CreateTemp(dir, kwinFocusPlugin+"-*.js")
+	if err != nil {
+		return fmt.Errorf("write kwin script: %w", err)
+	}
+	path := f.Name()
+	_, err = f.WriteString(kwinFocusScript)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("write kwin script: %w", err)
+	}
+	w.removeKWinScript()
+	w.kwinScript = path
+
+	obj := w.conn.Object("org.kde.KWin", "/Scripting")
+	// A copy left behind by a previous run would keep the new one from loading.
+	obj.Call("org.kde.kwin.Scripting.unloadScript", 0, kwinFocusPlugin)
+
+	var id int32
+	if err := obj.Call("org.kde.kwin.Scripting.loadScript", 0, path, kwinFocusPlugin).Store(&id); err != nil {
+		return fmt.Errorf("load kwin script: %w", err)
+	}
+	if id < 0 {
+		return fmt.Errorf("load kwin script: kwin refused it")
+	}
+	if call := obj.Call("org.kde.kwin.Scripting.start", 0); call.Err != nil {
+		return fmt.Errorf("start kwin script: %w", call.Err)
+	}
+	log.Printf("window: following focus changes via KWin script %d", id)
+	return nil
+}
+
+// unhook removes what Subscribe installed, where that is possible.
+func (w *WindowDetector) unhook() {
+	defer w.removeKWinScript()
+	w.mu.Lock()
+	pushed := w.pushed
+	w.mu.Unlock()
+	if !pushed || w.compositor != CompositorKDE {
+		return
+	}
+	w.conn.Object("org.kde.KWin", "/Scripting").Call("org.kde.kwin.Scripting.unloadScript", 0, kwinFocusPlugin)
+}
+
+// removeKWinScript deletes the focus script file written by hookKDE.
+func (w *WindowDetector) removeKWinScript() {
+	if w.kwinScript != "" {
+		os.Remove(w.kwinScript)
+		w.kwinScript = ""
+	}
+}
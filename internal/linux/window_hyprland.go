@@ -0,0 +1,122 @@
+package linux
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hyprlandTimeout bounds one request over the Hyprland IPC socket.
+const hyprlandTimeout = 2 * time.Second
+
+// hyprlandWindow is the part of hyprctl's activewindow reply the detector
+// reads.
+type hyprlandWindow struct {
+	Address string `json:"address"`
+	Class   string `json:"class"`
+	Title   string `json:"title"`
+	PID     int    `json:"pid"`
+	// Fullscreen is a bool before Hyprland 0.42 and a mode number after.
+	Fullscreen json.RawMessage `json:"fullscreen"`
+}
+
+// hyprlandDir returns the directory holding the running Hyprland's IPC
+// sockets, or "" outside Hyprland.
+func hyprlandDir() string {
+	sig := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if sig == "" {
+		return ""
+	}
+	// Hyprland 0.40 moved the sockets from /tmp into the runtime dir.
+	if runtime := os.Getenv("XDG_RUNTIME_DIR"); runtime != "" {
+		dir := filepath.Join(runtime, "hypr", sig)
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return filepath.Join("/tmp", "hypr", sig)
+}
+
+// detectHyprland gets the active window the way `hyprctl -j activewindow`
+// does.
+func (w *WindowDetector) detectHyprland() (*WindowInfo, error) {
+	conn, err := net.DialTimeout("unix", filepath.Join(hyprlandDir(), ".socket.sock"), hyprlandTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect to hyprland: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(hyprlandTimeout))
+
+	if _, err := conn.Write([]byte("j/activewindow")); err != nil {
+		return nil, fmt.Errorf("hyprland activewindow: %w", err)
+	}
+	payload, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("hyprland activewindow: %w", err)
+	}
+
+	var win hyprlandWindow
+	if err := json.Unmarshal(payload, &win); err != nil {
+		return nil, fmt.Errorf("parse hyprland activewindow: %w", err)
+	}
+	// An empty object means no window has focus.
+	if win.Address == "" {
+		return nil, nil
+	}
+
+	fullscreen := strings.TrimSpace(string(win.Fullscreen))
+	return &WindowInfo{
+		Title:      win.Title,
+		Class:      win.Class,
+		Instance:   strings.ToLower(win.Class),
+		PID:        win.PID,
+		Fullscreen: fullscreen != "" && fullscreen != "false" && fullscreen != "0",
+	}, nil
+}
+
+// hookHyprland follows Hyprland's event socket and re-reads the active
+// window whenever it reports a focus or title change. If Hyprland goes
+// away the detector goes back to querying.
+func (w *WindowDetector) hookHyprland() error {
+	conn, err := net.DialTimeout("unix", filepath.Join(hyprlandDir(), ".socket2.sock"), hyprlandTimeout)
+	if err != nil {
+		return fmt.Errorf("connect to hyprland events: %w", err)
+	}
+
+	w.mu.Lock()
+	w.ipcEvents = conn
+	w.mu.Unlock()
+
+	go func() {
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			event, _, _ := strings.Cut(scanner.Text(), ">>")
+			switch event {
+			case "activewindow", "windowtitle", "fullscreen", "closewindow":
+			default:
+				continue
+			}
+			info, err := w.detectHyprland()
+			if err != nil {
+				log.Printf("window: hyprland event: %v", err)
+				continue
+			}
+			w.setPushed(info)
+		}
+		err := scanner.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		log.Printf("window: hyprland events stopped, querying on each request: %v", err)
+		w.setPolling()
+	}()
+	return nil
+}
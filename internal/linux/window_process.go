@@ -0,0 +1,142 @@
+package linux
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// desktopProcesses are graphical session processes that are never what the
+// user is looking at, matched as comm prefixes.
+var desktopProcesses = []string{
+	"gnome-shell", "gnome-session", "gsd-", "kwin", "plasmashell", "ksmserver", "kded",
+	"xwayland", "xorg", "pipewire", "wireplumber", "pulseaudio", "dbus-",
+	"ibus", "fcitx", "xdg-", "at-spi", "gvfs", "evolution-", "tracker-",
+}
+
+// ProcessHeuristic guesses the active application when no compositor
+// backend answers: the graphical process of this user that used the most
+// CPU since the last call. It knows no titles and can't tell focus from
+// background work, so it is the last resort.
+type ProcessHeuristic struct {
+	mu    sync.Mutex
+	ticks map[int]uint64 // CPU ticks per PID at the last call
+}
+
+// NewProcessHeuristic creates a process heuristic.
+func NewProcessHeuristic() *ProcessHeuristic {
+	return &ProcessHeuristic{ticks: make(map[int]uint64)}
+}
+
+// Detect returns the busiest graphical process as a window, or nil when
+// none used any CPU.
+func (h *ProcessHeuristic) Detect() (*WindowInfo, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	uid := os.Getuid()
+	self := os.Getpid()
+	ticks := make(map[int]uint64)
+	var best *WindowInfo
+	var bestDelta uint64
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		procDir := filepath.Join("/proc", e.Name())
+		if !ownedBy(procDir, uid) {
+			continue
+		}
+		comm, total, ok := procCPU(procDir)
+		if !ok || isDesktopProcess(comm) || !graphical(procDir) {
+			continue
+		}
+		ticks[pid] = total
+
+		// On the first sight of a process its whole runtime counts.
+		delta := total
+		if prev, seen := h.ticks[pid]; seen {
+			delta = 0
+			if total > prev {
+				delta = total - prev
+			}
+		}
+		if delta > bestDelta {
+			bestDelta = delta
+			best = &WindowInfo{Class: comm, Instance: strings.ToLower(comm), PID: pid}
+		}
+	}
+	h.ticks = ticks
+	return best, nil
+}
+
+func ownedBy(procDir string, uid int) bool {
+	status, err := os.ReadFile(filepath.Join(procDir, "status"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if v, ok := strings.CutPrefix(line, "Uid:"); ok {
+			fields := strings.Fields(v)
+			return len(fields) > 0 && fields[0] == strconv.Itoa(uid)
+		}
+	}
+	return false
+}
+
+// procCPU returns a process's name and its user plus system CPU ticks.
+func procCPU(procDir string) (string, uint64, bool) {
+	stat, err := os.ReadFile(filepath.Join(procDir, "stat"))
+	if err != nil {
+		return "", 0, false
+	}
+	// The name is parenthesized and may itself contain spaces or parens.
+	open, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return "", 0, false
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 13 {
+		return "", 0, false
+	}
+	utime, err1 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err2 := strconv.ParseUint(fields[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return "", 0, false
+	}
+	return string(stat[open+1 : end]), utime + stime, true
+}
+
+// graphical reports whether a process was started in the graphical
+// session, which is as close as /proc gets to owning a window.
+func graphical(procDir string) bool {
+	environ, err := os.ReadFile(filepath.Join(procDir, "environ"))
+	if err != nil {
+		return false
+	}
+	for _, v := range bytes.Split(environ, []byte{0}) {
+		if bytes.HasPrefix(v, []byte("WAYLAND_DISPLAY=")) || bytes.HasPrefix(v, []byte("DISPLAY=")) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDesktopProcess(comm string) bool {
+	lower := strings.ToLower(comm)
+	for _, p := range desktopProcesses {
+		if strings.HasPrefix(lower, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package linux
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// swayTimeout bounds one request over the sway IPC socket.
+const swayTimeout = 2 * time.Second
+
+// sway IPC message types, from sway-ipc(7).
+const (
+	swayGetTree     = 4
+	swaySubscribe   = 2
+	swayWindowEvent = 0x80000003
+)
+
+var swayMagic = []byte("i3-ipc")
+
+// swayNode is the part of a sway tree node the detector reads.
+type swayNode struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Focused bool   `json:"focused"`
+	AppID   string `json:"app_id"`
+	PID     int    `json:"pid"`
+	// FullscreenMode is 1 for fullscreen on the output, 2 globally.
+	FullscreenMode int `json:"fullscreen_mode"`
+	// WindowProperties is only set for XWayland windows.
+	WindowProperties *struct {
+		Class    string `json:"class"`
+		Instance string `json:"instance"`
+	} `json:"window_properties"`
+	Nodes         []swayNode `json:"nodes"`
+	FloatingNodes []swayNode `json:"floating_nodes"`
+}
+
+// focused returns the focused node in the tree under n, or nil.
+func (n *swayNode) focused() *swayNode {
+	if n.Focused {
+		return n
+	}
+	for _, children := range [][]swayNode{n.Nodes, n.FloatingNodes} {
+		for i := range children {
+			if f := children[i].focused(); f != nil {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// swaySocket returns the IPC socket of the running sway, or "" outside sway.
+func swaySocket() string {
+	return os.Getenv("SWAYSOCK")
+}
+
+// detectSway gets the focused window from sway's layout tree. Any
+// compositor speaking the sway IPC protocol on SWAYSOCK works.
+func (w *WindowDetector) detectSway() (*WindowInfo, error) {
+	conn, err := net.DialTimeout("unix", swaySocket(), swayTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect to sway: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(swayTimeout))
+
+	if err := writeSwayMessage(conn, swayGetTree, nil); err != nil {
+		return nil, fmt.Errorf("sway get_tree: %w", err)
+	}
+	_, payload, err := readSwayMessage(conn)
+	if err != nil {
+		return nil, fmt.Errorf("sway get_tree: %w", err)
+	}
+
+	var tree swayNode
+	if err := json.Unmarshal(payload, &tree); err != nil {
+		return nil, fmt.Errorf("parse sway tree: %w", err)
+	}
+
+	// A focused workspace or output means no window has focus.
+	node := tree.focused()
+	if node == nil || (node.Type != "con" && node.Type != "floating_con") {
+		return nil, nil
+	}
+
+	info := &WindowInfo{
+		Title:      node.Name,
+		Class:      node.AppID,
+		PID:        node.PID,
+		Fullscreen: node.FullscreenMode != 0,
+	}
+	if node.WindowProperties != nil && info.Class == "" {
+		info.Class = node.WindowProperties.Class
+	}
+	info.Instance = strings.ToLower(info.Class)
+	return info, nil
+}
+
+// hookSway subscribes to sway's window events and re-reads the focused
+// window on each. If sway goes away the detector goes back to querying.
+func (w *WindowDetector) hookSway() error {
+	conn, err := net.DialTimeout("unix", swaySocket(), swayTimeout)
+	if err != nil {
+		return fmt.Errorf("connect to sway: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(swayTimeout))
+	if err := writeSwayMessage(conn, swaySubscribe, []byte(`["window"]`)); err != nil {
+		conn.Close()
+		return fmt.Errorf("sway subscribe: %w", err)
+	}
+	_, payload, err := readSwayMessage(conn)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("sway subscribe: %w", err)
+	}
+	var reply struct {
+		Success bool `json:"success"`
+	}
+	if err := json.Unmarshal(payload, &reply); err != nil || !reply.Success {
+		conn.Close()
+		return fmt.Errorf("sway subscribe refused: %s", payload)
+	}
+	conn.SetDeadline(time.Time{})
+
+	w.mu.Lock()
+	w.ipcEvents = conn
+	w.mu.Unlock()
+
+	go func() {
+		defer conn.Close()
+		for {
+			typ, _, err := readSwayMessage(conn)
+			if err != nil {
+				log.Printf("window: sway events stopped, querying on each request: %v", err)
+				w.setPolling()
+				return
+			}
+			if typ != swayWindowEvent {
+				continue
+			}
+			info, err := w.detectSway()
+			if err != nil {
+				log.Printf("window: sway event: %v", err)
+				continue
+			}
+			w.setPushed(info)
+		}
+	}()
+	return nil
+}
+
+func writeSwayMessage(conn net.Conn, typ uint32, payload []byte) error {
+	msg := append([]byte(nil), swayMagic...)
+	msg = binary.NativeEndian.AppendUint32(msg, uint32(len(payload)))
+	msg = binary.NativeEndian.AppendUint32(msg, typ)
+	_, err := conn.Write(append(msg, payload...))
+	return err
+}
+
+func readSwayMessage(conn net.Conn) (uint32, []byte, error) {
+	header := make([]byte, len(swayMagic)+8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(swayMagic)]) != string(swayMagic) {
+		return 0, nil, fmt.Errorf("bad sway ipc magic %q", header[:len(swayMagic)])
+	}
+	n := binary.NativeEndian.Uint32(header[len(swayMagic):])
+	typ := binary.NativeEndian.Uint32(header[len(swayMagic)+4:])
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return 0, nil, err
+	}
+	return typ, payload, nil
+}
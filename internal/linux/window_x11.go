@@ -0,0 +1,111 @@
+package linux
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xpropTimeout bounds one xprop call.
+const xpropTimeout = 2 * time.Second
+
+// detectX11 gets the active window from the EWMH _NET_ACTIVE_WINDOW
+// property via xprop. Under Wayland only XWayland windows are visible to
+// it, so there a focused native window is an error rather than no window.
+func detectX11() (*WindowInfo, error) {
+	out, err := xprop("-root", "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		return nil, err
+	}
+	_, id, ok := strings.Cut(out, "window id # ")
+	id = strings.TrimSpace(id)
+	if !ok || id == "" {
+		return nil, fmt.Errorf("unexpected xprop output %q", strings.TrimSpace(out))
+	}
+	if n, err := strconv.ParseUint(strings.TrimPrefix(id, "0x"), 16, 32); err != nil || n == 0 {
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			return nil, fmt.Errorf("no X11 window focused")
+		}
+		return nil, nil
+	}
+
+	out, err = xprop("-id", id, "_NET_WM_NAME", "WM_NAME", "WM_CLASS", "_NET_WM_PID", "_NET_WM_STATE")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &WindowInfo{}
+	var legacyName string
+	for _, line := range strings.Split(out, "\n") {
+		prop, value, ok := strings.Cut(line, " = ")
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(prop, "(")
+		switch name {
+		case "_NET_WM_NAME":
+			info.Title = xpropStrings(value)[0]
+		case "WM_NAME":
+			legacyName = xpropStrings(value)[0]
+		case "WM_CLASS":
+			// Instance then class; the class is what the other backends
+			// report.
+			classes := xpropStrings(value)
+			info.Class = classes[len(classes)-1]
+			info.Instance = strings.ToLower(info.Class)
+		case "_NET_WM_PID":
+			info.PID, _ = strconv.Atoi(strings.TrimSpace(value))
+		case "_NET_WM_STATE":
+			info.Fullscreen = strings.Contains(value, "_NET_WM_STATE_FULLSCREEN")
+		}
+	}
+	if info.Title == "" {
+		info.Title = legacyName
+	}
+	if info.Title == "" && info.Class == "" {
+		return nil, nil
+	}
+	return info, nil
+}
+
+func xprop(args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), xpropTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "xprop", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("xprop: %w", err)
+	}
+	return string(out), nil
+}
+
+// xpropStrings parses a list of quoted xprop values such as
+// "a \"b\", c", "d", always returning at least one element. Commas and
+// escaped quotes inside a value stay part of it.
+func xpropStrings(value string) []string {
+	var out []string
+	for i := 0; i < len(value); i++ {
+		if value[i] != '"' {
+			continue
+		}
+		j := i + 1
+		for ; j < len(value) && value[j] != '"'; j++ {
+			if value[j] == '\\' {
+				j++
+			}
+		}
+		part := value[i+1 : min(j, len(value))]
+		if s, err := strconv.Unquote(`"` + part + `"`); err == nil {
+			part = s
+		}
+		out = append(out, part)
+		i = j
+	}
+	if len(out) == 0 {
+		out = append(out, "")
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package linux
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WineResolver maps windows owned by Wine/Proton processes back to the
+// Windows executable they run, so "steam_app_0" or "explorer.exe" windows
+// get a real game name
+type WineResolver struct {
+	names map[string]string // lowercase exe name -> friendly name
+}
+
+// DefaultWineNamesPath returns the default lookup file for Wine executables
+func DefaultWineNamesPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "screentime-agent", "wine-games.json"), nil
+}
+
+// NewWineResolver loads the exe -> name lookup file, falling back to
+// DefaultWineNamesPath when path is empty. A missing file is not an error;
+// executables are then reported by name without ".exe".
+func NewWineResolver(path string) (*WineResolver, error) {
+	r := &WineResolver{names: make(map[string]string)}
+	if path == "" {
+		p, err := DefaultWineNamesPath()
+		if err != nil {
+			return r, nil
+		}
+		path = p
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("read wine names: %w", err)
+	}
+
+	var names map[string]string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("parse wine names: %w", err)
+	}
+	for exe, name := range names {
+		r.names[strings.ToLower(exe)] = name
+	}
+	return r, nil
+}
+
+// Resolve returns the Windows executable behind the window and a friendly
+// name for it. ok is false when the window isn't a Wine window.
+func (r *WineResolver) Resolve(info *WindowInfo) (exe, name string, ok bool) {
+	if info == nil || info.PID <= 0 {
+		return "", "", false
+	}
+
+	exe = wineExecutable(info.PID)
+	if exe == "" {
+		return "", "", false
+	}
+
+	if n, found := r.names[exe]; found {
+		return exe, n, true
+	}
+	return exe, strings.TrimSuffix(exe, ".exe"), true
+}
+
+// wineExecutable reads the Windows executable name from a Wine process.
+// Wine rewrites argv[0] to the Windows path (e.g. "Z:\games\foo\Foo.exe")
+// while /proc/<pid>/exe still points at the wine preloader.
+func wineExecutable(pid int) string {
+	procDir := filepath.Join("/proc", strconv.Itoa(pid))
+
+	target, _ := os.Readlink(filepath.Join(procDir, "exe"))
+	isWine := strings.Contains(filepath.Base(target), "wine")
+
+	cmdline, err := os.ReadFile(filepath.Join(procDir, "cmdline"))
+	if err != nil {
+		return ""
+	}
+	argv0, _, _ := strings.Cut(string(cmdline), "\x00")
+	if i := strings.LastIndexAny(argv0, `/\`); i >= 0 {
+		argv0 = argv0[i+1:]
+	}
+	argv0 = strings.ToLower(argv0)
+
+	if !strings.HasSuffix(argv0, ".exe") {
+		return ""
+	}
+	// Some launchers are plain Linux binaries named *.exe; only trust the
+	// name when the process is really Wine or the argv looks like a DOS path.
+	if !isWine && !strings.Contains(string(cmdline), `:\`) {
+		return ""
+	}
+	return argv0
+}
@@ -0,0 +1,245 @@
+// Package locale formats durations, dates and times for people to read,
+// in the language and clock style the household chose.
+package locale
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Clock styles.
+const (
+	Clock12 = "12h"
+	Clock24 = "24h"
+)
+
+// language holds what differs between the supported languages.
+type language struct {
+	hoursMinutes string // format for a duration of hours and minutes
+	minutes      string // format for a duration under an hour
+	weekdays     [7]string
+	months       [12]string
+	// day formats a weekday abbreviation, day of month and month
+	// abbreviation; date leaves out the weekday.
+	day    func(weekday string, d int, month string) string
+	date   func(d int, month string) string
+	labels Labels
+}
+
+// Labels are the words around the values on pages and in reports. The
+// ones with verbs take the formatted values, so each language can put
+// them where it needs to.
+type Labels struct {
+	ScreenTimeFor  string // "Screen time for %s", with a name
+	Total          string // "Total: %s"
+	VsPreviousWeek string // "%s vs previous week", with a change
+	ByDay          string
+	Categories     string
+	TopApps        string
+	Left           string // "%s left"
+	TimesUp        string // "Time's up – %s to wrap up", with the grace left
+	ThisWeek       string // after a weekly budget's category
+	UsedOf         string // "used %s of %s"
+	In             string // "in %s", until something starts
+}
+
+var languages = map[string]*language{
+	"en": {
+		hoursMinutes: "%dh %dm",
+		minutes:      "%dm",
+		weekdays:     [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		months:       [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		day:          func(wd string, d int, m string) string { return fmt.Sprintf("%s %s %d", wd, m, d) },
+		date:         func(d int, m string) string { return fmt.Sprintf("%s %d", m, d) },
+		labels: Labels{
+			ScreenTimeFor:  "Screen time for %s",
+			Total:          "Total: %s",
+			VsPreviousWeek: "%s vs previous week",
+			ByDay:          "By day",
+			Categories:     "Categories",
+			TopApps:        "Top apps",
+			Left:           "%s left",
+			TimesUp:        "Time's up – %s to wrap up",
+			ThisWeek:       "this week",
+			UsedOf:         "used %s of %s",
+			In:             "in %s",
+		},
+	},
+	"de": {
+		hoursMinutes: "%d Std. %d Min.",
+		minutes:      "%d Min.",
+		weekdays:     [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+		months:       [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		day:          func(wd string, d int, m string) string { return fmt.Sprintf("%s %d. %s", wd, d, m) },
+		date:         func(d int, m string) string { return fmt.Sprintf("%d. %s", d, m) },
+		labels: Labels{
+			ScreenTimeFor:  "Bildschirmzeit für %s",
+			Total:          "Gesamt: %s",
+			VsPreviousWeek: "%s ggü. Vorwoche",
+			ByDay:          "Nach Tag",
+			Categories:     "Kategorien",
+			TopApps:        "Meistgenutzte Apps",
+			Left:           "noch %s",
+			TimesUp:        "Zeit ist um – %s zum Abschließen",
+			ThisWeek:       "diese Woche",
+			UsedOf:         "%s von %s genutzt",
+			In:             "in %s",
+		},
+	},
+	"es": {
+		hoursMinutes: "%d h %d min",
+		minutes:      "%d min",
+		weekdays:     [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		months:       [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		day:          func(wd string, d int, m string) string { return fmt.Sprintf("%s, %d %s", wd, d, m) },
+		date:         func(d int, m string) string { return fmt.Sprintf("%d %s", d, m) },
+		labels: Labels{
+			ScreenTimeFor:  "Tiempo de pantalla de %s",
+			Total:          "Total: %s",
+			VsPreviousWeek: "%s respecto a la semana anterior",
+			ByDay:          "Por día",
+			Categories:     "Categorías",
+			TopApps:        "Apps más usadas",
+			Left:           "quedan %s",
+			TimesUp:        "Se acabó el tiempo: %s para terminar",
+			ThisWeek:       "esta semana",
+			UsedOf:         "usado %s de %s",
+			In:             "en %s",
+		},
+	},
+	"fr": {
+		hoursMinutes: "%d h %d min",
+		minutes:      "%d min",
+		weekdays:     [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		months:       [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		day:          func(wd string, d int, m string) string { return fmt.Sprintf("%s %d %s", wd, d, m) },
+		date:         func(d int, m string) string { return fmt.Sprintf("%d %s", d, m) },
+		labels: Labels{
+			ScreenTimeFor:  "Temps d'écran de %s",
+			Total:          "Total : %s",
+			VsPreviousWeek: "%s par rapport à la semaine précédente",
+			ByDay:          "Par jour",
+			Categories:     "Catégories",
+			TopApps:        "Applications les plus utilisées",
+			Left:           "%s restantes",
+			TimesUp:        "Temps écoulé – %s pour terminer",
+			ThisWeek:       "cette semaine",
+			UsedOf:         "%s utilisées sur %s",
+			In:             "dans %s",
+		},
+	},
+	"nl": {
+		hoursMinutes: "%d u %d min",
+		minutes:      "%d min",
+		weekdays:     [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+		months:       [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		day:          func(wd string, d int, m string) string { return fmt.Sprintf("%s %d %s", wd, d, m) },
+		date:         func(d int, m string) string { return fmt.Sprintf("%d %s", d, m) },
+		labels: Labels{
+			ScreenTimeFor:  "Schermtijd van %s",
+			Total:          "Totaal: %s",
+			VsPreviousWeek: "%s t.o.v. vorige week",
+			ByDay:          "Per dag",
+			Categories:     "Categorieën",
+			TopApps:        "Meest gebruikte apps",
+			Left:           "nog %s",
+			TimesUp:        "Tijd is op – %s om af te ronden",
+			ThisWeek:       "deze week",
+			UsedOf:         "%s van %s gebruikt",
+			In:             "over %s",
+		},
+	},
+}
+
+// clock12Regions are the regions whose locales default to a 12-hour clock.
+var clock12Regions = map[string]bool{
+	"US": true, "CA": true, "AU": true, "NZ": true, "IN": true, "PH": true,
+}
+
+// Formatter renders values for one locale and clock style. The zero value
+// formats in English with a 24-hour clock.
+type Formatter struct {
+	lang    *language
+	clock12 bool
+}
+
+// New returns a formatter for a locale such as "en-US", "de" or "fr_FR",
+// and a clock style of Clock12 or Clock24. An empty locale is English; an
+// empty clock is the locale's usual one.
+func New(locale, clock string) (Formatter, error) {
+	if locale == "" {
+		locale = "en"
+	}
+	tag, region, _ := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	lang, ok := languages[strings.ToLower(tag)]
+	if !ok {
+		return Formatter{}, fmt.Errorf("unsupported locale %q", locale)
+	}
+
+	f := Formatter{lang: lang}
+	switch clock {
+	case "":
+		f.clock12 = clock12Regions[strings.ToUpper(region)]
+	case Clock12:
+		f.clock12 = true
+	case Clock24:
+	default:
+		return Formatter{}, fmt.Errorf("clock must be %s or %s", Clock12, Clock24)
+	}
+	return f, nil
+}
+
+func (f Formatter) language() *language {
+	if f.lang == nil {
+		return languages["en"]
+	}
+	return f.lang
+}
+
+// Duration renders seconds as hours and minutes, "1h 42m" or "17m" in
+// English.
+func (f Formatter) Duration(secs int64) string {
+	h := secs / 3600
+	m := (secs % 3600) / 60
+	if h > 0 {
+		return fmt.Sprintf(f.language().hoursMinutes, h, m)
+	}
+	return fmt.Sprintf(f.language().minutes, m)
+}
+
+// Clock renders the time of day of t, "15:04" or "3:04 PM".
+func (f Formatter) Clock(t time.Time) string {
+	if f.clock12 {
+		return t.Format("3:04 PM")
+	}
+	return t.Format("15:04")
+}
+
+// Weekday renders the abbreviated day of the week of t.
+func (f Formatter) Weekday(t time.Time) string {
+	return f.language().weekdays[t.Weekday()]
+}
+
+// WeekdayClock renders the day of the week and time of day of t, for
+// times within the coming week.
+func (f Formatter) WeekdayClock(t time.Time) string {
+	return f.Weekday(t) + " " + f.Clock(t)
+}
+
+// Day renders the weekday and date of t, "Mon Jan 2" in English.
+func (f Formatter) Day(t time.Time) string {
+	l := f.language()
+	return l.day(l.weekdays[t.Weekday()], t.Day(), l.months[t.Month()-1])
+}
+
+// Labels returns the words around the values on pages and in reports.
+func (f Formatter) Labels() Labels {
+	return f.language().labels
+}
+
+// Date renders the date of t without the year, "Jan 2" in English.
+func (f Formatter) Date(t time.Time) string {
+	l := f.language()
+	return l.date(t.Day(), l.months[t.Month()-1])
+}
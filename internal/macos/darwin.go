@@ -0,0 +1,167 @@
+//go:build darwin && cgo
+
+package macos
+
+/*
+#cgo CFLAGS: -x objective-c -fobjc-arc
+#cgo LDFLAGS: -framework AppKit -framework ApplicationServices
+#import <AppKit/AppKit.h>
+#import <ApplicationServices/ApplicationServices.h>
+#include <stdlib.h>
+
+typedef struct {
+	char *bundle_id;
+	char *name;
+	char *title;
+	int pid;
+} front_app;
+
+static char *copy_string(NSString *s) {
+	return strdup(s != nil ? s.UTF8String : "");
+}
+
+// focused_pid asks Accessibility for the focused application, which unlike
+// NSWorkspace stays current without a run loop. It returns -1 when that
+// isn't allowed or no app has focus.
+static pid_t focused_pid(void) {
+	if (!AXIsProcessTrusted()) {
+		return -1;
+	}
+	pid_t pid = -1;
+	AXUIElementRef sys = AXUIElementCreateSystemWide();
+	CFTypeRef app = NULL;
+	if (AXUIElementCopyAttributeValue(sys, kAXFocusedApplicationAttribute, &app) == kAXErrorSuccess && app != NULL) {
+		AXUIElementGetPid((AXUIElementRef)app, &pid);
+		CFRelease(app);
+	}
+	CFRelease(sys);
+	return pid;
+}
+
+// window_title returns the title of pid's focused window, or NULL.
+static char *window_title(pid_t pid) {
+	char *out = NULL;
+	AXUIElementRef app = AXUIElementCreateApplication(pid);
+	CFTypeRef win = NULL;
+	if (AXUIElementCopyAttributeValue(app, kAXFocusedWindowAttribute, &win) == kAXErrorSuccess && win != NULL) {
+		CFTypeRef title = NULL;
+		if (AXUIElementCopyAttributeValue((AXUIElementRef)win, kAXTitleAttribute, &title) == kAXErrorSuccess && title != NULL) {
+			if (CFGetTypeID(title) == CFStringGetTypeID()) {
+				out = copy_string((__bridge NSString *)title);
+			}
+			CFRelease(title);
+		}
+		CFRelease(win);
+	}
+	CFRelease(app);
+	return out;
+}
+
+// frontmost fills app with the frontmost application and, with
+// Accessibility access, its focused window's title. The strings are
+// malloc'd. It returns 0 when no app is frontmost.
+static int frontmost(front_app *out) {
+	@autoreleasepool {
+		NSRunningApplication *app = nil;
+		pid_t pid = focused_pid();
+		if (pid > 0) {
+			app = [NSRunningApplication runningApplicationWithProcessIdentifier:pid];
+		}
+		if (app == nil) {
+			app = [[NSWorkspace sharedWorkspace] frontmostApplication];
+		}
+		if (app == nil) {
+			return 0;
+		}
+		out->pid = app.processIdentifier;
+		out->bundle_id = copy_string(app.bundleIdentifier);
+		out->name = copy_string(app.localizedName);
+		out->title = AXIsProcessTrusted() ? window_title(app.processIdentifier) : NULL;
+		return 1;
+	}
+}
+
+static double idle_seconds(void) {
+	return CGEventSourceSecondsSinceLastEventType(kCGEventSourceStateCombinedSessionState, kCGAnyInputEventType);
+}
+
+// screen_locked reports the login session's screen lock, which the lock
+// screen sets whichever app is frontmost. It returns -1 outside a window
+// server session.
+static int screen_locked(void) {
+	CFDictionaryRef session = CGSessionCopyCurrentDictionary();
+	if (session == NULL) {
+		return -1;
+	}
+	CFTypeRef v = CFDictionaryGetValue(session, CFSTR("CGSSessionScreenIsLocked"));
+	int locked = v != NULL && CFGetTypeID(v) == CFBooleanGetTypeID() && CFBooleanGetValue((CFBooleanRef)v);
+	CFRelease(session);
+	return locked;
+}
+
+static int trusted(int prompt) {
+	NSDictionary *opts = @{(__bridge NSString *)kAXTrustedCheckOptionPrompt: @(prompt != 0)};
+	return AXIsProcessTrustedWithOptions((__bridge CFDictionaryRef)opts);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"time"
+	"unsafe"
+
+	"screentime-agent/internal/linux"
+)
+
+// frontmostApp returns the frontmost app as a window: Class is its name
+// and Instance its bundle identifier. Title is empty without
+// Accessibility access.
+func frontmostApp() (*linux.WindowInfo, error) {
+	var app C.front_app
+	if C.frontmost(&app) == 0 {
+		return nil, nil
+	}
+	defer C.free(unsafe.Pointer(app.bundle_id))
+	defer C.free(unsafe.Pointer(app.name))
+
+	info := &linux.WindowInfo{
+		Class:    C.GoString(app.name),
+		Instance: C.GoString(app.bundle_id),
+		PID:      int(app.pid),
+	}
+	if app.title != nil {
+		info.Title = C.GoString(app.title)
+		C.free(unsafe.Pointer(app.title))
+	}
+	if info.Instance == "" {
+		info.Instance = info.Class
+	}
+	return info, nil
+}
+
+// inputIdleTime returns how long since the last keyboard or mouse input.
+func inputIdleTime() (time.Duration, error) {
+	return time.Duration(float64(C.idle_seconds()) * float64(time.Second)), nil
+}
+
+// screenLocked reports whether the screen is locked.
+func screenLocked() (bool, error) {
+	switch C.screen_locked() {
+	case -1:
+		return false, errors.New("no window server session")
+	case 0:
+		return false, nil
+	}
+	return true, nil
+}
+
+// AccessibilityTrusted reports whether the agent may read window titles.
+// With prompt set, macOS asks the user to grant access if they haven't.
+func AccessibilityTrusted(prompt bool) bool {
+	p := C.int(0)
+	if prompt {
+		p = 1
+	}
+	return C.trusted(p) != 0
+}
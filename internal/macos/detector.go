@@ -0,0 +1,150 @@
+// Package macos implements the macOS agent's detector: it reports the
+// frontmost app, which package agent serves like the Linux agent does.
+package macos
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"screentime-agent/internal/agent"
+	"screentime-agent/internal/linux"
+)
+
+// Config holds the macOS agent configuration
+type Config struct {
+	agent.Config
+	// IgnoredApps are bundle identifiers, e.g. "com.apple.finder".
+	IgnoredApps []string `json:"ignored_apps"`
+}
+
+// DefaultConfig returns a config with sensible defaults
+func DefaultConfig() *Config {
+	return &Config{
+		Config:      agent.DefaultConfig(),
+		IgnoredApps: []string{"com.apple.loginwindow", "com.apple.ScreenSaver.Engine"},
+	}
+}
+
+// Platform runs the macOS agent with agent.Main.
+var Platform = agent.Platform{
+	Name:          "macOS",
+	DefaultConfig: func() agent.Settings { return DefaultConfig() },
+	NewDetector: func(cfg agent.Settings) agent.Detector {
+		// Window titles need Accessibility access; ask once, then carry
+		// on with app names only until it is granted.
+		if !AccessibilityTrusted(true) {
+			log.Printf("Accessibility access not granted; reporting app names without window titles")
+		}
+		return NewDetector(cfg.(*Config))
+	},
+}
+
+// Detector reports the frontmost app as an activity, categorized the same
+// way as on Linux.
+type Detector struct {
+	config      *Config
+	categorizer *linux.Categorizer
+
+	// frontmost, idleTime and locked query AppKit and Core Graphics; see
+	// darwin.go.
+	frontmost func() (*linux.WindowInfo, error)
+	idleTime  func() (time.Duration, error)
+	locked    func() (bool, error)
+}
+
+// NewDetector creates a detector
+func NewDetector(cfg *Config) *Detector {
+	return &Detector{
+		config:      cfg,
+		categorizer: linux.NewCategorizer(cfg.Categories),
+		frontmost:   frontmostApp,
+		idleTime:    inputIdleTime,
+		locked:      screenLocked,
+	}
+}
+
+// Detect returns the current activity.
+func (d *Detector) Detect() linux.Activity {
+	locked, lockErr := d.locked()
+	if locked {
+		return linux.Activity{
+			ID:    "idle:lock",
+			Name:  "Screen Locked",
+			State: "idle",
+		}
+	}
+	if d.config.IdleSeconds > 0 {
+		idle, err := d.idleTime()
+		if err != nil {
+			log.Printf("idle time error: %v", err)
+		} else if idle >= time.Duration(d.config.IdleSeconds)*time.Second {
+			return linux.Activity{
+				ID:    "idle:input",
+				Name:  "No Input",
+				State: "idle",
+			}
+		}
+	}
+
+	info, err := d.frontmost()
+	if err != nil {
+		log.Printf("app detection error: %v", err)
+		return linux.Activity{
+			ID:    "unknown",
+			Name:  "Unknown",
+			State: "offline",
+		}
+	}
+	if info == nil {
+		return linux.Activity{
+			ID:    "idle:no-window",
+			Name:  "No Window",
+			State: "idle",
+		}
+	}
+	if info.IsIdle(d.config.IdleWindowPatterns) {
+		// Without the session's lock state, a lock screen is told from
+		// a screensaver by its title
+		id := "idle:screensaver"
+		if lockErr != nil && info.IsLockScreen() {
+			id = "idle:lock"
+		}
+		return linux.Activity{
+			ID:    id,
+			Name:  info.Title,
+			State: "idle",
+		}
+	}
+	if slices.Contains(d.config.IgnoredApps, info.Instance) {
+		return linux.Activity{
+			ID:    "idle:ignored",
+			Name:  info.Class,
+			State: "idle",
+		}
+	}
+
+	if info.IsBrowser() {
+		if domain, category, ok := d.categorizer.CategorizeTitle(info.Title); ok {
+			return linux.Activity{
+				ID:    fmt.Sprintf("browser:%s:%s", category, domain),
+				Name:  domain,
+				State: "active",
+				Title: info.Title,
+			}
+		}
+	}
+	// Without Accessibility access there is no title; the app name is the
+	// best there is.
+	name := info.Title
+	if name == "" {
+		name = info.Class
+	}
+	return linux.Activity{
+		ID:    fmt.Sprintf("window:%s", info.Instance),
+		Name:  name,
+		State: "active",
+		Title: info.Title,
+	}
+}
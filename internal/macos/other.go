@@ -0,0 +1,29 @@
+//go:build !darwin || !cgo
+
+package macos
+
+import (
+	"errors"
+	"time"
+
+	"screentime-agent/internal/linux"
+)
+
+var errNotDarwin = errors.New("the macos agent only runs on macOS, built with cgo")
+
+func frontmostApp() (*linux.WindowInfo, error) {
+	return nil, errNotDarwin
+}
+
+func inputIdleTime() (time.Duration, error) {
+	return 0, errNotDarwin
+}
+
+func screenLocked() (bool, error) {
+	return false, errNotDarwin
+}
+
+// AccessibilityTrusted reports whether the agent may read window titles.
+func AccessibilityTrusted(prompt bool) bool {
+	return false
+}
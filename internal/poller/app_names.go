@@ -0,0 +1,41 @@
+package poller
+
+// rokuAppNames maps well-known Roku channel IDs to canonical display names.
+// Channels are renamed from time to time ("YouTube" vs "YouTube - TV"), and
+// keying names off the ID keeps usage history from fragmenting.
+var rokuAppNames = map[string]string{
+	"12":     "Netflix",
+	"13":     "Prime Video",
+	"28":     "Pandora",
+	"837":    "YouTube",
+	"2285":   "Hulu",
+	"2595":   "Crunchyroll",
+	"13535":  "Plex",
+	"22297":  "Spotify",
+	"23333":  "PBS KIDS",
+	"31440":  "Paramount+",
+	"41468":  "Tubi",
+	"46041":  "Sling TV",
+	"61322":  "Max",
+	"74519":  "Pluto TV",
+	"151908": "The Roku Channel",
+	"195316": "YouTube TV",
+	"291097": "Disney+",
+	"551012": "Apple TV",
+	"593099": "Peacock",
+}
+
+// NormalizeAppName returns the canonical name for an app. Config overrides take
+// precedence over the built-in table; unknown IDs keep the reported name.
+func NormalizeAppName(appID, appName string, overrides map[string]string) string {
+	if appID == "" {
+		return appName
+	}
+	if name, ok := overrides[appID]; ok && name != "" {
+		return name
+	}
+	if name, ok := rokuAppNames[appID]; ok {
+		return name
+	}
+	return appName
+}
@@ -0,0 +1,54 @@
+package poller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"screentime-agent/internal/cec"
+	"screentime-agent/internal/config"
+)
+
+// pollCEC asks the device's cec-agent what the TV is doing.
+func pollCEC(ctx context.Context, client *http.Client, c *config.CECConfig) (cec.Status, error) {
+	var st cec.Status
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.BaseURL, "/")+"/status", nil)
+	if err != nil {
+		return st, fmt.Errorf("build request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return st, classifyRequestError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return st, &PollError{Kind: ErrHTTPStatus, StatusCode: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return st, &PollError{Kind: ErrBadResponse, Err: fmt.Errorf("decode cec status: %w", err)}
+	}
+	return st, nil
+}
+
+// cecState returns the state an active poll of d should have given what its
+// TV is doing: "display_off" with the TV in standby, "other_input" with it
+// showing another input. Anything the TV hasn't reported yet leaves the
+// poll active.
+func (r *Runner) cecState(ctx context.Context, d config.DeviceConfig) string {
+	st, err := pollCEC(ctx, r.client, d.CEC)
+	if err != nil {
+		log.Printf("device %s cec poll error: %v", d.ID, err)
+		return "active"
+	}
+	if st.Power == cec.PowerStandby {
+		return "display_off"
+	}
+	if st.Input != "" && !strings.EqualFold(st.Input, d.CEC.Input) {
+		return "other_input"
+	}
+	return "active"
+}
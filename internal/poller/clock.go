@@ -0,0 +1,41 @@
+package poller
+
+import "time"
+
+// clockJumpThreshold is how far wall time may drift from monotonic elapsed
+// time before it is treated as a clock change (NTP step, manual reset).
+const clockJumpThreshold = 2 * time.Second
+
+// monoClock produces UTC timestamps that advance with the monotonic clock, so
+// wall-clock steps don't stretch or shrink the sessions being tracked.
+type monoClock struct {
+	anchorWall time.Time // UTC wall time at anchor, monotonic reading stripped
+	anchorMono time.Time // time.Now() at anchor, carrying the monotonic reading
+}
+
+func newMonoClock() *monoClock {
+	c := &monoClock{}
+	c.reanchor(time.Now())
+	return c
+}
+
+func (c *monoClock) reanchor(now time.Time) {
+	c.anchorMono = now
+	c.anchorWall = now.Round(0).UTC()
+}
+
+// Now returns the current timestamp. When the wall clock has jumped relative
+// to monotonic time, the clock re-anchors on wall time and returns the size
+// of the jump so callers can reconcile state recorded under the old clock.
+func (c *monoClock) Now() (time.Time, time.Duration) {
+	now := time.Now()
+	mono := c.anchorWall.Add(now.Sub(c.anchorMono))
+	wall := now.Round(0).UTC()
+
+	jump := wall.Sub(mono)
+	if jump > clockJumpThreshold || jump < -clockJumpThreshold {
+		c.reanchor(now)
+		return wall, jump
+	}
+	return mono, 0
+}
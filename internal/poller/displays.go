@@ -0,0 +1,44 @@
+package poller
+
+import "screentime-agent/internal/config"
+
+// recordCategoryDisplays keeps the category display metadata an agent
+// reported. When agents disagree the last one polled wins.
+func (r *Runner) recordCategoryDisplays(displays map[string]config.CategoryDisplay) {
+	if len(displays) == 0 {
+		return
+	}
+	r.displayMu.Lock()
+	defer r.displayMu.Unlock()
+	if r.displays == nil {
+		r.displays = make(map[string]config.CategoryDisplay)
+	}
+	for name, d := range displays {
+		r.displays[name] = d
+	}
+}
+
+// CategoryDisplays returns the display metadata of every category, with
+// the hub's configured colors and icons overriding what agents reported.
+func (r *Runner) CategoryDisplays() map[string]config.CategoryDisplay {
+	out := make(map[string]config.CategoryDisplay)
+	r.displayMu.Lock()
+	for name, d := range r.displays {
+		out[name] = d
+	}
+	r.displayMu.Unlock()
+
+	for name, c := range r.cfg.Categories {
+		d, ok := out[name]
+		if c.Color != "" {
+			d.Color, ok = c.Color, true
+		}
+		if c.Icon != "" {
+			d.Icon, ok = c.Icon, true
+		}
+		if ok {
+			out[name] = d
+		}
+	}
+	return out
+}
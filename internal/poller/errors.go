@@ -0,0 +1,78 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// ErrorKind classifies why a poll failed.
+type ErrorKind string
+
+const (
+	ErrTimeout           ErrorKind = "timeout"
+	ErrConnectionRefused ErrorKind = "connection_refused"
+	ErrDNS               ErrorKind = "dns"
+	ErrNetwork           ErrorKind = "network"
+	ErrHTTPStatus        ErrorKind = "http_status"
+	ErrBadResponse       ErrorKind = "bad_response"
+)
+
+// Describe returns a short human-readable label, e.g. "DNS failure".
+func (k ErrorKind) Describe() string {
+	switch k {
+	case ErrTimeout:
+		return "timeout"
+	case ErrConnectionRefused:
+		return "connection refused"
+	case ErrDNS:
+		return "DNS failure"
+	case ErrHTTPStatus:
+		return "HTTP error"
+	case ErrBadResponse:
+		return "bad response"
+	default:
+		return "network error"
+	}
+}
+
+// PollError is a classified poll failure.
+type PollError struct {
+	Kind       ErrorKind
+	StatusCode int // set for ErrHTTPStatus
+	Err        error
+}
+
+func (e *PollError) Error() string {
+	if e.Kind == ErrHTTPStatus {
+		return fmt.Sprintf("%s: status %d", e.Kind.Describe(), e.StatusCode)
+	}
+	if e.Err == nil {
+		return e.Kind.Describe()
+	}
+	return fmt.Sprintf("%s: %v", e.Kind.Describe(), e.Err)
+}
+
+func (e *PollError) Unwrap() error {
+	return e.Err
+}
+
+// classifyRequestError sorts an HTTP client error into an ErrorKind.
+func classifyRequestError(err error) *PollError {
+	kind := ErrNetwork
+
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		kind = ErrDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		kind = ErrConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		kind = ErrTimeout
+	}
+	return &PollError{Kind: kind, Err: err}
+}
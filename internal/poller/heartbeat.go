@@ -0,0 +1,103 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/alert"
+	"screentime-agent/internal/config"
+)
+
+// heartbeatInterval is how often agents are pinged and silence is evaluated.
+const heartbeatInterval = 30 * time.Second
+
+// runHeartbeat pings the device's heartbeat path (if configured) and raises an
+// alert when nothing has been heard from it, by heartbeat or poll, for longer
+// than the configured silence threshold. A disabled device is neither pinged
+// nor alerted about, and gets the full threshold again once re-enabled.
+func (r *Runner) runHeartbeat(ctx context.Context, d config.DeviceConfig) {
+	interval := d.PollInterval()
+	timeout := time.Duration(r.cfg.Alerts.AgentSilentMinutes) * time.Minute
+	client := &http.Client{Timeout: 3 * time.Second, Transport: r.transport}
+	since := time.Now().UTC()
+	disabled := false
+
+	beat := func() {
+		enabled, err := r.store.GetDeviceEnabled(ctx, d.ID)
+		if err != nil {
+			log.Printf("device %s enabled check error: %v", d.ID, err)
+			return
+		}
+		if !enabled {
+			if !disabled {
+				r.stats.clearSilent(d.ID, interval)
+				disabled = true
+			}
+			return
+		}
+		if disabled {
+			since = time.Now().UTC()
+			disabled = false
+		}
+
+		if d.HeartbeatPath != "" {
+			if err := ping(ctx, client, strings.TrimRight(d.BaseURL, "/")+d.HeartbeatPath); err == nil {
+				r.stats.recordHeartbeat(d.ID, interval, time.Now().UTC())
+			}
+		}
+
+		now := time.Now().UTC()
+		silent, changed, last := r.stats.checkSilent(d.ID, interval, now, since, timeout)
+		if !changed {
+			return
+		}
+
+		a := alert.Alert{DeviceID: d.ID, Time: now}
+		if silent {
+			a.Kind = "agent_silent"
+			if last.IsZero() {
+				a.Message = fmt.Sprintf("no heartbeat since hub start (%s)", timeout)
+			} else {
+				a.Message = fmt.Sprintf("no heartbeat since %s", last.Format(time.RFC3339))
+			}
+		} else {
+			a.Kind = "agent_recovered"
+			a.Message = "heartbeat resumed"
+		}
+		if err := r.notifier.Notify(ctx, a); err != nil {
+			log.Printf("device %s notify error: %v", d.ID, err)
+		}
+	}
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			beat()
+		}
+	}
+}
+
+func ping(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package poller
+
+import (
+	"context"
+	"log"
+)
+
+// agentIdentity is what an agent reports about the machine it runs on.
+type agentIdentity struct {
+	hostname string
+	version  string
+}
+
+// RecordIdentity stores the hostname and agent version a device reported
+// as device metadata, so two machines behind the same address or a
+// reinstalled agent can be told apart. Only changes are written.
+func (r *Runner) RecordIdentity(ctx context.Context, deviceID, hostname, version string) {
+	if hostname == "" && version == "" {
+		return
+	}
+	id := agentIdentity{hostname: hostname, version: version}
+
+	r.identityMu.Lock()
+	defer r.identityMu.Unlock()
+	if r.identities[deviceID] == id {
+		return
+	}
+
+	metadata := make(map[string]string)
+	if hostname != "" {
+		metadata["hostname"] = hostname
+	}
+	if version != "" {
+		metadata["agent_version"] = version
+	}
+	if err := r.store.MergeDeviceMetadata(ctx, deviceID, metadata); err != nil {
+		log.Printf("device %s record identity error: %v", deviceID, err)
+		return
+	}
+	if r.identities == nil {
+		r.identities = make(map[string]agentIdentity)
+	}
+	r.identities[deviceID] = id
+}
@@ -0,0 +1,147 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/storage"
+)
+
+// minPushTimeout is the shortest silence after which a push-only device's
+// session is ended, however often it pushes.
+const minPushTimeout = 30 * time.Second
+
+// ErrPushRejected is returned by Ingest for a push that could be a replay:
+// one whose timestamp is missing, outside the clock skew tolerance, or not
+// newer than the last push accepted for its device and slot. The signature
+// covers the timestamp, so a captured push can't be made fresh again.
+var ErrPushRejected = errors.New("push rejected")
+
+// pushState is what the runner keeps between a device's pushes.
+type pushState struct {
+	mu      sync.Mutex
+	writes  writeQueue
+	blocked string    // blocked app ID already reported for this appearance
+	last    time.Time // hub time of the last push
+	ended   bool      // the session was ended for silence since the last push
+}
+
+func (r *Runner) pushState(deviceID string) *pushState {
+	r.pushMu.Lock()
+	defer r.pushMu.Unlock()
+	if r.pushes == nil {
+		r.pushes = make(map[string]*pushState)
+	}
+	ps, ok := r.pushes[deviceID]
+	if !ok {
+		ps = &pushState{}
+		r.pushes[deviceID] = ps
+	}
+	return ps
+}
+
+// Ingest applies a poll update an agent pushed for d, as if the hub had
+// polled it, at the agent's timestamp. A push too far from hub time, or
+// no newer than the last one for its slot, is refused with
+// ErrPushRejected.
+func (r *Runner) Ingest(ctx context.Context, d config.DeviceConfig, u storage.PollUpdate) error {
+	ps := r.pushState(d.ID)
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	now := time.Now().UTC()
+	interval := d.PollInterval()
+	if u.Timestamp.IsZero() {
+		return fmt.Errorf("%w: no timestamp", ErrPushRejected)
+	}
+	u.Timestamp = u.Timestamp.UTC()
+	skew := ClockSkew(u.Timestamp, now)
+	if r.stats.recordSkew(d.ID, interval, skew, r.maxSkew()) {
+		log.Printf("warning: device %s clock is off by %s (tolerance %s)", d.ID, skew.Round(time.Second), r.maxSkew())
+	}
+	if skew > r.maxSkew() || skew < -r.maxSkew() {
+		return fmt.Errorf("%w: timestamp is %s off hub time (tolerance %s)", ErrPushRejected, skew.Round(time.Second), r.maxSkew())
+	}
+	if fresh, err := r.store.AcceptPush(ctx, d.ID, u.Slot, u.Timestamp); err != nil {
+		return err
+	} else if !fresh {
+		return fmt.Errorf("%w: timestamp %s is not after the last push", ErrPushRejected, u.Timestamp.Format(time.RFC3339Nano))
+	}
+
+	enabled, err := r.store.GetDeviceEnabled(ctx, d.ID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		if err := r.store.EndCurrentSession(ctx, d.ID, now, "disabled"); err != nil {
+			return err
+		}
+		return r.store.ApplySecondary(ctx, d.ID, nil, now)
+	}
+
+	u.DeviceID = d.ID
+	u.AppName = NormalizeAppName(u.AppID, u.AppName, r.cfg.AppNames)
+
+	r.stats.recordHeartbeat(d.ID, interval, now)
+	if err := r.store.TouchDevice(ctx, d.ID, now); err != nil {
+		log.Printf("device %s touch error: %v", d.ID, err)
+	}
+	ps.last, ps.ended = now, false
+
+	if r.cfg.RawPollDays > 0 {
+		if err := r.store.RecordRawPoll(ctx, u); err != nil {
+			log.Printf("device %s record raw poll error: %v", d.ID, err)
+		}
+	}
+	applied, err := ps.writes.apply(ctx, r.store.ApplyPoll, u, now)
+	if err != nil {
+		log.Printf("device %s apply poll error, %d queued for retry in %s: %v",
+			d.ID, len(ps.writes.pending), ps.writes.backoff, err)
+	} else if applied > 0 {
+		log.Printf("device %s applied %d queued polls", d.ID, applied)
+	}
+	r.stats.recordWrites(d.ID, interval, &ps.writes)
+
+	if u.State == "active" && u.AppID != "" {
+		r.recordApp(ctx, u)
+	}
+	if u.Slot == storage.PrimarySlot {
+		r.checkBlocked(ctx, d, u, &ps.blocked)
+	}
+	return nil
+}
+
+// runPushTimeout ends a push-only device's sessions as offline, at the
+// last push, once it has been silent for three push intervals, so a
+// laptop that drops off the network doesn't keep accruing time.
+func (r *Runner) runPushTimeout(ctx context.Context, d config.DeviceConfig) {
+	timeout := max(3*d.PollInterval(), minPushTimeout)
+	ps := r.pushState(d.ID)
+
+	ticker := time.NewTicker(timeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ps.mu.Lock()
+		if !ps.last.IsZero() && !ps.ended && time.Since(ps.last) > timeout {
+			if err := r.store.EndCurrentSession(ctx, d.ID, ps.last, "offline"); err != nil {
+				log.Printf("device %s end session error: %v", d.ID, err)
+			} else {
+				log.Printf("device %s: no push for %s, ended session", d.ID, timeout)
+				ps.ended = true
+			}
+		}
+		ps.mu.Unlock()
+	}
+}
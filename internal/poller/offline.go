@@ -0,0 +1,66 @@
+package poller
+
+import (
+	"fmt"
+	"time"
+
+	"screentime-agent/internal/alert"
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/locale"
+)
+
+// offlineTracker turns a stream of poll results for one device into
+// device_offline / device_online alerts. Offline time outside the device's
+// active hours doesn't count toward the threshold.
+type offlineTracker struct {
+	threshold time.Duration
+	since     time.Time // start of the current offline stretch inside active hours
+	alerted   bool
+	cause     ErrorKind // first failure kind seen in the current stretch
+	format    locale.Formatter
+}
+
+func (t *offlineTracker) observe(d config.DeviceConfig, res PollResult, loc *time.Location) (alert.Alert, bool) {
+	if res.State != "offline" {
+		wasAlerted := t.alerted
+		t.since = time.Time{}
+		t.alerted = false
+		t.cause = ""
+		if wasAlerted {
+			return alert.Alert{
+				Kind:     "device_online",
+				DeviceID: d.ID,
+				Message:  "device is reachable again",
+				Time:     res.Timestamp,
+			}, true
+		}
+		return alert.Alert{}, false
+	}
+
+	if !d.IsActiveHour(res.Timestamp.In(loc)) {
+		t.since = time.Time{}
+		t.cause = ""
+		return alert.Alert{}, false
+	}
+	if t.since.IsZero() {
+		t.since = res.Timestamp
+		if res.Failure != nil {
+			t.cause = res.Failure.Kind
+		}
+	}
+	if t.alerted || res.Timestamp.Sub(t.since) < t.threshold {
+		return alert.Alert{}, false
+	}
+
+	t.alerted = true
+	msg := fmt.Sprintf("unreachable since %s during active hours", t.format.Clock(t.since.In(loc)))
+	if t.cause != "" {
+		msg = fmt.Sprintf("%s since %s during active hours", t.cause.Describe(), t.format.Clock(t.since.In(loc)))
+	}
+	return alert.Alert{
+		Kind:     "device_offline",
+		DeviceID: d.ID,
+		Message:  msg,
+		Time:     res.Timestamp,
+	}, true
+}
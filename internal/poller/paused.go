@@ -0,0 +1,33 @@
+package poller
+
+import (
+	"time"
+)
+
+// pausedTracker follows how long a device's media has been paused so the
+// runner can end the session once the paused-media policy says it's idle.
+type pausedTracker struct {
+	appID string
+	since time.Time // when playback of appID was first seen paused
+}
+
+// observe records the media state for the current app and reports whether
+// it has been paused for at least idleAfter.
+func (t *pausedTracker) observe(appID, media string, ts time.Time, idleAfter time.Duration) bool {
+	if media != "pause" || appID != t.appID {
+		t.appID = appID
+		t.since = time.Time{}
+	}
+	if media != "pause" {
+		return false
+	}
+	if t.since.IsZero() {
+		t.since = ts
+	}
+	return ts.Sub(t.since) >= idleAfter
+}
+
+// paused reports whether the last observation saw the current app paused.
+func (t *pausedTracker) paused() bool {
+	return !t.since.IsZero()
+}
@@ -0,0 +1,68 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"screentime-agent/internal/storage"
+)
+
+// maxPendingWrites bounds a device's retry queue; at a one-second poll
+// interval it covers ten minutes of storage trouble.
+const maxPendingWrites = 600
+
+// Backoff bounds between retries of failed writes.
+const (
+	minWriteBackoff = time.Second
+	maxWriteBackoff = time.Minute
+)
+
+// writeQueue holds poll updates whose ApplyPoll failed, so a transient
+// storage error (a locked database, a full disk) delays them instead of
+// losing that time. Updates are applied strictly in order: while any are
+// pending, new ones queue behind them. When the queue is full the oldest
+// update is dropped. An update ApplyPoll refuses as out of order is
+// dropped too, and counted in stale.
+type writeQueue struct {
+	pending []storage.PollUpdate
+	backoff time.Duration
+	retryAt time.Time
+	dropped int64
+	stale   int64
+}
+
+// apply queues u behind any pending updates and, unless still backing off
+// from a failure, applies the queue in order. It returns the number of
+// previously queued updates applied, and the error that stopped it.
+func (q *writeQueue) apply(ctx context.Context, fn func(context.Context, storage.PollUpdate) error, u storage.PollUpdate, now time.Time) (int, error) {
+	q.pending = append(q.pending, u)
+	if len(q.pending) > maxPendingWrites {
+		q.pending = q.pending[1:]
+		q.dropped++
+	}
+	if now.Before(q.retryAt) {
+		return 0, nil
+	}
+
+	queued := len(q.pending) - 1
+	applied := 0
+	for len(q.pending) > 0 {
+		err := fn(ctx, q.pending[0])
+		if errors.Is(err, storage.ErrStalePoll) {
+			q.pending = q.pending[1:]
+			q.stale++
+			continue
+		}
+		if err != nil {
+			q.backoff = min(max(2*q.backoff, minWriteBackoff), maxWriteBackoff)
+			q.retryAt = now.Add(q.backoff)
+			return min(applied, queued), err
+		}
+		q.pending = q.pending[1:]
+		applied++
+	}
+	q.backoff = 0
+	q.retryAt = time.Time{}
+	return min(applied, queued), nil
+}
@@ -8,14 +8,64 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/storage"
 )
 
 type PollResult struct {
 	DeviceID  string
 	AppID     string
 	AppName   string
-	State     string // "active", "idle", "offline"
+	State     string // "active", "idle", "offline", "unknown"
 	Timestamp time.Time
+	// IdleReason is one of the storage.Idle constants when the device
+	// says why it is idle.
+	IdleReason string
+	// AgentTime is the device's own clock, taken from the response Date
+	// header. Zero when the device didn't send one.
+	AgentTime time.Time
+	// Reachable is true when the device answered at all, even with an error
+	// status; it feeds heartbeat tracking.
+	Reachable bool
+	// Failure explains an "offline" state caused by an error rather than
+	// the device simply reporting nothing. Nil on success.
+	Failure *PollError
+	// Secondary lists background activity reported by agents that speak
+	// the extended protocol. Always empty for real Roku devices.
+	Secondary []SecondaryApp
+	// Account is the platform account an agent attributes the app to,
+	// another extended protocol field.
+	Account string
+	// Title is the window or tab title, sent by agents tracking titles.
+	Title string
+	// Slots lists further foreground activities running at the same time
+	// as the primary app, such as picture-in-picture, each in a named
+	// slot. Another extended protocol field.
+	Slots []SlotApp
+	// Hostname and AgentVersion identify the machine and agent build that
+	// answered, when a screentime agent sent them.
+	Hostname     string
+	AgentVersion string
+	// Categories is the display metadata of the agent's own categories,
+	// by name.
+	Categories map[string]config.CategoryDisplay
+}
+
+// SecondaryApp is a background activity reported alongside the primary app.
+type SecondaryApp struct {
+	AppID   string
+	AppName string
+}
+
+// SlotApp is an activity reported in a named slot next to the primary app.
+// Unlike a secondary app it gets sessions of its own.
+type SlotApp struct {
+	Slot    string
+	AppID   string
+	AppName string
+	Account string
+	Title   string
 }
 
 type RokuPoller struct {
@@ -24,24 +74,50 @@ type RokuPoller struct {
 	client   *http.Client
 }
 
-func NewRokuPoller(deviceID, baseURL string) *RokuPoller {
+// NewRokuPoller polls the device at baseURL. transport is used for its
+// requests; nil means http.DefaultTransport.
+func NewRokuPoller(deviceID, baseURL string, transport http.RoundTripper) *RokuPoller {
 	return &RokuPoller{
 		deviceID: deviceID,
 		baseURL:  strings.TrimRight(baseURL, "/"),
-		client:   &http.Client{},
+		client:   &http.Client{Transport: transport},
 	}
 }
 
 type activeAppResponse struct {
 	XMLName xml.Name `xml:"active-app"`
-	App     struct {
-		ID   string `xml:"id,attr"`
-		Name string `xml:",chardata"`
-	} `xml:"app"`
+	App     xmlApp   `xml:"app"`
+	// Secondary, Slots, Categories, Hostname and Version are extensions
+	// sent by screentime agents.
+	Secondary  []xmlApp      `xml:"secondary>app"`
+	Slots      []xmlSlotApp  `xml:"slots>app"`
+	Categories []xmlCategory `xml:"categories>category"`
+	Hostname   string        `xml:"hostname,attr"`
+	Version    string        `xml:"version,attr"`
+}
+
+type xmlCategory struct {
+	Name  string `xml:"name,attr"`
+	Color string `xml:"color,attr"`
+	Icon  string `xml:"icon,attr"`
+}
+
+type xmlApp struct {
+	ID      string `xml:"id,attr"`
+	Account string `xml:"account,attr"` // screentime agent extension
+	Title   string `xml:"title,attr"`   // screentime agent extension
+	Name    string `xml:",chardata"`
+}
+
+type xmlSlotApp struct {
+	Slot string `xml:"slot,attr"`
+	xmlApp
 }
 
 // Poll queries /query/active-app and returns a PollResult.
-// Network errors are mapped to State="offline" with no error returned.
+// Network errors and non-200 responses are mapped to State="offline" with
+// the classified cause in Failure and no error returned. A response that
+// can't be parsed returns a *PollError.
 func (p *RokuPoller) Poll(ctx context.Context) (PollResult, error) {
 	now := time.Now().UTC()
 	res := PollResult{
@@ -58,23 +134,32 @@ func (p *RokuPoller) Poll(ctx context.Context) (PollResult, error) {
 	resp, err := p.client.Do(req)
 	if err != nil {
 		// offline
+		res.Failure = classifyRequestError(err)
 		return res, nil
 	}
 	defer resp.Body.Close()
+	res.Reachable = true
+
+	if d, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		res.AgentTime = d.UTC()
+	}
 
 	if resp.StatusCode != http.StatusOK {
 		// treat non-200 as offline
+		res.Failure = &PollError{Kind: ErrHTTPStatus, StatusCode: resp.StatusCode}
 		return res, nil
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return res, fmt.Errorf("read active-app response: %w", err)
+		res.Failure = classifyRequestError(fmt.Errorf("read active-app response: %w", err))
+		return res, res.Failure
 	}
 
 	var a activeAppResponse
 	if err := xml.Unmarshal(body, &a); err != nil {
-		return res, fmt.Errorf("unmarshal active-app response: %w", err)
+		res.Failure = &PollError{Kind: ErrBadResponse, Err: fmt.Errorf("unmarshal active-app response: %w", err)}
+		return res, res.Failure
 	}
 
 	appID := strings.TrimSpace(a.App.ID)
@@ -82,9 +167,57 @@ func (p *RokuPoller) Poll(ctx context.Context) (PollResult, error) {
 
 	res.AppID = appID
 	res.AppName = appName
+	res.Account = strings.TrimSpace(a.App.Account)
+	res.Title = strings.TrimSpace(a.App.Title)
+	res.Hostname = strings.TrimSpace(a.Hostname)
+	res.AgentVersion = strings.TrimSpace(a.Version)
+
+	for _, sec := range a.Secondary {
+		id := strings.TrimSpace(sec.ID)
+		if id == "" {
+			continue
+		}
+		res.Secondary = append(res.Secondary, SecondaryApp{
+			AppID:   id,
+			AppName: strings.TrimSpace(sec.Name),
+		})
+	}
+
+	for _, sl := range a.Slots {
+		slot := strings.TrimSpace(sl.Slot)
+		id := strings.TrimSpace(sl.ID)
+		if slot == "" || id == "" {
+			continue
+		}
+		res.Slots = append(res.Slots, SlotApp{
+			Slot:    slot,
+			AppID:   id,
+			AppName: strings.TrimSpace(sl.Name),
+			Account: strings.TrimSpace(sl.Account),
+			Title:   strings.TrimSpace(sl.Title),
+		})
+	}
 
-	if appName == "" || isIdleAppName(appName) {
+	for _, c := range a.Categories {
+		name := strings.TrimSpace(c.Name)
+		display := config.CategoryDisplay{Color: strings.TrimSpace(c.Color), Icon: strings.TrimSpace(c.Icon)}
+		if name == "" || !config.ValidColor(display.Color) {
+			continue
+		}
+		if res.Categories == nil {
+			res.Categories = make(map[string]config.CategoryDisplay)
+		}
+		res.Categories[name] = display
+	}
+
+	if reason, ok := agentIdleReason(appID); ok {
+		res.State = "idle"
+		res.IdleReason = reason
+	} else if appName == "" || isIdleAppName(appName) {
 		res.State = "idle"
+		if strings.EqualFold(appName, "screensaver") {
+			res.IdleReason = storage.IdleScreensaver
+		}
 	} else {
 		res.State = "active"
 	}
@@ -92,6 +225,118 @@ func (p *RokuPoller) Poll(ctx context.Context) (PollResult, error) {
 	return res, nil
 }
 
+type mediaPlayerResponse struct {
+	XMLName xml.Name `xml:"player"`
+	State   string   `xml:"state,attr"`
+}
+
+// PollMedia queries /query/media-player and returns the playback state,
+// e.g. "play", "pause" or "stop". Devices that don't support the endpoint
+// report "".
+func (p *RokuPoller) PollMedia(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/query/media-player", nil)
+	if err != nil {
+		return "", fmt.Errorf("build request: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", classifyRequestError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &PollError{Kind: ErrHTTPStatus, StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", classifyRequestError(fmt.Errorf("read media-player response: %w", err))
+	}
+	var m mediaPlayerResponse
+	if err := xml.Unmarshal(body, &m); err != nil {
+		return "", &PollError{Kind: ErrBadResponse, Err: fmt.Errorf("unmarshal media-player response: %w", err)}
+	}
+	return strings.TrimSpace(m.State), nil
+}
+
+type deviceInfoResponse struct {
+	XMLName             xml.Name `xml:"device-info"`
+	PowerMode           string   `xml:"power-mode"`
+	HeadphonesConnected string   `xml:"headphones-connected"`
+}
+
+// DeviceInfo is the part of /query/device-info that says whether the
+// device is being watched.
+type DeviceInfo struct {
+	// PowerMode is e.g. "PowerOn", "DisplayOff" or "Ready"; "" when the
+	// device doesn't report it, as most streaming sticks don't.
+	PowerMode string
+	// HeadphonesConnected is set while headphones are plugged into the
+	// remote or the mobile app is in private listening mode.
+	HeadphonesConnected bool
+}
+
+// DisplayOff reports whether the device says its display is off.
+func (i DeviceInfo) DisplayOff() bool {
+	return i.PowerMode != "" && i.PowerMode != "PowerOn"
+}
+
+// PollDeviceInfo queries /query/device-info.
+func (p *RokuPoller) PollDeviceInfo(ctx context.Context) (DeviceInfo, error) {
+	var info DeviceInfo
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/query/device-info", nil)
+	if err != nil {
+		return info, fmt.Errorf("build request: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return info, classifyRequestError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return info, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return info, &PollError{Kind: ErrHTTPStatus, StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return info, classifyRequestError(fmt.Errorf("read device-info response: %w", err))
+	}
+	var d deviceInfoResponse
+	if err := xml.Unmarshal(body, &d); err != nil {
+		return info, &PollError{Kind: ErrBadResponse, Err: fmt.Errorf("unmarshal device-info response: %w", err)}
+	}
+	info.PowerMode = strings.TrimSpace(d.PowerMode)
+	info.HeadphonesConnected = strings.TrimSpace(d.HeadphonesConnected) == "true"
+	return info, nil
+}
+
+// agentIdleIDs maps the app IDs screentime agents report while idle to
+// their idle reasons.
+var agentIdleIDs = map[string]string{
+	"idle:screensaver": storage.IdleScreensaver,
+	"idle:lock":        storage.IdleLock,
+	"idle:input":       storage.IdleNoInput,
+	"idle:ignored":     storage.IdleIgnoredWindow,
+	"idle:no-window":   storage.IdleNoWindow,
+	"idle:none":        storage.IdleNoWindow,
+}
+
+// agentIdleReason reports whether appID is one a screentime agent sends
+// while idle, and why; an "idle:" ID it doesn't know has no reason.
+func agentIdleReason(appID string) (string, bool) {
+	if !strings.HasPrefix(appID, "idle:") {
+		return "", false
+	}
+	return agentIdleIDs[appID], true
+}
+
 func isIdleAppName(name string) bool {
 	l := strings.ToLower(strings.TrimSpace(name))
 	switch l {
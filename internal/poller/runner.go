@@ -2,56 +2,352 @@ package poller
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net/http"
+	"sync"
 	"time"
 
+	"screentime-agent/internal/alert"
+	"screentime-agent/internal/category"
 	"screentime-agent/internal/config"
+	"screentime-agent/internal/enforce"
 	"screentime-agent/internal/storage"
 )
 
 type Runner struct {
-	devices []config.DeviceConfig
-	store   *storage.SessionStore
+	cfg        *config.Config
+	store      *storage.SessionStore
+	notifier   alert.Notifier
+	stats      *statsRegistry
+	categories *category.Categorizer
+	blocklist  *enforce.Blocklist
+	client     *http.Client // for device notifications and cec-agents
+	transport  http.RoundTripper
+	loc        *time.Location
+
+	pushMu sync.Mutex
+	pushes map[string]*pushState // by device ID
+
+	identityMu sync.Mutex
+	identities map[string]agentIdentity // last stored, by device ID
+
+	displayMu sync.Mutex
+	displays  map[string]config.CategoryDisplay // reported by agents, by category
 }
 
-func NewRunner(devices []config.DeviceConfig, store *storage.SessionStore) *Runner {
+func NewRunner(cfg *config.Config, store *storage.SessionStore, notifier alert.Notifier) *Runner {
+	loc, err := cfg.ResolveLocation()
+	if err != nil {
+		log.Printf("runner: %v, falling back to local time", err)
+		loc = time.Local
+	}
+	transport := cfg.DeviceTransport()
 	return &Runner{
-		devices: devices,
-		store:   store,
+		cfg:        cfg,
+		store:      store,
+		notifier:   notifier,
+		stats:      newStatsRegistry(),
+		categories: category.New(cfg.Categories, store.AppCategory),
+		blocklist:  enforce.NewBlocklist(cfg.Blocklist),
+		client:     &http.Client{Timeout: 3 * time.Second, Transport: transport},
+		transport:  transport,
+		loc:        loc,
+	}
+}
+
+// Stats returns a snapshot of per-device poll statistics.
+func (r *Runner) Stats() []PollerStats {
+	return r.stats.snapshot()
+}
+
+func (r *Runner) maxSkew() time.Duration {
+	if r.cfg.MaxClockSkewSeconds > 0 {
+		return time.Duration(r.cfg.MaxClockSkewSeconds) * time.Second
+	}
+	return DefaultMaxClockSkew
+}
+
+func (r *Runner) recordApp(ctx context.Context, u storage.PollUpdate) {
+	isNew, err := r.store.RecordAppSeen(ctx, u.DeviceID, u.AppID, u.AppName, u.Timestamp)
+	if err != nil {
+		log.Printf("device %s record app error: %v", u.DeviceID, err)
+		return
+	}
+	if !isNew || !r.cfg.Alerts.NewApps {
+		return
+	}
+	a := alert.Alert{
+		Kind:     "new_app",
+		DeviceID: u.DeviceID,
+		Message:  fmt.Sprintf("first time seeing %s (%s)", u.AppName, u.AppID),
+		Time:     u.Timestamp,
+	}
+	if err := r.notifier.Notify(ctx, a); err != nil {
+		log.Printf("device %s notify error: %v", u.DeviceID, err)
 	}
 }
 
 func (r *Runner) Start(ctx context.Context) {
-	for _, d := range r.devices {
+	for _, d := range r.cfg.Devices {
 		dev := d
-		go r.runDevice(ctx, dev)
+		if dev.PushOnly() {
+			go r.runPushTimeout(ctx, dev)
+		} else {
+			go r.runDevice(ctx, dev)
+		}
+		go r.runHeartbeat(ctx, dev)
+	}
+	if r.cfg.RawPollDays > 0 {
+		go r.pruneRawPolls(ctx)
+	}
+	go r.runHubHeartbeat(ctx)
+	go r.rollUpDays(ctx)
+}
+
+// Hub heartbeats mark the hub as up; a gap longer than hubDowntimeGap
+// between them (a restart, or the machine sleeping) is recorded as
+// downtime, when nothing was monitored.
+const (
+	hubHeartbeatInterval = 30 * time.Second
+	hubDowntimeGap       = 2 * time.Minute
+)
+
+// runHubHeartbeat records the hub's run and keeps it current until ctx is
+// done, logging any downtime found on the way.
+func (r *Runner) runHubHeartbeat(ctx context.Context) {
+	id, down, err := r.store.StartHubRun(ctx, time.Now(), hubDowntimeGap)
+	if err != nil {
+		log.Printf("record hub start error: %v", err)
+		return
+	}
+	logDowntime(down)
+
+	ticker := time.NewTicker(hubHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Round(0) drops the monotonic reading so a wake from sleep shows
+		// as the gap it was.
+		id, down, err = r.store.HubHeartbeat(ctx, id, time.Now().Round(0), hubDowntimeGap)
+		if err != nil {
+			log.Printf("hub heartbeat error: %v", err)
+			continue
+		}
+		logDowntime(down)
+	}
+}
+
+func logDowntime(down *storage.HubEvent) {
+	if down != nil {
+		log.Printf("hub was down for %s since %s; that time is unmonitored",
+			down.EndTime.Sub(down.StartTime).Round(time.Second), down.StartTime.Format(time.RFC3339))
+	}
+}
+
+// rollUpDays builds the daily usage rollup at startup, catching up on any
+// days without one, and again just after each day boundary to settle the
+// day that ended.
+func (r *Runner) rollUpDays(ctx context.Context) {
+	for {
+		now := time.Now().In(r.loc)
+		if n, err := r.store.RollUpDays(ctx, now); err != nil {
+			log.Printf("roll up daily usage error: %v", err)
+		} else if n > 2 {
+			log.Printf("rolled up usage for %d days", n)
+		}
+
+		timer := time.NewTimer(time.Until(r.cfg.NextDayStart(now).Add(time.Minute)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// pruneRawPolls drops raw poll observations past the retention period,
+// once at startup and then hourly.
+func (r *Runner) pruneRawPolls(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().AddDate(0, 0, -r.cfg.RawPollDays)
+		if n, err := r.store.PruneRawPolls(ctx, before); err != nil {
+			log.Printf("prune raw polls error: %v", err)
+		} else if n > 0 {
+			log.Printf("pruned %d raw polls", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
 func (r *Runner) runDevice(ctx context.Context, d config.DeviceConfig) {
-	poller := NewRokuPoller(d.ID, d.BaseURL)
-	interval := time.Duration(d.PollIntervalSeconds) * time.Second
+	poller := NewRokuPoller(d.ID, d.BaseURL, r.transport)
+	interval := d.PollInterval()
+	clock := newMonoClock()
+	offline := offlineTracker{
+		threshold: time.Duration(r.cfg.Alerts.OfflineMinutes) * time.Minute,
+		format:    r.cfg.Display.Formatter(),
+	}
+	startup := newStartupTracker(r.cfg.StartupGrace())
+	var paused pausedTracker
+	var blocked string // blocked app ID already reported for this appearance
+	var writes writeQueue
+	openSlots := make(map[string]bool) // named slots with a session open
 
 	doPoll := func() {
+		enabled, err := r.store.GetDeviceEnabled(ctx, d.ID)
+		if err != nil {
+			log.Printf("device %s enabled check error: %v", d.ID, err)
+			return
+		}
+		if !enabled {
+			// Close out anything still open so a disabled device doesn't keep accruing time.
+			now := time.Now().UTC()
+			if err := r.store.EndCurrentSession(ctx, d.ID, now, "disabled"); err != nil {
+				log.Printf("device %s end session error: %v", d.ID, err)
+			}
+			if err := r.store.ApplySecondary(ctx, d.ID, nil, now); err != nil {
+				log.Printf("device %s end secondary error: %v", d.ID, err)
+			}
+			return
+		}
+
 		pollCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 		defer cancel()
 
+		ts, jump := clock.Now()
+		if jump != 0 {
+			log.Printf("device %s: wall clock jumped by %s, shifting current session", d.ID, jump.Round(time.Millisecond))
+			if err := r.store.ShiftCurrentSession(ctx, d.ID, jump); err != nil {
+				log.Printf("device %s shift session error: %v", d.ID, err)
+			}
+		}
+
+		started := time.Now()
 		result, err := poller.Poll(pollCtx)
+		latency := time.Since(started)
+		result.Timestamp = ts
+		if result.Reachable {
+			r.stats.recordHeartbeat(d.ID, interval, ts)
+			if err := r.store.TouchDevice(ctx, d.ID, ts); err != nil {
+				log.Printf("device %s touch error: %v", d.ID, err)
+			}
+			r.RecordIdentity(ctx, d.ID, result.Hostname, result.AgentVersion)
+			r.recordCategoryDisplays(result.Categories)
+		}
+
+		if r.stats.record(d.ID, interval, ts, latency, err != nil) {
+			log.Printf("warning: device %s polls consistently slower than its %s interval (last %s); timestamps will skew",
+				d.ID, interval, latency.Round(time.Millisecond))
+		}
+
+		failure := result.Failure
+		if failure == nil && err != nil {
+			failure = &PollError{Kind: ErrNetwork, Err: err}
+		}
+		r.stats.recordFailure(d.ID, interval, ts, failure)
+
 		if err != nil {
 			log.Printf("device %s poll error: %v", d.ID, err)
 			return
 		}
 
+		result.State = startup.observe(result.State)
+		r.stats.recordUnknown(d.ID, interval, result.State == "unknown")
+
+		if a, ok := offline.observe(d, result, r.loc); ok {
+			if err := r.notifier.Notify(ctx, a); err != nil {
+				log.Printf("device %s notify error: %v", d.ID, err)
+			}
+		}
+
+		if !result.AgentTime.IsZero() {
+			skew := ClockSkew(result.AgentTime, result.Timestamp)
+			if r.stats.recordSkew(d.ID, interval, skew, r.maxSkew()) {
+				log.Printf("warning: device %s clock is off by %s (tolerance %s)", d.ID, skew.Round(time.Second), r.maxSkew())
+			}
+		}
+
 		update := storage.PollUpdate{
-			DeviceID:  result.DeviceID,
-			AppID:     result.AppID,
-			AppName:   result.AppName,
-			State:     result.State,
-			Timestamp: result.Timestamp,
+			DeviceID:   result.DeviceID,
+			AppID:      result.AppID,
+			AppName:    NormalizeAppName(result.AppID, result.AppName, r.cfg.AppNames),
+			State:      result.State,
+			Timestamp:  result.Timestamp,
+			Account:    result.Account,
+			Title:      result.Title,
+			IdleReason: result.IdleReason,
+		}
+
+		if update.State == "active" && d.CEC != nil {
+			update.State = r.cecState(pollCtx, d)
+		}
+
+		var listening bool
+		if update.State == "active" && r.cfg.WatchSignals != nil {
+			update.State, listening = r.watchState(pollCtx, poller, update)
+		}
+
+		if update.State == "active" && r.cfg.PausedMedia != nil {
+			if r.pausedTooLong(pollCtx, poller, &paused, update) && !listening {
+				update.State = "paused"
+			} else if paused.paused() {
+				// Still counted, but already looking idle.
+				update.IdleReason = storage.IdlePausedMedia
+			}
 		}
 
-		if err := r.store.ApplyPoll(ctx, update); err != nil {
-			log.Printf("device %s apply poll error: %v", d.ID, err)
+		updates := append([]storage.PollUpdate{update}, r.slotUpdates(update, result.Slots, openSlots)...)
+		for _, u := range updates {
+			if r.cfg.RawPollDays > 0 {
+				if err := r.store.RecordRawPoll(ctx, u); err != nil {
+					log.Printf("device %s record raw poll error: %v", d.ID, err)
+				}
+			}
+
+			applied, err := writes.apply(ctx, r.store.ApplyPoll, u, time.Now())
+			if err != nil {
+				log.Printf("device %s apply poll error, %d queued for retry in %s: %v",
+					d.ID, len(writes.pending), writes.backoff, err)
+			} else if applied > 0 {
+				log.Printf("device %s applied %d queued polls", d.ID, applied)
+			}
+			if u.Slot != storage.PrimarySlot && u.State == "active" {
+				r.recordApp(ctx, u)
+			}
+		}
+		r.stats.recordWrites(d.ID, interval, &writes)
+
+		if update.State == "active" && update.AppID != "" {
+			r.recordApp(ctx, update)
+		}
+		r.checkBlocked(ctx, d, update, &blocked)
+
+		var secondary []storage.SecondaryApp
+		if result.State != "offline" {
+			for _, sec := range result.Secondary {
+				secondary = append(secondary, storage.SecondaryApp{
+					AppID:   sec.AppID,
+					AppName: NormalizeAppName(sec.AppID, sec.AppName, r.cfg.AppNames),
+				})
+			}
+		}
+		if err := r.store.ApplySecondary(ctx, d.ID, secondary, update.Timestamp); err != nil {
+			log.Printf("device %s apply secondary error: %v", d.ID, err)
 		}
 	}
 
@@ -70,3 +366,127 @@ func (r *Runner) runDevice(ctx context.Context, d config.DeviceConfig) {
 		}
 	}
 }
+
+// slotUpdates turns the named slots reported alongside primary into
+// updates for their own sessions, tracking which slots are open. A slot
+// that is no longer reported ends with the primary's state when that
+// isn't active, and as idle otherwise.
+func (r *Runner) slotUpdates(primary storage.PollUpdate, slots []SlotApp, open map[string]bool) []storage.PollUpdate {
+	if primary.State == "unknown" {
+		return nil
+	}
+
+	var out []storage.PollUpdate
+	seen := make(map[string]bool)
+	for _, sl := range slots {
+		if seen[sl.Slot] {
+			continue
+		}
+		seen[sl.Slot] = true
+		u := storage.PollUpdate{
+			DeviceID:  primary.DeviceID,
+			Slot:      sl.Slot,
+			AppID:     sl.AppID,
+			AppName:   NormalizeAppName(sl.AppID, sl.AppName, r.cfg.AppNames),
+			State:     "active",
+			Timestamp: primary.Timestamp,
+			Account:   sl.Account,
+			Title:     sl.Title,
+		}
+		if sl.AppName == "" || isIdleAppName(sl.AppName) {
+			u.State = "idle"
+		}
+		if u.State == "active" {
+			open[sl.Slot] = true
+		} else {
+			delete(open, sl.Slot)
+		}
+		out = append(out, u)
+	}
+
+	for slot := range open {
+		if seen[slot] {
+			continue
+		}
+		state, reason := primary.State, primary.IdleReason
+		if state == "active" {
+			state, reason = "idle", ""
+		}
+		out = append(out, storage.PollUpdate{
+			DeviceID:   primary.DeviceID,
+			Slot:       slot,
+			State:      state,
+			Timestamp:  primary.Timestamp,
+			IdleReason: reason,
+		})
+		delete(open, slot)
+	}
+	return out
+}
+
+// watchState queries the device's device-info and returns the state the
+// active update u should have under the watch signals policy, and whether
+// private listening is holding it active.
+func (r *Runner) watchState(ctx context.Context, poller *RokuPoller, u storage.PollUpdate) (string, bool) {
+	info, err := poller.PollDeviceInfo(ctx)
+	if err != nil {
+		log.Printf("device %s device-info poll error: %v", u.DeviceID, err)
+		return u.State, false
+	}
+	if r.cfg.WatchSignals.Headphones && info.HeadphonesConnected {
+		return u.State, true
+	}
+	if r.cfg.WatchSignals.DisplayOff && info.DisplayOff() {
+		return "display_off", false
+	}
+	return u.State, false
+}
+
+// pausedTooLong queries the device's media player and reports whether the
+// current app has been paused longer than the paused-media policy allows.
+func (r *Runner) pausedTooLong(ctx context.Context, poller *RokuPoller, paused *pausedTracker, u storage.PollUpdate) bool {
+	idleAfter, ok := r.cfg.PausedMedia.IdleAfter(u.AppID, u.AppName, r.categories.Categorize(u.AppID, u.AppName))
+	if !ok {
+		paused.observe(u.AppID, "", u.Timestamp, 0)
+		return false
+	}
+
+	media, err := poller.PollMedia(ctx)
+	if err != nil {
+		log.Printf("device %s media poll error: %v", u.DeviceID, err)
+		return false
+	}
+	return paused.observe(u.AppID, media, u.Timestamp, idleAfter)
+}
+
+// checkBlocked alerts and notifies the device when a blocklisted app comes
+// up, once per appearance.
+func (r *Runner) checkBlocked(ctx context.Context, d config.DeviceConfig, u storage.PollUpdate, reported *string) {
+	if u.State != "active" || !r.blocklist.Blocked(u.AppID, u.AppName) {
+		*reported = ""
+		return
+	}
+	if *reported == u.AppID {
+		return
+	}
+	*reported = u.AppID
+
+	a := alert.Alert{
+		Kind:     "blocked_app",
+		DeviceID: d.ID,
+		Message:  fmt.Sprintf("blocked app %s (%s) opened", u.AppName, u.AppID),
+		Time:     u.Timestamp,
+	}
+	if err := r.notifier.Notify(ctx, a); err != nil {
+		log.Printf("device %s notify error: %v", d.ID, err)
+	}
+
+	n := enforce.Notification{
+		Title:   "Screen time",
+		Message: fmt.Sprintf("%s is not allowed.", u.AppName),
+		Urgent:  true,
+	}
+	if err := enforce.NotifyDevice(ctx, r.client, d, n); err != nil {
+		log.Printf("device %s notify error: %v", d.ID, err)
+	}
+}
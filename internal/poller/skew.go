@@ -0,0 +1,13 @@
+package poller
+
+import "time"
+
+// DefaultMaxClockSkew is used when the config does not set max_clock_skew_seconds.
+const DefaultMaxClockSkew = 2 * time.Minute
+
+// ClockSkew returns how far an agent's clock is ahead of hub time, or
+// behind it when negative. Polled sessions are recorded at hub time, so
+// the skew is only measured, to flag a drifting agent clock.
+func ClockSkew(agentTime, hubNow time.Time) time.Duration {
+	return agentTime.Sub(hubNow)
+}
@@ -0,0 +1,29 @@
+package poller
+
+import "time"
+
+// startupTracker reports a device as "unknown" rather than "offline" until
+// it first answers or the startup grace period runs out, so a hub that boots
+// before the network (say, after a power cut) doesn't record a phantom
+// offline stretch. The deadline uses the monotonic clock because the wall
+// clock often jumps right after boot.
+type startupTracker struct {
+	deadline time.Time
+	seen     bool
+}
+
+func newStartupTracker(grace time.Duration) *startupTracker {
+	return &startupTracker{deadline: time.Now().Add(grace)}
+}
+
+// observe returns the state to record for a poll result.
+func (t *startupTracker) observe(state string) string {
+	if state != "offline" {
+		t.seen = true
+		return state
+	}
+	if !t.seen && time.Now().Before(t.deadline) {
+		return "unknown"
+	}
+	return state
+}
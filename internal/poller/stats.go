@@ -0,0 +1,271 @@
+package poller
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyWindow is the number of recent poll latencies kept per device for percentiles.
+const latencyWindow = 100
+
+// slowPollStreak is how many consecutive over-interval polls trigger a warning.
+const slowPollStreak = 5
+
+// PollerStats is a snapshot of the poll health of a single device.
+type PollerStats struct {
+	DeviceID     string
+	Interval     time.Duration
+	Polls        int64
+	Errors       int64
+	LastPollTime time.Time
+	LastLatency  time.Duration
+	LatencySum   time.Duration
+	P50          time.Duration
+	P90          time.Duration
+	P99          time.Duration
+	SlowStreak   int
+	// Slow is set once SlowStreak reaches the streak that logs a warning.
+	Slow         bool
+	ClockSkew    time.Duration
+	SkewExceeded bool
+	// LastHeartbeat is the last time the device answered any request.
+	LastHeartbeat time.Time
+	AgentSilent   bool
+	// Failure is the cause of the current failure streak, nil when the
+	// last poll succeeded; FailingSince is when that streak began.
+	Failure      *PollError
+	FailingSince time.Time
+	// FailuresByKind counts every failed poll by cause.
+	FailuresByKind map[ErrorKind]int64
+	// Unknown is set while the device hasn't answered since hub startup
+	// and the startup grace period is still running.
+	Unknown bool
+	// PendingWrites is the number of polls waiting to be stored after a
+	// storage error; DroppedWrites counts those lost to a full queue.
+	PendingWrites int
+	DroppedWrites int64
+	// StalePolls counts polls left out for being older than the session
+	// they would have changed.
+	StalePolls int64
+}
+
+type deviceStats struct {
+	interval    time.Duration
+	polls       int64
+	errors      int64
+	lastPoll    time.Time
+	lastLatency time.Duration
+	latencySum  time.Duration
+	latencies   []time.Duration // ring buffer of the last latencyWindow samples
+	next        int
+	slowStreak  int
+	clockSkew   time.Duration
+	skewOver    bool
+	heartbeat   time.Time
+	silent      bool
+	failure     *PollError
+	failSince   time.Time
+	failures    map[ErrorKind]int64
+	unknown     bool
+	pending     int
+	dropped     int64
+	stale       int64
+}
+
+type statsRegistry struct {
+	mu      sync.Mutex
+	devices map[string]*deviceStats
+}
+
+func newStatsRegistry() *statsRegistry {
+	return &statsRegistry{devices: make(map[string]*deviceStats)}
+}
+
+func (r *statsRegistry) get(deviceID string, interval time.Duration) *deviceStats {
+	ds, ok := r.devices[deviceID]
+	if !ok {
+		ds = &deviceStats{interval: interval}
+		r.devices[deviceID] = ds
+	}
+	return ds
+}
+
+// record stores a poll latency and returns true when the device has just crossed
+// the slow-poll threshold.
+func (r *statsRegistry) record(deviceID string, interval time.Duration, at time.Time, latency time.Duration, failed bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ds := r.get(deviceID, interval)
+	ds.polls++
+	if failed {
+		ds.errors++
+	}
+	ds.lastPoll = at
+	ds.lastLatency = latency
+	ds.latencySum += latency
+
+	if len(ds.latencies) < latencyWindow {
+		ds.latencies = append(ds.latencies, latency)
+	} else {
+		ds.latencies[ds.next] = latency
+		ds.next = (ds.next + 1) % latencyWindow
+	}
+
+	if latency > interval {
+		ds.slowStreak++
+		return ds.slowStreak == slowPollStreak
+	}
+	ds.slowStreak = 0
+	return false
+}
+
+// recordSkew stores the latest clock skew for a device and returns true when
+// the device has just moved beyond tolerance.
+func (r *statsRegistry) recordSkew(deviceID string, interval, skew, tolerance time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ds := r.get(deviceID, interval)
+	ds.clockSkew = skew
+	over := skew > tolerance || skew < -tolerance
+	crossed := over && !ds.skewOver
+	ds.skewOver = over
+	return crossed
+}
+
+// recordFailure stores the outcome of a poll; f is nil on success. A streak
+// keeps its start time as long as the kind of failure doesn't change.
+func (r *statsRegistry) recordFailure(deviceID string, interval time.Duration, at time.Time, f *PollError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ds := r.get(deviceID, interval)
+	if f == nil {
+		ds.failure = nil
+		ds.failSince = time.Time{}
+		return
+	}
+	if ds.failure == nil || ds.failure.Kind != f.Kind {
+		ds.failSince = at
+	}
+	ds.failure = f
+	if ds.failures == nil {
+		ds.failures = make(map[ErrorKind]int64)
+	}
+	ds.failures[f.Kind]++
+}
+
+func (r *statsRegistry) recordUnknown(deviceID string, interval time.Duration, unknown bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.get(deviceID, interval).unknown = unknown
+}
+
+func (r *statsRegistry) recordWrites(deviceID string, interval time.Duration, q *writeQueue) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ds := r.get(deviceID, interval)
+	ds.pending, ds.dropped, ds.stale = len(q.pending), q.dropped, q.stale
+}
+
+func (r *statsRegistry) recordHeartbeat(deviceID string, interval time.Duration, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ds := r.get(deviceID, interval)
+	if at.After(ds.heartbeat) {
+		ds.heartbeat = at
+	}
+}
+
+// checkSilent updates the silent flag for a device and reports whether it
+// changed, along with the last heartbeat time (zero if never heard from).
+// Silence is measured from the last heartbeat or since, whichever is
+// later, so a device is given the full timeout after hub start or being
+// re-enabled.
+func (r *statsRegistry) checkSilent(deviceID string, interval time.Duration, now, since time.Time, timeout time.Duration) (silent, changed bool, last time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ds := r.get(deviceID, interval)
+	ref := ds.heartbeat
+	if ref.Before(since) {
+		ref = since
+	}
+	silent = now.Sub(ref) > timeout
+	changed = silent != ds.silent
+	ds.silent = silent
+	return silent, changed, ds.heartbeat
+}
+
+// clearSilent forgets that a device was silent, without it having
+// recovered, for a device that was disabled.
+func (r *statsRegistry) clearSilent(deviceID string, interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.get(deviceID, interval).silent = false
+}
+
+func (r *statsRegistry) snapshot() []PollerStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]PollerStats, 0, len(r.devices))
+	for id, ds := range r.devices {
+		sorted := append([]time.Duration(nil), ds.latencies...)
+		failures := make(map[ErrorKind]int64, len(ds.failures))
+		for k, v := range ds.failures {
+			failures[k] = v
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		out = append(out, PollerStats{
+			DeviceID:      id,
+			Interval:      ds.interval,
+			Polls:         ds.polls,
+			Errors:        ds.errors,
+			LastPollTime:  ds.lastPoll,
+			LastLatency:   ds.lastLatency,
+			LatencySum:    ds.latencySum,
+			P50:           percentile(sorted, 0.50),
+			P90:           percentile(sorted, 0.90),
+			P99:           percentile(sorted, 0.99),
+			SlowStreak:    ds.slowStreak,
+			Slow:          ds.slowStreak >= slowPollStreak,
+			ClockSkew:     ds.clockSkew,
+			SkewExceeded:  ds.skewOver,
+			LastHeartbeat: ds.heartbeat,
+			AgentSilent:   ds.silent,
+
+			Failure:        ds.failure,
+			FailingSince:   ds.failSince,
+			FailuresByKind: failures,
+			Unknown:        ds.unknown,
+			PendingWrites:  ds.pending,
+			DroppedWrites:  ds.dropped,
+			StalePolls:     ds.stale,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DeviceID < out[j].DeviceID })
+	return out
+}
+
+// percentile returns the nearest-rank percentile of an ascending slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
@@ -0,0 +1,184 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/cron"
+)
+
+// attachment is one rendered report file.
+type attachment struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// Scheduler renders and delivers reports on the configured cron schedule.
+type Scheduler struct {
+	cfg      *config.Config
+	builder  *Builder
+	schedule *cron.Schedule
+	loc      *time.Location
+}
+
+// NewScheduler creates a scheduler for cfg.Reports, which must have a
+// schedule set.
+func NewScheduler(cfg *config.Config, builder *Builder, loc *time.Location) (*Scheduler, error) {
+	schedule, err := cron.Parse(cfg.Reports.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("parse report schedule: %w", err)
+	}
+	return &Scheduler{cfg: cfg, builder: builder, schedule: schedule, loc: loc}, nil
+}
+
+// Run waits for each scheduled time and delivers reports until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		next := s.schedule.Next(time.Now().In(s.loc))
+		if next.IsZero() {
+			log.Printf("reports: schedule %q never fires", s.schedule)
+			return
+		}
+		log.Printf("reports: next run at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// RunOnce has logged each failure already
+		s.RunOnce(ctx, next)
+	}
+}
+
+// RunOnce builds every user's report for the last tracking week to end
+// before now and delivers it. A user whose report fails is logged and
+// skipped, so one bad address doesn't hold back everyone else's; the
+// failures are returned together.
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) error {
+	end := s.cfg.WeekStart(now.In(s.loc))
+
+	var errs []error
+	for _, u := range s.builder.Users() {
+		if err := s.deliver(ctx, u, end); err != nil {
+			log.Printf("reports: %v", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver builds u's report for the week ending at end and delivers it.
+func (s *Scheduler) deliver(ctx context.Context, u config.UserConfig, end time.Time) error {
+	w, err := s.builder.Build(ctx, u, end)
+	if err != nil {
+		return fmt.Errorf("build report for %s: %w", u.ID, err)
+	}
+
+	var files []attachment
+	base := fmt.Sprintf("screentime-%s-%s", u.ID, w.Start.Format("2006-01-02"))
+	for _, format := range s.cfg.Reports.Formats {
+		switch format {
+		case "html":
+			data, err := RenderHTML(w)
+			if err != nil {
+				return fmt.Errorf("render report for %s: %w", u.ID, err)
+			}
+			files = append(files, attachment{base + ".html", "text/html; charset=utf-8", data})
+		case "pdf":
+			data, err := RenderPDF(w)
+			if err != nil {
+				return fmt.Errorf("render report for %s: %w", u.ID, err)
+			}
+			files = append(files, attachment{base + ".pdf", "application/pdf", data})
+		}
+	}
+
+	if dir := s.cfg.Reports.OutputDir; dir != "" {
+		if err := writeFiles(dir, files); err != nil {
+			return fmt.Errorf("write report for %s: %w", u.ID, err)
+		}
+	}
+	if e := s.cfg.Reports.Email; e != nil {
+		subject := fmt.Sprintf("Screen time for %s, week of %s", w.Name, w.Format.Date(w.Start))
+		body := strings.Join(Lines(w), "\r\n")
+		if err := sendEmail(e, subject, body, files); err != nil {
+			return fmt.Errorf("email report for %s: %w", u.ID, err)
+		}
+	}
+	log.Printf("reports: delivered %s for %s", base, u.ID)
+	return nil
+}
+
+func writeFiles(dir string, files []attachment) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create report dir: %w", err)
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), f.data, 0o644); err != nil {
+			return fmt.Errorf("write report: %w", err)
+		}
+	}
+	return nil
+}
+
+// sendEmail sends a multipart message with the rendered reports attached.
+func sendEmail(e *config.EmailConfig, subject, body string, files []attachment) error {
+	const boundary = "screentime-report-boundary"
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&msg, "--%s\r\n", boundary)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(body)
+	msg.WriteString("\r\n")
+
+	for _, f := range files {
+		fmt.Fprintf(&msg, "--%s\r\n", boundary)
+		fmt.Fprintf(&msg, "Content-Type: %s\r\n", f.contentType)
+		msg.WriteString("Content-Transfer-Encoding: base64\r\n")
+		fmt.Fprintf(&msg, "Content-Disposition: attachment; filename=%q\r\n\r\n", f.name)
+		encoded := base64.StdEncoding.EncodeToString(f.data)
+		for len(encoded) > 76 {
+			msg.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		msg.WriteString(encoded + "\r\n")
+	}
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.SMTPAddr)
+		if err != nil {
+			return fmt.Errorf("parse smtp_addr: %w", err)
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	if err := smtp.SendMail(e.SMTPAddr, auth, e.From, e.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("send mail: %w", err)
+	}
+	return nil
+}
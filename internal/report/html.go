@@ -0,0 +1,81 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+)
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"change": Change,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{printf .Format.Labels.ScreenTimeFor .Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: .25em .5em; border-bottom: 1px solid #ddd; }
+td.num, th.num { text-align: right; }
+.over { color: #b00; }
+.swatch { display: inline-block; width: .8em; height: .8em; margin-right: .4em; border-radius: 2px; }
+</style>
+</head>
+<body>
+<h1>{{printf .Format.Labels.ScreenTimeFor .Name}}</h1>
+<p>{{.Format.Day .Start}} &ndash; {{.Format.Day .LastDay}}</p>
+<p><strong>{{printf .Format.Labels.Total (.Format.Duration .TotalSeconds)}}</strong> ({{printf .Format.Labels.VsPreviousWeek (change .TotalSeconds .PreviousTotalSeconds)}})</p>
+
+<h2>{{.Format.Labels.ByDay}}</h2>
+<table>
+<tr><th>Day</th><th class="num">Time</th></tr>
+{{range .Days}}<tr><td>{{$.Format.Day .Start}}</td><td class="num">{{$.Format.Duration .TotalSeconds}}</td></tr>
+{{end}}</table>
+
+<h2>{{.Format.Labels.Categories}}</h2>
+<table>
+<tr><th>Category</th><th class="num">Time</th><th class="num">Trend</th></tr>
+{{range .Categories}}<tr><td><span class="swatch" style="background: {{.Color}}"></span>{{with .Icon}}{{.}} {{end}}{{.Category}}</td><td class="num">{{$.Format.Duration .Seconds}}</td><td class="num">{{change .Seconds .PreviousSeconds}}</td></tr>
+{{end}}</table>
+
+<h2>{{.Format.Labels.TopApps}}</h2>
+<table>
+<tr><th>App</th><th class="num">Time</th></tr>
+{{range .TopApps}}<tr><td>{{.AppName}}</td><td class="num">{{$.Format.Duration .Seconds}}</td></tr>
+{{end}}</table>
+{{if .Compliance}}
+<h2>Limits</h2>
+<table>
+<tr><th>Category</th><th class="num">Daily limit</th><th class="num">Days within</th><th class="num">Days over</th></tr>
+{{range .Compliance}}<tr><td>{{.Category}}</td><td class="num">{{.LimitMinutes}}m</td><td class="num">{{.DaysWithin}}</td><td class="num{{if .DaysOver}} over{{end}}">{{.DaysOver}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Exceptions}}
+<h2>Approved exceptions</h2>
+<p>Not counted toward limits.</p>
+<table>
+<tr><th>Day</th><th>Label</th><th>App</th><th class="num">Time</th></tr>
+{{range .Exceptions}}<tr><td>{{$.Format.Day .Start}}</td><td>{{.Label}}</td><td>{{.AppName}}</td><td class="num">{{$.Format.Duration .Seconds}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Uncategorized}}
+<h2>Uncategorized apps</h2>
+<p>No category matches these; assign them one to keep the totals above meaningful.</p>
+<table>
+<tr><th>App</th><th class="num">Time</th><th class="num">Days over</th></tr>
+{{range .Uncategorized}}<tr><td>{{.AppName}}</td><td class="num">{{$.Format.Duration .Seconds}}</td><td class="num">{{.Days}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// RenderHTML renders the report as a standalone HTML page.
+func RenderHTML(w *Weekly) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, w); err != nil {
+		return nil, fmt.Errorf("render html report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
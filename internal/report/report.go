@@ -0,0 +1,311 @@
+// Package report builds and renders the weekly per-user screen time report.
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"screentime-agent/internal/category"
+	"screentime-agent/internal/chart"
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/locale"
+	"screentime-agent/internal/storage"
+)
+
+// topApps is how many apps the report lists.
+const topApps = 10
+
+// Weekly is one user's report for the seven tracking days ending at End.
+type Weekly struct {
+	UserID string
+	Name   string
+	Start  time.Time
+	End    time.Time
+
+	TotalSeconds         int64
+	PreviousTotalSeconds int64 // the week before, for trends
+
+	Days       []Day
+	Categories []CategoryTotal
+	TopApps    []AppTotal
+	Compliance []Compliance
+
+	// Exceptions are sessions a parent approved; they're included in the
+	// totals above but not in Compliance.
+	Exceptions []Exception
+
+	// Uncategorized lists the apps no category matches that went over
+	// alerts.uncategorized_minutes on a day of the week, when set.
+	Uncategorized []UncategorizedApp
+
+	// Format writes the report's durations and dates in the configured
+	// locale.
+	Format locale.Formatter
+}
+
+// Exception is one approved exception session.
+type Exception struct {
+	Start   time.Time
+	AppName string
+	Label   string
+	Seconds int64
+}
+
+// Day is a single tracking day in the report.
+type Day struct {
+	Start        time.Time
+	TotalSeconds int64
+}
+
+// CategoryTotal compares a category's usage with the previous week.
+type CategoryTotal struct {
+	Category        string
+	Seconds         int64
+	PreviousSeconds int64
+	// Color is the category's configured "#rrggbb" color, or the one
+	// charts give it when none is set, and Icon its configured icon.
+	Color string
+	Icon  string
+	// Days holds the category's seconds per report day, aligned with
+	// Weekly.Days.
+	Days []int64
+}
+
+// AppTotal is usage of one app across the user's devices.
+type AppTotal struct {
+	AppID   string
+	AppName string
+	Seconds int64
+}
+
+// Compliance counts the days a category stayed within its daily limit.
+type Compliance struct {
+	Category     string
+	LimitMinutes int
+	DaysWithin   int
+	DaysOver     int
+}
+
+// Builder gathers report data from the store.
+type Builder struct {
+	cfg        *config.Config
+	store      *storage.SessionStore
+	categories *category.Categorizer
+	displays   func() map[string]config.CategoryDisplay
+}
+
+// NewBuilder creates a report builder.
+func NewBuilder(cfg *config.Config, store *storage.SessionStore) *Builder {
+	return &Builder{
+		cfg:        cfg,
+		store:      store,
+		categories: category.New(cfg.Categories, store.AppCategory),
+	}
+}
+
+// Users returns the people reports are built for. Without configured users
+// the whole household is reported as one.
+func (b *Builder) Users() []config.UserConfig {
+	if len(b.cfg.Users) > 0 {
+		return b.cfg.Users
+	}
+	all := config.UserConfig{ID: "household", Name: "Household"}
+	for _, d := range b.cfg.Devices {
+		all.Devices = append(all.Devices, d.ID)
+	}
+	return []config.UserConfig{all}
+}
+
+// DisplayWith makes reports draw categories with the colors and icons fn
+// returns, such as the poller's, which add those agents report to the
+// config's. Without it only the config's are used.
+func (b *Builder) DisplayWith(fn func() map[string]config.CategoryDisplay) {
+	b.displays = fn
+}
+
+// categoryDisplays returns the colors and icons to draw categories with.
+func (b *Builder) categoryDisplays() map[string]config.CategoryDisplay {
+	if b.displays != nil {
+		return b.displays()
+	}
+	out := make(map[string]config.CategoryDisplay)
+	for name, c := range b.cfg.Categories {
+		out[name] = c.CategoryDisplay
+	}
+	return out
+}
+
+// Build assembles the report for the seven tracking days ending at end,
+// which should be a day start.
+func (b *Builder) Build(ctx context.Context, u config.UserConfig, end time.Time) (*Weekly, error) {
+	start := end.AddDate(0, 0, -7)
+	w := &Weekly{
+		UserID: u.ID,
+		Name:   u.Name,
+		Start:  start,
+		End:    end,
+		Format: b.cfg.Display.Formatter(),
+	}
+	if w.Name == "" {
+		w.Name = u.ID
+	}
+
+	goals := b.cfg.GoalsFor(u)
+	compliance := make(map[string]*Compliance)
+	for _, g := range goals {
+		if g.MaxMinutes > 0 {
+			compliance[g.Category] = &Compliance{Category: g.Category, LimitMinutes: g.MaxMinutes}
+		}
+	}
+
+	categories := make(map[string]*CategoryTotal)
+	apps := make(map[string]*AppTotal)
+	uncategorized := newUncategorizedTally(b.categories, b.cfg.Alerts.UncategorizedMinutes)
+
+	newCategory := func(cat string) *CategoryTotal {
+		return &CategoryTotal{Category: cat, Days: make([]int64, 7)}
+	}
+
+	for i, day := 0, start; day.Before(end); i, day = i+1, day.AddDate(0, 0, 1) {
+		entries, err := b.userUsage(ctx, u, day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+
+		d := Day{Start: day}
+		for _, e := range entries {
+			d.TotalSeconds += e.TotalSeconds
+
+			key := e.AppID + "\x00" + e.AppName
+			if apps[key] == nil {
+				apps[key] = &AppTotal{AppID: e.AppID, AppName: e.AppName}
+			}
+			apps[key].Seconds += e.TotalSeconds
+		}
+		w.Days = append(w.Days, d)
+		w.TotalSeconds += d.TotalSeconds
+		uncategorized.addDay(entries)
+
+		for cat, secs := range b.categories.Totals(entries) {
+			if categories[cat] == nil {
+				categories[cat] = newCategory(cat)
+			}
+			categories[cat].Seconds += secs
+			categories[cat].Days[i] = secs
+		}
+		counted := b.categories.LimitTotals(entries)
+		for cat, c := range compliance {
+			if counted[cat] > int64(c.LimitMinutes)*60 {
+				c.DaysOver++
+			} else {
+				c.DaysWithin++
+			}
+		}
+	}
+
+	prev, err := b.userUsage(ctx, u, start.AddDate(0, 0, -7), start)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range prev {
+		w.PreviousTotalSeconds += e.TotalSeconds
+	}
+	for cat, secs := range b.categories.Totals(prev) {
+		if categories[cat] == nil {
+			categories[cat] = newCategory(cat)
+		}
+		categories[cat].PreviousSeconds = secs
+	}
+
+	displays := b.categoryDisplays()
+	for _, c := range categories {
+		d := displays[c.Category]
+		c.Icon = d.Icon
+		if col, ok := chart.ParseColor(d.Color); ok {
+			c.Color = chart.Hex(col)
+		} else {
+			c.Color = chart.Hex(chart.PaletteColor(c.Category))
+		}
+		w.Categories = append(w.Categories, *c)
+	}
+	sort.Slice(w.Categories, func(i, j int) bool {
+		if w.Categories[i].Seconds != w.Categories[j].Seconds {
+			return w.Categories[i].Seconds > w.Categories[j].Seconds
+		}
+		return w.Categories[i].Category < w.Categories[j].Category
+	})
+
+	for _, a := range apps {
+		w.TopApps = append(w.TopApps, *a)
+	}
+	sort.Slice(w.TopApps, func(i, j int) bool {
+		if w.TopApps[i].Seconds != w.TopApps[j].Seconds {
+			return w.TopApps[i].Seconds > w.TopApps[j].Seconds
+		}
+		return w.TopApps[i].AppName < w.TopApps[j].AppName
+	})
+	if len(w.TopApps) > topApps {
+		w.TopApps = w.TopApps[:topApps]
+	}
+
+	for _, c := range compliance {
+		w.Compliance = append(w.Compliance, *c)
+	}
+	sort.Slice(w.Compliance, func(i, j int) bool { return w.Compliance[i].Category < w.Compliance[j].Category })
+
+	if b.cfg.Alerts.UncategorizedMinutes > 0 {
+		w.Uncategorized = uncategorized.result()
+	}
+
+	exceptions, err := b.store.GetExceptions(ctx, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("get exceptions for %s: %w", u.ID, err)
+	}
+	for _, e := range exceptions {
+		if !u.HasDevice(e.DeviceID) {
+			continue
+		}
+		w.Exceptions = append(w.Exceptions, Exception{
+			Start:   e.StartTime.In(start.Location()),
+			AppName: e.AppName,
+			Label:   e.ExceptionLabel,
+			Seconds: e.DurationSecs,
+		})
+	}
+
+	return w, nil
+}
+
+// LastDay returns the start of the final day covered by the report.
+func (w *Weekly) LastDay() time.Time {
+	return w.End.AddDate(0, 0, -1)
+}
+
+func (b *Builder) userUsage(ctx context.Context, u config.UserConfig, start, end time.Time) ([]storage.UsageEntry, error) {
+	entries, err := b.store.GetUsageBetween(ctx, start.UTC(), end.UTC(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get usage for %s: %w", u.ID, err)
+	}
+	mine := entries[:0]
+	for _, e := range entries {
+		if b.cfg.Attributed(u, e.DeviceID, e.Account) {
+			mine = append(mine, e)
+		}
+	}
+	return mine, nil
+}
+
+// Change describes the trend from previous to current as a signed percentage,
+// or "new" when there was no previous usage.
+func Change(current, previous int64) string {
+	if previous == 0 {
+		if current == 0 {
+			return "-"
+		}
+		return "new"
+	}
+	pct := float64(current-previous) / float64(previous) * 100
+	return fmt.Sprintf("%+.0f%%", pct)
+}
@@ -0,0 +1,74 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Lines renders the report as plain text, one line per entry. It backs
+// both the PDF output and the email body.
+func Lines(w *Weekly) []string {
+	f, l := w.Format, w.Format.Labels()
+	change := fmt.Sprintf(l.VsPreviousWeek, Change(w.TotalSeconds, w.PreviousTotalSeconds))
+	lines := []string{
+		fmt.Sprintf(l.ScreenTimeFor, w.Name),
+		fmt.Sprintf("%s - %s", f.Day(w.Start), f.Day(w.LastDay())),
+		"",
+		fmt.Sprintf("%s (%s)", fmt.Sprintf(l.Total, f.Duration(w.TotalSeconds)), change),
+		"",
+		l.ByDay,
+	}
+	for _, d := range w.Days {
+		lines = append(lines, fmt.Sprintf("  %s %s", padRight(f.Day(d.Start), 12), padLeft(f.Duration(d.TotalSeconds), 10)))
+	}
+
+	lines = append(lines, "", l.Categories)
+	for _, c := range w.Categories {
+		lines = append(lines, fmt.Sprintf("  %s %s %s", padRight(c.Category, 20), padLeft(f.Duration(c.Seconds), 10), padLeft(Change(c.Seconds, c.PreviousSeconds), 6)))
+	}
+
+	lines = append(lines, "", l.TopApps)
+	for _, a := range w.TopApps {
+		lines = append(lines, fmt.Sprintf("  %s %s", padRight(a.AppName, 30), padLeft(f.Duration(a.Seconds), 10)))
+	}
+
+	if len(w.Compliance) > 0 {
+		lines = append(lines, "", "Limits")
+		for _, c := range w.Compliance {
+			lines = append(lines, fmt.Sprintf("  %s %4dm/day  within %d, over %d", padRight(c.Category, 20), c.LimitMinutes, c.DaysWithin, c.DaysOver))
+		}
+	}
+
+	if len(w.Exceptions) > 0 {
+		lines = append(lines, "", "Approved exceptions (not counted toward limits)")
+		for _, e := range w.Exceptions {
+			lines = append(lines, fmt.Sprintf("  %s %s %s %s", padRight(f.Day(e.Start), 10), padRight(e.Label, 20), padRight(e.AppName, 20), padLeft(f.Duration(e.Seconds), 8)))
+		}
+	}
+
+	if len(w.Uncategorized) > 0 {
+		lines = append(lines, "", "Uncategorized apps (assign them a category)")
+		for _, a := range w.Uncategorized {
+			lines = append(lines, fmt.Sprintf("  %s %s  days over: %d", padRight(a.AppName, 30), padLeft(f.Duration(a.Seconds), 10), a.Days))
+		}
+	}
+	return lines
+}
+
+// padRight and padLeft pad s with spaces to width characters, counting
+// runes rather than bytes as fmt's widths do, so names with accents line
+// up.
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+func padLeft(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return strings.Repeat(" ", width-n) + s
+	}
+	return s
+}
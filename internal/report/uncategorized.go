@@ -0,0 +1,168 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"time"
+
+	"screentime-agent/internal/alert"
+	"screentime-agent/internal/category"
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/storage"
+)
+
+// UncategorizedApp is an app or domain no category matches that was used
+// longer than the threshold on at least one day. Assigning it a category
+// through the API keeps the rules up to date with what is actually used.
+type UncategorizedApp struct {
+	AppID     string
+	AppName   string
+	DeviceIDs []string
+	// Seconds is the app's usage over the whole period, and Days the
+	// number of days it went over the threshold.
+	Seconds int64
+	Days    int
+}
+
+// uncategorizedTally collects UncategorizedApps a day at a time.
+type uncategorizedTally struct {
+	categories *category.Categorizer
+	minSeconds int64
+	apps       map[string]*UncategorizedApp
+	devices    map[string]map[string]bool
+}
+
+func newUncategorizedTally(categories *category.Categorizer, minMinutes int) *uncategorizedTally {
+	return &uncategorizedTally{
+		categories: categories,
+		minSeconds: int64(minMinutes) * 60,
+		apps:       make(map[string]*UncategorizedApp),
+		devices:    make(map[string]map[string]bool),
+	}
+}
+
+// addDay counts one day's usage entries.
+func (t *uncategorizedTally) addDay(entries []storage.UsageEntry) {
+	day := make(map[string]int64)
+	for _, e := range entries {
+		if t.categories.Categorize(e.AppID, e.AppName) != category.Uncategorized {
+			continue
+		}
+		day[e.AppID] += e.TotalSeconds
+
+		a := t.apps[e.AppID]
+		if a == nil {
+			a = &UncategorizedApp{AppID: e.AppID, AppName: e.AppName}
+			t.apps[e.AppID] = a
+			t.devices[e.AppID] = make(map[string]bool)
+		}
+		a.Seconds += e.TotalSeconds
+		t.devices[e.AppID][e.DeviceID] = true
+	}
+	for appID, secs := range day {
+		if secs > t.minSeconds {
+			t.apps[appID].Days++
+		}
+	}
+}
+
+// result returns the apps that went over the threshold, most used first.
+func (t *uncategorizedTally) result() []UncategorizedApp {
+	var out []UncategorizedApp
+	for appID, a := range t.apps {
+		if a.Days == 0 {
+			continue
+		}
+		for id := range t.devices[appID] {
+			a.DeviceIDs = append(a.DeviceIDs, id)
+		}
+		sort.Strings(a.DeviceIDs)
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Seconds != out[j].Seconds {
+			return out[i].Seconds > out[j].Seconds
+		}
+		return out[i].AppID < out[j].AppID
+	})
+	return out
+}
+
+// Uncategorized returns the uncategorized apps used on any device for
+// longer than minMinutes on a tracking day between start and end, which
+// should be day starts.
+func (b *Builder) Uncategorized(ctx context.Context, start, end time.Time, minMinutes int) ([]UncategorizedApp, error) {
+	tally := newUncategorizedTally(b.categories, minMinutes)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		entries, err := b.store.GetUsageBetween(ctx, day.UTC(), day.AddDate(0, 0, 1).UTC(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("get usage: %w", err)
+		}
+		tally.addDay(entries)
+	}
+	return tally.result(), nil
+}
+
+// UncategorizedAlerter alerts once a day, as each tracking day ends, about
+// the uncategorized apps used longer than alerts.uncategorized_minutes
+// that day.
+type UncategorizedAlerter struct {
+	cfg      *config.Config
+	builder  *Builder
+	notifier alert.Notifier
+	loc      *time.Location
+}
+
+// NewUncategorizedAlerter creates an alerter for cfg.Alerts.
+func NewUncategorizedAlerter(cfg *config.Config, builder *Builder, notifier alert.Notifier, loc *time.Location) *UncategorizedAlerter {
+	return &UncategorizedAlerter{cfg: cfg, builder: builder, notifier: notifier, loc: loc}
+}
+
+// Run waits for the end of each tracking day and alerts about it until ctx
+// is done.
+func (a *UncategorizedAlerter) Run(ctx context.Context) {
+	for {
+		next := a.cfg.NextDayStart(time.Now().In(a.loc))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := a.RunOnce(ctx, next); err != nil {
+			log.Printf("uncategorized alerts: %v", err)
+		}
+	}
+}
+
+// RunOnce alerts about the tracking day ending at end.
+func (a *UncategorizedAlerter) RunOnce(ctx context.Context, end time.Time) error {
+	start := end.AddDate(0, 0, -1)
+	apps, err := a.builder.Uncategorized(ctx, start, end, a.cfg.Alerts.UncategorizedMinutes)
+	if err != nil {
+		return err
+	}
+
+	f := a.cfg.Display.Formatter()
+	for _, app := range apps {
+		al := alert.Alert{
+			Kind:  "uncategorized_app",
+			AppID: app.AppID,
+			Message: fmt.Sprintf("%s (%s) was used for %s on %s without a category; assign one with PUT /v1/apps/%s/category",
+				app.AppName, app.AppID, f.Duration(app.Seconds), f.Day(start), url.PathEscape(app.AppID)),
+			Time: end,
+		}
+		if len(app.DeviceIDs) == 1 {
+			al.DeviceID = app.DeviceIDs[0]
+		}
+		if err := a.notifier.Notify(ctx, al); err != nil {
+			log.Printf("uncategorized alerts: notify about %s: %v", app.AppID, err)
+		}
+	}
+	return nil
+}
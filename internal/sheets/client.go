@@ -0,0 +1,73 @@
+// Package sheets appends screen time usage to a Google Sheet.
+package sheets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const apiBase = "https://sheets.googleapis.com/v4/spreadsheets/"
+
+// Client is a minimal Sheets API client authenticated as a service account.
+type Client struct {
+	http   *http.Client
+	tokens *tokenSource
+}
+
+// NewClient creates a client from a service account JSON key file.
+func NewClient(credentialsFile string) (*Client, error) {
+	hc := &http.Client{Timeout: 30 * time.Second}
+	tokens, err := loadServiceAccount(credentialsFile, hc)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{http: hc, tokens: tokens}, nil
+}
+
+// Append adds rows after the last row of data in the given sheet tab.
+// Values are interpreted as if typed by a user, so dates and numbers
+// become real spreadsheet values.
+func (c *Client) Append(ctx context.Context, spreadsheetID, sheet string, rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Quote the tab name so names with spaces work as an A1 range.
+	rng := "'" + strings.ReplaceAll(sheet, "'", "''") + "'!A1"
+	u := apiBase + url.PathEscape(spreadsheetID) + "/values/" + url.PathEscape(rng) +
+		":append?valueInputOption=USER_ENTERED&insertDataOption=INSERT_ROWS"
+
+	body, err := json.Marshal(map[string]any{"values": rows})
+	if err != nil {
+		return fmt.Errorf("marshal rows: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build append request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("append rows: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return fmt.Errorf("append rows: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package sheets
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"screentime-agent/internal/category"
+	"screentime-agent/internal/config"
+	"screentime-agent/internal/storage"
+)
+
+const (
+	// sessionBatch is how many sessions go into one append request.
+	sessionBatch = 500
+	// maxDaysPerSync bounds the first catch-up of a long history.
+	maxDaysPerSync = 60
+
+	dateFormat     = "2006-01-02"
+	dateTimeFormat = "2006-01-02 15:04:05"
+)
+
+var (
+	sessionHeader = []any{"Date", "Device", "App", "Category", "Start", "End", "Minutes", "End reason"}
+	dailyHeader   = []any{"Date", "Device", "Category", "Minutes"}
+)
+
+// Exporter appends new usage to the configured sheet. Progress is kept in
+// the database so each session or day is written once, even across
+// restarts.
+type Exporter struct {
+	cfg        *config.Config
+	sheet      *config.SheetsConfig
+	store      *storage.SessionStore
+	client     *Client
+	categories *category.Categorizer
+	loc        *time.Location
+}
+
+// NewExporter creates an exporter for cfg.Sheets, which must be set.
+func NewExporter(cfg *config.Config, store *storage.SessionStore, loc *time.Location) (*Exporter, error) {
+	client, err := NewClient(cfg.Sheets.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("create sheets client: %w", err)
+	}
+	return &Exporter{
+		cfg:        cfg,
+		sheet:      cfg.Sheets,
+		store:      store,
+		client:     client,
+		categories: category.New(cfg.Categories, store.AppCategory),
+		loc:        loc,
+	}, nil
+}
+
+// Run syncs immediately and then every interval until ctx is done.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(e.sheet.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Sync(ctx, time.Now()); err != nil {
+			log.Printf("sheets: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync appends everything not yet exported as of now.
+func (e *Exporter) Sync(ctx context.Context, now time.Time) error {
+	if e.sheet.Mode == "daily" {
+		return e.syncDaily(ctx, now)
+	}
+	return e.syncSessions(ctx)
+}
+
+// cursorName is per spreadsheet and tab, so pointing the exporter at a new
+// sheet starts it from the beginning.
+func (e *Exporter) cursorName() string {
+	return fmt.Sprintf("sheets:%s:%s:%s", e.sheet.Mode, e.sheet.SpreadsheetID, e.sheet.Sheet)
+}
+
+func (e *Exporter) syncSessions(ctx context.Context) error {
+	name := e.cursorName()
+	cursor, err := e.store.GetExportCursor(ctx, name)
+	if err != nil {
+		return err
+	}
+	var lastID int64
+	if cursor != "" {
+		if lastID, err = strconv.ParseInt(cursor, 10, 64); err != nil {
+			return fmt.Errorf("parse cursor %q: %w", cursor, err)
+		}
+	}
+
+	for {
+		sessions, err := e.store.GetSessionsAfterID(ctx, lastID, sessionBatch)
+		if err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			return nil
+		}
+
+		var rows [][]any
+		if lastID == 0 {
+			rows = append(rows, sessionHeader)
+		}
+		for _, se := range sessions {
+			start := se.StartTime.In(e.loc)
+			rows = append(rows, []any{
+				e.cfg.DayStart(start).Format(dateFormat),
+				se.DeviceID,
+				se.AppName,
+				e.categories.Categorize(se.AppID, se.AppName),
+				start.Format(dateTimeFormat),
+				se.EndTime.In(e.loc).Format(dateTimeFormat),
+				minutes(se.DurationSecs),
+				se.EndReason,
+			})
+		}
+		if err := e.client.Append(ctx, e.sheet.SpreadsheetID, e.sheet.Sheet, rows); err != nil {
+			return err
+		}
+
+		lastID = sessions[len(sessions)-1].ID
+		if err := e.store.SetExportCursor(ctx, name, strconv.FormatInt(lastID, 10)); err != nil {
+			return err
+		}
+		log.Printf("sheets: appended %d sessions", len(sessions))
+		if len(sessions) < sessionBatch {
+			return nil
+		}
+	}
+}
+
+// syncDaily appends one row per device and category for every tracking day
+// that has finished since the last sync.
+func (e *Exporter) syncDaily(ctx context.Context, now time.Time) error {
+	name := e.cursorName()
+	cursor, err := e.store.GetExportCursor(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	var day time.Time
+	if cursor != "" {
+		last, err := time.ParseInLocation(dateFormat, cursor, e.loc)
+		if err != nil {
+			return fmt.Errorf("parse cursor %q: %w", cursor, err)
+		}
+		day = time.Date(last.Year(), last.Month(), last.Day()+1, e.cfg.DayStartHour, 0, 0, 0, e.loc)
+	} else {
+		first, err := e.store.FirstSessionStart(ctx)
+		if err != nil {
+			return err
+		}
+		if first.IsZero() {
+			return nil
+		}
+		day = e.cfg.DayStart(first.In(e.loc))
+	}
+
+	today := e.cfg.DayStart(now.In(e.loc))
+	var rows [][]any
+	if cursor == "" {
+		rows = append(rows, dailyHeader)
+	}
+	var lastDay time.Time
+	for n := 0; day.Before(today) && n < maxDaysPerSync; n++ {
+		next := day.AddDate(0, 0, 1)
+		entries, err := e.store.GetUsageBetween(ctx, day, next, nil)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, e.dailyRows(day, entries)...)
+		lastDay = day
+		day = next
+	}
+	if lastDay.IsZero() {
+		return nil
+	}
+
+	if err := e.client.Append(ctx, e.sheet.SpreadsheetID, e.sheet.Sheet, rows); err != nil {
+		return err
+	}
+	if err := e.store.SetExportCursor(ctx, name, lastDay.Format(dateFormat)); err != nil {
+		return err
+	}
+	log.Printf("sheets: appended daily totals through %s", lastDay.Format(dateFormat))
+	return nil
+}
+
+func (e *Exporter) dailyRows(day time.Time, entries []storage.UsageEntry) [][]any {
+	type key struct{ device, category string }
+	totals := make(map[key]int64)
+	for _, u := range entries {
+		totals[key{u.DeviceID, e.categories.Categorize(u.AppID, u.AppName)}] += u.TotalSeconds
+	}
+
+	keys := make([]key, 0, len(totals))
+	for k := range totals {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].device != keys[j].device {
+			return keys[i].device < keys[j].device
+		}
+		return keys[i].category < keys[j].category
+	})
+
+	rows := make([][]any, 0, len(keys))
+	for _, k := range keys {
+		rows = append(rows, []any{day.Format(dateFormat), k.device, k.category, minutes(totals[k])})
+	}
+	return rows
+}
+
+// minutes rounds to a tenth of a minute, which is plenty for a spreadsheet.
+func minutes(secs int64) float64 {
+	return math.Round(float64(secs)/6) / 10
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LoadAppCategories reads the assigned app categories into the cache
+// AppCategory answers from. The hub calls it once at startup.
+func (s *SessionStore) LoadAppCategories(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT app_id, category FROM app_categories`)
+	if err != nil {
+		return fmt.Errorf("query app categories: %w", err)
+	}
+	defer rows.Close()
+
+	m := make(map[string]string)
+	for rows.Next() {
+		var appID, category string
+		if err := rows.Scan(&appID, &category); err != nil {
+			return fmt.Errorf("scan app category: %w", err)
+		}
+		m[appID] = category
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate app categories: %w", err)
+	}
+
+	s.appCategoriesMu.Lock()
+	s.appCategories = m
+	s.appCategoriesMu.Unlock()
+	return nil
+}
+
+// AppCategory returns the category assigned to an app through the API, if
+// any. It reads the cache loaded by LoadAppCategories.
+func (s *SessionStore) AppCategory(appID string) (string, bool) {
+	s.appCategoriesMu.RLock()
+	defer s.appCategoriesMu.RUnlock()
+	category, ok := s.appCategories[appID]
+	return category, ok
+}
+
+// SetAppCategory assigns category to an app on every device, overriding
+// the config's rules. An empty category removes the assignment. Sessions
+// already stored keep their category until RecategorizeApp is run.
+func (s *SessionStore) SetAppCategory(ctx context.Context, appID, category string, now time.Time) error {
+	if category == "" {
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM app_categories WHERE app_id = ?`, appID); err != nil {
+			return fmt.Errorf("delete app category: %w", err)
+		}
+	} else if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO app_categories (app_id, category, assigned_at) VALUES (?, ?, ?)
+		ON CONFLICT(app_id) DO UPDATE SET category = excluded.category, assigned_at = excluded.assigned_at`,
+		appID, category, now.UTC(),
+	); err != nil {
+		return fmt.Errorf("upsert app category: %w", err)
+	}
+
+	s.appCategoriesMu.Lock()
+	defer s.appCategoriesMu.Unlock()
+	if s.appCategories == nil {
+		s.appCategories = make(map[string]string)
+	}
+	if category == "" {
+		delete(s.appCategories, appID)
+	} else {
+		s.appCategories[appID] = category
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// App is an app observed on a device, with lifetime totals.
+type App struct {
+	DeviceID     string
+	AppID        string
+	AppName      string
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	TotalSeconds int64
+}
+
+// AppName is one name an app has been seen under on a device.
+type AppName struct {
+	AppName   string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// RecordAppSeen notes that an app was active on a device at t and reports
+// whether this is the first time the app has been seen there. The apps row
+// always carries the latest name, which usage reports display; every name
+// is kept in app_names.
+func (s *SessionStore) RecordAppSeen(ctx context.Context, deviceID, appID, appName string, t time.Time) (bool, error) {
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO app_names (device_id, app_id, app_name, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(device_id, app_id, app_name) DO UPDATE SET last_seen = excluded.last_seen`,
+		deviceID, appID, appName, t, t,
+	); err != nil {
+		return false, fmt.Errorf("upsert app name: %w", err)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO apps (device_id, app_id, app_name, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(device_id, app_id) DO NOTHING`,
+		deviceID, appID, appName, t, t,
+	)
+	if err != nil {
+		return false, fmt.Errorf("insert app: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("insert app rows affected: %w", err)
+	}
+	if n > 0 {
+		return true, nil
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE apps SET app_name = ?, last_seen = ?
+		WHERE device_id = ? AND app_id = ?`,
+		appName, t, deviceID, appID,
+	); err != nil {
+		return false, fmt.Errorf("update app: %w", err)
+	}
+	return false, nil
+}
+
+// GetApps returns every app seen, optionally for one device, with total
+// usage from closed sessions.
+func (s *SessionStore) GetApps(ctx context.Context, deviceID *string) ([]App, error) {
+	q := `
+		SELECT a.device_id, a.app_id, a.app_name, a.first_seen, a.last_seen,
+			COALESCE(SUM(s.duration_seconds), 0)
+		FROM apps a
+		LEFT JOIN sessions s ON s.device_id = a.device_id AND s.app_id = a.app_id`
+	var args []any
+	if deviceID != nil {
+		q += " WHERE a.device_id = ?"
+		args = append(args, *deviceID)
+	}
+	q += `
+		GROUP BY a.device_id, a.app_id
+		ORDER BY a.first_seen DESC`
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query apps: %w", err)
+	}
+	defer rows.Close()
+
+	var out []App
+	for rows.Next() {
+		var a App
+		if err := rows.Scan(&a.DeviceID, &a.AppID, &a.AppName, &a.FirstSeen, &a.LastSeen, &a.TotalSeconds); err != nil {
+			return nil, fmt.Errorf("scan app: %w", err)
+		}
+		out = append(out, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate apps: %w", err)
+	}
+	return out, nil
+}
+
+// GetAppNames returns every name an app has been seen under on a device,
+// most recent first.
+func (s *SessionStore) GetAppNames(ctx context.Context, deviceID, appID string) ([]AppName, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT app_name, first_seen, last_seen
+		FROM app_names
+		WHERE device_id = ? AND app_id = ?
+		ORDER BY last_seen DESC`, deviceID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("query app names: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AppName
+	for rows.Next() {
+		var n AppName
+		if err := rows.Scan(&n.AppName, &n.FirstSeen, &n.LastSeen); err != nil {
+			return nil, fmt.Errorf("scan app name: %w", err)
+		}
+		out = append(out, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate app names: %w", err)
+	}
+	return out, nil
+}
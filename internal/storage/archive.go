@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ArchiveVersion is bumped whenever the archive layout changes. Version 2
+// added each session's account, slot, category and idle reason, and the
+// assigned app categories.
+const ArchiveVersion = 2
+
+// minArchiveVersion is the oldest archive Import still reads. Sessions
+// from a version 1 archive come in in the primary slot with no account,
+// category or idle reason; POST /sessions/recategorize fills in their
+// categories.
+const minArchiveVersion = 1
+
+// Archive is a portable snapshot of a hub database, used to move a hub to
+// new hardware or merge two histories.
+type Archive struct {
+	Version           int
+	ExportedAt        time.Time
+	Since             *time.Time `json:",omitempty"`
+	Devices           []Device
+	Sessions          []Session
+	SecondarySessions []Session
+	Apps              []App
+	AppCategories     []AssignedCategory `json:",omitempty"`
+	// Config is the exporting hub's config file, carried along verbatim.
+	Config json.RawMessage `json:",omitempty"`
+}
+
+// ImportStats counts what an import added.
+type ImportStats struct {
+	Devices           int
+	Sessions          int
+	SecondarySessions int
+	Apps              int
+	AppCategories     int
+}
+
+// AssignedCategory is a category assigned to an app through the API.
+type AssignedCategory struct {
+	AppID      string
+	Category   string
+	AssignedAt time.Time
+}
+
+// Export snapshots devices, apps, assigned app categories and closed
+// sessions. When since is set, only sessions ending and apps seen at or
+// after it are included; devices and app categories are always exported
+// in full.
+func (s *SessionStore) Export(ctx context.Context, since *time.Time, now time.Time) (*Archive, error) {
+	a := &Archive{Version: ArchiveVersion, ExportedAt: now, Since: since}
+
+	var err error
+	if a.Devices, err = s.GetDevices(ctx); err != nil {
+		return nil, err
+	}
+	if a.Sessions, err = s.exportSessions(ctx, "sessions", since); err != nil {
+		return nil, err
+	}
+	if a.SecondarySessions, err = s.exportSessions(ctx, "secondary_sessions", since); err != nil {
+		return nil, err
+	}
+	if a.Apps, err = s.GetApps(ctx, nil); err != nil {
+		return nil, err
+	}
+	if since != nil {
+		apps := a.Apps[:0]
+		for _, app := range a.Apps {
+			if !app.LastSeen.Before(*since) {
+				apps = append(apps, app)
+			}
+		}
+		a.Apps = apps
+	}
+	if a.AppCategories, err = s.exportAppCategories(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *SessionStore) exportAppCategories(ctx context.Context) ([]AssignedCategory, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT app_id, category, assigned_at FROM app_categories ORDER BY app_id`)
+	if err != nil {
+		return nil, fmt.Errorf("query app categories: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AssignedCategory
+	for rows.Next() {
+		var c AssignedCategory
+		if err := rows.Scan(&c.AppID, &c.Category, &c.AssignedAt); err != nil {
+			return nil, fmt.Errorf("scan app category: %w", err)
+		}
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate app categories: %w", err)
+	}
+	return out, nil
+}
+
+func (s *SessionStore) exportSessions(ctx context.Context, table string, since *time.Time) ([]Session, error) {
+	// secondary_sessions has only the columns common to both tables
+	extra := "end_reason, exception_label, account, slot, category, idle_reason"
+	if table == "secondary_sessions" {
+		extra = "'', '', '', '', '', ''"
+	}
+	q := fmt.Sprintf(`
+		SELECT id, device_id, app_id, app_name, start_time, end_time, duration_seconds, %s
+		FROM %s`, extra, table)
+	var args []any
+	if since != nil {
+		q += " WHERE end_time >= ?"
+		args = append(args, since.UTC())
+	}
+	q += " ORDER BY start_time ASC"
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var out []Session
+	for rows.Next() {
+		var se Session
+		if err := rows.Scan(
+			&se.ID, &se.DeviceID, &se.AppID, &se.AppName,
+			&se.StartTime, &se.EndTime, &se.DurationSecs, &se.EndReason, &se.ExceptionLabel,
+			&se.Account, &se.Slot, &se.Category, &se.IdleReason,
+		); err != nil {
+			return nil, fmt.Errorf("scan %s: %w", table, err)
+		}
+		out = append(out, se)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate %s: %w", table, err)
+	}
+	return out, nil
+}
+
+// Import merges an archive into the database in one transaction. Sessions
+// already present (same device, app and start time) are skipped, so
+// importing the same archive twice is harmless. Existing devices keep their
+// enabled flag and take the archive's display name only when they have
+// none; first/last seen widen to cover both histories and metadata is
+// merged. Of two categories assigned to the same app, the later assignment
+// wins.
+func (s *SessionStore) Import(ctx context.Context, a *Archive) (ImportStats, error) {
+	var stats ImportStats
+	if a.Version < minArchiveVersion || a.Version > ArchiveVersion {
+		return stats, fmt.Errorf("unsupported archive version %d", a.Version)
+	}
+
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		for _, d := range a.Devices {
+			res, err := tx.ExecContext(ctx, `
+				INSERT INTO devices (device_id, enabled, first_seen, last_seen, source, display_name)
+				VALUES (?, ?, ?, ?, ?, ?)
+				ON CONFLICT(device_id) DO UPDATE SET
+					first_seen = CASE
+						WHEN devices.first_seen IS NULL OR excluded.first_seen < devices.first_seen
+						THEN excluded.first_seen ELSE devices.first_seen END,
+					last_seen = CASE
+						WHEN devices.last_seen IS NULL OR excluded.last_seen > devices.last_seen
+						THEN excluded.last_seen ELSE devices.last_seen END,
+					display_name = CASE
+						WHEN devices.display_name = '' THEN excluded.display_name
+						ELSE devices.display_name END
+				WHERE devices.first_seen IS NOT excluded.first_seen
+					OR devices.last_seen IS NOT excluded.last_seen
+					OR (devices.display_name = '' AND excluded.display_name != '')`,
+				d.DeviceID, d.Enabled, utcPtr(d.FirstSeen), utcPtr(d.LastSeen), d.Source, d.DisplayName,
+			)
+			if err != nil {
+				return fmt.Errorf("import device %s: %w", d.DeviceID, err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				stats.Devices++
+			}
+			if err := mergeDeviceMetadataTx(ctx, tx, d.DeviceID, d.Metadata); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if stats.Sessions, err = importSessionsTx(ctx, tx, "sessions", a.Sessions); err != nil {
+			return err
+		}
+		if err := forgetRolledUpTx(ctx, tx, a.Sessions); err != nil {
+			return err
+		}
+		if stats.SecondarySessions, err = importSessionsTx(ctx, tx, "secondary_sessions", a.SecondarySessions); err != nil {
+			return err
+		}
+
+		for _, app := range a.Apps {
+			res, err := tx.ExecContext(ctx, `
+				INSERT INTO apps (device_id, app_id, app_name, first_seen, last_seen)
+				VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT(device_id, app_id) DO UPDATE SET
+					first_seen = MIN(apps.first_seen, excluded.first_seen),
+					last_seen = MAX(apps.last_seen, excluded.last_seen),
+					app_name = CASE WHEN excluded.last_seen > apps.last_seen
+						THEN excluded.app_name ELSE apps.app_name END
+				WHERE excluded.first_seen < apps.first_seen
+					OR excluded.last_seen > apps.last_seen`,
+				app.DeviceID, app.AppID, app.AppName, app.FirstSeen.UTC(), app.LastSeen.UTC(),
+			)
+			if err != nil {
+				return fmt.Errorf("import app %s/%s: %w", app.DeviceID, app.AppID, err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				stats.Apps++
+			}
+		}
+
+		for _, c := range a.AppCategories {
+			res, err := tx.ExecContext(ctx, `
+				INSERT INTO app_categories (app_id, category, assigned_at)
+				VALUES (?, ?, ?)
+				ON CONFLICT(app_id) DO UPDATE SET
+					category = excluded.category,
+					assigned_at = excluded.assigned_at
+				WHERE excluded.assigned_at > app_categories.assigned_at`,
+				c.AppID, c.Category, c.AssignedAt.UTC(),
+			)
+			if err != nil {
+				return fmt.Errorf("import app category %s: %w", c.AppID, err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				stats.AppCategories++
+			}
+		}
+		return nil
+	})
+	if err != nil || stats.AppCategories == 0 {
+		return stats, err
+	}
+	return stats, s.LoadAppCategories(ctx)
+}
+
+func importSessionsTx(ctx context.Context, tx *sql.Tx, table string, sessions []Session) (int, error) {
+	cols := "device_id, app_id, app_name, start_time, end_time, duration_seconds, end_reason, exception_label, account, slot, category, idle_reason"
+	placeholders := "?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?"
+	if table == "secondary_sessions" {
+		cols = "device_id, app_id, app_name, start_time, end_time, duration_seconds"
+		placeholders = "?, ?, ?, ?, ?, ?"
+	}
+	q := fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		SELECT %s
+		WHERE NOT EXISTS (
+			SELECT 1 FROM %s WHERE device_id = ? AND app_id = ? AND start_time = ?
+		)`, table, cols, placeholders, table)
+
+	n := 0
+	for _, se := range sessions {
+		args := []any{se.DeviceID, se.AppID, se.AppName, se.StartTime.UTC(), se.EndTime.UTC(), se.DurationSecs}
+		if table != "secondary_sessions" {
+			args = append(args, se.EndReason, se.ExceptionLabel, se.Account, se.Slot, se.Category, se.IdleReason)
+		}
+		args = append(args, se.DeviceID, se.AppID, se.StartTime.UTC())
+
+		res, err := tx.ExecContext(ctx, q, args...)
+		if err != nil {
+			return n, fmt.Errorf("import %s: %w", table, err)
+		}
+		if affected, _ := res.RowsAffected(); affected > 0 {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// forgetRolledUpTx unmarks the rolled-up days the sessions could touch,
+// so usage for them is summed from sessions until RollUpDays rebuilds them.
+// A day is at most 25 hours long, so the first one starts less than that
+// before the first session.
+func forgetRolledUpTx(ctx context.Context, tx *sql.Tx, sessions []Session) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	first, last := sessions[0].StartTime, sessions[0].EndTime
+	for _, se := range sessions[1:] {
+		first = minTime(first, se.StartTime)
+		last = maxTime(last, se.EndTime)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM rollup_days WHERE day > ? AND day < ?`,
+		first.Add(-25*time.Hour).UTC(), last.UTC(),
+	); err != nil {
+		return fmt.Errorf("forget rolled-up days: %w", err)
+	}
+	return nil
+}
+
+func utcPtr(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
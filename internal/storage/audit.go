@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AuditEntry records one administrative action.
+type AuditEntry struct {
+	ID     int64
+	Time   time.Time
+	Actor  string // who made the change, as reported by the client
+	Action string // e.g. "device.disable", "exception.set"
+	Target string // what was changed, usually a device ID
+	// Details holds action-specific parameters, e.g. the exception label.
+	Details map[string]string
+}
+
+// RecordAudit appends an entry to the audit log.
+func (s *SessionStore) RecordAudit(ctx context.Context, e AuditEntry) error {
+	details := "{}"
+	if len(e.Details) > 0 {
+		data, err := json.Marshal(e.Details)
+		if err != nil {
+			return fmt.Errorf("marshal audit details: %w", err)
+		}
+		details = string(data)
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (time, actor, action, target, details)
+		VALUES (?, ?, ?, ?, ?)`,
+		e.Time.UTC(), e.Actor, e.Action, e.Target, details,
+	); err != nil {
+		return fmt.Errorf("insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// GetAuditLog returns audit entries, newest first, optionally limited to a
+// time range and actor. limit <= 0 means no limit.
+func (s *SessionStore) GetAuditLog(ctx context.Context, since, until *time.Time, actor *string, limit int) ([]AuditEntry, error) {
+	q := `
+		SELECT id, time, actor, action, target, details
+		FROM audit_log
+		WHERE 1=1`
+	var args []any
+	if since != nil {
+		q += " AND time >= ?"
+		args = append(args, since.UTC())
+	}
+	if until != nil {
+		q += " AND time < ?"
+		args = append(args, until.UTC())
+	}
+	if actor != nil {
+		q += " AND actor = ?"
+		args = append(args, *actor)
+	}
+	q += " ORDER BY time DESC, id DESC"
+	if limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AuditEntry
+	for rows.Next() {
+		var (
+			e       AuditEntry
+			details string
+		)
+		if err := rows.Scan(&e.ID, &e.Time, &e.Actor, &e.Action, &e.Target, &details); err != nil {
+			return nil, fmt.Errorf("scan audit entry: %w", err)
+		}
+		if err := json.Unmarshal([]byte(details), &e.Details); err != nil {
+			return nil, fmt.Errorf("unmarshal audit details: %w", err)
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate audit log: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxBuckets bounds how many buckets a single bucketed usage query may use.
+const MaxBuckets = 2000
+
+// Bucket is a [Start, End) time range.
+type Bucket struct {
+	Start time.Time
+	End   time.Time
+}
+
+// BucketUsage is the usage of one app within one bucket.
+type BucketUsage struct {
+	Bucket       int // index into the buckets passed to GetUsageBuckets
+	DeviceID     string
+	AppID        string
+	AppName      string
+	TotalSeconds int64
+}
+
+// GetUsageBuckets computes per-app usage within each bucket. Session overlap
+// with each bucket is computed in SQL so long ranges don't pull every
+// session into memory. Apps are keyed by ID and reported under their latest
+// name, as in GetUsageBetween.
+func (s *SessionStore) GetUsageBuckets(ctx context.Context, buckets []Bucket, deviceID *string) ([]BucketUsage, error) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+	if len(buckets) > MaxBuckets {
+		return nil, fmt.Errorf("too many buckets: %d > %d", len(buckets), MaxBuckets)
+	}
+
+	values := make([]string, len(buckets))
+	args := make([]any, 0, len(buckets)*3+3)
+	for i, b := range buckets {
+		values[i] = "(?, ?, ?)"
+		args = append(args, i, b.Start.UTC(), b.End.UTC())
+	}
+
+	rangeStart := buckets[0].Start.UTC()
+	rangeEnd := buckets[len(buckets)-1].End.UTC()
+
+	deviceFilter := ""
+	args = append(args, rangeStart, rangeEnd)
+	if deviceID != nil {
+		deviceFilter = " AND device_id = ?"
+		args = append(args, *deviceID)
+	}
+	curFilter := ""
+	if deviceID != nil {
+		curFilter = " WHERE device_id = ?"
+		args = append(args, *deviceID)
+	}
+
+	q := `
+		WITH buckets(idx, b_start, b_end) AS (VALUES ` + strings.Join(values, ", ") + `),
+		spans AS (
+			SELECT device_id, app_id, app_name, start_time, end_time
+			FROM sessions
+			WHERE end_time > ? AND start_time < ?` + deviceFilter + `
+			UNION ALL
+			SELECT device_id, app_id, app_name, start_time, last_seen_time
+			FROM current_sessions` + curFilter + `
+		)
+		SELECT b.idx, sp.device_id, sp.app_id, COALESCE(a.app_name, MAX(sp.app_name)),
+			CAST(SUM(
+				(MIN(julianday(sp.end_time), julianday(b.b_end)) -
+				 MAX(julianday(sp.start_time), julianday(b.b_start))) * 86400
+			) AS INTEGER)
+		FROM buckets b
+		JOIN spans sp ON sp.start_time < b.b_end AND sp.end_time > b.b_start
+		LEFT JOIN apps a ON a.device_id = sp.device_id AND a.app_id = sp.app_id
+		GROUP BY b.idx, sp.device_id, sp.app_id
+		ORDER BY b.idx`
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query bucketed usage: %w", err)
+	}
+	defer rows.Close()
+
+	var out []BucketUsage
+	for rows.Next() {
+		var bu BucketUsage
+		if err := rows.Scan(&bu.Bucket, &bu.DeviceID, &bu.AppID, &bu.AppName, &bu.TotalSeconds); err != nil {
+			return nil, fmt.Errorf("scan bucketed usage: %w", err)
+		}
+		out = append(out, bu)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate bucketed usage: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetExportCursor returns how far the named exporter has got, or "" if it
+// has never run.
+func (s *SessionStore) GetExportCursor(ctx context.Context, name string) (string, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT value FROM export_cursors WHERE name = ?`, name).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query export cursor: %w", err)
+	}
+	return value, nil
+}
+
+// SetExportCursor records how far the named exporter has got.
+func (s *SessionStore) SetExportCursor(ctx context.Context, name, value string) error {
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO export_cursors (name, value) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET value = excluded.value`,
+		name, value,
+	); err != nil {
+		return fmt.Errorf("upsert export cursor: %w", err)
+	}
+	return nil
+}
+
+// GetSessionsAfterID returns up to limit closed sessions with an id greater
+// than afterID, oldest first, so exporters can page through new sessions.
+func (s *SessionStore) GetSessionsAfterID(ctx context.Context, afterID int64, limit int) ([]Session, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, device_id, app_id, app_name, start_time, end_time, duration_seconds, end_reason
+		FROM sessions
+		WHERE id > ?
+		ORDER BY id ASC
+		LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Session
+	for rows.Next() {
+		var se Session
+		if err := rows.Scan(
+			&se.ID, &se.DeviceID, &se.AppID, &se.AppName,
+			&se.StartTime, &se.EndTime, &se.DurationSecs, &se.EndReason,
+		); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		out = append(out, se)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate sessions: %w", err)
+	}
+	return out, nil
+}
+
+// FirstSessionStart returns the start of the oldest closed session, or the
+// zero time if there are none.
+func (s *SessionStore) FirstSessionStart(ctx context.Context) (time.Time, error) {
+	var t time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT start_time FROM sessions ORDER BY start_time ASC LIMIT 1`).Scan(&t)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query first session: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Device is a row in the device registry.
+type Device struct {
+	DeviceID  string
+	Enabled   bool
+	FirstSeen *time.Time
+	LastSeen  *time.Time
+	Metadata  map[string]string
+	Source    string // where the device was registered from, e.g. "config"
+	// DisplayName is the name set through the API, overriding the
+	// config's; empty when none is set.
+	DisplayName string
+}
+
+// GetDeviceEnabled reports whether polling is enabled for a device.
+// Devices without a stored row are enabled.
+func (s *SessionStore) GetDeviceEnabled(ctx context.Context, deviceID string) (bool, error) {
+	var enabled bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT enabled FROM devices WHERE device_id = ?`, deviceID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("query device enabled: %w", err)
+	}
+	return enabled, nil
+}
+
+// SetDeviceEnabled persists the enabled flag for a device.
+func (s *SessionStore) SetDeviceEnabled(ctx context.Context, deviceID string, enabled bool) error {
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO devices (device_id, enabled) VALUES (?, ?)
+		ON CONFLICT(device_id) DO UPDATE SET enabled = excluded.enabled`,
+		deviceID, enabled,
+	); err != nil {
+		return fmt.Errorf("upsert device enabled: %w", err)
+	}
+	return nil
+}
+
+// SetDeviceDisplayName stores the name a device is shown under. An empty
+// name clears it.
+func (s *SessionStore) SetDeviceDisplayName(ctx context.Context, deviceID, name string) error {
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO devices (device_id, display_name) VALUES (?, ?)
+		ON CONFLICT(device_id) DO UPDATE SET display_name = excluded.display_name`,
+		deviceID, name,
+	); err != nil {
+		return fmt.Errorf("upsert device display name: %w", err)
+	}
+	return nil
+}
+
+// GetDeviceDisplayNames returns the stored display names by device ID, for
+// the devices that have one.
+func (s *SessionStore) GetDeviceDisplayNames(ctx context.Context) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT device_id, display_name FROM devices WHERE display_name != ''`)
+	if err != nil {
+		return nil, fmt.Errorf("query device display names: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]string)
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("scan device display name: %w", err)
+		}
+		out[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate device display names: %w", err)
+	}
+	return out, nil
+}
+
+// RegisterDevice records a device in the registry, setting first_seen on the
+// first registration and merging metadata into what is already stored.
+func (s *SessionStore) RegisterDevice(ctx context.Context, deviceID, source string, metadata map[string]string, now time.Time) error {
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO devices (device_id, enabled, first_seen, source) VALUES (?, 1, ?, ?)
+			ON CONFLICT(device_id) DO UPDATE SET
+				first_seen = COALESCE(devices.first_seen, excluded.first_seen),
+				source = excluded.source`,
+			deviceID, now, source,
+		); err != nil {
+			return fmt.Errorf("register device: %w", err)
+		}
+		return mergeDeviceMetadataTx(ctx, tx, deviceID, metadata)
+	})
+}
+
+// MergeDeviceMetadata merges metadata into a device's stored metadata.
+func (s *SessionStore) MergeDeviceMetadata(ctx context.Context, deviceID string, metadata map[string]string) error {
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		return mergeDeviceMetadataTx(ctx, tx, deviceID, metadata)
+	})
+}
+
+func mergeDeviceMetadataTx(ctx context.Context, tx *sql.Tx, deviceID string, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	var raw string
+	err := tx.QueryRowContext(ctx, `SELECT metadata FROM devices WHERE device_id = ?`, deviceID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("device %s not registered", deviceID)
+	}
+	if err != nil {
+		return fmt.Errorf("query device metadata: %w", err)
+	}
+
+	merged := make(map[string]string)
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &merged); err != nil {
+			return fmt.Errorf("unmarshal device metadata: %w", err)
+		}
+	}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("marshal device metadata: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE devices SET metadata = ? WHERE device_id = ?`, string(data), deviceID); err != nil {
+		return fmt.Errorf("update device metadata: %w", err)
+	}
+	return nil
+}
+
+// TouchDevice records that a device was heard from at t.
+func (s *SessionStore) TouchDevice(ctx context.Context, deviceID string, t time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE devices
+		SET last_seen = ?, first_seen = COALESCE(first_seen, ?)
+		WHERE device_id = ?`,
+		t, t, deviceID,
+	); err != nil {
+		return fmt.Errorf("touch device: %w", err)
+	}
+	return nil
+}
+
+// GetDevices returns every device in the registry, including devices no
+// longer present in config.
+func (s *SessionStore) GetDevices(ctx context.Context) ([]Device, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT device_id, enabled, first_seen, last_seen, metadata, source, display_name
+		FROM devices
+		ORDER BY device_id`)
+	if err != nil {
+		return nil, fmt.Errorf("query devices: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Device
+	for rows.Next() {
+		var (
+			d                   Device
+			firstSeen, lastSeen sql.NullTime
+			metadata            string
+		)
+		if err := rows.Scan(&d.DeviceID, &d.Enabled, &firstSeen, &lastSeen, &metadata, &d.Source, &d.DisplayName); err != nil {
+			return nil, fmt.Errorf("scan device: %w", err)
+		}
+		if firstSeen.Valid {
+			d.FirstSeen = &firstSeen.Time
+		}
+		if lastSeen.Valid {
+			d.LastSeen = &lastSeen.Time
+		}
+		if metadata != "" {
+			if err := json.Unmarshal([]byte(metadata), &d.Metadata); err != nil {
+				return nil, fmt.Errorf("unmarshal device metadata: %w", err)
+			}
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate devices: %w", err)
+	}
+	return out, nil
+}
+
+// EndCurrentSession closes a device's current sessions, in every slot,
+// with the given reason.
+func (s *SessionStore) EndCurrentSession(ctx context.Context, deviceID string, end time.Time, reason string) error {
+	var events []SessionEvent
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		current, err := deviceSessionsTx(ctx, tx, deviceID)
+		if err != nil {
+			return err
+		}
+		for i := range current {
+			if err := s.endSessionTx(ctx, tx, &current[i], end, reason, ""); err != nil {
+				return err
+			}
+			events = append(events, endEvent(&current[i], end, reason, ""))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.emit(events)
+	return nil
+}
+
+// ShiftCurrentSession moves a device's current sessions by offset so their
+// elapsed durations survive a wall-clock jump.
+func (s *SessionStore) ShiftCurrentSession(ctx context.Context, deviceID string, offset time.Duration) error {
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		current, err := deviceSessionsTx(ctx, tx, deviceID)
+		if err != nil {
+			return err
+		}
+		for _, cs := range current {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE current_sessions
+				SET start_time = ?, last_seen_time = ?
+				WHERE device_id = ? AND slot = ?`,
+				cs.StartTime.Add(offset), cs.LastSeenTime.Add(offset), deviceID, cs.Slot,
+			); err != nil {
+				return fmt.Errorf("shift current_session: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// deviceSessionsTx returns a device's current sessions in all slots.
+func deviceSessionsTx(ctx context.Context, tx *sql.Tx, deviceID string) ([]CurrentSession, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT device_id, slot, app_id, app_name, start_time, last_seen_time, state, account, title
+		FROM current_sessions
+		WHERE device_id = ?`, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("query current_sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []CurrentSession
+	for rows.Next() {
+		var cs CurrentSession
+		if err := rows.Scan(&cs.DeviceID, &cs.Slot, &cs.AppID, &cs.AppName, &cs.StartTime, &cs.LastSeenTime, &cs.State, &cs.Account, &cs.Title); err != nil {
+			return nil, fmt.Errorf("scan current_session: %w", err)
+		}
+		out = append(out, cs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate current_sessions: %w", err)
+	}
+	return out, nil
+}
+
+// deviceDataTables holds every table with per-device usage data; a device's
+// rows in all of them are removed by DeleteDeviceData.
+var deviceDataTables = []string{
+	"sessions",
+	"current_sessions",
+	"secondary_sessions",
+	"current_secondary",
+	"apps",
+	"app_names",
+	"raw_polls",
+	"title_events",
+	"daily_usage",
+}
+
+// DeleteDeviceData removes all recorded usage for a device and returns the
+// number of rows deleted per table. The device registration (and its
+// enabled flag) is kept so a still-configured device stays disabled if it
+// was.
+func (s *SessionStore) DeleteDeviceData(ctx context.Context, deviceID string) (map[string]int64, error) {
+	deleted := make(map[string]int64)
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		for _, table := range deviceDataTables {
+			res, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE device_id = ?`, table), deviceID)
+			if err != nil {
+				return fmt.Errorf("delete %s: %w", table, err)
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("delete %s rows affected: %w", table, err)
+			}
+			deleted[table] = n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SetCurrentException labels a device's running primary session as an
+// approved exception, or clears the label when label is empty. It reports
+// false when the device has no running session.
+func (s *SessionStore) SetCurrentException(ctx context.Context, deviceID, label string) (CurrentSession, bool, error) {
+	var cs CurrentSession
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE current_sessions SET exception_label = ? WHERE device_id = ? AND slot = ?`,
+		label, deviceID, PrimarySlot,
+	)
+	if err != nil {
+		return cs, false, fmt.Errorf("update current_session exception: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return cs, false, fmt.Errorf("update current_session exception rows affected: %w", err)
+	} else if n == 0 {
+		return cs, false, nil
+	}
+
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT device_id, app_id, app_name, start_time, last_seen_time, state, exception_label
+		FROM current_sessions WHERE device_id = ? AND slot = ?`, deviceID, PrimarySlot,
+	).Scan(&cs.DeviceID, &cs.AppID, &cs.AppName, &cs.StartTime, &cs.LastSeenTime, &cs.State, &cs.ExceptionLabel); err != nil {
+		return cs, false, fmt.Errorf("scan current_session: %w", err)
+	}
+	return cs, true, nil
+}
+
+// GetExceptions returns the closed and running sessions marked as approved
+// exceptions that overlap [start, end), oldest first. Running sessions end
+// at their last seen time.
+func (s *SessionStore) GetExceptions(ctx context.Context, start, end time.Time) ([]Session, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, device_id, app_id, app_name, start_time, end_time, duration_seconds, end_reason, exception_label
+		FROM sessions
+		WHERE exception_label != '' AND end_time > ? AND start_time < ?
+		UNION ALL
+		SELECT 0, device_id, app_id, app_name, start_time, last_seen_time,
+			CAST(ROUND((julianday(last_seen_time) - julianday(start_time)) * 86400) AS INTEGER), '', exception_label
+		FROM current_sessions
+		WHERE exception_label != '' AND last_seen_time > ? AND start_time < ?
+		ORDER BY start_time ASC`,
+		start, end, start, end,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query exceptions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Session
+	for rows.Next() {
+		var se Session
+		if err := rows.Scan(
+			&se.ID, &se.DeviceID, &se.AppID, &se.AppName,
+			&se.StartTime, &se.EndTime, &se.DurationSecs, &se.EndReason, &se.ExceptionLabel,
+		); err != nil {
+			return nil, fmt.Errorf("scan exception: %w", err)
+		}
+		out = append(out, se)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate exceptions: %w", err)
+	}
+	return out, nil
+}
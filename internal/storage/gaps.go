@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Gap is a stretch of time a device had no session in its primary slot.
+type Gap struct {
+	Start time.Time
+	End   time.Time
+	// Reason is the end reason of the session before the gap, e.g. "idle"
+	// or "offline"; empty when the device has no earlier session.
+	Reason string
+	// IdleReason is that session's idle reason, if it had one.
+	IdleReason string
+}
+
+// GetGaps returns the stretches of [start, end) in which deviceID had no
+// primary session, oldest first. The running session, if any, counts up
+// to when it was last seen.
+func (s *SessionStore) GetGaps(ctx context.Context, deviceID string, start, end time.Time) ([]Gap, error) {
+	type span struct {
+		start, end time.Time
+		reason     string
+		idleReason string
+	}
+	var spans []span
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT start_time, end_time, end_reason, idle_reason
+		FROM sessions
+		WHERE device_id = ? AND slot = ? AND end_time > ? AND start_time < ?`,
+		deviceID, PrimarySlot, start.UTC(), end.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sp span
+		if err := rows.Scan(&sp.start, &sp.end, &sp.reason, &sp.idleReason); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		spans = append(spans, sp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate sessions: %w", err)
+	}
+
+	var cur span
+	err = s.db.QueryRowContext(ctx, `
+		SELECT start_time, last_seen_time
+		FROM current_sessions
+		WHERE device_id = ? AND slot = ?`, deviceID, PrimarySlot,
+	).Scan(&cur.start, &cur.end)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, fmt.Errorf("query current_session: %w", err)
+	case cur.end.After(start) && cur.start.Before(end):
+		spans = append(spans, cur)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+
+	// The reason for a gap at the start of the range comes from the last
+	// session before it.
+	var reason, idleReason string
+	err = s.db.QueryRowContext(ctx, `
+		SELECT end_reason, idle_reason
+		FROM sessions
+		WHERE device_id = ? AND slot = ? AND end_time <= ?
+		ORDER BY end_time DESC
+		LIMIT 1`, deviceID, PrimarySlot, start.UTC(),
+	).Scan(&reason, &idleReason)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("query previous session: %w", err)
+	}
+
+	var out []Gap
+	cursor := start
+	for _, sp := range spans {
+		if sp.start.After(cursor) {
+			out = append(out, Gap{Start: cursor, End: minTime(sp.start, end), Reason: reason, IdleReason: idleReason})
+		}
+		if sp.end.After(cursor) {
+			cursor = sp.end
+			reason, idleReason = sp.reason, sp.idleReason
+		}
+	}
+	if cursor.Before(end) {
+		out = append(out, Gap{Start: cursor, End: end, Reason: reason, IdleReason: idleReason})
+	}
+	return out, nil
+}
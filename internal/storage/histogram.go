@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SessionLengths is the distribution of closed session lengths for one
+// device and category.
+type SessionLengths struct {
+	DeviceID string
+	Category string
+	// Counts[i] is the number of sessions no longer than the i-th bound
+	// passed to GetSessionLengths, so the counts are cumulative.
+	Counts       []int64
+	Count        int64
+	TotalSeconds int64
+}
+
+// GetSessionLengths buckets every closed session's duration by device and
+// stored category. The buckets are counted in SQL in a single pass over
+// sessions.
+func (s *SessionStore) GetSessionLengths(ctx context.Context, bounds []time.Duration) ([]SessionLengths, error) {
+	cols := make([]string, len(bounds))
+	args := make([]any, len(bounds))
+	for i, b := range bounds {
+		cols[i] = "SUM(duration_seconds <= ?)"
+		args[i] = int64(b / time.Second)
+	}
+
+	q := `SELECT device_id, category, COUNT(*), SUM(duration_seconds)`
+	if len(cols) > 0 {
+		q += ", " + strings.Join(cols, ", ")
+	}
+	q += `
+		FROM sessions
+		GROUP BY device_id, category
+		ORDER BY device_id, category`
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query session lengths: %w", err)
+	}
+	defer rows.Close()
+
+	var out []SessionLengths
+	for rows.Next() {
+		l := SessionLengths{Counts: make([]int64, len(bounds))}
+		dest := []any{&l.DeviceID, &l.Category, &l.Count, &l.TotalSeconds}
+		for i := range l.Counts {
+			dest = append(dest, &l.Counts[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan session lengths: %w", err)
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
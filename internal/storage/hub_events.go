@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Hub event kinds.
+const (
+	// HubEventRunning spans a stretch the hub was up, ending at its last
+	// heartbeat.
+	HubEventRunning = "running"
+	// HubEventDowntime spans a gap between heartbeats: the hub was stopped
+	// or its machine asleep, so nothing was monitored.
+	HubEventDowntime = "downtime"
+)
+
+// HubEvent is a period in the hub's own history.
+type HubEvent struct {
+	ID        int64
+	Kind      string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// StartHubRun records the hub starting at now and returns the new running
+// event's ID. The gap back to the previous run's last heartbeat is recorded
+// as downtime and returned when it is longer than minGap.
+func (s *SessionStore) StartHubRun(ctx context.Context, now time.Time, minGap time.Duration) (int64, *HubEvent, error) {
+	var (
+		id   int64
+		down *HubEvent
+	)
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var last time.Time
+		err := tx.QueryRowContext(ctx, `
+			SELECT end_time FROM hub_events
+			WHERE kind = ?
+			ORDER BY end_time DESC
+			LIMIT 1`, HubEventRunning,
+		).Scan(&last)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("query last hub run: %w", err)
+		}
+		if err == nil {
+			if down, err = recordDowntimeTx(ctx, tx, last, now, minGap); err != nil {
+				return err
+			}
+		}
+		id, err = insertHubEventTx(ctx, tx, HubEventRunning, now, now)
+		return err
+	})
+	return id, down, err
+}
+
+// HubHeartbeat extends the running event id to now and returns the ID to
+// use for the next heartbeat. A heartbeat more than minGap after the last
+// one means the machine slept: the gap is recorded as downtime, returned,
+// and a new running event started.
+func (s *SessionStore) HubHeartbeat(ctx context.Context, id int64, now time.Time, minGap time.Duration) (int64, *HubEvent, error) {
+	next := id
+	var down *HubEvent
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var last time.Time
+		if err := tx.QueryRowContext(ctx, `
+			SELECT end_time FROM hub_events WHERE id = ?`, id,
+		).Scan(&last); err != nil {
+			return fmt.Errorf("query hub run: %w", err)
+		}
+
+		var err error
+		if down, err = recordDowntimeTx(ctx, tx, last, now, minGap); err != nil {
+			return err
+		}
+		if down != nil {
+			next, err = insertHubEventTx(ctx, tx, HubEventRunning, now, now)
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE hub_events SET end_time = ? WHERE id = ?`, now.UTC(), id,
+		); err != nil {
+			return fmt.Errorf("update hub run: %w", err)
+		}
+		return nil
+	})
+	return next, down, err
+}
+
+// recordDowntimeTx records [last, now) as downtime if it exceeds minGap.
+func recordDowntimeTx(ctx context.Context, tx *sql.Tx, last, now time.Time, minGap time.Duration) (*HubEvent, error) {
+	if now.Sub(last) <= minGap {
+		return nil, nil
+	}
+	id, err := insertHubEventTx(ctx, tx, HubEventDowntime, last, now)
+	if err != nil {
+		return nil, err
+	}
+	return &HubEvent{ID: id, Kind: HubEventDowntime, StartTime: last, EndTime: now}, nil
+}
+
+func insertHubEventTx(ctx context.Context, tx *sql.Tx, kind string, start, end time.Time) (int64, error) {
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO hub_events (kind, start_time, end_time)
+		VALUES (?, ?, ?)`,
+		kind, start.UTC(), end.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("insert hub event: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// GetDowntime returns the hub downtime overlapping [start, end), oldest
+// first.
+func (s *SessionStore) GetDowntime(ctx context.Context, start, end time.Time) ([]HubEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, kind, start_time, end_time
+		FROM hub_events
+		WHERE kind = ? AND end_time > ? AND start_time < ?
+		ORDER BY start_time ASC`,
+		HubEventDowntime, start.UTC(), end.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query hub downtime: %w", err)
+	}
+	defer rows.Close()
+
+	var out []HubEvent
+	for rows.Next() {
+		var e HubEvent
+		if err := rows.Scan(&e.ID, &e.Kind, &e.StartTime, &e.EndTime); err != nil {
+			return nil, fmt.Errorf("scan hub event: %w", err)
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate hub downtime: %w", err)
+	}
+	return out, nil
+}
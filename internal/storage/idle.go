@@ -0,0 +1,31 @@
+package storage
+
+// Idle reasons say why a device stopped being active, next to the end
+// reason "idle" or "paused" that closed its session, so that walking away
+// can be told apart from locking the screen for the night.
+const (
+	// IdleScreensaver is a screensaver showing.
+	IdleScreensaver = "screensaver"
+	// IdleLock is the screen being locked.
+	IdleLock = "lock"
+	// IdleNoInput is no keyboard or mouse input for the agent's idle
+	// timeout.
+	IdleNoInput = "no_input"
+	// IdlePausedMedia is media paused for longer than the paused-media
+	// policy allows.
+	IdlePausedMedia = "paused_media"
+	// IdleIgnoredWindow is an ignored window, such as the desktop, in
+	// front.
+	IdleIgnoredWindow = "ignored_window"
+	// IdleNoWindow is no window focused at all.
+	IdleNoWindow = "no_window"
+)
+
+// ValidIdleReason reports whether reason is one of the idle reasons.
+func ValidIdleReason(reason string) bool {
+	switch reason {
+	case IdleScreensaver, IdleLock, IdleNoInput, IdlePausedMedia, IdleIgnoredWindow, IdleNoWindow:
+		return true
+	}
+	return false
+}
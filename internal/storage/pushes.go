@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AcceptPush records t as the agent timestamp of a device's latest push
+// for slot, if it is later than the one recorded, and reports whether it
+// was. A push no later than the last is a possible replay; keeping the
+// last one in the database keeps refusing it after a restart.
+func (s *SessionStore) AcceptPush(ctx context.Context, deviceID, slot string, t time.Time) (bool, error) {
+	// Nanoseconds since the epoch compare exactly, unlike DATETIME text
+	// with varying fractional digits.
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO push_timestamps (device_id, slot, unix_nanos) VALUES (?, ?, ?)
+		ON CONFLICT(device_id, slot) DO UPDATE SET unix_nanos = excluded.unix_nanos
+		WHERE excluded.unix_nanos > push_timestamps.unix_nanos`,
+		deviceID, slot, t.UnixNano(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("upsert push timestamp: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("upsert push timestamp: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RecordRawPoll stores a poll update exactly as it was applied, so odd
+// sessions can be traced back to the observations behind them.
+func (s *SessionStore) RecordRawPoll(ctx context.Context, p PollUpdate) error {
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO raw_polls (device_id, app_id, app_name, state, timestamp, account, slot)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		p.DeviceID, p.AppID, p.AppName, p.State, p.Timestamp.UTC(), p.Account, p.Slot,
+	); err != nil {
+		return fmt.Errorf("insert raw poll: %w", err)
+	}
+	return nil
+}
+
+// GetRawPolls returns recorded poll updates in [since, until), oldest first,
+// optionally for one device. limit <= 0 means no limit.
+func (s *SessionStore) GetRawPolls(ctx context.Context, deviceID *string, since, until time.Time, limit int) ([]PollUpdate, error) {
+	q := `
+		SELECT device_id, app_id, app_name, state, timestamp, account, slot
+		FROM raw_polls
+		WHERE timestamp >= ? AND timestamp < ?`
+	args := []any{since.UTC(), until.UTC()}
+	if deviceID != nil {
+		q += " AND device_id = ?"
+		args = append(args, *deviceID)
+	}
+	q += " ORDER BY timestamp ASC, id ASC"
+	if limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query raw polls: %w", err)
+	}
+	defer rows.Close()
+
+	var out []PollUpdate
+	for rows.Next() {
+		var p PollUpdate
+		if err := rows.Scan(&p.DeviceID, &p.AppID, &p.AppName, &p.State, &p.Timestamp, &p.Account, &p.Slot); err != nil {
+			return nil, fmt.Errorf("scan raw poll: %w", err)
+		}
+		out = append(out, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate raw polls: %w", err)
+	}
+	return out, nil
+}
+
+// PruneRawPolls deletes recorded poll updates older than before.
+func (s *SessionStore) PruneRawPolls(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM raw_polls WHERE timestamp < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("prune raw polls: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("prune raw polls rows affected: %w", err)
+	}
+	return n, nil
+}
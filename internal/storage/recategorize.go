@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// CategoryChange is a group of sessions of one app whose stored category
+// differs from what the current rules say.
+type CategoryChange struct {
+	DeviceID string `json:"device_id"`
+	AppID    string `json:"app_id"`
+	AppName  string `json:"app_name"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Sessions int64  `json:"sessions"`
+	Seconds  int64  `json:"seconds"`
+}
+
+// Recategorize runs categorize over every stored session's app ID and name
+// and returns the sessions whose category it changes, grouped by app.
+// Unless dryRun is set the new categories are stored, in one transaction.
+func (s *SessionStore) Recategorize(ctx context.Context, categorize func(appID, appName string) string, dryRun bool) ([]CategoryChange, error) {
+	return s.recategorize(ctx, categorize, dryRun, nil)
+}
+
+// RecategorizeApp is Recategorize for the sessions of one app, such as
+// after its category was assigned.
+func (s *SessionStore) RecategorizeApp(ctx context.Context, appID string, categorize func(appID, appName string) string) ([]CategoryChange, error) {
+	return s.recategorize(ctx, categorize, false, &appID)
+}
+
+func (s *SessionStore) recategorize(ctx context.Context, categorize func(appID, appName string) string, dryRun bool, appID *string) ([]CategoryChange, error) {
+	var changes []CategoryChange
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT device_id, app_id, app_name, category, COUNT(*), SUM(duration_seconds)
+			FROM sessions`
+		var args []any
+		if appID != nil {
+			query += " WHERE app_id = ?"
+			args = append(args, *appID)
+		}
+		query += `
+			GROUP BY device_id, app_id, app_name, category
+			ORDER BY device_id, app_id, app_name`
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("query session categories: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var c CategoryChange
+			if err := rows.Scan(&c.DeviceID, &c.AppID, &c.AppName, &c.From, &c.Sessions, &c.Seconds); err != nil {
+				return fmt.Errorf("scan session category: %w", err)
+			}
+			if c.To = categorize(c.AppID, c.AppName); c.To != c.From {
+				changes = append(changes, c)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterate session categories: %w", err)
+		}
+		rows.Close()
+
+		if dryRun {
+			return nil
+		}
+		for _, c := range changes {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE sessions SET category = ?
+				WHERE device_id = ? AND app_id = ? AND app_name = ? AND category = ?`,
+				c.To, c.DeviceID, c.AppID, c.AppName, c.From,
+			); err != nil {
+				return fmt.Errorf("update session category: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
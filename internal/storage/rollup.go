@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RollUpDaily makes the store keep per-day usage totals in daily_usage and
+// answer usage queries from them for the days they cover. dayStart returns
+// the start of the day containing its argument.
+//
+// A day is only read from the rollup once RollUpDays has built it; until
+// then, and for the part-days at either end of a query, usage is summed
+// from the sessions themselves.
+func (s *SessionStore) RollUpDaily(dayStart func(time.Time) time.Time) {
+	s.dayStart = dayStart
+}
+
+// nextDay returns the start of the day after the one starting at day.
+// Adding 25 hours lands inside the next day even across a DST change.
+func (s *SessionStore) nextDay(day time.Time) time.Time {
+	return s.dayStart(day.Add(25 * time.Hour))
+}
+
+// RollUpDays rebuilds the rollup for the day containing now and the day
+// before it from the sessions, then builds every earlier day that has none
+// yet. It returns the number of days built.
+func (s *SessionStore) RollUpDays(ctx context.Context, now time.Time) (int, error) {
+	if s.dayStart == nil {
+		return 0, nil
+	}
+	today := s.dayStart(now)
+	yesterday := s.dayStart(today.Add(-time.Minute))
+
+	first, err := s.FirstSessionStart(ctx)
+	if err != nil {
+		return 0, err
+	}
+	built, err := s.rolledUpDays(ctx, time.Time{}, today)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	if !first.IsZero() {
+		for day := s.dayStart(first); day.Before(yesterday); day = s.nextDay(day) {
+			if built[day.UTC()] {
+				continue
+			}
+			if err := s.rebuildDay(ctx, day); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	for _, day := range []time.Time{yesterday, today} {
+		if err := s.rebuildDay(ctx, day); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// rebuildDay replaces the rollup of the day starting at day with totals
+// summed from the sessions overlapping it, and marks the day built.
+func (s *SessionStore) rebuildDay(ctx context.Context, day time.Time) error {
+	start, end := day.UTC(), s.nextDay(day).UTC()
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM daily_usage WHERE day = ?`, start); err != nil {
+			return fmt.Errorf("clear daily_usage: %w", err)
+		}
+		agg := newUsageAgg()
+		if err := addSessionUsageTx(ctx, tx, agg, start, end, nil); err != nil {
+			return err
+		}
+		for k, secs := range agg.seconds {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO daily_usage (day, device_id, app_id, account, app_name, name_start, seconds, exception_seconds)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				start, k.deviceID, k.appID, k.account, agg.names[k], agg.nameStart[k].UTC(), secs, agg.exceptions[k],
+			); err != nil {
+				return fmt.Errorf("build daily_usage: %w", err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO rollup_days (day) VALUES (?)`, start,
+		); err != nil {
+			return fmt.Errorf("mark rollup day: %w", err)
+		}
+		return nil
+	})
+}
+
+// splitRolledUp splits [start, end) into the built days wholly inside it
+// and the ranges left over, which must be summed from sessions.
+func (s *SessionStore) splitRolledUp(ctx context.Context, start, end time.Time) ([]time.Time, [][2]time.Time, error) {
+	day := s.dayStart(start)
+	if day.Before(start) {
+		day = s.nextDay(day)
+	}
+	built, err := s.rolledUpDays(ctx, day, end)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var days []time.Time
+	var ranges [][2]time.Time
+	from := start
+	for ; day.Before(end); day = s.nextDay(day) {
+		next := s.nextDay(day)
+		if next.After(end) {
+			break
+		}
+		if !built[day.UTC()] {
+			continue
+		}
+		if from.Before(day) {
+			ranges = append(ranges, [2]time.Time{from, day.UTC()})
+		}
+		days = append(days, day)
+		from = next.UTC()
+	}
+	if from.Before(end) {
+		ranges = append(ranges, [2]time.Time{from, end})
+	}
+	return days, ranges, nil
+}
+
+// rolledUpDays returns the built days starting in [from, until), by their
+// UTC start.
+func (s *SessionStore) rolledUpDays(ctx context.Context, from, until time.Time) (map[time.Time]bool, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT day FROM rollup_days WHERE day >= ? AND day < ?`, from.UTC(), until.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("query rollup_days: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[time.Time]bool)
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("scan rollup_days: %w", err)
+		}
+		out[day.UTC()] = true
+	}
+	return out, rows.Err()
+}
+
+// rollUpSessionTx adds the session just inserted as id, spanning
+// [start, end), to the rollup of every built day it overlaps.
+func (s *SessionStore) rollUpSessionTx(ctx context.Context, tx *sql.Tx, id int64, start, end time.Time) error {
+	if s.dayStart == nil {
+		return nil
+	}
+	for day := s.dayStart(start); day.Before(end); day = s.nextDay(day) {
+		from := maxTime(start, day)
+		to := minTime(end, s.nextDay(day))
+		secs := int64(to.Sub(from).Seconds())
+		if secs <= 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO daily_usage (day, device_id, app_id, account, app_name, name_start, seconds, exception_seconds)
+			SELECT ?, device_id, app_id, account, app_name, start_time, ?,
+				CASE WHEN exception_label != '' THEN ? ELSE 0 END
+			FROM sessions
+			WHERE id = ? AND EXISTS (SELECT 1 FROM rollup_days WHERE day = ?)
+			ON CONFLICT (day, device_id, app_id, account) DO UPDATE SET
+				seconds = seconds + excluded.seconds,
+				exception_seconds = exception_seconds + excluded.exception_seconds,
+				app_name = CASE WHEN excluded.name_start >= name_start
+					THEN excluded.app_name ELSE app_name END,
+				name_start = MAX(name_start, excluded.name_start)`,
+			day.UTC(), secs, secs, id, day.UTC(),
+		); err != nil {
+			return fmt.Errorf("roll up session: %w", err)
+		}
+	}
+	return nil
+}
+
+// addRolledUpUsage adds the rollup of the built days starting at days to
+// agg.
+func (s *SessionStore) addRolledUpUsage(ctx context.Context, agg *usageAgg, days []time.Time, deviceID *string) error {
+	if len(days) == 0 {
+		return nil
+	}
+	q := `
+		SELECT d.device_id, d.app_id, COALESCE(a.app_name, d.app_name),
+			d.name_start, d.seconds, d.exception_seconds, d.account
+		FROM daily_usage d
+		LEFT JOIN apps a ON a.device_id = d.device_id AND a.app_id = d.app_id
+		WHERE d.day IN (?` + strings.Repeat(", ?", len(days)-1) + `)`
+	args := make([]any, 0, len(days)+1)
+	for _, day := range days {
+		args = append(args, day.UTC())
+	}
+	if deviceID != nil {
+		q += " AND d.device_id = ?"
+		args = append(args, *deviceID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("query daily_usage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var device, appID, appName, account string
+		var nameStart time.Time
+		var secs, exceptionSecs int64
+		if err := rows.Scan(&device, &appID, &appName, &nameStart, &secs, &exceptionSecs, &account); err != nil {
+			return fmt.Errorf("scan daily_usage: %w", err)
+		}
+		agg.add(device, appID, appName, account, nameStart, secs, exceptionSecs)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate daily_usage: %w", err)
+	}
+	return nil
+}
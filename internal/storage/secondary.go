@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SecondaryApp is background activity reported next to a device's primary
+// app, such as music playing or a game left running behind another window.
+type SecondaryApp struct {
+	AppID   string
+	AppName string
+}
+
+// ApplySecondary reconciles the open secondary sessions for a device with
+// the set reported at ts. Apps no longer reported are closed into
+// secondary_sessions; an empty set closes everything.
+func (s *SessionStore) ApplySecondary(ctx context.Context, deviceID string, apps []SecondaryApp, ts time.Time) error {
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT app_id, app_name, start_time
+			FROM current_secondary
+			WHERE device_id = ?`, deviceID)
+		if err != nil {
+			return fmt.Errorf("query current_secondary: %w", err)
+		}
+
+		type open struct {
+			appName   string
+			startTime time.Time
+		}
+		current := make(map[string]open)
+		for rows.Next() {
+			var id string
+			var o open
+			if err := rows.Scan(&id, &o.appName, &o.startTime); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan current_secondary: %w", err)
+			}
+			current[id] = o
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterate current_secondary: %w", err)
+		}
+
+		seen := make(map[string]bool)
+		for _, a := range apps {
+			seen[a.AppID] = true
+			if _, ok := current[a.AppID]; ok {
+				if _, err := tx.ExecContext(ctx, `
+					UPDATE current_secondary SET last_seen_time = ?
+					WHERE device_id = ? AND app_id = ?`,
+					ts, deviceID, a.AppID,
+				); err != nil {
+					return fmt.Errorf("update current_secondary: %w", err)
+				}
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO current_secondary (device_id, app_id, app_name, start_time, last_seen_time)
+				VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT(device_id, app_id) DO NOTHING`,
+				deviceID, a.AppID, a.AppName, ts, ts,
+			); err != nil {
+				return fmt.Errorf("insert current_secondary: %w", err)
+			}
+		}
+
+		for id, o := range current {
+			if seen[id] {
+				continue
+			}
+			if err := endSecondaryTx(ctx, tx, deviceID, id, o.appName, o.startTime, ts); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func endSecondaryTx(ctx context.Context, tx *sql.Tx, deviceID, appID, appName string, start, end time.Time) error {
+	if end.Before(start) {
+		end = start
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO secondary_sessions (device_id, app_id, app_name, start_time, end_time, duration_seconds)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		deviceID, appID, appName, start, end, int64(end.Sub(start).Seconds()),
+	); err != nil {
+		return fmt.Errorf("insert secondary_session: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM current_secondary WHERE device_id = ? AND app_id = ?`,
+		deviceID, appID,
+	); err != nil {
+		return fmt.Errorf("delete current_secondary: %w", err)
+	}
+	return nil
+}
+
+// closeStaleSecondaryTx closes secondary sessions left open by a previous
+// run at their last-seen time.
+func closeStaleSecondaryTx(ctx context.Context, tx *sql.Tx, now time.Time) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO secondary_sessions (device_id, app_id, app_name, start_time, end_time, duration_seconds)
+		SELECT device_id, app_id, app_name, start_time,
+			MIN(last_seen_time, ?),
+			MAX(0, CAST((julianday(MIN(last_seen_time, ?)) - julianday(start_time)) * 86400 AS INTEGER))
+		FROM current_secondary`,
+		now, now,
+	); err != nil {
+		return fmt.Errorf("close stale secondary sessions: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM current_secondary`); err != nil {
+		return fmt.Errorf("delete current_secondary: %w", err)
+	}
+	return nil
+}
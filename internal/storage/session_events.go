@@ -0,0 +1,71 @@
+package storage
+
+import "time"
+
+// SessionEvent is a session starting or ending, as ApplyPoll decided, or
+// ending because its device was disabled, went offline or the hub
+// restarted.
+type SessionEvent struct {
+	// Type is "session_start" or "session_end".
+	Type      string    `json:"type"`
+	DeviceID  string    `json:"device_id"`
+	Slot      string    `json:"slot,omitempty"`
+	AppID     string    `json:"app_id"`
+	AppName   string    `json:"app_name"`
+	Account   string    `json:"account,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	// EndTime and EndReason are set for session_end, and IdleReason too
+	// when the session ended as idle for a known reason.
+	EndTime    *time.Time `json:"end_time,omitempty"`
+	EndReason  string     `json:"end_reason,omitempty"`
+	IdleReason string     `json:"idle_reason,omitempty"`
+}
+
+// OnSessionEvent makes the store call fn for every session it starts or
+// ends, once the change is committed. fn must not block.
+func (s *SessionStore) OnSessionEvent(fn func(SessionEvent)) {
+	s.onSessionEvent = fn
+}
+
+// emit passes committed events to the OnSessionEvent callback.
+func (s *SessionStore) emit(events []SessionEvent) {
+	if s.onSessionEvent == nil {
+		return
+	}
+	for _, e := range events {
+		s.onSessionEvent(e)
+	}
+}
+
+func startEvent(p PollUpdate) SessionEvent {
+	return SessionEvent{
+		Type:      "session_start",
+		DeviceID:  p.DeviceID,
+		Slot:      p.Slot,
+		AppID:     p.AppID,
+		AppName:   p.AppName,
+		Account:   p.Account,
+		Title:     p.Title,
+		StartTime: p.Timestamp,
+	}
+}
+
+func endEvent(cur *CurrentSession, end time.Time, reason, idleReason string) SessionEvent {
+	if end.Before(cur.StartTime) {
+		end = cur.StartTime
+	}
+	return SessionEvent{
+		Type:       "session_end",
+		DeviceID:   cur.DeviceID,
+		Slot:       cur.Slot,
+		AppID:      cur.AppID,
+		AppName:    cur.AppName,
+		Account:    cur.Account,
+		Title:      cur.Title,
+		StartTime:  cur.StartTime,
+		EndTime:    &end,
+		EndReason:  reason,
+		IdleReason: idleReason,
+	}
+}
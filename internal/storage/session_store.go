@@ -3,35 +3,122 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
 type SessionStore struct {
 	db *DB
+
+	// nextDayStart, when set, splits sessions as they are stored at every
+	// day boundary they cross.
+	nextDayStart func(time.Time) time.Time
+
+	// splitOn returns a device's split mode; nil means SplitOnAppID.
+	splitOn func(deviceID string) string
+
+	// onSessionEvent, when set, is told of sessions the store starts and
+	// ends.
+	onSessionEvent func(SessionEvent)
+
+	// categorize, when set, names the category stored with each session.
+	categorize func(appID, appName string) string
+
+	// dayStart, when set, returns the start of the day containing its
+	// argument; closed sessions are then rolled up per day.
+	dayStart func(time.Time) time.Time
+
+	// appCategories caches the app_categories table for AppCategory,
+	// which categorizers call for every app they see.
+	appCategoriesMu sync.RWMutex
+	appCategories   map[string]string
 }
 
+// PrimarySlot is the slot of a device's main activity, the one every
+// device reports. Agents may report further concurrent activities, such as
+// picture-in-picture, in named slots, each with its own current session.
+const PrimarySlot = ""
+
+// Split modes choose which app change ends a device's session.
+const (
+	SplitOnAppID   = "app_id"   // a new app ID, whatever the name
+	SplitOnAppName = "app_name" // a new app name, whatever the ID
+	SplitOnBoth    = "both"     // either one
+)
+
 func NewSessionStore(db *DB) *SessionStore {
 	return &SessionStore{db: db}
 }
 
+// SplitAtDayBoundaries makes the store split sessions crossing a day
+// boundary into one row per day as they are closed. next returns the first
+// boundary strictly after its argument.
+func (s *SessionStore) SplitAtDayBoundaries(next func(time.Time) time.Time) {
+	s.nextDayStart = next
+}
+
+// SplitOn sets how each device's sessions are split by ApplyPoll; mode
+// returns one of the SplitOn constants for a device ID.
+func (s *SessionStore) SplitOn(mode func(deviceID string) string) {
+	s.splitOn = mode
+}
+
+// CategorizeWith makes the store record fn's category for each session as
+// it is closed. Recategorize brings older sessions up to date.
+func (s *SessionStore) CategorizeWith(fn func(appID, appName string) string) {
+	s.categorize = fn
+}
+
+// splitMode returns the device's split mode.
+func (s *SessionStore) splitMode(deviceID string) string {
+	if s.splitOn == nil {
+		return SplitOnAppID
+	}
+	return s.splitOn(deviceID)
+}
+
 // PollUpdate represents the normalized state for a device at a point in time.
 type PollUpdate struct {
 	DeviceID  string
 	AppID     string
 	AppName   string
-	State     string // "active", "idle", "offline"
+	State     string // "active", "idle", "offline", "paused", "display_off", "other_input", "unknown"
 	Timestamp time.Time
+	// Account is the platform account the agent attributes the app to,
+	// e.g. "steam:76561198000000000"; empty when it can't tell.
+	Account string
+	// Title is the window or tab title, when the agent tracks titles.
+	// Changes within a session are recorded as title events.
+	Title string
+	// Slot is the device activity slot the update is for; PrimarySlot for
+	// the device's main activity.
+	Slot string
+	// IdleReason is one of the Idle constants, saying why an idle device
+	// is idle, or why an active one looks it, e.g. media paused but not
+	// yet for long enough to end the session. Empty when not known.
+	IdleReason string
 }
 
 type CurrentSession struct {
 	DeviceID     string
+	Slot         string
 	AppID        string
 	AppName      string
 	StartTime    time.Time
 	LastSeenTime time.Time
 	State        string
+	// ExceptionLabel marks a session a parent approved as an exception
+	// (e.g. "family movie"); it doesn't count toward limits.
+	ExceptionLabel string
+	Account        string
+	Title          string // last title seen
+	// IdleReason is the idle reason of the last poll, set while the
+	// session is held open though the device looks idle.
+	IdleReason string
 }
 
 type Session struct {
@@ -43,6 +130,15 @@ type Session struct {
 	EndTime        time.Time
 	DurationSecs   int64
 	EndReason      string
+	ExceptionLabel string
+	Slot           string
+	Category       string
+	// Account is the platform account the session was attributed to, if
+	// any.
+	Account string
+	// IdleReason is why the device went idle, when the session ended as
+	// idle or paused and the reason is known.
+	IdleReason string
 }
 
 type UsageEntry struct {
@@ -50,13 +146,20 @@ type UsageEntry struct {
 	AppID         string
 	AppName       string
 	TotalSeconds  int64
+	// ExceptionSeconds is the part of TotalSeconds spent in approved
+	// exception sessions.
+	ExceptionSeconds int64
+	// Account is the platform account the usage was attributed to, if
+	// any; the same app under two accounts is two entries.
+	Account string
 }
 
 // CloseStaleCurrentSessions closes any rows left in current_sessions at startup.
 func (s *SessionStore) CloseStaleCurrentSessions(ctx context.Context, now time.Time) error {
-	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+	var events []SessionEvent
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
 		rows, err := tx.QueryContext(ctx, `
-			SELECT device_id, app_id, app_name, start_time, last_seen_time, state
+			SELECT device_id, slot, app_id, app_name, start_time, last_seen_time, state, account, title
 			FROM current_sessions`)
 		if err != nil {
 			return fmt.Errorf("query current_sessions: %w", err)
@@ -65,17 +168,20 @@ func (s *SessionStore) CloseStaleCurrentSessions(ctx context.Context, now time.T
 
 		type rowData struct {
 			deviceID     string
+			slot         string
 			appID        string
 			appName      string
 			startTime    time.Time
 			lastSeenTime time.Time
 			state        string
+			account      string
+			title        string
 		}
 		var rowsData []rowData
 
 		for rows.Next() {
 			var r rowData
-			if err := rows.Scan(&r.deviceID, &r.appID, &r.appName, &r.startTime, &r.lastSeenTime, &r.state); err != nil {
+			if err := rows.Scan(&r.deviceID, &r.slot, &r.appID, &r.appName, &r.startTime, &r.lastSeenTime, &r.state, &r.account, &r.title); err != nil {
 				return fmt.Errorf("scan current_sessions: %w", err)
 			}
 			rowsData = append(rowsData, r)
@@ -92,43 +198,53 @@ func (s *SessionStore) CloseStaleCurrentSessions(ctx context.Context, now time.T
 			if end.Before(r.startTime) {
 				end = r.startTime
 			}
-			dur := end.Sub(r.startTime).Seconds()
-			if dur < 0 {
-				dur = 0
-			}
-			if _, err := tx.ExecContext(ctx, `
-				INSERT INTO sessions (device_id, app_id, app_name, start_time, end_time, duration_seconds, end_reason)
-				VALUES (?, ?, ?, ?, ?, ?, ?)`,
-				r.deviceID, r.appID, r.appName, r.startTime, end, int64(dur), "agent_restart",
-			); err != nil {
-				return fmt.Errorf("insert session from current_sessions: %w", err)
+			cur := CurrentSession{DeviceID: r.deviceID, Slot: r.slot, AppID: r.appID, AppName: r.appName, StartTime: r.startTime, Account: r.account, Title: r.title}
+			if err := s.insertSessionTx(ctx, tx, &cur, end, "agent_restart", ""); err != nil {
+				return err
 			}
+			events = append(events, endEvent(&cur, end, "agent_restart", ""))
 		}
 
 		if _, err := tx.ExecContext(ctx, `DELETE FROM current_sessions`); err != nil {
 			return fmt.Errorf("delete current_sessions: %w", err)
 		}
 
-		return nil
+		return closeStaleSecondaryTx(ctx, tx, now)
 	})
+	if err != nil {
+		return err
+	}
+
+	s.emit(events)
+	return nil
 }
 
-// ApplyPoll updates sessions based on a PollUpdate.
+// ErrStalePoll is returned by ApplyPoll for a poll older than the last
+// sighting of its device and slot's open session. Applying it would end or
+// extend the session backwards, so it is left out; retrying it never helps.
+var ErrStalePoll = errors.New("poll is older than the session it would change")
+
+// ApplyPoll updates sessions based on a PollUpdate. Polls must arrive in
+// timestamp order per device and slot while a session is open: one at the
+// same time as the last is applied as a duplicate, harmlessly, and an
+// earlier one is refused with ErrStalePoll. A session a late poll starts
+// begins no earlier than the slot's last closed session ended.
 func (s *SessionStore) ApplyPoll(ctx context.Context, p PollUpdate) error {
 	if p.DeviceID == "" {
 		return fmt.Errorf("poll update missing device_id")
 	}
 
-	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+	var events []SessionEvent
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
 		var cur *CurrentSession
 
 		row := tx.QueryRowContext(ctx, `
-			SELECT device_id, app_id, app_name, start_time, last_seen_time, state
+			SELECT device_id, slot, app_id, app_name, start_time, last_seen_time, state, account, title
 			FROM current_sessions
-			WHERE device_id = ?`, p.DeviceID)
+			WHERE device_id = ? AND slot = ?`, p.DeviceID, p.Slot)
 
 		var cs CurrentSession
-		err := row.Scan(&cs.DeviceID, &cs.AppID, &cs.AppName, &cs.StartTime, &cs.LastSeenTime, &cs.State)
+		err := row.Scan(&cs.DeviceID, &cs.Slot, &cs.AppID, &cs.AppName, &cs.StartTime, &cs.LastSeenTime, &cs.State, &cs.Account, &cs.Title)
 		if err == sql.ErrNoRows {
 			cur = nil
 		} else if err != nil {
@@ -137,85 +253,114 @@ func (s *SessionStore) ApplyPoll(ctx context.Context, p PollUpdate) error {
 			cur = &cs
 		}
 
-		switch p.State {
-		case "active":
-			if p.AppID == "" || p.AppName == "" {
-				// nothing useful to do
-				return nil
+		t := NextTransition(cur, p, s.splitMode(p.DeviceID))
+		if t.UnknownState {
+			log.Printf("unknown poll state %q for device %s", p.State, p.DeviceID)
+		}
+		if t.Action != ActionNone {
+			if err := checkPollOrder(cur, p); err != nil {
+				return err
 			}
+		}
 
-			if cur == nil {
-				// start new current session
-				if _, err := tx.ExecContext(ctx, `
-					INSERT INTO current_sessions (device_id, app_id, app_name, start_time, last_seen_time, state)
-					VALUES (?, ?, ?, ?, ?, 'active')`,
-					p.DeviceID, p.AppID, p.AppName, p.Timestamp, p.Timestamp,
-				); err != nil {
-					return fmt.Errorf("insert current_session: %w", err)
-				}
-				return nil
+		switch t.Action {
+		case ActionEnd, ActionSwitch:
+			if err := s.endSessionTx(ctx, tx, cur, p.Timestamp, t.EndReason, t.IdleReason); err != nil {
+				return err
 			}
+			events = append(events, endEvent(cur, p.Timestamp, t.EndReason, t.IdleReason))
+		}
 
-			if cur.AppID != p.AppID {
-				// close old session
-				if err := endSessionTx(ctx, tx, cur, p.Timestamp, "app_change"); err != nil {
+		switch t.Action {
+		case ActionStart, ActionSwitch:
+			if cur == nil {
+				if p.Timestamp, err = startAfterLastTx(ctx, tx, p); err != nil {
 					return err
 				}
-				// start new current session
-				if _, err := tx.ExecContext(ctx, `
-					INSERT INTO current_sessions (device_id, app_id, app_name, start_time, last_seen_time, state)
-					VALUES (?, ?, ?, ?, ?, 'active')`,
-					p.DeviceID, p.AppID, p.AppName, p.Timestamp, p.Timestamp,
-				); err != nil {
-					return fmt.Errorf("insert new current_session: %w", err)
-				}
-				return nil
 			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO current_sessions (device_id, slot, app_id, app_name, start_time, last_seen_time, state, account, title, idle_reason)
+				VALUES (?, ?, ?, ?, ?, ?, 'active', ?, ?, ?)`,
+				p.DeviceID, p.Slot, p.AppID, p.AppName, p.Timestamp, p.Timestamp, p.Account, p.Title, p.IdleReason,
+			); err != nil {
+				return fmt.Errorf("insert current_session: %w", err)
+			}
+			events = append(events, startEvent(p))
+			return recordTitleTx(ctx, tx, p)
 
-			// same app: just update last_seen_time
+		case ActionTouch:
 			if _, err := tx.ExecContext(ctx, `
 				UPDATE current_sessions
-				SET last_seen_time = ?
-				WHERE device_id = ?`,
-				p.Timestamp, p.DeviceID,
+				SET last_seen_time = ?, idle_reason = ?
+				WHERE device_id = ? AND slot = ?`,
+				p.Timestamp, p.IdleReason, p.DeviceID, p.Slot,
 			); err != nil {
 				return fmt.Errorf("update current_session last_seen: %w", err)
 			}
-
-		case "idle", "offline":
-			if cur == nil {
-				return nil
-			}
-			if err := endSessionTx(ctx, tx, cur, p.Timestamp, p.State); err != nil {
-				return err
+			if t.NewTitle {
+				if _, err := tx.ExecContext(ctx, `
+					UPDATE current_sessions SET title = ? WHERE device_id = ? AND slot = ?`,
+					p.Title, p.DeviceID, p.Slot,
+				); err != nil {
+					return fmt.Errorf("update current_session title: %w", err)
+				}
+				return recordTitleTx(ctx, tx, p)
 			}
-		default:
-			// unknown state; ignore
-			log.Printf("unknown poll state %q for device %s", p.State, p.DeviceID)
 		}
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	s.emit(events)
+	return nil
 }
 
-func endSessionTx(ctx context.Context, tx *sql.Tx, cur *CurrentSession, end time.Time, reason string) error {
+// checkPollOrder returns ErrStalePoll if p is older than the last poll
+// applied to the open session cur. With no session open any poll goes;
+// startAfterLastTx starts its session no earlier than the last closed one
+// ended, and polls behind that are then refused here until they catch up,
+// as that time is counted already.
+func checkPollOrder(cur *CurrentSession, p PollUpdate) error {
+	if cur == nil || !p.Timestamp.Before(cur.LastSeenTime) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s at %s, last recorded %s", ErrStalePoll,
+		p.DeviceID, p.Timestamp.UTC().Format(time.RFC3339), cur.LastSeenTime.UTC().Format(time.RFC3339))
+}
+
+// startAfterLastTx returns when a session p starts should begin: at p's
+// time, or where the slot's last closed session ended if that is later,
+// so a late poll doesn't count the same time twice.
+func startAfterLastTx(ctx context.Context, tx *sql.Tx, p PollUpdate) (time.Time, error) {
+	var last time.Time
+	err := tx.QueryRowContext(ctx, `
+		SELECT end_time FROM sessions
+		WHERE device_id = ? AND slot = ?
+		ORDER BY end_time DESC
+		LIMIT 1`, p.DeviceID, p.Slot,
+	).Scan(&last)
+	if err == sql.ErrNoRows || (err == nil && !p.Timestamp.Before(last)) {
+		return p.Timestamp, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query last session: %w", err)
+	}
+	return last, nil
+}
+
+func (s *SessionStore) endSessionTx(ctx context.Context, tx *sql.Tx, cur *CurrentSession, end time.Time, reason, idleReason string) error {
 	if end.Before(cur.StartTime) {
 		end = cur.StartTime
 	}
-	dur := end.Sub(cur.StartTime).Seconds()
-	if dur < 0 {
-		dur = 0
-	}
-	if _, err := tx.ExecContext(ctx, `
-		INSERT INTO sessions (device_id, app_id, app_name, start_time, end_time, duration_seconds, end_reason)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		cur.DeviceID, cur.AppID, cur.AppName, cur.StartTime, end, int64(dur), reason,
-	); err != nil {
-		return fmt.Errorf("insert session: %w", err)
+	if err := s.insertSessionTx(ctx, tx, cur, end, reason, idleReason); err != nil {
+		return err
 	}
 
 	if _, err := tx.ExecContext(ctx, `
-		DELETE FROM current_sessions WHERE device_id = ?`, cur.DeviceID); err != nil {
+		DELETE FROM current_sessions WHERE device_id = ? AND slot = ?`, cur.DeviceID, cur.Slot); err != nil {
 		return fmt.Errorf("delete current_session: %w", err)
 	}
 	return nil
@@ -224,8 +369,9 @@ func endSessionTx(ctx context.Context, tx *sql.Tx, cur *CurrentSession, end time
 // GetCurrentSessions returns all active current_sessions.
 func (s *SessionStore) GetCurrentSessions(ctx context.Context) ([]CurrentSession, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT device_id, app_id, app_name, start_time, last_seen_time, state
-		FROM current_sessions`)
+		SELECT device_id, slot, app_id, app_name, start_time, last_seen_time, state, exception_label, account, idle_reason
+		FROM current_sessions
+		ORDER BY device_id, slot`)
 	if err != nil {
 		return nil, fmt.Errorf("query current_sessions: %w", err)
 	}
@@ -234,7 +380,7 @@ func (s *SessionStore) GetCurrentSessions(ctx context.Context) ([]CurrentSession
 	var out []CurrentSession
 	for rows.Next() {
 		var cs CurrentSession
-		if err := rows.Scan(&cs.DeviceID, &cs.AppID, &cs.AppName, &cs.StartTime, &cs.LastSeenTime, &cs.State); err != nil {
+		if err := rows.Scan(&cs.DeviceID, &cs.Slot, &cs.AppID, &cs.AppName, &cs.StartTime, &cs.LastSeenTime, &cs.State, &cs.ExceptionLabel, &cs.Account, &cs.IdleReason); err != nil {
 			return nil, fmt.Errorf("scan current_session: %w", err)
 		}
 		out = append(out, cs)
@@ -245,25 +391,46 @@ func (s *SessionStore) GetCurrentSessions(ctx context.Context) ([]CurrentSession
 	return out, nil
 }
 
-// GetSessions returns historic sessions, optionally filtered.
-func (s *SessionStore) GetSessions(ctx context.Context, deviceID *string, since, until *time.Time) ([]Session, error) {
+// SessionFilter narrows GetSessions; its zero value matches every session.
+type SessionFilter struct {
+	DeviceID *string
+	// Since and Until bound the session start time, [Since, Until).
+	Since, Until *time.Time
+	// MinDuration leaves out sessions shorter than it.
+	MinDuration time.Duration
+	// Categories, when set, keeps sessions stored under any of them.
+	Categories []string
+}
+
+// GetSessions returns historic sessions matching f.
+func (s *SessionStore) GetSessions(ctx context.Context, f SessionFilter) ([]Session, error) {
 	q := `
-		SELECT id, device_id, app_id, app_name, start_time, end_time, duration_seconds, end_reason
+		SELECT id, device_id, app_id, app_name, start_time, end_time, duration_seconds, end_reason, exception_label, slot, category, account, idle_reason
 		FROM sessions
 		WHERE 1=1`
 	var args []any
 
-	if deviceID != nil {
+	if f.DeviceID != nil {
 		q += " AND device_id = ?"
-		args = append(args, *deviceID)
+		args = append(args, *f.DeviceID)
 	}
-	if since != nil {
+	if f.Since != nil {
 		q += " AND start_time >= ?"
-		args = append(args, *since)
+		args = append(args, *f.Since)
 	}
-	if until != nil {
+	if f.Until != nil {
 		q += " AND start_time < ?"
-		args = append(args, *until)
+		args = append(args, *f.Until)
+	}
+	if f.MinDuration > 0 {
+		q += " AND duration_seconds >= ?"
+		args = append(args, int64(f.MinDuration/time.Second))
+	}
+	if len(f.Categories) > 0 {
+		q += " AND category IN (?" + strings.Repeat(", ?", len(f.Categories)-1) + ")"
+		for _, c := range f.Categories {
+			args = append(args, c)
+		}
 	}
 	q += " ORDER BY start_time ASC"
 
@@ -278,7 +445,7 @@ func (s *SessionStore) GetSessions(ctx context.Context, deviceID *string, since,
 		var se Session
 		if err := rows.Scan(
 			&se.ID, &se.DeviceID, &se.AppID, &se.AppName,
-			&se.StartTime, &se.EndTime, &se.DurationSecs, &se.EndReason,
+			&se.StartTime, &se.EndTime, &se.DurationSecs, &se.EndReason, &se.ExceptionLabel, &se.Slot, &se.Category, &se.Account, &se.IdleReason,
 		); err != nil {
 			return nil, fmt.Errorf("scan session: %w", err)
 		}
@@ -291,6 +458,10 @@ func (s *SessionStore) GetSessions(ctx context.Context, deviceID *string, since,
 }
 
 // GetUsageBetween aggregates usage per device/app between [start, end).
+// Time in approved exception sessions is included and also reported
+// separately in ExceptionSeconds. With RollUpDaily set, whole days that
+// have been rolled up are read from daily_usage rather than summed from
+// their sessions.
 func (s *SessionStore) GetUsageBetween(
 	ctx context.Context,
 	start, end time.Time,
@@ -300,58 +471,35 @@ func (s *SessionStore) GetUsageBetween(
 		return nil, nil
 	}
 
-	type key struct {
-		deviceID string
-		appID    string
-		appName  string
+	agg := newUsageAgg()
+	ranges := [][2]time.Time{{start, end}}
+	if s.dayStart != nil {
+		var days []time.Time
+		var err error
+		if days, ranges, err = s.splitRolledUp(ctx, start, end); err != nil {
+			return nil, err
+		}
+		if err := s.addRolledUpUsage(ctx, agg, days, deviceID); err != nil {
+			return nil, err
+		}
 	}
-	agg := make(map[key]int64)
 
 	// Closed sessions
-	q := `
-		SELECT device_id, app_id, app_name, start_time, end_time
-		FROM sessions
-		WHERE end_time > ? AND start_time < ?`
-	args := []any{start, end}
-	if deviceID != nil {
-		q += " AND device_id = ?"
-		args = append(args, *deviceID)
-	}
-
-	rows, err := s.db.QueryContext(ctx, q, args...)
-	if err != nil {
-		return nil, fmt.Errorf("query sessions for usage: %w", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var device, appID, appName string
-		var sStart, sEnd time.Time
-		if err := rows.Scan(&device, &appID, &appName, &sStart, &sEnd); err != nil {
-			return nil, fmt.Errorf("scan session for usage: %w", err)
-		}
-		eStart := maxTime(start, sStart)
-		eEnd := minTime(end, sEnd)
-		if eEnd.After(eStart) {
-			secs := int64(eEnd.Sub(eStart).Seconds())
-			if secs < 0 {
-				secs = 0
-			}
-			k := key{deviceID: device, appID: appID, appName: appName}
-			agg[k] += secs
+	for _, r := range ranges {
+		if err := addSessionUsageTx(ctx, s.db, agg, r[0], r[1], deviceID); err != nil {
+			return nil, err
 		}
 	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate sessions for usage: %w", err)
-	}
 
 	// Current sessions
 	qCur := `
-		SELECT device_id, app_id, app_name, start_time, last_seen_time
-		FROM current_sessions`
+		SELECT c.device_id, c.app_id, COALESCE(a.app_name, c.app_name),
+			c.start_time, c.last_seen_time, c.exception_label, c.account
+		FROM current_sessions c
+		LEFT JOIN apps a ON a.device_id = c.device_id AND a.app_id = c.app_id`
 	var argsCur []any
 	if deviceID != nil {
-		qCur += " WHERE device_id = ?"
+		qCur += " WHERE c.device_id = ?"
 		argsCur = append(argsCur, *deviceID)
 	}
 
@@ -364,43 +512,191 @@ func (s *SessionStore) GetUsageBetween(
 	now := end
 
 	for rowsCur.Next() {
-		var device, appID, appName string
+		var device, appID, appName, label, account string
 		var sStart, sLast time.Time
-		if err := rowsCur.Scan(&device, &appID, &appName, &sStart, &sLast); err != nil {
+		if err := rowsCur.Scan(&device, &appID, &appName, &sStart, &sLast, &label, &account); err != nil {
 			return nil, fmt.Errorf("scan current_session for usage: %w", err)
 		}
 		sEnd := now
 		if sLast.Before(sEnd) {
 			sEnd = sLast
 		}
-		eStart := maxTime(start, sStart)
-		eEnd := minTime(end, sEnd)
-		if eEnd.After(eStart) {
-			secs := int64(eEnd.Sub(eStart).Seconds())
-			if secs < 0 {
-				secs = 0
-			}
-			k := key{deviceID: device, appID: appID, appName: appName}
-			agg[k] += secs
-		}
+		agg.addSpan(device, appID, appName, label, account, sStart, sEnd, start, end)
 	}
 	if err := rowsCur.Err(); err != nil {
 		return nil, fmt.Errorf("iterate current_sessions for usage: %w", err)
 	}
 
 	var out []UsageEntry
-	for k, secs := range agg {
+	for k, secs := range agg.seconds {
 		out = append(out, UsageEntry{
 			DeviceID:     k.deviceID,
 			AppID:        k.appID,
-			AppName:      k.appName,
+			AppName:      agg.names[k],
 			TotalSeconds: secs,
+
+			ExceptionSeconds: agg.exceptions[k],
+			Account:          k.account,
 		})
 	}
 
 	return out, nil
 }
 
+// usageKey identifies one usage entry. Usage is keyed on app ID alone so a
+// renamed channel stays one entry.
+type usageKey struct {
+	deviceID string
+	appID    string
+	account  string
+}
+
+// usageAgg sums usage per usageKey. The display name comes from the apps
+// table, which tracks the latest name seen; without a row there, the most
+// recently started session's name wins.
+type usageAgg struct {
+	seconds    map[usageKey]int64
+	exceptions map[usageKey]int64
+	names      map[usageKey]string
+	nameStart  map[usageKey]time.Time
+}
+
+func newUsageAgg() *usageAgg {
+	return &usageAgg{
+		seconds:    make(map[usageKey]int64),
+		exceptions: make(map[usageKey]int64),
+		names:      make(map[usageKey]string),
+		nameStart:  make(map[usageKey]time.Time),
+	}
+}
+
+// add counts secs, exceptionSecs of them in an exception, for an app
+// whose session started at nameStart under appName.
+func (u *usageAgg) add(device, appID, appName, account string, nameStart time.Time, secs, exceptionSecs int64) {
+	k := usageKey{deviceID: device, appID: appID, account: account}
+	if _, ok := u.names[k]; !ok || !nameStart.Before(u.nameStart[k]) {
+		u.names[k] = appName
+		u.nameStart[k] = nameStart
+	}
+	if secs > 0 {
+		u.seconds[k] += secs
+		u.exceptions[k] += exceptionSecs
+	}
+}
+
+// addSpan counts the part of a session spanning [sStart, sEnd) that falls
+// in [start, end).
+func (u *usageAgg) addSpan(device, appID, appName, label, account string, sStart, sEnd, start, end time.Time) {
+	var secs int64
+	if eStart, eEnd := maxTime(start, sStart), minTime(end, sEnd); eEnd.After(eStart) {
+		secs = int64(eEnd.Sub(eStart).Seconds())
+	}
+	var exceptionSecs int64
+	if label != "" {
+		exceptionSecs = secs
+	}
+	u.add(device, appID, appName, account, sStart, secs, exceptionSecs)
+}
+
+// queryer is a *DB or *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// addSessionUsageTx adds the closed sessions overlapping [start, end),
+// clipped to it, to agg.
+func addSessionUsageTx(ctx context.Context, q queryer, agg *usageAgg, start, end time.Time, deviceID *string) error {
+	query := `
+		SELECT s.device_id, s.app_id, COALESCE(a.app_name, s.app_name),
+			s.start_time, s.end_time, s.exception_label, s.account
+		FROM sessions s
+		LEFT JOIN apps a ON a.device_id = s.device_id AND a.app_id = s.app_id
+		WHERE s.end_time > ? AND s.start_time < ?`
+	args := []any{start, end}
+	if deviceID != nil {
+		query += " AND s.device_id = ?"
+		args = append(args, *deviceID)
+	}
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("query sessions for usage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var device, appID, appName, label, account string
+		var sStart, sEnd time.Time
+		if err := rows.Scan(&device, &appID, &appName, &sStart, &sEnd, &label, &account); err != nil {
+			return fmt.Errorf("scan session for usage: %w", err)
+		}
+		agg.addSpan(device, appID, appName, label, account, sStart, sEnd, start, end)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate sessions for usage: %w", err)
+	}
+	return nil
+}
+
+// insertSessionTx stores cur as a closed session ending at end, split at
+// day boundaries when configured. Every piece but the last ends with
+// reason "day_boundary" and the title showing at the boundary is recorded
+// again, so each piece has its own opening title event. The exception
+// label and title are read from the current session row, so it must not
+// have been deleted yet. idleReason goes on the last piece only.
+func (s *SessionStore) insertSessionTx(ctx context.Context, tx *sql.Tx, cur *CurrentSession, end time.Time, reason, idleReason string) error {
+	category := ""
+	if s.categorize != nil {
+		category = s.categorize(cur.AppID, cur.AppName)
+	}
+	start := cur.StartTime
+	if s.nextDayStart != nil {
+		for {
+			boundary := s.nextDayStart(start)
+			if !boundary.Before(end) {
+				break
+			}
+			if err := s.insertSessionRowTx(ctx, tx, cur, start, boundary, "day_boundary", "", category); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO title_events (device_id, app_id, time, title)
+				SELECT device_id, app_id, ?, title
+				FROM current_sessions
+				WHERE device_id = ? AND slot = ? AND title != ''`,
+				boundary.UTC(), cur.DeviceID, cur.Slot,
+			); err != nil {
+				return fmt.Errorf("insert boundary title_event: %w", err)
+			}
+			start = boundary
+		}
+	}
+	return s.insertSessionRowTx(ctx, tx, cur, start, end, reason, idleReason, category)
+}
+
+func (s *SessionStore) insertSessionRowTx(ctx context.Context, tx *sql.Tx, cur *CurrentSession, start, end time.Time, reason, idleReason, category string) error {
+	dur := end.Sub(start).Seconds()
+	if dur < 0 {
+		dur = 0
+	}
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO sessions (device_id, slot, app_id, app_name, start_time, end_time, duration_seconds, end_reason, exception_label, account, category, idle_reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?,
+			(SELECT exception_label FROM current_sessions WHERE device_id = ? AND slot = ?),
+			(SELECT account FROM current_sessions WHERE device_id = ? AND slot = ?), ?, ?)`,
+		cur.DeviceID, cur.Slot, cur.AppID, cur.AppName, start.UTC(), end.UTC(), int64(dur), reason,
+		cur.DeviceID, cur.Slot, cur.DeviceID, cur.Slot, category, idleReason,
+	)
+	if err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert session id: %w", err)
+	}
+	return s.rollUpSessionTx(ctx, tx, id, start, end)
+}
+
 func maxTime(a, b time.Time) time.Time {
 	if a.After(b) {
 		return a
@@ -18,7 +18,24 @@ func NewDB(ctx context.Context, path string) (*DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
+	return initDB(ctx, db)
+}
+
+// NewMemoryDB opens a database that lives only in memory, gone when it is
+// closed. Every SQLite connection to ":memory:" is its own database, so
+// it is kept to a single connection.
+func NewMemoryDB(ctx context.Context) (*DB, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	return initDB(ctx, db)
+}
 
+func initDB(ctx context.Context, db *sql.DB) (*DB, error) {
 	// Enable foreign keys (good practice, even if not heavily used here)
 	if _, err := db.ExecContext(ctx, `PRAGMA foreign_keys = ON;`); err != nil {
 		log.Printf("warning: failed to enable foreign_keys pragma: %v", err)
@@ -62,6 +79,116 @@ func (db *DB) runMigrations(ctx context.Context) error {
 			last_seen_time DATETIME NOT NULL,
 			state TEXT NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS devices (
+			device_id TEXT PRIMARY KEY,
+			enabled INTEGER NOT NULL DEFAULT 1
+		);`,
+		`CREATE TABLE IF NOT EXISTS apps (
+			device_id TEXT NOT NULL,
+			app_id TEXT NOT NULL,
+			app_name TEXT NOT NULL,
+			first_seen DATETIME NOT NULL,
+			last_seen DATETIME NOT NULL,
+			PRIMARY KEY (device_id, app_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS app_names (
+			device_id TEXT NOT NULL,
+			app_id TEXT NOT NULL,
+			app_name TEXT NOT NULL,
+			first_seen DATETIME NOT NULL,
+			last_seen DATETIME NOT NULL,
+			PRIMARY KEY (device_id, app_id, app_name)
+		);`,
+		// Seed name history from sessions recorded before app_names existed.
+		`INSERT OR IGNORE INTO app_names (device_id, app_id, app_name, first_seen, last_seen)
+		 SELECT device_id, app_id, app_name, MIN(start_time), MAX(end_time)
+		 FROM sessions
+		 WHERE NOT EXISTS (SELECT 1 FROM app_names)
+		 GROUP BY device_id, app_id, app_name;`,
+		`CREATE TABLE IF NOT EXISTS secondary_sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id TEXT NOT NULL,
+			app_id TEXT NOT NULL,
+			app_name TEXT NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			duration_seconds INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_secondary_sessions_device_time
+		 ON secondary_sessions(device_id, start_time);`,
+		`CREATE TABLE IF NOT EXISTS current_secondary (
+			device_id TEXT NOT NULL,
+			app_id TEXT NOT NULL,
+			app_name TEXT NOT NULL,
+			start_time DATETIME NOT NULL,
+			last_seen_time DATETIME NOT NULL,
+			PRIMARY KEY (device_id, app_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS raw_polls (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id TEXT NOT NULL,
+			app_id TEXT NOT NULL,
+			app_name TEXT NOT NULL,
+			state TEXT NOT NULL,
+			timestamp DATETIME NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_raw_polls_time
+		 ON raw_polls(timestamp, device_id);`,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time DATETIME NOT NULL,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			target TEXT NOT NULL,
+			details TEXT NOT NULL DEFAULT '{}'
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_time ON audit_log(time);`,
+		`CREATE TABLE IF NOT EXISTS title_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id TEXT NOT NULL,
+			app_id TEXT NOT NULL,
+			time DATETIME NOT NULL,
+			title TEXT NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_title_events_device_time
+		 ON title_events(device_id, time);`,
+		`CREATE TABLE IF NOT EXISTS hub_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_hub_events_kind_time
+		 ON hub_events(kind, start_time);`,
+		`CREATE TABLE IF NOT EXISTS export_cursors (
+			name TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS daily_usage (
+			day DATETIME NOT NULL,
+			device_id TEXT NOT NULL,
+			app_id TEXT NOT NULL,
+			account TEXT NOT NULL DEFAULT '',
+			app_name TEXT NOT NULL,
+			name_start DATETIME NOT NULL,
+			seconds INTEGER NOT NULL,
+			exception_seconds INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (day, device_id, app_id, account)
+		);`,
+		`CREATE TABLE IF NOT EXISTS rollup_days (
+			day DATETIME PRIMARY KEY
+		);`,
+		`CREATE TABLE IF NOT EXISTS app_categories (
+			app_id TEXT PRIMARY KEY,
+			category TEXT NOT NULL,
+			assigned_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS push_timestamps (
+			device_id TEXT NOT NULL,
+			slot TEXT NOT NULL,
+			unix_nanos INTEGER NOT NULL,
+			PRIMARY KEY (device_id, slot)
+		);`,
 	}
 
 	for _, stmt := range stmts {
@@ -69,6 +196,111 @@ func (db *DB) runMigrations(ctx context.Context) error {
 			return fmt.Errorf("run migration: %w", err)
 		}
 	}
+
+	// Columns added after a table was first created. SQLite has no
+	// ADD COLUMN IF NOT EXISTS, so check table_info first.
+	columns := []struct {
+		table, column, def string
+	}{
+		{"devices", "first_seen", "DATETIME"},
+		{"devices", "last_seen", "DATETIME"},
+		{"devices", "metadata", "TEXT NOT NULL DEFAULT '{}'"},
+		{"devices", "source", "TEXT NOT NULL DEFAULT ''"},
+		{"devices", "display_name", "TEXT NOT NULL DEFAULT ''"},
+		{"sessions", "exception_label", "TEXT NOT NULL DEFAULT ''"},
+		{"current_sessions", "exception_label", "TEXT NOT NULL DEFAULT ''"},
+		{"sessions", "account", "TEXT NOT NULL DEFAULT ''"},
+		{"current_sessions", "account", "TEXT NOT NULL DEFAULT ''"},
+		{"raw_polls", "account", "TEXT NOT NULL DEFAULT ''"},
+		{"current_sessions", "title", "TEXT NOT NULL DEFAULT ''"},
+		{"sessions", "slot", "TEXT NOT NULL DEFAULT ''"},
+		{"raw_polls", "slot", "TEXT NOT NULL DEFAULT ''"},
+		{"sessions", "category", "TEXT NOT NULL DEFAULT ''"},
+		{"sessions", "idle_reason", "TEXT NOT NULL DEFAULT ''"},
+		{"current_sessions", "idle_reason", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, c := range columns {
+		if err := db.addColumnIfMissing(ctx, c.table, c.column, c.def); err != nil {
+			return err
+		}
+	}
+	return db.migrateCurrentSessionSlots(ctx)
+}
+
+// migrateCurrentSessionSlots rebuilds current_sessions keyed by device and
+// slot rather than device alone. SQLite can't change a primary key in
+// place, so the rows are copied into a new table.
+func (db *DB) migrateCurrentSessionSlots(ctx context.Context) error {
+	var n int
+	if err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM pragma_table_info('current_sessions') WHERE name = 'slot'`,
+	).Scan(&n); err != nil {
+		return fmt.Errorf("check current_sessions.slot: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	return db.WithTx(ctx, func(tx *sql.Tx) error {
+		for _, stmt := range []string{
+			`CREATE TABLE current_sessions_slots (
+				device_id TEXT NOT NULL,
+				slot TEXT NOT NULL DEFAULT '',
+				app_id TEXT NOT NULL,
+				app_name TEXT NOT NULL,
+				start_time DATETIME NOT NULL,
+				last_seen_time DATETIME NOT NULL,
+				state TEXT NOT NULL,
+				exception_label TEXT NOT NULL DEFAULT '',
+				account TEXT NOT NULL DEFAULT '',
+				title TEXT NOT NULL DEFAULT '',
+				idle_reason TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY (device_id, slot)
+			);`,
+			`INSERT INTO current_sessions_slots
+				(device_id, app_id, app_name, start_time, last_seen_time, state, exception_label, account, title)
+			 SELECT device_id, app_id, app_name, start_time, last_seen_time, state, exception_label, account, title
+			 FROM current_sessions;`,
+			`DROP TABLE current_sessions;`,
+			`ALTER TABLE current_sessions_slots RENAME TO current_sessions;`,
+		} {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("migrate current_sessions slots: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) addColumnIfMissing(ctx context.Context, table, column, def string) error {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return fmt.Errorf("table_info %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name, typ string
+			notNull   bool
+			dflt      sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("scan table_info %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate table_info %s: %w", table, err)
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, def)); err != nil {
+		return fmt.Errorf("add column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TitleEvent is a window or tab title an agent reported, recorded when a
+// session starts and whenever the title changes without the app changing.
+type TitleEvent struct {
+	DeviceID string
+	AppID    string
+	Time     time.Time
+	Title    string
+}
+
+// recordTitleTx records p's title as a title event; polls without one are
+// ignored.
+func recordTitleTx(ctx context.Context, tx *sql.Tx, p PollUpdate) error {
+	if p.Title == "" {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO title_events (device_id, app_id, time, title)
+		VALUES (?, ?, ?, ?)`,
+		p.DeviceID, p.AppID, p.Timestamp, p.Title,
+	); err != nil {
+		return fmt.Errorf("insert title_event: %w", err)
+	}
+	return nil
+}
+
+// GetSession returns the stored session with the given ID, or nil if there
+// is none.
+func (s *SessionStore) GetSession(ctx context.Context, id int64) (*Session, error) {
+	var se Session
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, device_id, app_id, app_name, start_time, end_time, duration_seconds, end_reason, exception_label, slot
+		FROM sessions
+		WHERE id = ?`, id,
+	).Scan(
+		&se.ID, &se.DeviceID, &se.AppID, &se.AppName,
+		&se.StartTime, &se.EndTime, &se.DurationSecs, &se.EndReason, &se.ExceptionLabel, &se.Slot,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query session: %w", err)
+	}
+	return &se, nil
+}
+
+// GetTitleEvents returns a device's title events for appID between
+// [start, end), in time order.
+func (s *SessionStore) GetTitleEvents(ctx context.Context, deviceID, appID string, start, end time.Time) ([]TitleEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT device_id, app_id, time, title
+		FROM title_events
+		WHERE device_id = ? AND app_id = ? AND time >= ? AND time < ?
+		ORDER BY time ASC, id ASC`,
+		deviceID, appID, start.UTC(), end.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query title_events: %w", err)
+	}
+	defer rows.Close()
+
+	var out []TitleEvent
+	for rows.Next() {
+		var e TitleEvent
+		if err := rows.Scan(&e.DeviceID, &e.AppID, &e.Time, &e.Title); err != nil {
+			return nil, fmt.Errorf("scan title_event: %w", err)
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate title_events: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,107 @@
+package storage
+
+// Action is what a poll does to a device's current session.
+type Action int
+
+const (
+	// ActionNone leaves the current session, if any, alone.
+	ActionNone Action = iota
+	// ActionStart opens a session for the polled app.
+	ActionStart
+	// ActionTouch extends the current session to the poll's time.
+	ActionTouch
+	// ActionEnd closes the current session at the poll's time.
+	ActionEnd
+	// ActionSwitch closes the current session and opens one for the
+	// polled app.
+	ActionSwitch
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionStart:
+		return "start"
+	case ActionTouch:
+		return "touch"
+	case ActionEnd:
+		return "end"
+	case ActionSwitch:
+		return "switch"
+	}
+	return "none"
+}
+
+// Transition is the outcome of applying a poll to a device's current
+// session.
+type Transition struct {
+	Action Action
+	// EndReason is why the current session ends, for ActionEnd and
+	// ActionSwitch.
+	EndReason string
+	// IdleReason is why the device went idle, for an ActionEnd by an idle
+	// or paused poll.
+	IdleReason string
+	// NewTitle is set when an ActionTouch poll reports a title other than
+	// the session's last one, recorded as a title event.
+	NewTitle bool
+	// UnknownState is set when the poll's state isn't one ApplyPoll knows.
+	UnknownState bool
+}
+
+// NextTransition decides what poll p does to the current session cur (nil
+// when the device has none), under split mode splitOn. It only decides;
+// ApplyPoll carries the transition out in SQL.
+func NextTransition(cur *CurrentSession, p PollUpdate, splitOn string) Transition {
+	switch p.State {
+	case "active":
+		if p.AppID == "" || p.AppName == "" {
+			// nothing useful to do
+			return Transition{}
+		}
+		if cur == nil {
+			return Transition{Action: ActionStart}
+		}
+		if appChanged(cur, p, splitOn) {
+			return Transition{Action: ActionSwitch, EndReason: "app_change"}
+		}
+		// A new title is a sub-event, not a new session.
+		return Transition{
+			Action:   ActionTouch,
+			NewTitle: p.Title != "" && p.Title != cur.Title,
+		}
+	case "idle", "offline", "paused", "display_off", "other_input":
+		if cur == nil {
+			return Transition{}
+		}
+		t := Transition{Action: ActionEnd, EndReason: p.State}
+		switch p.State {
+		case "idle":
+			t.IdleReason = p.IdleReason
+		case "paused":
+			t.IdleReason = IdlePausedMedia
+		}
+		return t
+	case "unknown":
+		// The device hasn't answered since hub startup; leave any
+		// session alone until it does.
+		return Transition{}
+	}
+	return Transition{UnknownState: true}
+}
+
+// appChanged reports whether p is a different app than cur under split
+// mode mode. A change of account always counts, so each account's time is
+// kept apart.
+func appChanged(cur *CurrentSession, p PollUpdate, mode string) bool {
+	if cur.Account != p.Account {
+		return true
+	}
+	switch mode {
+	case SplitOnAppName:
+		return cur.AppName != p.AppName
+	case SplitOnBoth:
+		return cur.AppID != p.AppID || cur.AppName != p.AppName
+	default:
+		return cur.AppID != p.AppID
+	}
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextTransition(t *testing.T) {
+	at := time.Date(2026, 1, 2, 20, 0, 0, 0, time.UTC)
+	cur := &CurrentSession{
+		DeviceID:  "tv",
+		AppID:     "12",
+		AppName:   "Netflix",
+		StartTime: at.Add(-time.Hour),
+		State:     "active",
+		Title:     "Episode 1",
+	}
+	withAccount := *cur
+	withAccount.Account = "steam:1"
+	inSlot := *cur
+	inSlot.Slot = "pip"
+
+	poll := func(state, appID, appName string) PollUpdate {
+		return PollUpdate{DeviceID: "tv", AppID: appID, AppName: appName, State: state, Timestamp: at}
+	}
+	titled := func(p PollUpdate, title string) PollUpdate {
+		p.Title = title
+		return p
+	}
+	slotted := func(p PollUpdate, slot string) PollUpdate {
+		p.Slot = slot
+		return p
+	}
+	idle := func(reason string) PollUpdate {
+		p := poll("idle", "", "")
+		p.IdleReason = reason
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		cur     *CurrentSession
+		poll    PollUpdate
+		splitOn string
+		want    Transition
+	}{
+		{"start", nil, poll("active", "12", "Netflix"), SplitOnAppID,
+			Transition{Action: ActionStart}},
+		{"start without app id", nil, poll("active", "", "Netflix"), SplitOnAppID,
+			Transition{}},
+		{"start without app name", nil, poll("active", "12", ""), SplitOnAppID,
+			Transition{}},
+		{"start in slot", nil, slotted(poll("active", "13", "YouTube"), "pip"), SplitOnAppID,
+			Transition{Action: ActionStart}},
+
+		{"touch", cur, poll("active", "12", "Netflix"), SplitOnAppID,
+			Transition{Action: ActionTouch}},
+		{"touch in slot", &inSlot, slotted(poll("active", "12", "Netflix"), "pip"), SplitOnAppID,
+			Transition{Action: ActionTouch}},
+		{"touch same title", cur, titled(poll("active", "12", "Netflix"), "Episode 1"), SplitOnAppID,
+			Transition{Action: ActionTouch}},
+		{"touch new title", cur, titled(poll("active", "12", "Netflix"), "Episode 2"), SplitOnAppID,
+			Transition{Action: ActionTouch, NewTitle: true}},
+		{"touch without title", cur, poll("active", "12", "Netflix"), SplitOnAppID,
+			Transition{Action: ActionTouch}},
+
+		{"switch app id", cur, poll("active", "13", "YouTube"), SplitOnAppID,
+			Transition{Action: ActionSwitch, EndReason: "app_change"}},
+		{"switch account", &withAccount, poll("active", "12", "Netflix"), SplitOnAppID,
+			Transition{Action: ActionSwitch, EndReason: "app_change"}},
+
+		{"split on app_id ignores name", cur, poll("active", "12", "Netflix Kids"), SplitOnAppID,
+			Transition{Action: ActionTouch}},
+		{"split on app_name ignores id", cur, poll("active", "99", "Netflix"), SplitOnAppName,
+			Transition{Action: ActionTouch}},
+		{"split on app_name", cur, poll("active", "12", "Netflix Kids"), SplitOnAppName,
+			Transition{Action: ActionSwitch, EndReason: "app_change"}},
+		{"split on both by id", cur, poll("active", "99", "Netflix"), SplitOnBoth,
+			Transition{Action: ActionSwitch, EndReason: "app_change"}},
+		{"split on both by name", cur, poll("active", "12", "Netflix Kids"), SplitOnBoth,
+			Transition{Action: ActionSwitch, EndReason: "app_change"}},
+		{"split on both unchanged", cur, poll("active", "12", "Netflix"), SplitOnBoth,
+			Transition{Action: ActionTouch}},
+
+		{"end idle", cur, poll("idle", "", ""), SplitOnAppID,
+			Transition{Action: ActionEnd, EndReason: "idle"}},
+		{"end idle with reason", cur, idle(IdleLock), SplitOnAppID,
+			Transition{Action: ActionEnd, EndReason: "idle", IdleReason: IdleLock}},
+		{"end paused", cur, poll("paused", "12", "Netflix"), SplitOnAppID,
+			Transition{Action: ActionEnd, EndReason: "paused", IdleReason: IdlePausedMedia}},
+		{"end offline", cur, poll("offline", "", ""), SplitOnAppID,
+			Transition{Action: ActionEnd, EndReason: "offline"}},
+		{"end display off", cur, poll("display_off", "12", "Netflix"), SplitOnAppID,
+			Transition{Action: ActionEnd, EndReason: "display_off"}},
+		{"end other input", cur, poll("other_input", "12", "Netflix"), SplitOnAppID,
+			Transition{Action: ActionEnd, EndReason: "other_input"}},
+		{"offline ignores idle reason", cur, func() PollUpdate {
+			p := poll("offline", "", "")
+			p.IdleReason = IdleLock
+			return p
+		}(), SplitOnAppID,
+			Transition{Action: ActionEnd, EndReason: "offline"}},
+		{"idle without session", nil, poll("idle", "", ""), SplitOnAppID,
+			Transition{}},
+		{"offline without session", nil, poll("offline", "", ""), SplitOnAppID,
+			Transition{}},
+
+		{"unknown keeps session", cur, poll("unknown", "", ""), SplitOnAppID,
+			Transition{}},
+		{"unknown without session", nil, poll("unknown", "", ""), SplitOnAppID,
+			Transition{}},
+		{"unrecognized state", cur, poll("asleep", "", ""), SplitOnAppID,
+			Transition{UnknownState: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextTransition(tt.cur, tt.poll, tt.splitOn); got != tt.want {
+				t.Errorf("NextTransition() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
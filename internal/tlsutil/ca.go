@@ -0,0 +1,330 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File names of the hub's CA, kept next to its database, and of what an
+// agent keeps in its config directory after enrolling.
+const (
+	CACertFile = "ca-cert.pem"
+	CAKeyFile  = "ca-key.pem"
+
+	EnrolledCertFile = "enrolled-cert.pem"
+	EnrolledKeyFile  = "enrolled-key.pem"
+	HubCAFile        = "hub-ca.pem"
+)
+
+const (
+	caValidity = 20 * 365 * 24 * time.Hour
+	// agentValidity is how long an enrolled agent's certificate lasts;
+	// re-enrolling issues a new one.
+	agentValidity = 5 * 365 * 24 * time.Hour
+	// hubClientValidity is how long the hub's client certificate lasts. It
+	// is issued anew every time the hub starts.
+	hubClientValidity = 365 * 24 * time.Hour
+)
+
+// CA is the hub's certificate authority for mutual TLS with agents. It
+// issues each enrolled agent a server certificate naming its device ID,
+// and the hub a client certificate agents accept.
+type CA struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     *ecdsa.PrivateKey
+	client  tls.Certificate
+}
+
+// LoadCA loads the CA kept in dir, creating it first if there is none. An
+// empty dir keeps a new CA in memory only.
+func LoadCA(dir string) (*CA, error) {
+	ca, err := OpenCA(dir)
+	if ca != nil || err != nil {
+		return ca, err
+	}
+	certPEM, keyPEM, err := newCA()
+	if err == nil && dir != "" {
+		err = writePair(filepath.Join(dir, CACertFile), filepath.Join(dir, CAKeyFile), certPEM, keyPEM)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ca: %w", err)
+	}
+	return parseCA(certPEM, keyPEM)
+}
+
+// OpenCA loads the CA kept in dir. It returns nil without an error if
+// there is none, so a hub that never enrolled an agent keeps no CA key.
+func OpenCA(dir string) (*CA, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	certPEM, err := os.ReadFile(filepath.Join(dir, CACertFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ca: %w", err)
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, CAKeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("ca: %w", err)
+	}
+	return parseCA(certPEM, keyPEM)
+}
+
+func parseCA(certPEM, keyPEM []byte) (*CA, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("ca: %w", err)
+	}
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("ca: key is not ECDSA")
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("ca: %w", err)
+	}
+	ca := &CA{cert: cert, certPEM: certPEM, key: key}
+
+	if ca.client, err = ca.issueClient(); err != nil {
+		return nil, fmt.Errorf("ca: %w", err)
+	}
+	return ca, nil
+}
+
+func newCA() (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate key: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          newSerial(),
+		Subject:               pkix.Name{CommonName: "screentime hub CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create certificate: %w", err)
+	}
+	keyPEM, err = marshalKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM, nil
+}
+
+// CertPEM returns the CA certificate, for agents to verify the hub by.
+func (ca *CA) CertPEM() []byte {
+	return ca.certPEM
+}
+
+// Fingerprint returns the CA certificate's fingerprint.
+func (ca *CA) Fingerprint() string {
+	return Fingerprint(ca.cert.Raw)
+}
+
+// ClientCertificate returns the hub's client certificate.
+func (ca *CA) ClientCertificate() tls.Certificate {
+	return ca.client
+}
+
+// Pool returns a pool holding only the CA certificate.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func (ca *CA) issueClient() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate key: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: newSerial(),
+		Subject:      pkix.Name{CommonName: "screentime hub"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(hubClientValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("create client certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der, ca.cert.Raw}, PrivateKey: key}, nil
+}
+
+// SignAgent issues the agent of deviceID a server certificate for the key
+// in csrPEM. The certificate names the device rather than a host, so it
+// stays valid when the agent's address changes; the hub checks the name.
+func (ca *CA) SignAgent(csrPEM []byte, deviceID string) ([]byte, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("csr is not a PEM certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse csr: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("csr signature: %w", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: newSerial(),
+		Subject:      pkix.Name{CommonName: deviceID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(agentValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, csr.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// verifyEnrolled checks that the server's certificate was issued by ca to
+// one of deviceIDs.
+func (ca *CA) verifyEnrolled(deviceIDs []string, cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: no server certificate")
+	}
+	leaf := cs.PeerCertificates[0]
+	opts := x509.VerifyOptions{
+		Roots:         ca.Pool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	if _, err := leaf.Verify(opts); err != nil {
+		return fmt.Errorf("tls: agent certificate: %w", err)
+	}
+	for _, id := range deviceIDs {
+		if leaf.Subject.CommonName == id {
+			return nil
+		}
+	}
+	return fmt.Errorf("tls: agent certificate is for device %q", leaf.Subject.CommonName)
+}
+
+// NewCSR generates an agent key and a certificate request for it, to send
+// to the hub when enrolling.
+func NewCSR(deviceID string) (csrPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate key: %w", err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: deviceID},
+	}, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create csr: %w", err)
+	}
+	keyPEM, err = marshalKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), keyPEM, nil
+}
+
+// SaveEnrolled writes what enrolling returned to dir.
+func SaveEnrolled(dir string, certPEM, keyPEM, caPEM []byte) error {
+	if err := writePair(filepath.Join(dir, EnrolledCertFile), filepath.Join(dir, EnrolledKeyFile), certPEM, keyPEM); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, HubCAFile), caPEM, 0o644); err != nil {
+		return fmt.Errorf("write hub ca: %w", err)
+	}
+	return nil
+}
+
+// LoadEnrolled returns the server TLS config of an agent enrolled into
+// dir: it serves the certificate the hub issued and answers only clients
+// with a certificate from the hub's CA.
+func LoadEnrolled(dir string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, EnrolledCertFile), filepath.Join(dir, EnrolledKeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("enrolled certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(filepath.Join(dir, HubCAFile))
+	if err != nil {
+		return nil, fmt.Errorf("hub ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("hub ca: no certificate found")
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// PEMFingerprint returns the fingerprint of the first certificate in
+// certPEM.
+func PEMFingerprint(certPEM []byte) (string, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", errors.New("not a PEM certificate")
+	}
+	return Fingerprint(block.Bytes), nil
+}
+
+// SameFingerprint reports whether two fingerprints match, in either of the
+// forms ValidFingerprint accepts.
+func SameFingerprint(a, b string) bool {
+	return normalizeFingerprint(a) == normalizeFingerprint(b)
+}
+
+func newSerial() *big.Int {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return serial
+}
+
+func marshalKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("marshal key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// writePair writes a certificate and its key, the key readable only by
+// its owner.
+func writePair(certFile, keyFile string, certPEM, keyPEM []byte) error {
+	if err := os.MkdirAll(filepath.Dir(certFile), 0o700); err != nil {
+		return fmt.Errorf("create certificate directory: %w", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		return fmt.Errorf("write key: %w", err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return fmt.Errorf("write certificate: %w", err)
+	}
+	return nil
+}
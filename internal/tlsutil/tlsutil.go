@@ -0,0 +1,233 @@
+// Package tlsutil serves the hub's and agents' listeners over TLS, with a
+// certificate from files or one generated on first start, and lets pollers
+// trust such self-signed certificates by fingerprint.
+package tlsutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// selfSignedValidity is how long a generated certificate lasts. Pinned
+// certificates are trusted by fingerprint, not expiry, so it is long.
+const selfSignedValidity = 10 * 365 * 24 * time.Hour
+
+// Default file names for a generated certificate and key.
+const (
+	DefaultCertFile = "tls-cert.pem"
+	DefaultKeyFile  = "tls-key.pem"
+)
+
+// Config is a listener's TLS settings.
+type Config struct {
+	// CertFile and KeyFile are a PEM certificate and key. With SelfSigned
+	// they are where the generated pair is kept, by default in the
+	// program's own directory.
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// SelfSigned generates a certificate on first start when CertFile
+	// doesn't exist yet, and reuses it after that, so its fingerprint
+	// stays the same.
+	SelfSigned bool `json:"self_signed,omitempty"`
+	// Hosts are extra DNS names and IP addresses a generated certificate
+	// is valid for, besides the hostname and localhost.
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// Validate checks that c names a certificate or asks for one.
+func (c *Config) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if !c.SelfSigned && (c.CertFile == "" || c.KeyFile == "") {
+		return errors.New("needs cert_file and key_file, or self_signed")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("cert_file and key_file go together")
+	}
+	return nil
+}
+
+// Load returns the server TLS config for c, generating a self-signed
+// certificate first if asked to and there is none yet. Without CertFile
+// and KeyFile a generated pair is kept in dir, or only in memory if dir is
+// empty. It also returns the certificate's fingerprint, for pollers to pin.
+func (c *Config) Load(dir string) (*tls.Config, string, error) {
+	certFile, keyFile := c.CertFile, c.KeyFile
+	if certFile == "" && dir != "" {
+		certFile, keyFile = filepath.Join(dir, DefaultCertFile), filepath.Join(dir, DefaultKeyFile)
+	}
+
+	var cert tls.Certificate
+	var err error
+	switch _, statErr := os.Stat(certFile); {
+	case c.SelfSigned && certFile == "":
+		cert, err = c.generate("", "")
+	case c.SelfSigned && os.IsNotExist(statErr):
+		cert, err = c.generate(certFile, keyFile)
+	default:
+		cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("tls certificate: %w", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, Fingerprint(cert.Certificate[0]), nil
+}
+
+// generate creates a self-signed certificate and writes it and its key to
+// certFile and keyFile, unless they are empty.
+func (c *Config) generate(certFile, keyFile string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate key: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	tmpl := &x509.Certificate{
+		SerialNumber: newSerial(),
+		Subject:      pkix.Name{CommonName: hostname},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(selfSignedValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range append([]string{hostname, "localhost", "127.0.0.1", "::1"}, c.Hosts...) {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("create certificate: %w", err)
+	}
+	keyPEM, err := marshalKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	if certFile != "" {
+		if err := writePair(certFile, keyFile, certPEM, keyPEM); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// Fingerprint returns the hex SHA-256 of a DER certificate, as pinned in
+// a device's tls_fingerprint.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeFingerprint accepts fingerprints as printed by this package or
+// by openssl ("AB:CD:...").
+func normalizeFingerprint(fp string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fp), ":", ""))
+}
+
+// ValidFingerprint reports whether fp looks like a SHA-256 fingerprint.
+func ValidFingerprint(fp string) bool {
+	b, err := hex.DecodeString(normalizeFingerprint(fp))
+	return err == nil && len(b) == sha256.Size
+}
+
+// Trust is how the hub checks the certificates of agents' hosts, keyed by
+// hostname or IP address. Other servers are trusted by the system roots
+// as usual.
+type Trust struct {
+	// Pins are the certificate fingerprints of self-signed agents. A host
+	// may have several when more than one agent runs on it.
+	Pins map[string][]string
+	// Enrolled are the device IDs of agents holding certificates from CA,
+	// which are polled with mutual TLS.
+	Enrolled map[string][]string
+	CA       *CA
+}
+
+// Apply makes t check servers' certificates as tr says.
+func (tr Trust) Apply(t *http.Transport) {
+	if len(tr.Pins) == 0 && (len(tr.Enrolled) == 0 || tr.CA == nil) {
+		return
+	}
+	pins := make(map[string][]string, len(tr.Pins))
+	for host, fps := range tr.Pins {
+		for _, fp := range fps {
+			pins[host] = append(pins[host], normalizeFingerprint(fp))
+		}
+	}
+
+	// The host is looked up when dialing rather than in the handshake,
+	// where the server name of an IP address is empty.
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		cfg := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		fps, pinned := pins[host]
+		ids, enrolled := tr.Enrolled[host]
+		enrolled = enrolled && tr.CA != nil
+		if enrolled {
+			cfg.Certificates = []tls.Certificate{tr.CA.ClientCertificate()}
+		}
+		if pinned || enrolled {
+			// Pinned and enrolled certificates don't chain to the system
+			// roots; VerifyConnection checks them instead.
+			cfg.InsecureSkipVerify = true
+			cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+				err := errors.New("tls: no trusted certificate")
+				if pinned {
+					if err = verifyPinned(host, fps, cs); err == nil {
+						return nil
+					}
+				}
+				if enrolled {
+					err = tr.CA.verifyEnrolled(ids, cs)
+				}
+				return err
+			}
+		}
+		d := &tls.Dialer{NetDialer: dialer, Config: cfg}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// verifyPinned checks that the server's certificate is one of fps.
+func verifyPinned(host string, fps []string, cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: no server certificate")
+	}
+	got := Fingerprint(cs.PeerCertificates[0].Raw)
+	for _, fp := range fps {
+		if fp == got {
+			return nil
+		}
+	}
+	return fmt.Errorf("tls: certificate for %s has fingerprint %s, not the pinned one", host, got)
+}
@@ -0,0 +1,143 @@
+// Package windows implements the Windows agent's detector: it reports the
+// foreground window, which package agent serves like the Linux agent does.
+package windows
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"screentime-agent/internal/agent"
+	"screentime-agent/internal/linux"
+)
+
+// Config holds the Windows agent configuration
+type Config struct {
+	agent.Config
+	// IgnoredWindows are executable names without ".exe", e.g. "explorer".
+	IgnoredWindows []string `json:"ignored_windows"`
+}
+
+// DefaultConfig returns a config with sensible defaults
+func DefaultConfig() *Config {
+	cfg := &Config{Config: agent.DefaultConfig(), IgnoredWindows: []string{}}
+	cfg.IdleWindowPatterns = []string{"lock screen", "screensaver"}
+	return cfg
+}
+
+// Platform runs the Windows agent with agent.Main.
+var Platform = agent.Platform{
+	Name:          "Windows",
+	DefaultConfig: func() agent.Settings { return DefaultConfig() },
+	NewDetector:   func(cfg agent.Settings) agent.Detector { return NewDetector(cfg.(*Config)) },
+}
+
+// Detector reports the foreground window as an activity, categorized the
+// same way as on Linux.
+type Detector struct {
+	config      *Config
+	categorizer *linux.Categorizer
+
+	// foreground, idleTime and locked query Win32; see
+	// win32_windows.go.
+	foreground func() (*linux.WindowInfo, error)
+	idleTime   func() (time.Duration, error)
+	locked     func() (bool, error)
+}
+
+// NewDetector creates a detector
+func NewDetector(cfg *Config) *Detector {
+	return &Detector{
+		config:      cfg,
+		categorizer: linux.NewCategorizer(cfg.Categories),
+		foreground:  foregroundWindow,
+		idleTime:    inputIdleTime,
+		locked:      screenLocked,
+	}
+}
+
+// Detect returns the current activity.
+func (d *Detector) Detect() linux.Activity {
+	locked, lockErr := d.locked()
+	if locked {
+		return linux.Activity{
+			ID:    "idle:lock",
+			Name:  "Screen Locked",
+			State: "idle",
+		}
+	}
+	if d.config.IdleSeconds > 0 {
+		idle, err := d.idleTime()
+		if err != nil {
+			log.Printf("idle time error: %v", err)
+		} else if idle >= time.Duration(d.config.IdleSeconds)*time.Second {
+			return linux.Activity{
+				ID:    "idle:input",
+				Name:  "No Input",
+				State: "idle",
+			}
+		}
+	}
+
+	info, err := d.foreground()
+	if err != nil {
+		log.Printf("window detection error: %v", err)
+		return linux.Activity{
+			ID:    "unknown",
+			Name:  "Unknown",
+			State: "offline",
+		}
+	}
+	if info == nil {
+		return linux.Activity{
+			ID:    "idle:no-window",
+			Name:  "No Window",
+			State: "idle",
+		}
+	}
+	if info.IsIdle(d.config.IdleWindowPatterns) {
+		// Without the session's lock state, a lock screen is told from
+		// a screensaver by its title
+		id := "idle:screensaver"
+		if lockErr != nil && info.IsLockScreen() {
+			id = "idle:lock"
+		}
+		return linux.Activity{
+			ID:    id,
+			Name:  info.Title,
+			State: "idle",
+		}
+	}
+	if info.IsIgnored(d.config.IgnoredWindows) {
+		return linux.Activity{
+			ID:    "idle:ignored",
+			Name:  info.Title,
+			State: "idle",
+		}
+	}
+
+	if info.IsBrowser() {
+		if domain, category, ok := d.categorizer.CategorizeTitle(info.Title); ok {
+			return linux.Activity{
+				ID:    fmt.Sprintf("browser:%s:%s", category, domain),
+				Name:  domain,
+				State: "active",
+				Title: info.Title,
+			}
+		}
+	}
+	return linux.Activity{
+		ID:    fmt.Sprintf("window:%s", info.Instance),
+		Name:  info.Title,
+		State: "active",
+		Title: info.Title,
+	}
+}
+
+// exeName returns an executable path's base name, lowercased and without
+// ".exe", the way windows are identified.
+func exeName(path string) string {
+	base := path[strings.LastIndexAny(path, `\/`)+1:]
+	return strings.TrimSuffix(strings.ToLower(base), ".exe")
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package windows
+
+import (
+	"errors"
+	"time"
+
+	"screentime-agent/internal/linux"
+)
+
+var errNotWindows = errors.New("the windows agent only runs on Windows")
+
+func foregroundWindow() (*linux.WindowInfo, error) {
+	return nil, errNotWindows
+}
+
+func inputIdleTime() (time.Duration, error) {
+	return 0, errNotWindows
+}
+
+func screenLocked() (bool, error) {
+	return false, errNotWindows
+}
@@ -0,0 +1,111 @@
+//go:build windows
+
+package windows
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"screentime-agent/internal/linux"
+)
+
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procGetForegroundWindow        = user32.NewProc("GetForegroundWindow")
+	procGetWindowTextLengthW       = user32.NewProc("GetWindowTextLengthW")
+	procGetWindowTextW             = user32.NewProc("GetWindowTextW")
+	procGetWindowThreadProcessID   = user32.NewProc("GetWindowThreadProcessId")
+	procGetLastInputInfo           = user32.NewProc("GetLastInputInfo")
+	procOpenInputDesktop           = user32.NewProc("OpenInputDesktop")
+	procSwitchDesktop              = user32.NewProc("SwitchDesktop")
+	procCloseDesktop               = user32.NewProc("CloseDesktop")
+	procGetTickCount               = kernel32.NewProc("GetTickCount")
+	procQueryFullProcessImageNameW = kernel32.NewProc("QueryFullProcessImageNameW")
+)
+
+const (
+	processQueryLimitedInformation = 0x1000
+	desktopSwitchDesktop           = 0x0100
+)
+
+// foregroundWindow returns the window with keyboard focus, or nil when
+// there is none (e.g. while the desktop is switching).
+func foregroundWindow() (*linux.WindowInfo, error) {
+	hwnd, _, _ := procGetForegroundWindow.Call()
+	if hwnd == 0 {
+		return nil, nil
+	}
+
+	n, _, _ := procGetWindowTextLengthW.Call(hwnd)
+	buf := make([]uint16, n+1)
+	procGetWindowTextW.Call(hwnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+
+	var pid uint32
+	procGetWindowThreadProcessID.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+	path, err := processImage(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	exe := exeName(path)
+	return &linux.WindowInfo{
+		Title:    syscall.UTF16ToString(buf),
+		Class:    exe,
+		Instance: exe,
+		PID:      int(pid),
+	}, nil
+}
+
+// processImage returns the full path of a process's executable.
+func processImage(pid uint32) (string, error) {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, pid)
+	if err != nil {
+		return "", fmt.Errorf("open process %d: %w", pid, err)
+	}
+	defer syscall.CloseHandle(h)
+
+	buf := make([]uint16, syscall.MAX_PATH)
+	size := uint32(len(buf))
+	r, _, err := procQueryFullProcessImageNameW.Call(uintptr(h), 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+	if r == 0 {
+		return "", fmt.Errorf("query process %d image: %w", pid, err)
+	}
+	return syscall.UTF16ToString(buf[:size]), nil
+}
+
+type lastInputInfo struct {
+	cbSize uint32
+	dwTime uint32
+}
+
+// inputIdleTime returns how long since the last keyboard or mouse input.
+func inputIdleTime() (time.Duration, error) {
+	info := lastInputInfo{cbSize: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	r, _, err := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info)))
+	if r == 0 {
+		return 0, fmt.Errorf("get last input info: %w", err)
+	}
+	// Both are milliseconds since boot and wrap together after 49 days.
+	now, _, _ := procGetTickCount.Call()
+	return time.Duration(uint32(now)-info.dwTime) * time.Millisecond, nil
+}
+
+// screenLocked reports whether the workstation is locked: the input
+// desktop is then the secure one, which a user's process may neither open
+// nor switch to.
+func screenLocked() (bool, error) {
+	h, _, err := procOpenInputDesktop.Call(0, 0, desktopSwitchDesktop)
+	if h == 0 {
+		if err == syscall.ERROR_ACCESS_DENIED {
+			return true, nil
+		}
+		return false, fmt.Errorf("open input desktop: %w", err)
+	}
+	defer procCloseDesktop.Call(h)
+	r, _, _ := procSwitchDesktop.Call(h)
+	return r == 0, nil
+}